@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+// walletStatusRequest 是冻结、解冻钱包接口的请求体
+type walletStatusRequest struct {
+	Currency string `json:"currency"`
+	Reason   string `json:"reason"`
+}
+
+// adjustmentRequest 是人工调账接口的请求体，Amount为正时入账、为负时扣减
+type adjustmentRequest struct {
+	Currency string           `json:"currency"`
+	Amount   *decimal.Decimal `json:"amount"`
+	Reason   string           `json:"reason"`
+}
+
+// walletLimitsRequest 是设置钱包限额接口的请求体，Limits整体替换钱包之前单独设置的限额
+type walletLimitsRequest struct {
+	Currency string             `json:"currency"`
+	Limits   model.WalletLimits `json:"limits"`
+}
+
+// adminRoutes 注册后台人员使用的接口，权限由调用方的角色决定
+func (a *API) adminRoutes(rt *router) {
+	rt.handle(http.MethodPost, "/v1/admin/wallets/{id}/freeze", a.idempotent(a.freezeWalletV1))
+	rt.handle(http.MethodPost, "/v1/admin/wallets/{id}/unfreeze", a.idempotent(a.unfreezeWalletV1))
+	rt.handle(http.MethodPost, "/v1/admin/wallets/{id}/close", a.idempotent(a.closeWalletV1))
+	rt.handle(http.MethodPost, "/v1/admin/wallets/{id}/adjustments", a.idempotent(a.adjustWalletV1))
+	rt.handle(http.MethodPut, "/v1/admin/wallets/{id}/limits", a.setWalletLimitsV1)
+	rt.handle(http.MethodGet, "/v1/admin/ledger", a.verifyLedgerV1)
+	rt.handle(http.MethodGet, "/v1/admin/ledger/chain", a.verifyHashChainV1)
+	rt.handle(http.MethodGet, "/v1/admin/audit", a.listAuditLogV1)
+}
+
+// freezeWalletV1 处理 POST /v1/admin/wallets/{id}/freeze
+func (a *API) freezeWalletV1(w http.ResponseWriter, r *http.Request) {
+	a.setWalletStatus(w, r, auth.ScopeFreeze, a.walletService.FreezeWallet)
+}
+
+// unfreezeWalletV1 处理 POST /v1/admin/wallets/{id}/unfreeze
+func (a *API) unfreezeWalletV1(w http.ResponseWriter, r *http.Request) {
+	a.setWalletStatus(w, r, auth.ScopeFreeze, a.walletService.UnfreezeWallet)
+}
+
+// closeWalletV1 处理 POST /v1/admin/wallets/{id}/close
+func (a *API) closeWalletV1(w http.ResponseWriter, r *http.Request) {
+	a.setWalletStatus(w, r, auth.ScopeClose, a.walletService.CloseWallet)
+}
+
+// setWalletStatus 检查调用方拥有scope，解析请求并调用set变更钱包状态
+func (a *API) setWalletStatus(w http.ResponseWriter, r *http.Request, scope string, set func(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error)) {
+	userID, ok := a.walletParam(w, r, scope)
+	if !ok {
+		return
+	}
+	var req walletStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		writeValidationError(w, "reason", "reason is required")
+		return
+	}
+
+	wallet, err := set(r.Context(), userID, a.currencyOrDefault(req.Currency), req.Reason)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusOK, wallet)
+}
+
+// adjustWalletV1 处理 POST /v1/admin/wallets/{id}/adjustments
+func (a *API) adjustWalletV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeAdjust)
+	if !ok {
+		return
+	}
+	var req adjustmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	scale, supported := model.CurrencyScale(currency)
+	switch {
+	case !supported:
+		writeServiceError(w, fmt.Errorf("%w: %q", service.ErrUnsupportedCurrency, currency))
+		return
+	case req.Amount == nil:
+		writeValidationError(w, "amount", "amount is required")
+		return
+	case req.Amount.IsZero():
+		writeValidationError(w, "amount", "amount must not be zero")
+		return
+	case !req.Amount.FitsScale(scale):
+		writeValidationError(w, "amount", fmt.Sprintf("amount must have at most %d decimal places for %s", scale, currency))
+		return
+	case strings.TrimSpace(req.Reason) == "":
+		writeValidationError(w, "reason", "reason is required")
+		return
+	}
+
+	transaction, err := a.walletService.Adjust(r.Context(), userID, currency, *req.Amount, req.Reason)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, transaction)
+}
+
+// setWalletLimitsV1 处理 PUT /v1/admin/wallets/{id}/limits，返回写入后钱包单独设置的限额
+func (a *API) setWalletLimitsV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeLimits)
+	if !ok {
+		return
+	}
+	var req walletLimitsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	limits, err := a.walletService.SetWalletLimits(r.Context(), userID, a.currencyOrDefault(req.Currency), req.Limits)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, limits)
+}
+
+// verifyLedgerV1 处理 GET /v1/admin/ledger，返回账本核对结果
+func (a *API) verifyLedgerV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeLedgerRead) {
+		return
+	}
+	report, err := a.walletService.VerifyLedger(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// verifyHashChainV1 处理 GET /v1/admin/ledger/chain，返回交易哈希链与检查点的校验结果
+func (a *API) verifyHashChainV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeLedgerRead) {
+		return
+	}
+	report, err := a.walletService.VerifyHashChain(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// listAuditLogV1 处理 GET /v1/admin/audit，只有审计角色可以查询；
+// 支持actor、action、request_id、user_id、from、to过滤及limit、cursor分页
+func (a *API) listAuditLogV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeAuditRead) {
+		return
+	}
+	filter, field, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		writeValidationError(w, field, err.Error())
+		return
+	}
+	page, err := a.walletService.ListAuditLog(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseAuditFilter 解析审计日志的查询参数，时间为RFC3339格式；出错时返回出错的参数名
+func parseAuditFilter(query url.Values) (model.AuditFilter, string, error) {
+	filter := model.AuditFilter{
+		Actor:     query.Get("actor"),
+		Action:    query.Get("action"),
+		RequestID: query.Get("request_id"),
+	}
+	for field, target := range map[string]*int{"user_id": &filter.UserID, "limit": &filter.Limit} {
+		if value := query.Get(field); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return filter, field, fmt.Errorf("%s must be a positive integer", field)
+			}
+			*target = n
+		}
+	}
+	for field, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(field); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, field, fmt.Errorf("%s must be an RFC3339 timestamp", field)
+			}
+			*target = &t
+		}
+	}
+	if value := query.Get("cursor"); value != "" {
+		id, err := model.DecodeAuditCursor(value)
+		if err != nil {
+			return filter, "cursor", fmt.Errorf("cursor is invalid")
+		}
+		filter.BeforeID = id
+	}
+	return filter, "", nil
+}
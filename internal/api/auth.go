@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/service"
+)
+
+// Authenticator 校验请求携带的凭证并返回调用方，由auth.Verifier实现
+type Authenticator interface {
+	Authenticate(r *http.Request) (*auth.Principal, error)
+}
+
+// WithAuthenticator 要求所有请求通过认证，并按调用方的权限与钱包归属进行授权
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(a *API) {
+		a.authenticator = authenticator
+	}
+}
+
+// authenticate 校验请求凭证，通过后把调用方放入请求context，失败时写出401
+func (a *API) authenticate(next http.Handler) http.Handler {
+	if a.authenticator == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticator.Authenticate(r)
+		if err != nil {
+			if !errors.Is(err, auth.ErrMissingCredentials) && !errors.Is(err, auth.ErrInvalidCredentials) {
+				logger.Log.Errorf("Error authenticating request: %v", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer, `+auth.HMACScheme)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	})
+}
+
+// authorizeWallet 检查调用方能否以scope权限操作userID的钱包，不能时写出403并返回false；未启用认证时总是允许
+func (a *API) authorizeWallet(w http.ResponseWriter, r *http.Request, scope string, userID int) bool {
+	if a.authenticator == nil {
+		return true
+	}
+	principal := auth.FromContext(r.Context())
+	if principal == nil || !principal.CanAccessWallet(userID, scope) {
+		writeForbidden(w, principal, fmt.Sprintf("not allowed to %s on wallet of user %d", scope, userID))
+		return false
+	}
+	return true
+}
+
+// authorizeScope 检查调用方拥有scope权限，用于不属于某个钱包的操作
+func (a *API) authorizeScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if a.authenticator == nil {
+		return true
+	}
+	principal := auth.FromContext(r.Context())
+	if principal == nil || !principal.HasScope(scope) {
+		writeForbidden(w, principal, fmt.Sprintf("%s permission is required", scope))
+		return false
+	}
+	return true
+}
+
+// authorizeHold 检查调用方能否以scope权限操作预授权，终端用户必须是付款方或收款方
+func (a *API) authorizeHold(w http.ResponseWriter, r *http.Request, scope string, holdID int) bool {
+	if a.authenticator == nil {
+		return true
+	}
+	principal := auth.FromContext(r.Context())
+	if principal == nil || !principal.IsUser() {
+		return a.authorizeScope(w, r, scope)
+	}
+	hold, err := a.walletService.GetHold(r.Context(), holdID)
+	if err != nil {
+		writeServiceError(w, err)
+		return false
+	}
+	if !principal.CanAccessWallet(hold.UserID, scope) && (hold.PayeeUserID == 0 || !principal.CanAccessWallet(hold.PayeeUserID, scope)) {
+		writeForbidden(w, principal, fmt.Sprintf("not allowed to %s on hold %d", scope, holdID))
+		return false
+	}
+	return true
+}
+
+// writeForbidden 写出403响应
+func writeForbidden(w http.ResponseWriter, principal *auth.Principal, message string) {
+	if principal != nil {
+		logger.Log.Warnf("Forbidden request by %s: %s", principal.Subject, message)
+	}
+	writeError(w, http.StatusForbidden, service.ErrForbidden.Code, message, nil)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// feeQuoteRequest 是手续费试算接口的请求体，Operation为withdrawal或transfer
+type feeQuoteRequest struct {
+	Operation string           `json:"operation"`
+	Amount    *decimal.Decimal `json:"amount"`
+	Currency  string           `json:"currency"`
+}
+
+// quoteFeeV1 处理 POST /v1/fees/quote，返回按当前费率表试算的手续费，供客户端在确认前展示
+func (a *API) quoteFeeV1(w http.ResponseWriter, r *http.Request) {
+	var req feeQuoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Operation != model.FeeOperationWithdrawal && req.Operation != model.FeeOperationTransfer {
+		writeValidationError(w, "operation", "operation must be withdrawal or transfer")
+		return
+	}
+	scope := auth.ScopeWithdraw
+	if req.Operation == model.FeeOperationTransfer {
+		scope = auth.ScopeTransfer
+	}
+	if !a.authorizeScope(w, r, scope) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	if !validateAmountField(w, req.Amount, currency) {
+		return
+	}
+
+	quote, err := a.walletService.QuoteFee(r.Context(), req.Operation, currency, *req.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, quote)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"wallet-service/internal/auth"
+	"wallet-service/pkg/decimal"
+)
+
+// quoteRequest 是换汇报价接口的请求体
+type quoteRequest struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+}
+
+// conversionRequest 是换汇接口的请求体，QuoteID为空时按实时汇率成交
+type conversionRequest struct {
+	FromCurrency string           `json:"from_currency"`
+	ToCurrency   string           `json:"to_currency"`
+	Amount       *decimal.Decimal `json:"amount"`
+	QuoteID      string           `json:"quote_id"`
+}
+
+// createQuoteV1 处理 POST /v1/fx/quotes，返回在有效期内锁定汇率的报价
+func (a *API) createQuoteV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeConvert) {
+		return
+	}
+	var req quoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.FromCurrency == "" {
+		writeValidationError(w, "from_currency", "from_currency is required")
+		return
+	}
+	if req.ToCurrency == "" {
+		writeValidationError(w, "to_currency", "to_currency is required")
+		return
+	}
+
+	quote, err := a.walletService.QuoteFX(r.Context(), a.currencyOrDefault(req.FromCurrency), a.currencyOrDefault(req.ToCurrency))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, quote)
+}
+
+// convertV1 处理 POST /v1/wallets/{id}/conversions
+func (a *API) convertV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeConvert)
+	if !ok {
+		return
+	}
+	var req conversionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ToCurrency == "" {
+		writeValidationError(w, "to_currency", "to_currency is required")
+		return
+	}
+	from := a.currencyOrDefault(req.FromCurrency)
+	if !validateAmountField(w, req.Amount, from) {
+		return
+	}
+
+	conversion, err := a.walletService.Convert(r.Context(), userID, from, a.currencyOrDefault(req.ToCurrency), *req.Amount, req.QuoteID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, conversion)
+}
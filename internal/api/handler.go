@@ -4,53 +4,76 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
 )
 
 func (a *API) DepositHandler(w http.ResponseWriter, r *http.Request) {
-	userID, amount, err := parseRequestParams(r)
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	userID, amount, err := parseRequestParams(r, currency)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.authorizeWallet(w, r, auth.ScopeDeposit, userID) {
+		return
+	}
 
-	err = a.walletService.Deposit(r.Context(), userID, amount)
+	_, err = a.walletService.Deposit(r.Context(), userID, currency, amount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status, _ := classifyError(err)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	markCommitted(r)
 	w.Write([]byte("Deposit successful"))
 }
 
 func (a *API) WithdrawHandler(w http.ResponseWriter, r *http.Request) {
-	userID, amount, err := parseRequestParams(r)
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	userID, amount, err := parseRequestParams(r, currency)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.authorizeWallet(w, r, auth.ScopeWithdraw, userID) {
+		return
+	}
 
-	err = a.walletService.Withdraw(r.Context(), userID, amount)
+	_, err = a.walletService.Withdraw(r.Context(), userID, currency, amount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status, _ := classifyError(err)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	markCommitted(r)
 	w.Write([]byte("Withdrawal successful"))
 }
 
 func (a *API) TransferHandler(w http.ResponseWriter, r *http.Request) {
-	fromUserID, toUserID, amount, err := parseTransferRequestParams(r)
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	fromUserID, toUserID, amount, err := parseTransferRequestParams(r, currency)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !a.authorizeWallet(w, r, auth.ScopeTransfer, fromUserID) {
+		return
+	}
 
-	err = a.walletService.Transfer(r.Context(), fromUserID, toUserID, amount)
+	err = a.walletService.Transfer(r.Context(), fromUserID, toUserID, currency, amount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status, _ := classifyError(err)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	markCommitted(r)
 	w.Write([]byte("Transfer successful"))
 }
 
@@ -60,14 +83,20 @@ func (a *API) BalanceHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	if !a.authorizeWallet(w, r, auth.ScopeWalletsRead, userID) {
+		return
+	}
 
-	balance, err := a.walletService.GetBalance(r.Context(), userID)
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	balance, err := a.walletService.GetBalance(r.Context(), userID, currency)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status, _ := classifyError(err)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	w.Write([]byte(fmt.Sprintf("Balance: %.2f", balance)))
+	// 旧接口保持原有格式返回账面余额，可用余额附在其后
+	w.Write([]byte(fmt.Sprintf("Balance: %s (available: %s)", model.NormalizeAmount(balance.Ledger, currency), model.NormalizeAmount(balance.Available, currency))))
 }
 
 func (a *API) HistoryHandler(w http.ResponseWriter, r *http.Request) {
@@ -76,58 +105,93 @@ func (a *API) HistoryHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	if !a.authorizeWallet(w, r, auth.ScopeWalletsRead, userID) {
+		return
+	}
 
-	history, err := a.walletService.GetTransactionHistory(r.Context(), userID)
+	filter, _, err := parseHistoryFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 构建交易历史响应
-	response := "Transaction History:\n"
-	for _, transaction := range history {
-		response += fmt.Sprintf("ID: %d, Type: %s, Amount: %.2f, Time: %s\n",
-			transaction.ID, transaction.TransactionType, transaction.Amount, transaction.TransactionTime.Format("2006-01-02 15:04:05"))
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	page, err := a.walletService.GetTransactionHistory(r.Context(), userID, currency, filter)
+	if err != nil {
+		status, _ := classifyError(err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// 构建交易历史响应，一次只返回一页，后续页通过cursor参数获取
+	var response strings.Builder
+	response.WriteString("Transaction History:\n")
+	for _, transaction := range page.Transactions {
+		fmt.Fprintf(&response, "ID: %d, Type: %s, Amount: %s %s, Time: %s",
+			transaction.ID, transaction.TransactionType, model.NormalizeAmount(transaction.Amount, transaction.Currency), transaction.Currency, transaction.TransactionTime.Format("2006-01-02 15:04:05"))
+		if transaction.ReversalOf != 0 {
+			fmt.Fprintf(&response, ", Reversal of: %d, Reason: %s", transaction.ReversalOf, transaction.Reason)
+		}
+		response.WriteString("\n")
+	}
+	if page.NextCursor != "" {
+		fmt.Fprintf(&response, "Next cursor: %s\n", page.NextCursor)
 	}
 
-	w.Write([]byte(response))
+	w.Write([]byte(response.String()))
+}
+
+// parseAmount 解析金额参数，拒绝超出币种小数位数的输入
+func parseAmount(amountStr, currency string) (decimal.Decimal, error) {
+	scale, ok := model.CurrencyScale(currency)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("Unsupported currency: %s", currency)
+	}
+	amount, err := decimal.Parse(amountStr)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("Invalid amount")
+	}
+	if !amount.FitsScale(scale) {
+		return decimal.Zero, fmt.Errorf("Invalid amount: at most %d decimal places allowed for %s", scale, currency)
+	}
+	return amount, nil
 }
 
-func parseRequestParams(r *http.Request) (int, float64, error) {
+func parseRequestParams(r *http.Request, currency string) (int, decimal.Decimal, error) {
 	userIDStr := r.URL.Query().Get("user_id")
 	amountStr := r.URL.Query().Get("amount")
 
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		return 0, 0, fmt.Errorf("Invalid user ID")
+		return 0, decimal.Zero, fmt.Errorf("Invalid user ID")
 	}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
+	amount, err := parseAmount(amountStr, currency)
 	if err != nil {
-		return 0, 0, fmt.Errorf("Invalid amount")
+		return 0, decimal.Zero, err
 	}
 
 	return userID, amount, nil
 }
 
-func parseTransferRequestParams(r *http.Request) (int, int, float64, error) {
+func parseTransferRequestParams(r *http.Request, currency string) (int, int, decimal.Decimal, error) {
 	fromUserIDStr := r.URL.Query().Get("from_user_id")
 	toUserIDStr := r.URL.Query().Get("to_user_id")
 	amountStr := r.URL.Query().Get("amount")
 
 	fromUserID, err := strconv.Atoi(fromUserIDStr)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Invalid from user ID")
+		return 0, 0, decimal.Zero, fmt.Errorf("Invalid from user ID")
 	}
 
 	toUserID, err := strconv.Atoi(toUserIDStr)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Invalid to user ID")
+		return 0, 0, decimal.Zero, fmt.Errorf("Invalid to user ID")
 	}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
+	amount, err := parseAmount(amountStr, currency)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("Invalid amount")
+		return 0, 0, decimal.Zero, err
 	}
 
 	return fromUserID, toUserID, amount, nil
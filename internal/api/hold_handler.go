@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/pkg/decimal"
+)
+
+// holdRequest 是预授权接口的请求体；PayeeUserID为空时请款转为取款，
+// TTLSeconds为空时使用服务端默认有效期
+type holdRequest struct {
+	Amount      *decimal.Decimal `json:"amount"`
+	Currency    string           `json:"currency"`
+	PayeeUserID int              `json:"payee_user_id"`
+	TTLSeconds  int              `json:"ttl_seconds"`
+}
+
+// captureRequest 是请款接口的请求体，Amount为空时按全额请款
+type captureRequest struct {
+	Amount *decimal.Decimal `json:"amount"`
+}
+
+// getBalanceV1 处理 GET /v1/wallets/{id}/balance?currency=，返回账面余额与可用余额
+func (a *API) getBalanceV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+
+	balance, err := a.walletService.GetBalance(r.Context(), userID, a.currencyOrDefault(r.URL.Query().Get("currency")))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balance)
+}
+
+// authorizeV1 处理 POST /v1/wallets/{id}/holds
+func (a *API) authorizeV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeHolds)
+	if !ok {
+		return
+	}
+	var req holdRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	if !validateAmountField(w, req.Amount, currency) {
+		return
+	}
+	if req.PayeeUserID < 0 {
+		writeValidationError(w, "payee_user_id", "payee_user_id must be a positive integer")
+		return
+	}
+	if req.TTLSeconds < 0 {
+		writeValidationError(w, "ttl_seconds", "ttl_seconds must not be negative")
+		return
+	}
+
+	hold, err := a.walletService.Authorize(r.Context(), userID, currency, *req.Amount, req.PayeeUserID, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, hold)
+}
+
+// getHoldV1 处理 GET /v1/holds/{hold_id}
+func (a *API) getHoldV1(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := holdIDParam(w, r)
+	if !ok || !a.authorizeHold(w, r, auth.ScopeWalletsRead, holdID) {
+		return
+	}
+
+	hold, err := a.walletService.GetHold(r.Context(), holdID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// captureV1 处理 POST /v1/holds/{hold_id}/capture，请求体可以为空
+func (a *API) captureV1(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := holdIDParam(w, r)
+	if !ok || !a.authorizeHold(w, r, auth.ScopeHolds, holdID) {
+		return
+	}
+	var req captureRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	amount := decimal.Zero
+	if req.Amount != nil {
+		if !req.Amount.IsPositive() {
+			writeValidationError(w, "amount", "amount must be positive")
+			return
+		}
+		amount = *req.Amount
+	}
+
+	hold, err := a.walletService.Capture(r.Context(), holdID, amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// voidV1 处理 POST /v1/holds/{hold_id}/void
+func (a *API) voidV1(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := holdIDParam(w, r)
+	if !ok || !a.authorizeHold(w, r, auth.ScopeHolds, holdID) {
+		return
+	}
+
+	hold, err := a.walletService.Void(r.Context(), holdID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// holdIDParam 解析路径中的预授权ID，非法时写出400并返回false
+func holdIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	holdID, err := strconv.Atoi(pathParam(r, "hold_id"))
+	if err != nil || holdID <= 0 {
+		writeValidationError(w, "hold_id", "hold id must be a positive integer")
+		return 0, false
+	}
+	return holdID, true
+}
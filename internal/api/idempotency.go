@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端用于标识重试请求的请求头
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader 标记响应是对首个请求结果的重放
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+)
+
+// mutationStateKey 是context中记录本次请求的变更是否已提交的键
+type mutationStateKey struct{}
+
+// mutationState 记录处理器的变更是否已提交
+type mutationState struct {
+	committed bool
+}
+
+// markCommitted 由处理器在变更成功提交后调用。此后即使写出响应失败或返回5xx，
+// 幂等键也会保存响应而不是释放，避免重试重复执行已提交的变更
+func markCommitted(r *http.Request) {
+	if state, ok := r.Context().Value(mutationStateKey{}).(*mutationState); ok {
+		state.committed = true
+	}
+}
+
+// idempotent 包装修改类处理器：带Idempotency-Key的请求只会被执行一次，
+// 相同键、相同请求的重试直接重放首次响应，相同键、不同请求返回422
+func (a *API) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || a.idempotencyRepo == nil {
+			next(w, r)
+			return
+		}
+		// 不同调用方的键互不影响，也不能借用他人的键读取其响应
+		if principal := auth.FromContext(r.Context()); principal != nil {
+			key = principal.Subject + ":" + key
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeValidationError(w, IdempotencyKeyHeader, "Idempotency-Key is too long")
+			return
+		}
+
+		// 多读一个字节以发现超长的请求体，不能让处理器与请求摘要基于截断后的请求体
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeValidation, "failed to read request body", nil)
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			writeError(w, http.StatusRequestEntityTooLarge, codeValidation, fmt.Sprintf("request body must not exceed %d bytes", maxIdempotentBodySize), nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := hashRequest(r, body)
+
+		now := time.Now()
+		reserved, err := a.idempotencyRepo.ReserveIdempotencyKey(r.Context(), model.IdempotencyRecord{
+			Key:         key,
+			RequestHash: requestHash,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(a.idempotencyTTL),
+		})
+		if err != nil {
+			logger.Log.Errorf("Error reserving idempotency key %q: %v", key, err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "failed to process idempotency key", nil)
+			return
+		}
+		if !reserved {
+			a.replayIdempotentResponse(w, r, key, requestHash)
+			return
+		}
+
+		state := &mutationState{}
+		defer func() {
+			// 处理器panic时键不能停留在处理中的状态，否则重试会一直返回409直到过期：
+			// 变更未提交时释放键，已提交时保存500响应，再把panic交给上层处理
+			if p := recover(); p != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				var err error
+				if state.committed {
+					body, _ := json.Marshal(errorResponse{Code: codeInternal, Message: "internal server error"})
+					err = a.idempotencyRepo.CompleteIdempotencyKey(ctx, key, http.StatusInternalServerError, "application/json", body)
+				} else {
+					err = a.idempotencyRepo.ReleaseIdempotencyKey(ctx, key)
+				}
+				if err != nil {
+					logger.Log.Errorf("Error saving result for idempotency key %q after panic: %v", key, err)
+				}
+				panic(p)
+			}
+		}()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(context.WithValue(r.Context(), mutationStateKey{}, state)))
+
+		// 客户端断开不应影响结果的保存，因此不使用请求的context
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if recorder.status >= http.StatusInternalServerError && !state.committed {
+			// 变更未提交时服务端错误不缓存，允许客户端用同一个键重试
+			err = a.idempotencyRepo.ReleaseIdempotencyKey(ctx, key)
+		} else {
+			err = a.idempotencyRepo.CompleteIdempotencyKey(ctx, key, recorder.status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			logger.Log.Errorf("Error saving result for idempotency key %q: %v", key, err)
+		}
+	}
+}
+
+// replayIdempotentResponse 处理幂等键已被占用的请求
+func (a *API) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key, requestHash string) {
+	record, err := a.idempotencyRepo.GetIdempotencyRecord(r.Context(), key)
+	if err != nil {
+		logger.Log.Errorf("Error loading idempotency key %q: %v", key, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "failed to process idempotency key", nil)
+		return
+	}
+	switch {
+	case record == nil || record.StatusCode == 0:
+		writeServiceError(w, fmt.Errorf("%w: a request with this Idempotency-Key is still being processed", service.ErrDuplicateRequest))
+	case record.RequestHash != requestHash:
+		writeError(w, http.StatusUnprocessableEntity, codeIdempotencyReused, "Idempotency-Key was already used with a different request", nil)
+	default:
+		if record.ContentType != "" {
+			w.Header().Set("Content-Type", record.ContentType)
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(record.StatusCode)
+		w.Write(record.ResponseBody)
+	}
+}
+
+// hashRequest 计算请求方法、路径、查询参数与请求体的摘要
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder 在写出响应的同时保留状态码与响应体
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
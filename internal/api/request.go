@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"wallet-service/internal/audit"
+)
+
+// RequestIDHeader 携带请求ID，客户端未提供时由服务生成，并总是在响应中返回
+const RequestIDHeader = "X-Request-ID"
+
+// WithTrustedProxies 设置受信任的反向代理网段，来自这些地址的请求以X-Forwarded-For中
+// 最右侧的不受信任地址作为客户端IP；未设置时总是使用连接的对端地址
+func WithTrustedProxies(networks []*net.IPNet) Option {
+	return func(a *API) {
+		a.trustedProxies = networks
+	}
+}
+
+// withRequestInfo 为请求分配请求ID并解析客户端IP，放入请求context供审计日志使用
+func (a *API) withRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !audit.ValidRequestID(requestID) {
+			requestID = audit.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		request := audit.Request{ID: requestID, ClientIP: a.clientIP(r)}
+		next.ServeHTTP(w, r.WithContext(audit.NewContext(r.Context(), request)))
+	})
+}
+
+// clientIP 返回请求的客户端IP，只信任受信任代理追加的X-Forwarded-For条目
+func (a *API) clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !a.trustedProxy(remote) {
+		return remote
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		if !a.trustedProxy(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+// trustedProxy 报告ip是否属于受信任的代理网段
+func (a *API) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range a.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
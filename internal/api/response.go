@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wallet-service/internal/logger"
+	"wallet-service/internal/service"
+)
+
+// API层自身的错误码；业务错误码来自service.Error.Code
+const (
+	codeValidation        = "validation_error"
+	codeUnauthorized      = "unauthorized"
+	codeNotFound          = "not_found"
+	codeMethodNotAllowed  = "method_not_allowed"
+	codeIdempotencyReused = "idempotency_key_reused"
+	codeInternal          = "internal_error"
+)
+
+// serviceErrorStatus 业务错误码对应的HTTP状态码
+var serviceErrorStatus = map[string]int{
+	service.ErrWalletNotFound.Code:          http.StatusNotFound,
+	service.ErrInsufficientFunds.Code:       http.StatusUnprocessableEntity,
+	service.ErrWalletFrozen.Code:            http.StatusConflict,
+	service.ErrWalletExists.Code:            http.StatusConflict,
+	service.ErrInvalidLimits.Code:           http.StatusBadRequest,
+	service.ErrInvalidMetadata.Code:         http.StatusBadRequest,
+	service.ErrWalletClosed.Code:            http.StatusConflict,
+	service.ErrInvalidStatusTransition.Code: http.StatusConflict,
+	service.ErrWalletNotEmpty.Code:          http.StatusConflict,
+	service.ErrInvalidAmount.Code:           http.StatusBadRequest,
+	service.ErrSameWallet.Code:              http.StatusBadRequest,
+	service.ErrLimitExceeded.Code:           http.StatusUnprocessableEntity,
+	service.ErrUnsupportedOperation.Code:    http.StatusBadRequest,
+	service.ErrDuplicateRequest.Code:        http.StatusConflict,
+	service.ErrUnsupportedCurrency.Code:     http.StatusBadRequest,
+	service.ErrCurrencyMismatch.Code:        http.StatusUnprocessableEntity,
+	service.ErrRateUnavailable.Code:         http.StatusServiceUnavailable,
+	service.ErrQuoteNotFound.Code:           http.StatusNotFound,
+	service.ErrQuoteExpired.Code:            http.StatusConflict,
+	service.ErrTransactionNotFound.Code:     http.StatusNotFound,
+	service.ErrNotReversible.Code:           http.StatusUnprocessableEntity,
+	service.ErrAlreadyReversed.Code:         http.StatusConflict,
+	service.ErrReasonRequired.Code:          http.StatusBadRequest,
+	service.ErrInvalidFilter.Code:           http.StatusBadRequest,
+	service.ErrHoldNotFound.Code:            http.StatusNotFound,
+	service.ErrHoldNotActive.Code:           http.StatusConflict,
+	service.ErrForbidden.Code:               http.StatusForbidden,
+	service.ErrCheckpointKeyMissing.Code:    http.StatusServiceUnavailable,
+	service.ErrInvalidWebhook.Code:          http.StatusBadRequest,
+	service.ErrWebhookEndpointNotFound.Code: http.StatusNotFound,
+	service.ErrWebhookDeliveryNotFound.Code: http.StatusNotFound,
+}
+
+// detailedError 由可携带结构化信息的业务错误实现，如service.LimitExceededError
+type detailedError interface {
+	Details() map[string]interface{}
+}
+
+// errorResponse 是所有v1接口统一的错误响应体
+type errorResponse struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeJSON 以JSON格式写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Errorf("Error encoding JSON response: %v", err)
+	}
+}
+
+// writeError 以统一的错误信封写出错误响应
+func writeError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	writeJSON(w, status, errorResponse{Code: code, Message: message, Details: details})
+}
+
+// writeValidationError 写出字段校验失败的400响应
+func writeValidationError(w http.ResponseWriter, field, message string) {
+	writeError(w, http.StatusBadRequest, codeValidation, message, map[string]interface{}{"field": field})
+}
+
+// writeServiceError 将服务层错误映射为HTTP状态码与错误码，未知错误不向客户端暴露细节
+func writeServiceError(w http.ResponseWriter, err error) {
+	status, code := classifyError(err)
+	if status == http.StatusInternalServerError {
+		logger.Log.Errorf("Unhandled service error: %v", err)
+		writeError(w, status, code, "internal server error", nil)
+		return
+	}
+
+	var details map[string]interface{}
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		details = detailed.Details()
+	}
+	writeError(w, status, code, err.Error(), details)
+}
+
+// classifyError 返回服务层错误对应的HTTP状态码与错误码
+func classifyError(err error) (int, string) {
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		if status, ok := serviceErrorStatus[domainErr.Code]; ok {
+			return status, domainErr.Code
+		}
+	}
+	return http.StatusInternalServerError, codeInternal
+}
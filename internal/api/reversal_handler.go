@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"wallet-service/internal/auth"
+	"wallet-service/pkg/decimal"
+)
+
+// reversalRequest 是冲正接口的请求体，Amount为空时全额冲正，设置时为转账的部分退款
+type reversalRequest struct {
+	Reason string           `json:"reason"`
+	Amount *decimal.Decimal `json:"amount"`
+}
+
+// reverseV1 处理 POST /v1/transactions/{tx_id}/reversals
+func (a *API) reverseV1(w http.ResponseWriter, r *http.Request) {
+	txID, err := strconv.Atoi(pathParam(r, "tx_id"))
+	if err != nil || txID <= 0 {
+		writeValidationError(w, "tx_id", "transaction id must be a positive integer")
+		return
+	}
+	if !a.authorizeScope(w, r, auth.ScopeReverse) {
+		return
+	}
+	var req reversalRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		writeValidationError(w, "reason", "reason is required")
+		return
+	}
+	if req.Amount != nil && !req.Amount.IsPositive() {
+		writeValidationError(w, "amount", "amount must be positive")
+		return
+	}
+
+	if req.Amount == nil {
+		reversal, err := a.walletService.Reverse(r.Context(), txID, req.Reason)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		markCommitted(r)
+		writeJSON(w, http.StatusCreated, reversal)
+		return
+	}
+	reversal, err := a.walletService.Refund(r.Context(), txID, *req.Amount, req.Reason)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, reversal)
+}
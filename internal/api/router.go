@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// pathParamsKey 是路径参数在请求context中的键
+type pathParamsKey struct{}
+
+// route 描述一条路由，pattern中形如{id}的段为路径参数
+type route struct {
+	method   string
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// router 是一个按方法与路径段匹配的最小路由器，路径存在但方法不匹配时返回405
+type router struct {
+	routes []route
+}
+
+func (rt *router) handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	var allowed []string
+	for _, rte := range rt.routes {
+		params, ok := matchSegments(rte.segments, segments)
+		if !ok {
+			continue
+		}
+		if rte.method != r.Method {
+			allowed = append(allowed, rte.method)
+			continue
+		}
+		ctx := context.WithValue(r.Context(), pathParamsKey{}, params)
+		rte.handler(w, r.WithContext(ctx))
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	writeError(w, http.StatusNotFound, codeNotFound, "resource not found", nil)
+}
+
+// pathParam 返回当前请求中名为name的路径参数
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func matchSegments(pattern, path []string) (map[string]string, bool) {
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range pattern {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if path[i] == "" {
+				return nil, false
+			}
+			params[seg[1:len(seg)-1]] = path[i]
+			continue
+		}
+		if seg != path[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/logger"
+	"wallet-service/internal/statement"
+)
+
+// getStatementV1 处理 GET /v1/wallets/{id}/statements?currency=&from=&to=&format=，
+// 期间为[from, to)，format为csv（默认）、jsonl或pdf。对账单边生成边写出；
+// 写出第一个字节前的错误按普通错误响应返回，之后的错误只能中断连接
+func (a *API) getStatementV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = statement.FormatCSV
+	}
+	contentType := statement.ContentType(format)
+	if contentType == "" {
+		writeValidationError(w, "format", "format must be one of csv, jsonl, pdf")
+		return
+	}
+	var period [2]time.Time
+	for i, field := range []string{"from", "to"} {
+		t, err := parseStatementTime(field, query.Get(field))
+		if err != nil {
+			writeValidationError(w, field, err.Error())
+			return
+		}
+		period[i] = t
+	}
+	from, to := period[0], period[1]
+	currency := a.currencyOrDefault(query.Get("currency"))
+
+	out := &lazyResponseWriter{ResponseWriter: w, header: func(h http.Header) {
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%d-%s-%s-%s.%s\"",
+			userID, currency, from.UTC().Format(statement.DateLayout), to.UTC().Format(statement.DateLayout), format))
+	}}
+	writer, err := statement.NewWriter(format, out)
+	if err != nil {
+		writeValidationError(w, "format", err.Error())
+		return
+	}
+
+	if _, err := a.walletService.GenerateStatement(r.Context(), userID, currency, from, to, writer); err != nil {
+		if !out.started {
+			writeServiceError(w, err)
+			return
+		}
+		logger.Log.Errorf("Error streaming statement for user ID %d (%s): %v", userID, currency, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseStatementTime 解析对账单的期间参数，必填，接受RFC3339时间或YYYY-MM-DD日期
+func parseStatementTime(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%s is required", field)
+	}
+	t, err := statement.ParseTime(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC3339 timestamp or a YYYY-MM-DD date", field)
+	}
+	return t, nil
+}
+
+// lazyResponseWriter 在第一次写入时才设置响应头并写出200，
+// 使生成对账单前的校验错误仍能以JSON错误响应返回
+type lazyResponseWriter struct {
+	http.ResponseWriter
+	header  func(h http.Header)
+	started bool
+}
+
+// Write 首次写入时写出响应头，之后直接写入响应体
+func (l *lazyResponseWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.started = true
+		l.header(l.ResponseWriter.Header())
+		l.ResponseWriter.WriteHeader(http.StatusOK)
+	}
+	return l.ResponseWriter.Write(p)
+}
@@ -0,0 +1,390 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+const maxRequestBodySize = 1 << 20
+
+// amountRequest 是存款、取款接口的请求体，Currency为空时使用默认币种
+type amountRequest struct {
+	Amount   *decimal.Decimal `json:"amount"`
+	Currency string           `json:"currency"`
+}
+
+// createWalletRequest 是开户接口的请求体，Currency为空时使用默认币种
+type createWalletRequest struct {
+	Currency      string             `json:"currency"`
+	OwnerMetadata map[string]string  `json:"owner_metadata"`
+	Limits        model.WalletLimits `json:"limits"`
+}
+
+// transferRequest 是转账接口的请求体；ToCurrency为空时与Currency相同，
+// 两者不一致时必须设置Convert显式换汇，QuoteID可选，用于锁定汇率
+type transferRequest struct {
+	FromUserID int              `json:"from_user_id"`
+	ToUserID   int              `json:"to_user_id"`
+	Amount     *decimal.Decimal `json:"amount"`
+	Currency   string           `json:"currency"`
+	ToCurrency string           `json:"to_currency"`
+	Convert    bool             `json:"convert"`
+	QuoteID    string           `json:"quote_id"`
+}
+
+// transferResponse 是转账成功后的响应体，跨币种转账时附带换汇结果
+type transferResponse struct {
+	FromUserID int               `json:"from_user_id"`
+	ToUserID   int               `json:"to_user_id"`
+	Amount     decimal.Decimal   `json:"amount"`
+	Currency   string            `json:"currency"`
+	Conversion *model.Conversion `json:"conversion,omitempty"`
+}
+
+// walletListResponse 是用户全部币种钱包的响应体
+type walletListResponse struct {
+	Wallets []model.Wallet `json:"wallets"`
+}
+
+func (a *API) v1Routes() http.Handler {
+	rt := &router{}
+	rt.handle(http.MethodGet, "/v1/wallets/{id}", a.getWalletV1)
+	rt.handle(http.MethodPost, "/v1/wallets/{id}", a.idempotent(a.createWalletV1))
+	rt.handle(http.MethodGet, "/v1/wallets/{id}/balances", a.listWalletsV1)
+	rt.handle(http.MethodGet, "/v1/wallets/{id}/limits", a.getLimitUsageV1)
+	rt.handle(http.MethodPost, "/v1/wallets/{id}/deposits", a.idempotent(a.depositV1))
+	rt.handle(http.MethodPost, "/v1/wallets/{id}/withdrawals", a.idempotent(a.withdrawV1))
+	rt.handle(http.MethodGet, "/v1/wallets/{id}/transactions", a.listTransactionsV1)
+	rt.handle(http.MethodGet, "/v1/wallets/{id}/statements", a.getStatementV1)
+	rt.handle(http.MethodPost, "/v1/transfers", a.idempotent(a.transferV1))
+	rt.handle(http.MethodPost, "/v1/transactions/{tx_id}/reversals", a.idempotent(a.reverseV1))
+	rt.handle(http.MethodPost, "/v1/wallets/{id}/conversions", a.idempotent(a.convertV1))
+	rt.handle(http.MethodPost, "/v1/fx/quotes", a.createQuoteV1)
+	rt.handle(http.MethodPost, "/v1/fees/quote", a.quoteFeeV1)
+	rt.handle(http.MethodGet, "/v1/wallets/{id}/balance", a.getBalanceV1)
+	rt.handle(http.MethodPost, "/v1/wallets/{id}/holds", a.idempotent(a.authorizeV1))
+	rt.handle(http.MethodGet, "/v1/holds/{hold_id}", a.getHoldV1)
+	rt.handle(http.MethodPost, "/v1/holds/{hold_id}/capture", a.idempotent(a.captureV1))
+	rt.handle(http.MethodPost, "/v1/holds/{hold_id}/void", a.idempotent(a.voidV1))
+	a.adminRoutes(rt)
+	a.webhookRoutes(rt)
+	return rt
+}
+
+// getWalletV1 处理 GET /v1/wallets/{id}?currency=
+func (a *API) getWalletV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	a.writeWallet(w, r, userID, currency, http.StatusOK)
+}
+
+// createWalletV1 处理 POST /v1/wallets/{id}，显式开户
+func (a *API) createWalletV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsCreate)
+	if !ok {
+		return
+	}
+	var req createWalletRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !req.Limits.IsEmpty() && !a.authorizeWallet(w, r, auth.ScopeLimits, userID) {
+		return
+	}
+
+	wallet, err := a.walletService.CreateWallet(r.Context(), model.WalletSpec{
+		UserID:        userID,
+		Currency:      a.currencyOrDefault(req.Currency),
+		OwnerMetadata: req.OwnerMetadata,
+		Limits:        req.Limits,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, wallet)
+}
+
+// listWalletsV1 处理 GET /v1/wallets/{id}/balances，返回用户全部币种的钱包
+func (a *API) listWalletsV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+
+	wallets, err := a.walletService.ListWallets(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, walletListResponse{Wallets: wallets})
+}
+
+// getLimitUsageV1 处理 GET /v1/wallets/{id}/limits?currency=，返回生效的限额、已用额度与剩余额度
+func (a *API) getLimitUsageV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+
+	usage, err := a.walletService.GetLimitUsage(r.Context(), userID, a.currencyOrDefault(r.URL.Query().Get("currency")))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// depositV1 处理 POST /v1/wallets/{id}/deposits
+func (a *API) depositV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeDeposit)
+	if !ok {
+		return
+	}
+	var req amountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	if !validateAmountField(w, req.Amount, currency) {
+		return
+	}
+
+	wallet, err := a.walletService.Deposit(r.Context(), userID, currency, *req.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, wallet)
+}
+
+// withdrawV1 处理 POST /v1/wallets/{id}/withdrawals
+func (a *API) withdrawV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWithdraw)
+	if !ok {
+		return
+	}
+	var req amountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	if !validateAmountField(w, req.Amount, currency) {
+		return
+	}
+
+	wallet, err := a.walletService.Withdraw(r.Context(), userID, currency, *req.Amount)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, wallet)
+}
+
+// transferV1 处理 POST /v1/transfers
+func (a *API) transferV1(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.FromUserID <= 0 {
+		writeValidationError(w, "from_user_id", "from_user_id must be a positive integer")
+		return
+	}
+	if req.ToUserID <= 0 {
+		writeValidationError(w, "to_user_id", "to_user_id must be a positive integer")
+		return
+	}
+	if !a.authorizeWallet(w, r, auth.ScopeTransfer, req.FromUserID) {
+		return
+	}
+	currency := a.currencyOrDefault(req.Currency)
+	if !validateAmountField(w, req.Amount, currency) {
+		return
+	}
+	toCurrency := currency
+	if req.ToCurrency != "" {
+		toCurrency = a.currencyOrDefault(req.ToCurrency)
+	}
+	if req.QuoteID != "" && !req.Convert {
+		writeValidationError(w, "quote_id", "quote_id requires convert to be true")
+		return
+	}
+	if req.Convert {
+		a.transferWithConversion(w, r, req, currency, toCurrency)
+		return
+	}
+	// 跨币种转账必须显式换汇，这里不做隐式转换
+	if toCurrency != currency {
+		writeServiceError(w, fmt.Errorf("%w: cannot transfer %s to a %s wallet without conversion", service.ErrCurrencyMismatch, currency, toCurrency))
+		return
+	}
+
+	if err := a.walletService.Transfer(r.Context(), req.FromUserID, req.ToUserID, currency, *req.Amount); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, transferResponse{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		Amount:     model.NormalizeAmount(*req.Amount, currency),
+		Currency:   currency,
+	})
+}
+
+// transferWithConversion 处理设置了convert的转账，金额以currency计价，转入方收到toCurrency
+func (a *API) transferWithConversion(w http.ResponseWriter, r *http.Request, req transferRequest, currency, toCurrency string) {
+	conversion, err := a.walletService.TransferWithConversion(r.Context(), req.FromUserID, req.ToUserID, currency, toCurrency, *req.Amount, req.QuoteID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	markCommitted(r)
+	writeJSON(w, http.StatusCreated, transferResponse{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		Amount:     conversion.SourceAmount,
+		Currency:   currency,
+		Conversion: conversion,
+	})
+}
+
+// listTransactionsV1 处理 GET /v1/wallets/{id}/transactions?currency=，
+// 支持type、min_amount、max_amount、from、to过滤及limit、cursor分页
+func (a *API) listTransactionsV1(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.walletParam(w, r, auth.ScopeWalletsRead)
+	if !ok {
+		return
+	}
+	filter, field, err := parseHistoryFilter(r.URL.Query())
+	if err != nil {
+		writeValidationError(w, field, err.Error())
+		return
+	}
+
+	currency := a.currencyOrDefault(r.URL.Query().Get("currency"))
+	page, err := a.walletService.GetTransactionHistory(r.Context(), userID, currency, filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseHistoryFilter 解析交易历史的查询参数，type可重复或以逗号分隔，时间为RFC3339格式；
+// 出错时返回出错的参数名
+func parseHistoryFilter(query url.Values) (model.HistoryFilter, string, error) {
+	var filter model.HistoryFilter
+	for _, value := range query["type"] {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+	for field, target := range map[string]**decimal.Decimal{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if value := query.Get(field); value != "" {
+			amount, err := decimal.Parse(value)
+			if err != nil {
+				return filter, field, fmt.Errorf("%s must be a decimal number", field)
+			}
+			*target = &amount
+		}
+	}
+	for field, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(field); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, field, fmt.Errorf("%s must be an RFC3339 timestamp", field)
+			}
+			*target = &t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, "limit", fmt.Errorf("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := model.DecodeHistoryCursor(value)
+		if err != nil {
+			return filter, "cursor", fmt.Errorf("cursor is invalid")
+		}
+		filter.Cursor = cursor
+	}
+	return filter, "", nil
+}
+
+// writeWallet 读取并返回钱包的最新状态，只用于查询；变更类操作应直接返回事务内得到的钱包
+func (a *API) writeWallet(w http.ResponseWriter, r *http.Request, userID int, currency string, status int) {
+	wallet, err := a.walletService.GetWallet(r.Context(), userID, currency)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, status, wallet)
+}
+
+// walletParam 解析路径中的钱包ID并检查调用方能否以scope权限操作该钱包，非法时写出400、无权限时写出403并返回false
+func (a *API) walletParam(w http.ResponseWriter, r *http.Request, scope string) (int, bool) {
+	userID, err := strconv.Atoi(pathParam(r, "id"))
+	if err != nil || userID <= 0 {
+		writeValidationError(w, "id", "wallet id must be a positive integer")
+		return 0, false
+	}
+	return userID, a.authorizeWallet(w, r, scope, userID)
+}
+
+// decodeJSON 严格解析JSON请求体，未知字段或格式错误时写出400并返回false
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeValidation, fmt.Sprintf("invalid JSON body: %v", err), nil)
+		return false
+	}
+	if decoder.More() {
+		writeError(w, http.StatusBadRequest, codeValidation, "invalid JSON body: unexpected data after object", nil)
+		return false
+	}
+	return true
+}
+
+// validateAmountField 校验请求中的金额字段及币种，非法时写出400并返回false
+func validateAmountField(w http.ResponseWriter, amount *decimal.Decimal, currency string) bool {
+	scale, supported := model.CurrencyScale(currency)
+	switch {
+	case !supported:
+		writeServiceError(w, fmt.Errorf("%w: %q", service.ErrUnsupportedCurrency, currency))
+	case amount == nil:
+		writeValidationError(w, "amount", "amount is required")
+	case !amount.IsPositive():
+		writeValidationError(w, "amount", "amount must be positive")
+	case !amount.FitsScale(scale):
+		writeValidationError(w, "amount", fmt.Sprintf("amount must have at most %d decimal places for %s", scale, currency))
+	default:
+		return true
+	}
+	return false
+}
@@ -1,27 +1,95 @@
 package api
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
 	"wallet-service/internal/service"
+	"wallet-service/internal/webhook"
 )
 
 type API struct {
 	walletService service.WalletService
+	// defaultCurrency 请求未指定币种时使用的币种
+	defaultCurrency string
+
+	idempotencyRepo _interface.IdempotencyRepository
+	idempotencyTTL  time.Duration
+
+	// authenticator 为nil时不做认证与授权，仅用于测试或受信任的内网部署
+	authenticator Authenticator
+
+	// trustedProxies 受信任的反向代理网段，用于解析客户端IP
+	trustedProxies []*net.IPNet
+
+	// webhooks 为nil时不提供事件推送接口
+	webhooks *webhook.Manager
+}
+
+// Option 用于在创建API时启用可选功能
+type Option func(*API)
+
+// WithIdempotency 为修改类接口启用Idempotency-Key支持，键在ttl后过期
+func WithIdempotency(repo _interface.IdempotencyRepository, ttl time.Duration) Option {
+	return func(a *API) {
+		a.idempotencyRepo = repo
+		a.idempotencyTTL = ttl
+	}
 }
 
-func NewAPI(walletService service.WalletService) *API {
-	return &API{walletService: walletService}
+// WithDefaultCurrency 设置请求未指定币种时使用的币种，默认为model.DefaultCurrency
+func WithDefaultCurrency(currency string) Option {
+	return func(a *API) {
+		if currency != "" {
+			a.defaultCurrency = strings.ToUpper(currency)
+		}
+	}
+}
+
+func NewAPI(walletService service.WalletService, opts ...Option) *API {
+	a := &API{walletService: walletService, defaultCurrency: model.DefaultCurrency}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 func (a *API) Routes() http.Handler {
 	router := http.NewServeMux()
 
-	router.HandleFunc("/deposit", a.DepositHandler)
-	router.HandleFunc("/withdraw", a.WithdrawHandler)
-	router.HandleFunc("/transfer", a.TransferHandler)
+	router.HandleFunc("/deposit", postOnly(a.idempotent(a.DepositHandler)))
+	router.HandleFunc("/withdraw", postOnly(a.idempotent(a.WithdrawHandler)))
+	router.HandleFunc("/transfer", postOnly(a.idempotent(a.TransferHandler)))
 	router.HandleFunc("/balance", a.BalanceHandler)
 	router.HandleFunc("/history", a.HistoryHandler)
 
-	return router
+	// v1 JSON接口
+	router.Handle("/v1/", a.v1Routes())
+
+	return a.withRequestInfo(a.authenticate(router))
+}
+
+// postOnly 使旧版变更接口只接受POST，其他方法返回405，避免GET等请求被代理或爬虫重放时改变余额
+func postOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// currencyOrDefault 规范化请求中的币种代码，未指定时返回默认币种；代码是否受支持由服务层校验
+func (a *API) currencyOrDefault(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return a.defaultCurrency
+	}
+	return currency
 }
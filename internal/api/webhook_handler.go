@@ -0,0 +1,146 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/internal/webhook"
+)
+
+// 推送记录每页的默认条数与最大条数
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WithWebhooks 启用事件推送地址的注册与推送记录的查询、重放接口
+func WithWebhooks(manager *webhook.Manager) Option {
+	return func(a *API) {
+		a.webhooks = manager
+	}
+}
+
+// webhookRoutes 注册事件推送接口，未启用时不注册
+func (a *API) webhookRoutes(rt *router) {
+	if a.webhooks == nil {
+		return
+	}
+	rt.handle(http.MethodPost, "/v1/webhooks/endpoints", a.registerWebhookV1)
+	rt.handle(http.MethodGet, "/v1/webhooks/endpoints", a.listWebhooksV1)
+	rt.handle(http.MethodDelete, "/v1/webhooks/endpoints/{endpoint_id}", a.disableWebhookV1)
+	rt.handle(http.MethodGet, "/v1/webhooks/endpoints/{endpoint_id}/deliveries", a.listWebhookDeliveriesV1)
+	rt.handle(http.MethodPost, "/v1/admin/webhooks/deliveries/{delivery_id}/replay", a.replayWebhookDeliveryV1)
+}
+
+// registerWebhookV1 处理 POST /v1/webhooks/endpoints，响应中的secret只返回这一次
+func (a *API) registerWebhookV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeWebhooks) {
+		return
+	}
+	var req model.WebhookEndpointSpec
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.URL == "" {
+		writeValidationError(w, "url", "url is required")
+		return
+	}
+
+	endpoint, err := a.webhooks.RegisterEndpoint(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, endpoint)
+}
+
+// listWebhooksV1 处理 GET /v1/webhooks/endpoints
+func (a *API) listWebhooksV1(w http.ResponseWriter, r *http.Request) {
+	if !a.authorizeScope(w, r, auth.ScopeWebhooks) {
+		return
+	}
+	endpoints, err := a.webhooks.ListEndpoints(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if endpoints == nil {
+		endpoints = []model.WebhookEndpoint{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
+}
+
+// disableWebhookV1 处理 DELETE /v1/webhooks/endpoints/{endpoint_id}，停用推送地址
+func (a *API) disableWebhookV1(w http.ResponseWriter, r *http.Request) {
+	endpointID, ok := positiveIDParam(w, r, "endpoint_id")
+	if !ok || !a.authorizeScope(w, r, auth.ScopeWebhooks) {
+		return
+	}
+	endpoint, err := a.webhooks.DisableEndpoint(r.Context(), endpointID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, endpoint)
+}
+
+// listWebhookDeliveriesV1 处理 GET /v1/webhooks/endpoints/{endpoint_id}/deliveries?status=&limit=
+func (a *API) listWebhookDeliveriesV1(w http.ResponseWriter, r *http.Request) {
+	endpointID, ok := positiveIDParam(w, r, "endpoint_id")
+	if !ok || !a.authorizeScope(w, r, auth.ScopeWebhooks) {
+		return
+	}
+	query := r.URL.Query()
+	status := model.WebhookDeliveryStatus(query.Get("status"))
+	switch status {
+	case "", model.WebhookPending, model.WebhookSucceeded, model.WebhookDead:
+	default:
+		writeValidationError(w, "status", "status must be pending, succeeded or dead")
+		return
+	}
+	limit := defaultDeliveryLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxDeliveryLimit {
+			writeValidationError(w, "limit", "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := a.webhooks.ListDeliveries(r.Context(), endpointID, status, limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
+
+// replayWebhookDeliveryV1 处理 POST /v1/admin/webhooks/deliveries/{delivery_id}/replay，把推送重置为立即推送
+func (a *API) replayWebhookDeliveryV1(w http.ResponseWriter, r *http.Request) {
+	deliveryID, ok := positiveIDParam(w, r, "delivery_id")
+	if !ok || !a.authorizeScope(w, r, auth.ScopeWebhooksAdmin) {
+		return
+	}
+	delivery, err := a.webhooks.ReplayDelivery(r.Context(), deliveryID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// positiveIDParam 解析路径中名为name的正整数ID，非法时写出400并返回false
+func positiveIDParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(pathParam(r, name))
+	if err != nil || id <= 0 {
+		writeValidationError(w, name, name+" must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
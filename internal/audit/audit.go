@@ -0,0 +1,55 @@
+// Package audit 在请求context中传递审计日志需要的请求元数据
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// maxRequestIDLength 客户端提供的请求ID的最大长度，超出或含不可见字符时重新生成
+const maxRequestIDLength = 128
+
+// Request 是发起操作的请求的元数据，由API层在收到请求时放入context
+type Request struct {
+	// ID 请求ID，取自X-Request-ID请求头或由服务生成，用于关联日志与审计记录
+	ID string
+	// ClientIP 客户端IP，经过受信任的代理时取自X-Forwarded-For
+	ClientIP string
+}
+
+// requestKey 是请求元数据在context中的键
+type requestKey struct{}
+
+// NewContext 返回携带请求元数据的context
+func NewContext(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// FromContext 返回context中的请求元数据，进程内调用（如后台任务、运维命令）返回零值
+func FromContext(ctx context.Context) Request {
+	request, _ := ctx.Value(requestKey{}).(Request)
+	return request
+}
+
+// ValidRequestID 报告客户端提供的请求ID是否可以直接使用
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID 生成随机的请求ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
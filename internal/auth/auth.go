@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 权限范围（scope），服务账号按需授予，终端用户只能在自己的钱包上使用UserScopes中的权限
+const (
+	ScopeWalletsRead   = "wallets:read"
+	ScopeWalletsCreate = "wallets:create"
+	ScopeDeposit       = "wallets:deposit"
+	ScopeWithdraw      = "wallets:withdraw"
+	ScopeTransfer      = "transfers:create"
+	ScopeConvert       = "fx:convert"
+	ScopeHolds         = "holds:write"
+	ScopeReverse       = "transactions:reverse"
+	ScopeFreeze        = "wallets:freeze"
+	ScopeClose         = "wallets:close"
+	ScopeAdjust        = "wallets:adjust"
+	ScopeLimits        = "wallets:limits"
+	ScopeLedgerRead    = "ledger:read"
+	ScopeAuditRead     = "audit:read"
+	ScopeWebhooks      = "webhooks:manage"
+	ScopeWebhooksAdmin = "webhooks:admin"
+)
+
+// 后台人员的角色，角色是一组权限范围的集合，只授予服务账号
+const (
+	// RoleSupport 客服：查看钱包与交易历史，开户，冻结、解冻钱包与销户，查看与重放事件推送
+	RoleSupport = "support"
+	// RoleFinance 财务：人工调账、设置钱包限额、冲正与核对账本
+	RoleFinance = "finance"
+	// RoleAuditor 审计：只读，唯一可以查询审计日志的角色
+	RoleAuditor = "auditor"
+)
+
+// roleScopes 每个角色拥有的权限范围
+var roleScopes = map[string][]string{
+	RoleSupport: {ScopeWalletsRead, ScopeWalletsCreate, ScopeFreeze, ScopeClose, ScopeWebhooks, ScopeWebhooksAdmin},
+	RoleFinance: {ScopeWalletsRead, ScopeAdjust, ScopeLimits, ScopeReverse, ScopeLedgerRead},
+	RoleAuditor: {ScopeWalletsRead, ScopeLedgerRead, ScopeAuditRead},
+}
+
+// UserScopes 是终端用户可以拥有的权限。存款由支付渠道的服务账号入账，冲正涉及他人钱包，都不授予终端用户
+var UserScopes = []string{ScopeWalletsRead, ScopeWalletsCreate, ScopeWithdraw, ScopeTransfer, ScopeConvert, ScopeHolds}
+
+// knownScopes 全部合法的权限范围
+var knownScopes = map[string]bool{
+	ScopeWalletsRead:   true,
+	ScopeWalletsCreate: true,
+	ScopeDeposit:       true,
+	ScopeWithdraw:      true,
+	ScopeTransfer:      true,
+	ScopeConvert:       true,
+	ScopeHolds:         true,
+	ScopeReverse:       true,
+	ScopeFreeze:        true,
+	ScopeClose:         true,
+	ScopeAdjust:        true,
+	ScopeLimits:        true,
+	ScopeLedgerRead:    true,
+	ScopeAuditRead:     true,
+	ScopeWebhooks:      true,
+	ScopeWebhooksAdmin: true,
+}
+
+var (
+	// ErrMissingCredentials 表示请求没有携带凭证
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials 表示凭证无法通过校验，如签名错误、令牌过期或密钥不存在
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal 是通过认证的调用方。UserID大于0时为终端用户，只能访问自己的钱包；
+// 为0时为服务账号，可以按Scopes访问任意钱包
+type Principal struct {
+	// Subject 调用方标识，如 user:42、key:gateway、service:billing
+	Subject string   `json:"subject"`
+	UserID  int      `json:"user_id,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	// Scopes 包含直接授予的权限与角色展开后的权限
+	Scopes []string `json:"scopes"`
+}
+
+// IsUser 报告调用方是否为终端用户
+func (p *Principal) IsUser() bool {
+	return p.UserID > 0
+}
+
+// HasScope 报告调用方是否拥有scope权限
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessWallet 报告调用方能否以scope权限操作userID的钱包
+func (p *Principal) CanAccessWallet(userID int, scope string) bool {
+	if !p.HasScope(scope) {
+		return false
+	}
+	return !p.IsUser() || p.UserID == userID
+}
+
+// Operator 返回拥有全部权限的运维人员，用于在服务器上直接执行的运维命令
+func Operator(name string) *Principal {
+	scopes := make([]string, 0, len(knownScopes))
+	for scope := range knownScopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return &Principal{Subject: "operator:" + name, Scopes: scopes}
+}
+
+// NewPrincipal 创建调用方。服务账号的权限为scopes与各角色权限的并集，未知角色被忽略；
+// 终端用户不能拥有角色，权限与UserScopes取交集，scopes为nil时获得全部UserScopes
+func NewPrincipal(subject string, userID int, scopes, roles []string) *Principal {
+	if userID <= 0 {
+		principal := &Principal{Subject: subject}
+		seen := make(map[string]bool)
+		grant := func(scopes []string) {
+			for _, scope := range scopes {
+				if !seen[scope] {
+					seen[scope] = true
+					principal.Scopes = append(principal.Scopes, scope)
+				}
+			}
+		}
+		grant(scopes)
+		for _, role := range roles {
+			if granted, ok := roleScopes[role]; ok {
+				principal.Roles = append(principal.Roles, role)
+				grant(granted)
+			}
+		}
+		return principal
+	}
+	if scopes == nil {
+		scopes = UserScopes
+	}
+	var granted []string
+	for _, scope := range scopes {
+		for _, allowed := range UserScopes {
+			if scope == allowed {
+				granted = append(granted, scope)
+				break
+			}
+		}
+	}
+	return &Principal{Subject: "user:" + strconv.Itoa(userID), UserID: userID, Scopes: granted}
+}
+
+// principalKey 是调用方在请求context中的键
+type principalKey struct{}
+
+// NewContext 返回携带调用方的context
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext 返回context中的调用方，未认证时返回nil
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Config 是认证配置，通常从AUTH_CONFIG_FILE指定的JSON文件加载
+type Config struct {
+	APIKeys []APIKey  `json:"api_keys"`
+	JWT     JWTConfig `json:"jwt"`
+	// MaxClockSkew 签名时间戳及令牌有效期允许的时钟偏差，默认5分钟
+	MaxClockSkew Duration `json:"max_clock_skew"`
+}
+
+// Duration 是以字符串（如"5m"）表示的时长
+type Duration time.Duration
+
+// UnmarshalJSON 解析time.ParseDuration格式的时长
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LoadConfigFile 从JSON文件加载认证配置
+func LoadConfigFile(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read auth config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse auth config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Verifier 校验请求携带的HMAC签名API密钥或JWT令牌，密钥均在本地配置
+type Verifier struct {
+	apiKeys  map[string]APIKey
+	jwtKeys  map[string]*jwtKey
+	issuer   string
+	audience string
+	maxSkew  time.Duration
+	now      func() time.Time
+}
+
+// NewVerifier 根据配置创建Verifier，配置中的密钥不合法时返回错误
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{
+		apiKeys:  make(map[string]APIKey, len(cfg.APIKeys)),
+		jwtKeys:  make(map[string]*jwtKey, len(cfg.JWT.Keys)),
+		issuer:   cfg.JWT.Issuer,
+		audience: cfg.JWT.Audience,
+		maxSkew:  time.Duration(cfg.MaxClockSkew),
+		now:      time.Now,
+	}
+	if v.maxSkew <= 0 {
+		v.maxSkew = 5 * time.Minute
+	}
+
+	for _, key := range cfg.APIKeys {
+		if key.ID == "" || key.Secret == "" {
+			return nil, fmt.Errorf("api key must have an id and a secret")
+		}
+		if _, ok := v.apiKeys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate api key %q", key.ID)
+		}
+		if err := checkScopes(key.Scopes); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", key.ID, err)
+		}
+		for _, role := range key.Roles {
+			if _, ok := roleScopes[role]; !ok {
+				return nil, fmt.Errorf("api key %q: unknown role %q", key.ID, role)
+			}
+		}
+		if key.UserID > 0 && len(key.Roles) > 0 {
+			return nil, fmt.Errorf("api key %q: roles cannot be granted to an end user", key.ID)
+		}
+		v.apiKeys[key.ID] = key
+	}
+	for _, cfgKey := range cfg.JWT.Keys {
+		key, err := parseJWTKey(cfgKey)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", cfgKey.ID, err)
+		}
+		if _, ok := v.jwtKeys[cfgKey.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key %q", cfgKey.ID)
+		}
+		v.jwtKeys[cfgKey.ID] = key
+	}
+	if len(v.apiKeys) == 0 && len(v.jwtKeys) == 0 {
+		return nil, fmt.Errorf("no api keys or jwt keys configured")
+	}
+	return v, nil
+}
+
+// Authenticate 校验请求的Authorization请求头：Bearer为JWT令牌，HMAC-SHA256为API密钥签名
+func (v *Verifier) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrMissingCredentials
+	}
+	scheme, credentials, _ := strings.Cut(header, " ")
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		return v.verifyJWT(strings.TrimSpace(credentials))
+	case strings.EqualFold(scheme, HMACScheme):
+		return v.verifyHMAC(r, strings.TrimSpace(credentials))
+	}
+	return nil, fmt.Errorf("%w: unsupported authorization scheme %q", ErrInvalidCredentials, scheme)
+}
+
+// AuthenticateToken 校验JWT令牌并返回对应的调用方，用于不经过HTTP请求的接口（如gRPC）
+func (v *Verifier) AuthenticateToken(token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrMissingCredentials
+	}
+	return v.verifyJWT(token)
+}
+
+// checkScopes 检查权限范围均为已知的scope
+func checkScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
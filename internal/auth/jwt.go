@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWTConfig 是JWT校验配置，Issuer与Audience非空时令牌的iss、aud必须匹配
+type JWTConfig struct {
+	Issuer   string         `json:"issuer"`
+	Audience string         `json:"audience"`
+	Keys     []JWTKeyConfig `json:"keys"`
+}
+
+// JWTKeyConfig 是一个JWT验签密钥，以令牌头中的kid选择。HS256使用Secret，RS256使用PEM格式的PublicKey
+type JWTKeyConfig struct {
+	ID        string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Secret    string `json:"secret"`
+	PublicKey string `json:"public_key"`
+}
+
+// Claims 是服务使用的JWT声明。sub为正整数时令牌代表该终端用户，否则为服务账号；
+// scope为空格分隔的权限范围，终端用户省略时获得全部UserScopes；roles为后台角色，只对服务账号生效
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// Audience 是JWT的aud声明，可以是字符串或字符串数组
+type Audience []string
+
+// UnmarshalJSON 同时接受字符串与字符串数组
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// jwtHeader 是JWT的头部
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// jwtKey 是解析后的验签密钥
+type jwtKey struct {
+	algorithm string
+	secret    []byte
+	publicKey *rsa.PublicKey
+}
+
+// parseJWTKey 解析配置中的验签密钥，只支持HS256与RS256
+func parseJWTKey(cfg JWTKeyConfig) (*jwtKey, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("kid is required")
+	}
+	switch cfg.Algorithm {
+	case "HS256":
+		if len(cfg.Secret) < 32 {
+			return nil, fmt.Errorf("HS256 secret must be at least 32 bytes")
+		}
+		return &jwtKey{algorithm: cfg.Algorithm, secret: []byte(cfg.Secret)}, nil
+	case "RS256":
+		block, _ := pem.Decode([]byte(cfg.PublicKey))
+		if block == nil {
+			return nil, fmt.Errorf("public_key must be a PEM encoded public key")
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public_key: %w", err)
+		}
+		publicKey, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public_key is not an RSA key")
+		}
+		return &jwtKey{algorithm: cfg.Algorithm, publicKey: publicKey}, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+}
+
+// SignHS256 用HS256签发令牌，供内部工具与测试使用
+func SignHS256(claims Claims, keyID, secret string) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: "HS256", KeyID: keyID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyJWT 校验令牌签名与有效期并返回对应的调用方
+func (v *Verifier) verifyJWT(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", ErrInvalidCredentials)
+	}
+	key, ok := v.jwtKeys[header.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown token key", ErrInvalidCredentials)
+	}
+	// 算法以服务端配置为准，防止令牌自行声明alg绕过验签
+	if header.Algorithm != key.algorithm {
+		return nil, fmt.Errorf("%w: unexpected token algorithm %q", ErrInvalidCredentials, header.Algorithm)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token signature", ErrInvalidCredentials)
+	}
+	if !key.verify(parts[0]+"."+parts[1], sig) {
+		return nil, fmt.Errorf("%w: token signature mismatch", ErrInvalidCredentials)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", ErrInvalidCredentials)
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	if claims.Scope != "" {
+		scopes = strings.Fields(claims.Scope)
+	}
+	if userID, err := strconv.Atoi(claims.Subject); err == nil && userID > 0 {
+		return NewPrincipal("", userID, scopes, nil), nil
+	}
+	return NewPrincipal("service:"+claims.Subject, 0, scopes, claims.Roles), nil
+}
+
+// validateClaims 校验有效期、签发方与受众
+func (v *Verifier) validateClaims(claims Claims) error {
+	now := v.now()
+	switch {
+	case claims.Subject == "":
+		return fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	case claims.ExpiresAt == 0:
+		return fmt.Errorf("%w: token has no expiry", ErrInvalidCredentials)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(v.maxSkew)):
+		return fmt.Errorf("%w: token has expired", ErrInvalidCredentials)
+	case claims.NotBefore != 0 && now.Add(v.maxSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidCredentials)
+	case v.issuer != "" && claims.Issuer != v.issuer:
+		return fmt.Errorf("%w: unexpected token issuer", ErrInvalidCredentials)
+	}
+	if v.audience != "" {
+		for _, aud := range claims.Audience {
+			if aud == v.audience {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: token is not intended for this service", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// verify 校验签名
+func (k *jwtKey) verify(signingInput string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	if k.publicKey != nil {
+		return rsa.VerifyPKCS1v15(k.publicKey, crypto.SHA256, digest[:], sig) == nil
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write([]byte(signingInput))
+	return hmac.Equal(mac.Sum(nil), sig)
+}
+
+// decodeSegment 解码base64url编码的JSON片段
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/internal/statement"
+	"wallet-service/pkg/decimal"
+)
+
+// ErrUsage 表示命令或参数不正确，调用方应输出用法说明
+var ErrUsage = errors.New("invalid usage")
+
+// ErrLedgerUnbalanced 表示账本核对未通过
+var ErrLedgerUnbalanced = errors.New("ledger is unbalanced")
+
+// ErrChainBroken 表示交易哈希链或检查点校验未通过
+var ErrChainBroken = errors.New("transaction hash chain is broken")
+
+// Usage 是运维命令的用法说明，每行一条命令
+const Usage = `  wallet show -user ID [-currency CUR]
+  wallet adjust -user ID -currency CUR -amount AMOUNT -reason TEXT
+  wallet freeze -user ID -currency CUR -reason TEXT
+  wallet unfreeze -user ID -currency CUR -reason TEXT
+  wallet close -user ID -currency CUR -reason TEXT
+  ledger verify
+  ledger checkpoint
+  export -user ID -currency CUR -from DATE -to DATE [-format csv|jsonl|pdf] [-output FILE]
+`
+
+// Admin 是面向运维人员的命令行工具。所有修改都通过service.WalletService完成，
+// 与API经过相同的校验、加锁与记账，避免直接改库
+type Admin struct {
+	service service.WalletService
+	out     io.Writer
+}
+
+// NewAdmin 创建把结果写入out的Admin
+func NewAdmin(walletService service.WalletService, out io.Writer) *Admin {
+	return &Admin{service: walletService, out: out}
+}
+
+// Run 执行一条命令，args[0]为命令名，如 ["wallet", "show", "-user", "1"]
+func (a *Admin) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", ErrUsage)
+	}
+	switch args[0] {
+	case "wallet":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: missing wallet subcommand", ErrUsage)
+		}
+		switch args[1] {
+		case "show":
+			return a.walletShow(ctx, args[2:])
+		case "adjust":
+			return a.walletAdjust(ctx, args[2:])
+		case "freeze":
+			return a.walletSetStatus(ctx, args[2:], a.service.FreezeWallet)
+		case "unfreeze":
+			return a.walletSetStatus(ctx, args[2:], a.service.UnfreezeWallet)
+		case "close":
+			return a.walletSetStatus(ctx, args[2:], a.service.CloseWallet)
+		}
+		return fmt.Errorf("%w: unknown wallet subcommand %q", ErrUsage, args[1])
+	case "ledger":
+		if len(args) == 2 {
+			switch args[1] {
+			case "verify":
+				return a.ledgerVerify(ctx)
+			case "checkpoint":
+				return a.ledgerCheckpoint(ctx)
+			}
+		}
+		return fmt.Errorf("%w: expected ledger verify or ledger checkpoint", ErrUsage)
+	case "export":
+		return a.export(ctx, args[1:])
+	}
+	return fmt.Errorf("%w: unknown command %q", ErrUsage, args[0])
+}
+
+// walletFlags 是钱包类命令共用的参数
+type walletFlags struct {
+	set      *flag.FlagSet
+	userID   int
+	currency string
+	reason   string
+}
+
+// newWalletFlags 创建包含-user、-currency参数的FlagSet，withReason为true时增加-reason参数
+func newWalletFlags(name string, withReason bool) *walletFlags {
+	f := &walletFlags{set: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.set.SetOutput(io.Discard)
+	f.set.IntVar(&f.userID, "user", 0, "user ID")
+	f.set.StringVar(&f.currency, "currency", "", "ISO-4217 currency code")
+	if withReason {
+		f.set.StringVar(&f.reason, "reason", "", "reason recorded with the change")
+	}
+	return f
+}
+
+// parse 解析参数并校验必填项，currencyRequired为false时-currency可以省略
+func (f *walletFlags) parse(args []string, currencyRequired bool) error {
+	if err := f.set.Parse(args); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUsage, f.set.Name(), err)
+	}
+	if f.set.NArg() > 0 {
+		return fmt.Errorf("%w: %s: unexpected argument %q", ErrUsage, f.set.Name(), f.set.Arg(0))
+	}
+	if f.userID <= 0 {
+		return fmt.Errorf("%w: %s: -user must be a positive integer", ErrUsage, f.set.Name())
+	}
+	if currencyRequired && f.currency == "" {
+		return fmt.Errorf("%w: %s: -currency is required", ErrUsage, f.set.Name())
+	}
+	return nil
+}
+
+// walletShow 列出用户的钱包及其余额、冻结金额与状态
+func (a *Admin) walletShow(ctx context.Context, args []string) error {
+	f := newWalletFlags("wallet show", false)
+	if err := f.parse(args, false); err != nil {
+		return err
+	}
+
+	var wallets []model.Wallet
+	if f.currency != "" {
+		wallet, err := a.service.GetWallet(ctx, f.userID, f.currency)
+		if err != nil {
+			return err
+		}
+		wallets = []model.Wallet{*wallet}
+	} else {
+		var err error
+		if wallets, err = a.service.ListWallets(ctx, f.userID); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tCURRENCY\tBALANCE\tHELD\tAVAILABLE\tSTATUS\tLAST UPDATED")
+	for _, wallet := range wallets {
+		balance, err := a.service.GetBalance(ctx, wallet.UserID, wallet.Currency)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", wallet.UserID, wallet.Currency, balance.Ledger, balance.Held, balance.Available,
+			wallet.Status, wallet.LastUpdated.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// walletAdjust 人工调账，-amount为负数时扣减
+func (a *Admin) walletAdjust(ctx context.Context, args []string) error {
+	f := newWalletFlags("wallet adjust", true)
+	var amountText string
+	f.set.StringVar(&amountText, "amount", "", "signed amount, e.g. 10.00 or -10.00")
+	if err := f.parse(args, true); err != nil {
+		return err
+	}
+	amount, err := decimal.Parse(amountText)
+	if err != nil {
+		return fmt.Errorf("%w: wallet adjust: -amount must be a decimal number", ErrUsage)
+	}
+
+	transaction, err := a.service.Adjust(ctx, f.userID, f.currency, amount, f.reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s %s %s posted to user %d (entry %d): %s\n", transaction.TransactionType, transaction.Amount, transaction.Currency,
+		transaction.UserID, transaction.EntryID, transaction.Reason)
+	return nil
+}
+
+// walletSetStatus 冻结、解冻钱包或销户
+func (a *Admin) walletSetStatus(ctx context.Context, args []string, set func(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error)) error {
+	f := newWalletFlags("wallet status", true)
+	if err := f.parse(args, true); err != nil {
+		return err
+	}
+	wallet, err := set(ctx, f.userID, f.currency, f.reason)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "%s wallet of user %d is %s\n", wallet.Currency, wallet.UserID, wallet.Status)
+	return nil
+}
+
+// ledgerVerify 核对账本并输出试算平衡与不一致的钱包，再校验交易哈希链，
+// 账不平时返回ErrLedgerUnbalanced，哈希链断开时返回ErrChainBroken
+func (a *Admin) ledgerVerify(ctx context.Context) error {
+	report, err := a.service.VerifyLedger(ctx)
+	if err != nil {
+		return err
+	}
+	chain, err := a.service.VerifyHashChain(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENCY\tDEBITS\tCREDITS")
+	for _, tb := range report.TrialBalances {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", tb.Currency, tb.TotalDebits, tb.TotalCredits)
+	}
+	if len(report.Mismatches) > 0 {
+		fmt.Fprintln(w, "\nUSER\tCURRENCY\tWALLET BALANCE\tLEDGER BALANCE")
+		for _, m := range report.Mismatches {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.UserID, m.Currency, m.WalletBalance, m.LedgerBalance)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "\nhash chain: %d transactions, %d unchained, %d checkpoints verified\n",
+		chain.TransactionsChecked, chain.Unchained, chain.CheckpointsVerified)
+	if b := chain.Break; b != nil {
+		fmt.Fprintf(a.out, "chain broken at transaction %d", b.TransactionID)
+		if b.CheckpointID != 0 {
+			fmt.Fprintf(a.out, " (checkpoint %d)", b.CheckpointID)
+		}
+		fmt.Fprintf(a.out, ": %s\n", b.Reason)
+		if b.Expected != "" || b.Actual != "" {
+			fmt.Fprintf(a.out, "  expected %s\n  actual   %s\n", b.Expected, b.Actual)
+		}
+	}
+
+	if !report.Balanced() {
+		return fmt.Errorf("%w: %d mismatched wallets", ErrLedgerUnbalanced, len(report.Mismatches))
+	}
+	if !chain.Intact() {
+		return fmt.Errorf("%w at transaction %d", ErrChainBroken, chain.Break.TransactionID)
+	}
+	fmt.Fprintln(a.out, "ledger is balanced and the hash chain is intact")
+	return nil
+}
+
+// ledgerCheckpoint 立即为哈希链生成一个签名检查点
+func (a *Admin) ledgerCheckpoint(ctx context.Context) error {
+	checkpoint, err := a.service.CreateCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		fmt.Fprintln(a.out, "no new transactions since the last checkpoint")
+		return nil
+	}
+	fmt.Fprintf(a.out, "checkpoint %d signed up to transaction %d with key %s\n", checkpoint.ID, checkpoint.LastTransactionID, checkpoint.KeyID)
+	return nil
+}
+
+// export 导出钱包在[from, to)期间的对账单，未指定-output时写到标准输出
+func (a *Admin) export(ctx context.Context, args []string) error {
+	f := newWalletFlags("export", false)
+	var fromText, toText, format, output string
+	f.set.StringVar(&fromText, "from", "", "period start, RFC3339 or YYYY-MM-DD")
+	f.set.StringVar(&toText, "to", "", "period end (exclusive), RFC3339 or YYYY-MM-DD")
+	f.set.StringVar(&format, "format", statement.FormatCSV, "csv, jsonl or pdf")
+	f.set.StringVar(&output, "output", "", "output file, defaults to stdout")
+	if err := f.parse(args, true); err != nil {
+		return err
+	}
+	from, err := statement.ParseTime(fromText)
+	if err != nil {
+		return fmt.Errorf("%w: export: -from must be an RFC3339 timestamp or a YYYY-MM-DD date", ErrUsage)
+	}
+	to, err := statement.ParseTime(toText)
+	if err != nil {
+		return fmt.Errorf("%w: export: -to must be an RFC3339 timestamp or a YYYY-MM-DD date", ErrUsage)
+	}
+
+	if statement.ContentType(format) == "" {
+		return fmt.Errorf("%w: export: -format must be one of csv, jsonl, pdf", ErrUsage)
+	}
+
+	out := a.out
+	var file *os.File
+	if output != "" {
+		if file, err = os.Create(output); err != nil {
+			return err
+		}
+		out = file
+	}
+	writer, err := statement.NewWriter(format, out)
+	if err == nil {
+		var summary *model.StatementSummary
+		summary, err = a.service.GenerateStatement(ctx, f.userID, f.currency, from, to, writer)
+		if err == nil && file != nil {
+			fmt.Fprintf(a.out, "exported %d transactions to %s, closing balance %s %s\n", summary.TransactionCount, output, summary.ClosingBalance, f.currency)
+		}
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		// 不保留不完整的导出文件
+		if err != nil {
+			os.Remove(output)
+		}
+	}
+	return err
+}
@@ -2,16 +2,104 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
 )
 
 // Config结构体用于存储整个项目的配置信息
 type Config struct {
 	DatabaseConfig DatabaseConfig
 	ServerPort     int
+	// GRPCPort gRPC接口的监听端口，为0时不启动gRPC服务
+	GRPCPort int
+	// IdempotencyTTL 幂等键的有效期，过期后同一个键可以被新请求复用
+	IdempotencyTTL time.Duration
+	// LedgerCashAccount 存取款记账的对手方系统账户
+	LedgerCashAccount string
+	// DefaultCurrency 请求未指定币种时使用的ISO-4217币种代码
+	DefaultCurrency string
+	// FX 换汇配置
+	FX FXConfig
+	// HoldTTL 预授权未指定有效期时的默认有效期
+	HoldTTL time.Duration
+	// AutoMigrate 启动服务时是否自动执行未执行的数据库迁移
+	AutoMigrate bool
+	// AutoCreateWallets 存款与换汇在钱包不存在时是否自动创建，仅为兼容未显式开户的旧客户端
+	AutoCreateWallets bool
+	// LimitsFile 默认钱包限额的JSON配置文件路径，未设置时只按钱包单独设置的限额检查
+	LimitsFile string
+	// FeesFile 取款与转账费率表的JSON配置文件路径，未设置时不收取手续费
+	FeesFile string
+	// Auth 认证配置
+	Auth AuthConfig
+	// Ledger 哈希链检查点配置
+	Ledger LedgerConfig
+	// Outbox 领域事件转发配置
+	Outbox OutboxConfig
+	// Webhook 事件推送配置
+	Webhook WebhookConfig
+	// TrustedProxies 受信任的反向代理网段，审计日志据此从X-Forwarded-For解析客户端IP
+	TrustedProxies []*net.IPNet
+}
+
+// AuthConfig结构体用于存储认证配置信息，未设置ConfigFile时必须显式设置Disabled才能启动服务
+type AuthConfig struct {
+	// ConfigFile API密钥与JWT验签密钥的JSON配置文件路径
+	ConfigFile string
+	// Disabled 关闭认证与授权，仅用于本地开发
+	Disabled bool
+}
+
+// LedgerConfig结构体用于存储哈希链检查点配置信息，未设置CheckpointKeyFile时不生成检查点
+type LedgerConfig struct {
+	// CheckpointKeyFile Ed25519签名私钥文件路径，PKCS#8 PEM格式或base64编码的32字节种子
+	CheckpointKeyFile string
+	// CheckpointInterval 定期生成检查点的间隔
+	CheckpointInterval time.Duration
+}
+
+// OutboxConfig结构体用于存储领域事件转发配置信息，未设置Publisher时事件只写入发件箱、不转发
+type OutboxConfig struct {
+	// Publisher 事件的发布方式：stdout或file
+	Publisher string
+	// File Publisher为file时事件追加写入的文件路径
+	File string
+	// RelayInterval 转发进程轮询发件箱的间隔
+	RelayInterval time.Duration
+}
+
+// WebhookConfig结构体用于存储事件推送配置信息
+type WebhookConfig struct {
+	// MaxAttempts 推送转为dead前的最多推送次数
+	MaxAttempts int
+	// BackoffBase 第一次失败后的重试间隔，之后每次失败翻倍
+	BackoffBase time.Duration
+	// BackoffMax 重试间隔的上限
+	BackoffMax time.Duration
+	// Timeout 单次推送请求的超时时间
+	Timeout time.Duration
+	// DispatchInterval 推送进程轮询到期推送的间隔
+	DispatchInterval time.Duration
+}
+
+// FXConfig结构体用于存储换汇配置信息，RatesURL与RatesFile都未设置时不启用换汇
+type FXConfig struct {
+	// RatesURL 外部汇率服务地址，优先于RatesFile
+	RatesURL string
+	// RatesFile 固定汇率表JSON文件路径
+	RatesFile string
+	// Spread 点差比例，如0.005表示0.5%
+	Spread decimal.Decimal
+	// QuoteTTL 报价锁定汇率的时长
+	QuoteTTL time.Duration
 }
 
 // DatabaseConfig结构体用于存储数据库连接配置信息
@@ -43,9 +131,168 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// 加载gRPC端口配置
+	grpcPort, err := loadGRPCPort(serverPort)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载幂等键有效期配置
+	idempotencyTTL, err := loadDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载默认币种配置
+	defaultCurrency := getEnv("DEFAULT_CURRENCY", model.DefaultCurrency)
+	if !model.IsSupportedCurrency(defaultCurrency) {
+		return nil, fmt.Errorf("unsupported DEFAULT_CURRENCY: %q", defaultCurrency)
+	}
+
+	// 加载换汇配置
+	fxConfig, err := loadFXConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载预授权默认有效期配置
+	holdTTL, err := loadDuration("HOLD_TTL", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载启动时自动迁移配置
+	autoMigrate, err := loadBool("AUTO_MIGRATE", true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载钱包自动创建配置
+	autoCreateWallets, err := loadBool("AUTO_CREATE_WALLETS", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载认证配置
+	authDisabled, err := loadBool("AUTH_DISABLED", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载哈希链检查点配置
+	checkpointInterval, err := loadDuration("LEDGER_CHECKPOINT_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载领域事件转发配置
+	outboxConfig, err := loadOutboxConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载事件推送配置
+	webhookConfig, err := loadWebhookConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// 加载受信任代理配置
+	trustedProxies, err := loadNetworks("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		DatabaseConfig: *dbConfig,
-		ServerPort:     serverPort,
+		DatabaseConfig:    *dbConfig,
+		ServerPort:        serverPort,
+		GRPCPort:          grpcPort,
+		IdempotencyTTL:    idempotencyTTL,
+		LedgerCashAccount: getEnv("LEDGER_CASH_ACCOUNT", "system:cash"),
+		DefaultCurrency:   defaultCurrency,
+		FX:                *fxConfig,
+		HoldTTL:           holdTTL,
+		AutoMigrate:       autoMigrate,
+		AutoCreateWallets: autoCreateWallets,
+		LimitsFile:        os.Getenv("LIMITS_FILE"),
+		FeesFile:          os.Getenv("FEES_FILE"),
+		Auth:              AuthConfig{ConfigFile: os.Getenv("AUTH_CONFIG_FILE"), Disabled: authDisabled},
+		Ledger:            LedgerConfig{CheckpointKeyFile: os.Getenv("LEDGER_CHECKPOINT_KEY_FILE"), CheckpointInterval: checkpointInterval},
+		Outbox:            *outboxConfig,
+		Webhook:           *webhookConfig,
+		TrustedProxies:    trustedProxies,
+	}, nil
+}
+
+// loadOutboxConfig函数用于从环境变量中加载领域事件转发配置信息
+func loadOutboxConfig() (*OutboxConfig, error) {
+	publisher := os.Getenv("OUTBOX_PUBLISHER")
+	file := os.Getenv("OUTBOX_FILE")
+	switch publisher {
+	case "", "stdout":
+	case "file":
+		if file == "" {
+			return nil, fmt.Errorf("OUTBOX_FILE is required when OUTBOX_PUBLISHER=file")
+		}
+	default:
+		return nil, fmt.Errorf("invalid OUTBOX_PUBLISHER: %q", publisher)
+	}
+	interval, err := loadDuration("OUTBOX_RELAY_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxConfig{Publisher: publisher, File: file, RelayInterval: interval}, nil
+}
+
+// loadWebhookConfig函数用于从环境变量中加载事件推送配置信息
+func loadWebhookConfig() (*WebhookConfig, error) {
+	maxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil || maxAttempts <= 0 {
+		return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: %q", os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	}
+	backoffBase, err := loadDuration("WEBHOOK_BACKOFF_BASE", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	backoffMax, err := loadDuration("WEBHOOK_BACKOFF_MAX", 6*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if backoffMax < backoffBase {
+		return nil, fmt.Errorf("WEBHOOK_BACKOFF_MAX must not be less than WEBHOOK_BACKOFF_BASE")
+	}
+	timeout, err := loadDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := loadDuration("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookConfig{
+		MaxAttempts:      maxAttempts,
+		BackoffBase:      backoffBase,
+		BackoffMax:       backoffMax,
+		Timeout:          timeout,
+		DispatchInterval: interval,
+	}, nil
+}
+
+// loadFXConfig函数用于从环境变量中加载换汇配置信息
+func loadFXConfig() (*FXConfig, error) {
+	spread, err := decimal.Parse(getEnv("FX_SPREAD", "0.005"))
+	if err != nil || spread.IsNegative() || !spread.LessThan(decimal.NewFromInt(1)) {
+		return nil, fmt.Errorf("invalid FX_SPREAD: %q", os.Getenv("FX_SPREAD"))
+	}
+	quoteTTL, err := loadDuration("FX_QUOTE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &FXConfig{
+		RatesURL:  os.Getenv("FX_RATES_URL"),
+		RatesFile: os.Getenv("FX_RATES_FILE"),
+		Spread:    spread,
+		QuoteTTL:  quoteTTL,
 	}, nil
 }
 
@@ -66,6 +313,80 @@ func loadServerPort() (int, error) {
 	return parseInt(portStr), nil
 }
 
+// loadGRPCPort函数用于从环境变量中加载gRPC端口配置信息，未设置时不启动gRPC服务
+func loadGRPCPort(serverPort int) (int, error) {
+	value := os.Getenv("GRPC_PORT")
+	if value == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid GRPC_PORT: %q", value)
+	}
+	if port != 0 && port == serverPort {
+		return 0, fmt.Errorf("GRPC_PORT must differ from SERVER_PORT")
+	}
+	return port, nil
+}
+
+// getEnv函数用于读取字符串类型的环境变量，未设置时返回默认值
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// loadDuration函数用于从环境变量中加载时长配置（如"24h"、"30m"），未设置时返回默认值
+func loadDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration for %s: %q", key, value)
+	}
+	return d, nil
+}
+
+// loadBool函数用于从环境变量中加载布尔配置（如"true"、"0"），未设置时返回默认值
+func loadBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean for %s: %q", key, value)
+	}
+	return b, nil
+}
+
+// loadNetworks函数用于从环境变量中加载逗号分隔的网段（如"10.0.0.0/8,192.168.1.1"），单个IP视为/32或/128
+func loadNetworks(key string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network in %s: %q", key, value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 // parseInt函数用于将字符串转换为整数
 func parseInt(s string) int {
 	i, err := strconv.Atoi(s)
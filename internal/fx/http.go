@@ -0,0 +1,82 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+)
+
+// HTTPProvider 通过HTTP从外部汇率服务获取汇率：
+// GET {baseURL}/rates?from=USD&to=CNY，响应体为model.FXRate的JSON
+type HTTPProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPProvider 创建HTTP汇率源，client为nil时使用5秒超时的默认客户端
+func NewHTTPProvider(baseURL string, client *http.Client) *HTTPProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &HTTPProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// GetRate 实现FXRateProvider接口
+func (p *HTTPProvider) GetRate(ctx context.Context, from, to string) (model.FXRate, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/rates?"+query.Encode(), nil)
+	if err != nil {
+		return model.FXRate{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return model.FXRate{}, fmt.Errorf("fetch fx rate %s/%s: %w", from, to, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return model.FXRate{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+	case resp.StatusCode != http.StatusOK:
+		return model.FXRate{}, fmt.Errorf("fetch fx rate %s/%s: unexpected status %d", from, to, resp.StatusCode)
+	}
+
+	var rate model.FXRate
+	if err := json.NewDecoder(resp.Body).Decode(&rate); err != nil {
+		return model.FXRate{}, fmt.Errorf("decode fx rate %s/%s: %w", from, to, err)
+	}
+	if rate.From != from || rate.To != to || !rate.Rate.IsPositive() {
+		return model.FXRate{}, fmt.Errorf("fx rate service returned invalid rate for %s/%s: %+v", from, to, rate)
+	}
+	return rate, nil
+}
+
+// NewStandInHandler 返回一个实现了HTTPProvider所用协议的本地汇率服务，
+// 用于开发与测试环境替代真实的外部汇率服务
+func NewStandInHandler(provider service.FXRateProvider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rates", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		from := strings.ToUpper(r.URL.Query().Get("from"))
+		to := strings.ToUpper(r.URL.Query().Get("to"))
+		rate, err := provider.GetRate(r.Context(), from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rate)
+	})
+	return mux
+}
@@ -0,0 +1,101 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// ErrRateNotFound 表示汇率源没有该币种对的汇率
+var ErrRateNotFound = errors.New("fx rate not found")
+
+// StaticProvider 是基于内存汇率表的FXRateProvider，用于测试或从文件加载固定汇率；
+// 只配置了反向汇率时按倒数计算
+type StaticProvider struct {
+	mu    sync.RWMutex
+	rates map[string]decimal.Decimal
+	asOf  time.Time
+}
+
+// NewStaticProvider 创建汇率表，键为 "FROM/TO"，如 "USD/CNY"
+func NewStaticProvider(rates map[string]decimal.Decimal) *StaticProvider {
+	p := &StaticProvider{rates: make(map[string]decimal.Decimal, len(rates)), asOf: time.Now()}
+	for pair, rate := range rates {
+		p.rates[strings.ToUpper(pair)] = rate
+	}
+	return p
+}
+
+// LoadFileProvider 从JSON文件加载汇率表，文件内容形如 {"USD/CNY": "7.2345"}
+func LoadFileProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fx rates file: %w", err)
+	}
+	var rates map[string]decimal.Decimal
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("parse fx rates file %s: %w", path, err)
+	}
+	for pair, rate := range rates {
+		if from, to, ok := splitPair(pair); !ok || !model.IsSupportedCurrency(from) || !model.IsSupportedCurrency(to) {
+			return nil, fmt.Errorf("fx rates file %s: invalid currency pair %q", path, pair)
+		}
+		if !rate.IsPositive() {
+			return nil, fmt.Errorf("fx rates file %s: rate for %s must be positive", path, pair)
+		}
+	}
+	p := NewStaticProvider(rates)
+	if info, err := os.Stat(path); err == nil {
+		p.asOf = info.ModTime()
+	}
+	return p, nil
+}
+
+// SetRate 设置或更新某个币种对的汇率
+func (p *StaticProvider) SetRate(from, to string, rate decimal.Decimal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates[pairKey(from, to)] = rate
+	p.asOf = time.Now()
+}
+
+// GetRate 实现FXRateProvider接口
+func (p *StaticProvider) GetRate(ctx context.Context, from, to string) (model.FXRate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := model.FXRate{From: from, To: to, AsOf: p.asOf}
+	if from == to {
+		result.Rate = decimal.NewFromInt(1)
+		return result, nil
+	}
+	if rate, ok := p.rates[pairKey(from, to)]; ok {
+		result.Rate = rate
+		return result, nil
+	}
+	if inverse, ok := p.rates[pairKey(to, from)]; ok && inverse.IsPositive() {
+		result.Rate = decimal.NewFromInt(1).QuoRound(inverse, model.FXRateScale, decimal.RoundHalfEven)
+		return result, nil
+	}
+	return model.FXRate{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+}
+
+func pairKey(from, to string) string {
+	return strings.ToUpper(from) + "/" + strings.ToUpper(to)
+}
+
+func splitPair(pair string) (from, to string, ok bool) {
+	parts := strings.Split(strings.ToUpper(pair), "/")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
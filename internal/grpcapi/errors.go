@@ -0,0 +1,93 @@
+package grpcapi
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"wallet-service/internal/logger"
+	"wallet-service/internal/service"
+)
+
+// errorDomain 是错误详情ErrorInfo.Domain的取值，Reason为与HTTP接口相同的业务错误码
+const errorDomain = "wallet-service"
+
+// codeValidation 请求参数不合法，与HTTP接口的validation_error相同
+const codeValidation = "validation_error"
+
+// serviceErrorCodes 业务错误码对应的gRPC状态码
+var serviceErrorCodes = map[string]codes.Code{
+	service.ErrWalletNotFound.Code:          codes.NotFound,
+	service.ErrInsufficientFunds.Code:       codes.FailedPrecondition,
+	service.ErrWalletFrozen.Code:            codes.FailedPrecondition,
+	service.ErrWalletExists.Code:            codes.AlreadyExists,
+	service.ErrInvalidLimits.Code:           codes.InvalidArgument,
+	service.ErrInvalidMetadata.Code:         codes.InvalidArgument,
+	service.ErrWalletClosed.Code:            codes.FailedPrecondition,
+	service.ErrInvalidStatusTransition.Code: codes.FailedPrecondition,
+	service.ErrWalletNotEmpty.Code:          codes.FailedPrecondition,
+	service.ErrInvalidAmount.Code:           codes.InvalidArgument,
+	service.ErrSameWallet.Code:              codes.InvalidArgument,
+	service.ErrLimitExceeded.Code:           codes.ResourceExhausted,
+	service.ErrUnsupportedOperation.Code:    codes.InvalidArgument,
+	service.ErrDuplicateRequest.Code:        codes.AlreadyExists,
+	service.ErrUnsupportedCurrency.Code:     codes.InvalidArgument,
+	service.ErrCurrencyMismatch.Code:        codes.FailedPrecondition,
+	service.ErrRateUnavailable.Code:         codes.Unavailable,
+	service.ErrQuoteNotFound.Code:           codes.NotFound,
+	service.ErrQuoteExpired.Code:            codes.FailedPrecondition,
+	service.ErrTransactionNotFound.Code:     codes.NotFound,
+	service.ErrNotReversible.Code:           codes.FailedPrecondition,
+	service.ErrAlreadyReversed.Code:         codes.FailedPrecondition,
+	service.ErrReasonRequired.Code:          codes.InvalidArgument,
+	service.ErrInvalidFilter.Code:           codes.InvalidArgument,
+	service.ErrHoldNotFound.Code:            codes.NotFound,
+	service.ErrHoldNotActive.Code:           codes.FailedPrecondition,
+	service.ErrForbidden.Code:               codes.PermissionDenied,
+	service.ErrCheckpointKeyMissing.Code:    codes.Unavailable,
+	service.ErrInvalidWebhook.Code:          codes.InvalidArgument,
+	service.ErrWebhookEndpointNotFound.Code: codes.NotFound,
+	service.ErrWebhookDeliveryNotFound.Code: codes.NotFound,
+}
+
+// detailedError 由可携带结构化信息的业务错误实现，如service.LimitExceededError
+type detailedError interface {
+	Details() map[string]interface{}
+}
+
+// serviceError 将服务层错误映射为gRPC状态，业务错误码放在ErrorInfo.Reason中；未知错误不向客户端暴露细节
+func serviceError(err error) error {
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		if code, ok := serviceErrorCodes[domainErr.Code]; ok {
+			var metadata map[string]string
+			var detailed detailedError
+			if errors.As(err, &detailed) {
+				metadata = make(map[string]string)
+				for k, v := range detailed.Details() {
+					metadata[k] = fmt.Sprint(v)
+				}
+			}
+			return statusError(code, domainErr.Code, err.Error(), metadata)
+		}
+	}
+	logger.Log.Errorf("Unhandled service error: %v", err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// validationError 返回请求参数不合法的InvalidArgument状态，field为出错的字段
+func validationError(field, message string) error {
+	return statusError(codes.InvalidArgument, codeValidation, message, map[string]string{"field": field})
+}
+
+// statusError 返回附带ErrorInfo详情的gRPC状态
+func statusError(code codes.Code, reason, message string, metadata map[string]string) error {
+	st := status.New(code, message)
+	if withDetails, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"wallet-service/internal/audit"
+	"wallet-service/internal/auth"
+	"wallet-service/internal/logger"
+)
+
+// RequestIDMetadata 携带请求ID的元数据键，客户端未提供时由服务生成，并总是在响应头中返回
+const RequestIDMetadata = "x-request-id"
+
+// recoverUnaryInterceptor 把处理一元调用时的panic转换为Internal错误，避免一次调用拖垮整个进程（包括HTTP接口）
+func recoverUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStreamInterceptor 把处理流式调用时的panic转换为Internal错误
+func recoverStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(info.FullMethod, p)
+		}
+	}()
+	return handler(srv, stream)
+}
+
+// recovered 记录panic及调用栈，返回不暴露细节的Internal状态
+func recovered(method string, p interface{}) error {
+	logger.Log.Errorf("Panic in gRPC method %s: %v\n%s", method, p, debug.Stack())
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// unaryInterceptor 为一元调用分配请求ID并完成认证
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.prepareContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor 为流式调用分配请求ID并完成认证
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.prepareContext(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// prepareContext 把请求元数据放入context供审计日志使用，启用认证时校验authorization元数据中的Bearer令牌
+func (s *Server) prepareContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := first(md, RequestIDMetadata)
+	if !audit.ValidRequestID(requestID) {
+		requestID = audit.NewRequestID()
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, requestID)); err != nil {
+		logger.Log.Warnf("Error setting gRPC response header: %v", err)
+	}
+	ctx = audit.NewContext(ctx, audit.Request{ID: requestID, ClientIP: clientIP(ctx)})
+
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+	scheme, token, _ := strings.Cut(first(md, "authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		token = ""
+	}
+	principal, err := s.authenticator.AuthenticateToken(strings.TrimSpace(token))
+	if err != nil {
+		if !errors.Is(err, auth.ErrMissingCredentials) && !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.Log.Errorf("Error authenticating gRPC call: %v", err)
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.NewContext(ctx, principal), nil
+}
+
+// first 返回元数据中key的第一个值
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// clientIP 返回连接的对端地址
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// contextStream 用拦截器补充过的context替换流的context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
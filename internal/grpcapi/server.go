@@ -0,0 +1,330 @@
+// Package grpcapi 以gRPC提供钱包服务，与HTTP接口共用同一个service.WalletService实现
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/grpcapi/walletpb"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+// historyPageSize 是推送交易历史时每次向服务层查询的条数
+const historyPageSize = 100
+
+// Authenticator 校验Bearer令牌并返回调用方，由auth.Verifier实现
+type Authenticator interface {
+	AuthenticateToken(token string) (*auth.Principal, error)
+}
+
+// Server 实现walletpb.WalletServiceServer
+type Server struct {
+	walletpb.UnimplementedWalletServiceServer
+
+	walletService service.WalletService
+	// authenticator 为nil时不做认证与授权，仅用于本地开发
+	authenticator Authenticator
+	// defaultCurrency 请求未指定币种时使用的币种
+	defaultCurrency string
+}
+
+// Option 用于在创建Server时调整可选配置
+type Option func(*Server)
+
+// WithAuthenticator 要求所有调用携带有效的Bearer令牌，并按调用方的权限与钱包归属进行授权
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(s *Server) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithDefaultCurrency 设置请求未指定币种时使用的币种，默认为model.DefaultCurrency
+func WithDefaultCurrency(currency string) Option {
+	return func(s *Server) {
+		if currency != "" {
+			s.defaultCurrency = currency
+		}
+	}
+}
+
+// NewServer 创建Server
+func NewServer(walletService service.WalletService, opts ...Option) *Server {
+	s := &Server{walletService: walletService, defaultCurrency: model.DefaultCurrency}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// GRPCServer 返回注册了WalletService与panic恢复、认证、请求元数据拦截器的grpc.Server，
+// panic恢复位于拦截器链的最外层
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(recoverUnaryInterceptor, s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(recoverStreamInterceptor, s.streamInterceptor),
+	)
+	server := grpc.NewServer(opts...)
+	walletpb.RegisterWalletServiceServer(server, s)
+	return server
+}
+
+// Deposit 存款，返回存款后的钱包
+func (s *Server) Deposit(ctx context.Context, req *walletpb.DepositRequest) (*walletpb.Wallet, error) {
+	userID, err := s.wallet(ctx, "user_id", req.GetUserId(), auth.ScopeDeposit)
+	if err != nil {
+		return nil, err
+	}
+	currency := s.currencyOrDefault(req.GetCurrency())
+	amount, err := parseAmount(req.GetAmount(), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.walletService.Deposit(ctx, userID, currency, amount)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return walletMessage(wallet), nil
+}
+
+// Withdraw 取款，返回取款后的钱包
+func (s *Server) Withdraw(ctx context.Context, req *walletpb.WithdrawRequest) (*walletpb.Wallet, error) {
+	userID, err := s.wallet(ctx, "user_id", req.GetUserId(), auth.ScopeWithdraw)
+	if err != nil {
+		return nil, err
+	}
+	currency := s.currencyOrDefault(req.GetCurrency())
+	amount, err := parseAmount(req.GetAmount(), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.walletService.Withdraw(ctx, userID, currency, amount)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return walletMessage(wallet), nil
+}
+
+// Transfer 同币种转账，授权以转出方为准
+func (s *Server) Transfer(ctx context.Context, req *walletpb.TransferRequest) (*walletpb.TransferResponse, error) {
+	fromUserID, err := s.wallet(ctx, "from_user_id", req.GetFromUserId(), auth.ScopeTransfer)
+	if err != nil {
+		return nil, err
+	}
+	toUserID, err := userIDField("to_user_id", req.GetToUserId())
+	if err != nil {
+		return nil, err
+	}
+	currency := s.currencyOrDefault(req.GetCurrency())
+	amount, err := parseAmount(req.GetAmount(), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.walletService.Transfer(ctx, fromUserID, toUserID, currency, amount); err != nil {
+		return nil, serviceError(err)
+	}
+	return &walletpb.TransferResponse{
+		FromUserId: int64(fromUserID),
+		ToUserId:   int64(toUserID),
+		Currency:   currency,
+		Amount:     model.NormalizeAmount(amount, currency).String(),
+	}, nil
+}
+
+// GetBalance 查询账面余额、冻结金额与可用余额
+func (s *Server) GetBalance(ctx context.Context, req *walletpb.GetBalanceRequest) (*walletpb.Balance, error) {
+	userID, err := s.wallet(ctx, "user_id", req.GetUserId(), auth.ScopeWalletsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.walletService.GetBalance(ctx, userID, s.currencyOrDefault(req.GetCurrency()))
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &walletpb.Balance{
+		UserId:    int64(balance.UserID),
+		Currency:  balance.Currency,
+		Ledger:    balance.Ledger.String(),
+		Held:      balance.Held.String(),
+		Available: balance.Available.String(),
+	}, nil
+}
+
+// StreamHistory 按从新到旧的顺序分页读取交易历史并逐条推送，客户端取消或推送失败时停止
+func (s *Server) StreamHistory(req *walletpb.StreamHistoryRequest, stream walletpb.WalletService_StreamHistoryServer) error {
+	ctx := stream.Context()
+	userID, err := s.wallet(ctx, "user_id", req.GetUserId(), auth.ScopeWalletsRead)
+	if err != nil {
+		return err
+	}
+	filter, err := historyFilter(req)
+	if err != nil {
+		return err
+	}
+	currency := s.currencyOrDefault(req.GetCurrency())
+	remaining := int(req.GetLimit())
+
+	for {
+		filter.Limit = historyPageSize
+		if remaining > 0 && remaining < historyPageSize {
+			filter.Limit = remaining
+		}
+		page, err := s.walletService.GetTransactionHistory(ctx, userID, currency, filter)
+		if err != nil {
+			return serviceError(err)
+		}
+		for _, tx := range page.Transactions {
+			if err := stream.Send(transactionMessage(tx)); err != nil {
+				return err
+			}
+		}
+		if remaining > 0 {
+			remaining -= len(page.Transactions)
+			if remaining <= 0 {
+				return nil
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		if filter.Cursor, err = model.DecodeHistoryCursor(page.NextCursor); err != nil {
+			return serviceError(err)
+		}
+	}
+}
+
+// wallet 校验钱包ID并检查调用方能否以scope权限操作该钱包；未启用认证时总是允许
+func (s *Server) wallet(ctx context.Context, field string, id int64, scope string) (int, error) {
+	userID, err := userIDField(field, id)
+	if err != nil {
+		return 0, err
+	}
+	if s.authenticator == nil {
+		return userID, nil
+	}
+	principal := auth.FromContext(ctx)
+	if principal == nil || !principal.CanAccessWallet(userID, scope) {
+		return 0, statusError(codes.PermissionDenied, service.ErrForbidden.Code,
+			fmt.Sprintf("not allowed to %s on wallet of user %d", scope, userID), nil)
+	}
+	return userID, nil
+}
+
+// walletMessage 把操作返回的钱包转换为响应消息
+func walletMessage(wallet *model.Wallet) *walletpb.Wallet {
+	return &walletpb.Wallet{
+		UserId:        int64(wallet.UserID),
+		Currency:      wallet.Currency,
+		Balance:       wallet.Balance.String(),
+		Status:        string(wallet.Status),
+		LastUpdated:   timestamppb.New(wallet.LastUpdated),
+		OwnerMetadata: wallet.OwnerMetadata,
+	}
+}
+
+// currencyOrDefault 规范化币种代码，为空时返回默认币种
+func (s *Server) currencyOrDefault(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return s.defaultCurrency
+	}
+	return currency
+}
+
+// userIDField 校验钱包ID为正整数
+func userIDField(field string, id int64) (int, error) {
+	if id <= 0 || int64(int(id)) != id {
+		return 0, validationError(field, field+" must be a positive integer")
+	}
+	return int(id), nil
+}
+
+// parseAmount 解析金额并校验为正数、整数部分不超过decimal.MaxIntegerDigits位且精度不超过币种的小数位数
+func parseAmount(value, currency string) (decimal.Decimal, error) {
+	scale, supported := model.CurrencyScale(currency)
+	if !supported {
+		return decimal.Decimal{}, serviceError(fmt.Errorf("%w: %q", service.ErrUnsupportedCurrency, currency))
+	}
+	if value == "" {
+		return decimal.Decimal{}, validationError("amount", "amount is required")
+	}
+	amount, err := decimal.Parse(value)
+	switch {
+	case errors.Is(err, decimal.ErrOverflow):
+		return decimal.Decimal{}, validationError("amount", fmt.Sprintf("amount must have at most %d integer digits", decimal.MaxIntegerDigits))
+	case err != nil:
+		return decimal.Decimal{}, validationError("amount", "amount must be a decimal number")
+	case !amount.IsPositive():
+		return decimal.Decimal{}, validationError("amount", "amount must be positive")
+	case !amount.FitsScale(scale):
+		return decimal.Decimal{}, validationError("amount", fmt.Sprintf("amount must have at most %d decimal places for %s", scale, currency))
+	}
+	return amount, nil
+}
+
+// historyFilter 把推送交易历史的请求转换为查询条件，不含页大小
+func historyFilter(req *walletpb.StreamHistoryRequest) (model.HistoryFilter, error) {
+	filter := model.HistoryFilter{Types: req.GetTypes()}
+	for field, value := range map[string]string{"min_amount": req.GetMinAmount(), "max_amount": req.GetMaxAmount()} {
+		if value == "" {
+			continue
+		}
+		amount, err := decimal.Parse(value)
+		if err != nil {
+			return filter, validationError(field, field+" must be a decimal number")
+		}
+		if field == "min_amount" {
+			filter.MinAmount = &amount
+		} else {
+			filter.MaxAmount = &amount
+		}
+	}
+	if req.GetFrom() != nil {
+		from := req.GetFrom().AsTime()
+		filter.From = &from
+	}
+	if req.GetTo() != nil {
+		to := req.GetTo().AsTime()
+		filter.To = &to
+	}
+	if req.GetLimit() < 0 {
+		return filter, validationError("limit", "limit must not be negative")
+	}
+	if req.GetCursor() != "" {
+		cursor, err := model.DecodeHistoryCursor(req.GetCursor())
+		if err != nil {
+			return filter, validationError("cursor", "cursor is invalid")
+		}
+		filter.Cursor = cursor
+	}
+	return filter, nil
+}
+
+// transactionMessage 把交易转换为推送的消息，cursor指向该交易
+func transactionMessage(tx model.Transaction) *walletpb.Transaction {
+	return &walletpb.Transaction{
+		Id:              int64(tx.ID),
+		UserId:          int64(tx.UserID),
+		Currency:        tx.Currency,
+		TransactionType: tx.TransactionType,
+		Amount:          tx.Amount.String(),
+		TransactionTime: timestamppb.New(tx.TransactionTime),
+		EntryId:         int64(tx.EntryID),
+		ReversalOf:      int64(tx.ReversalOf),
+		Reason:          tx.Reason,
+		Actor:           tx.Actor,
+		Cursor:          model.HistoryCursor{Time: tx.TransactionTime, ID: tx.ID}.Encode(),
+	}
+}
@@ -7,7 +7,8 @@ import (
 )
 
 var (
-	Log *logrus.Logger
+	// Log 在InitLogger调用前也可安全使用（使用logrus默认配置）
+	Log = logrus.New()
 )
 
 func InitLogger() {
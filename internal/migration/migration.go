@@ -0,0 +1,265 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey 是迁移使用的PostgreSQL会话级咨询锁，保证多个实例不会同时迁移
+const lockKey int64 = 0x77616c6c6574 // "wallet"
+
+// versionTable 记录已执行迁移的表
+const versionTable = "schema_migrations"
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// fileName 迁移文件名格式：{版本号}_{名称}.{up|down}.sql，如0001_initial_schema.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrUnknownVersion 表示数据库中存在当前程序不认识的迁移版本，通常是用较旧的程序连接了较新的库
+var ErrUnknownVersion = errors.New("database has migrations unknown to this build")
+
+// Migration 是一个版本的迁移，Up与Down为SQL脚本
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 是一个迁移的执行状态，AppliedAt为nil表示未执行
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Embedded 返回编译进程序的全部迁移，按版本号升序排列
+func Embedded() ([]Migration, error) {
+	dir, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Load(dir)
+}
+
+// Load 读取fsys根目录下的迁移文件，每个版本必须同时有up与down脚本且版本号不重复
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator 在数据库上执行迁移。每次操作都持有咨询锁，每个迁移与其版本记录在同一事务中提交
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator 创建执行migrations的Migrator，migrations须按版本号升序排列
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Up 按版本号顺序执行全部未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]time.Time) error {
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, migration.Up,
+				"INSERT INTO "+versionTable+" (version, name, applied_at) VALUES ($1, $2, $3)", migration.Version, migration.Name, time.Now())
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down 从最新的版本开始回滚steps个已执行的迁移，返回本次回滚的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]time.Time) error {
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			err := inTx(ctx, conn, migration.Down, "DELETE FROM "+versionTable+" WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status 返回全部迁移的执行状态，按版本号升序排列
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn, versions map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return m.checkKnown(versions)
+	})
+	return statuses, err
+}
+
+// withLock 在同一连接上获取咨询锁、确保版本表存在并读取已执行的版本后调用fn，结束时释放锁
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, versions map[int]time.Time) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// 使用新的context，避免ctx取消后锁无法释放
+		if _, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); unlockErr != nil && err == nil {
+			err = fmt.Errorf("release migration lock: %w", unlockErr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", versionTable, err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+versionTable+" ORDER BY version")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return err
+		}
+		versions[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return fn(conn, versions)
+}
+
+// checkKnown 检查数据库中已执行的版本是否都是已知迁移
+func (m *Migrator) checkKnown(versions map[int]time.Time) error {
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	var unknown []int
+	for version := range versions {
+		if !known[version] {
+			unknown = append(unknown, version)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Ints(unknown)
+		return fmt.Errorf("%w: %v", ErrUnknownVersion, unknown)
+	}
+	return nil
+}
+
+// inTx 在一个事务中执行迁移脚本并更新版本表
+func inTx(ctx context.Context, conn *sql.Conn, script, versionQuery string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, versionQuery, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS holds;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS wallets;
@@ -0,0 +1,90 @@
+CREATE TABLE wallets (
+    user_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    balance NUMERIC(20, 3) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    last_updated TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, currency),
+    CHECK (currency ~ '^[A-Z]{3}$')
+);
+
+CREATE TABLE fx_quotes (
+    id VARCHAR(32) PRIMARY KEY,
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+    spread NUMERIC(10, 6) NOT NULL CHECK (spread >= 0 AND spread < 1),
+    effective_rate NUMERIC(18, 8) NOT NULL CHECK (effective_rate > 0),
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    CHECK (from_currency <> to_currency)
+);
+
+CREATE TABLE journal_entries (
+    id SERIAL PRIMARY KEY,
+    entry_type VARCHAR(32) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    fx_quote_id VARCHAR(32) REFERENCES fx_quotes (id),
+    fx_rate NUMERIC(18, 8),
+    fx_spread NUMERIC(10, 6)
+);
+
+CREATE TABLE postings (
+    id SERIAL PRIMARY KEY,
+    entry_id INTEGER NOT NULL REFERENCES journal_entries (id),
+    account VARCHAR(64) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount NUMERIC(20, 3) NOT NULL CHECK (amount > 0)
+);
+
+CREATE INDEX idx_postings_account ON postings (account, currency);
+
+CREATE TABLE transactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    transaction_type VARCHAR(20) NOT NULL,
+    amount NUMERIC(20, 3) NOT NULL CHECK (amount > 0),
+    transaction_time TIMESTAMPTZ NOT NULL,
+    entry_id INTEGER REFERENCES journal_entries (id),
+    reversal_of INTEGER REFERENCES transactions (id),
+    reason TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (user_id, currency) REFERENCES wallets (user_id, currency)
+);
+
+CREATE INDEX idx_transactions_wallet ON transactions (user_id, currency, transaction_time DESC, id DESC);
+CREATE INDEX idx_transactions_entry ON transactions (entry_id);
+CREATE INDEX idx_transactions_reversal_of ON transactions (reversal_of);
+
+CREATE TABLE holds (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    amount NUMERIC(20, 3) NOT NULL CHECK (amount > 0),
+    payee_user_id INTEGER,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('authorized', 'captured', 'voided', 'expired')),
+    captured_amount NUMERIC(20, 3) NOT NULL DEFAULT 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    entry_id INTEGER REFERENCES journal_entries (id),
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (user_id, currency) REFERENCES wallets (user_id, currency),
+    FOREIGN KEY (payee_user_id, currency) REFERENCES wallets (user_id, currency),
+    CHECK (expires_at > created_at)
+);
+
+CREATE INDEX idx_holds_active ON holds (user_id, currency, expires_at) WHERE status = 'authorized';
+
+CREATE TABLE idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
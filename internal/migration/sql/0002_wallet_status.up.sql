@@ -0,0 +1 @@
+ALTER TABLE wallets ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen'));
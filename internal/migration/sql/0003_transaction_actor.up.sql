@@ -0,0 +1 @@
+ALTER TABLE transactions ADD COLUMN actor VARCHAR(255) NOT NULL DEFAULT '';
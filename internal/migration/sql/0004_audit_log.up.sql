@@ -0,0 +1,29 @@
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_id INTEGER,
+    currency CHAR(3),
+    balance_before NUMERIC(20, 3),
+    balance_after NUMERIC(20, 3),
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_log_actor ON audit_log (actor, id DESC);
+CREATE INDEX idx_audit_log_wallet ON audit_log (user_id, id DESC);
+CREATE INDEX idx_audit_log_request ON audit_log (request_id);
+
+-- 审计日志只允许追加，任何修改或删除都被拒绝
+CREATE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable();
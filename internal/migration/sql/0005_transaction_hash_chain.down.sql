@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ledger_checkpoints;
+ALTER TABLE transactions DROP COLUMN hash, DROP COLUMN prev_hash;
@@ -0,0 +1,15 @@
+-- 启用哈希链之前的交易hash为空，校验时计为未上链
+ALTER TABLE transactions
+    ADD COLUMN prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN hash VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE ledger_checkpoints (
+    id SERIAL PRIMARY KEY,
+    last_transaction_id INTEGER NOT NULL,
+    heads_digest VARCHAR(64) NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_ledger_checkpoints_last_transaction ON ledger_checkpoints (last_transaction_id);
@@ -0,0 +1,3 @@
+-- 存在已销户的钱包时回滚失败，需先人工处理这些钱包
+ALTER TABLE wallets DROP CONSTRAINT wallets_status_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen'));
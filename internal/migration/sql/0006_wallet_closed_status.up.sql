@@ -0,0 +1,2 @@
+ALTER TABLE wallets DROP CONSTRAINT wallets_status_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen', 'closed'));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_limits;
+ALTER TABLE wallets DROP COLUMN owner_metadata;
@@ -0,0 +1,15 @@
+ALTER TABLE wallets ADD COLUMN owner_metadata JSONB NOT NULL DEFAULT '{}';
+
+-- 钱包单独设置的限额，列为NULL时沿用默认限额
+CREATE TABLE wallet_limits (
+    user_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    max_single_withdrawal NUMERIC(20, 3) CHECK (max_single_withdrawal >= 0),
+    daily_outgoing NUMERIC(20, 3) CHECK (daily_outgoing >= 0),
+    monthly_outgoing NUMERIC(20, 3) CHECK (monthly_outgoing >= 0),
+    max_balance NUMERIC(20, 3) CHECK (max_balance >= 0),
+    max_transfers_per_hour INTEGER CHECK (max_transfers_per_hour >= 0),
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, currency),
+    FOREIGN KEY (user_id, currency) REFERENCES wallets (user_id, currency)
+);
@@ -0,0 +1,15 @@
+-- 发件箱：领域事件与引起它的变更在同一事务中写入，由转发进程按id顺序发布
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
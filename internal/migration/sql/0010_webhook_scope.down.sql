@@ -0,0 +1,2 @@
+ALTER TABLE webhook_endpoints DROP COLUMN owner_user_id;
+ALTER TABLE webhook_endpoints DROP COLUMN scope;
@@ -0,0 +1,6 @@
+-- 推送地址可以接收的事件范围，注册时按所有者的权限确定：all 全部事件，wallets 任意钱包的事件，
+-- user 只有 owner_user_id 钱包的事件。此前注册的合作方推送地址无法得知所有者的权限，
+-- 按 user 处理且不接收任何事件，需由合作方重新注册
+ALTER TABLE webhook_endpoints ADD COLUMN scope VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (scope IN ('all', 'wallets', 'user'));
+ALTER TABLE webhook_endpoints ADD COLUMN owner_user_id INTEGER NOT NULL DEFAULT 0;
+UPDATE webhook_endpoints SET scope = 'all' WHERE owner = 'system';
@@ -0,0 +1,2 @@
+-- 转账、换汇与转账冲正事件的对方钱包用户，使收款方范围内的推送地址也能收到入账事件；没有对方时为0
+ALTER TABLE outbox_events ADD COLUMN counterparty_user_id INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// AuditEntry 是一条审计记录，记录一次状态变更的发起方、操作与变更前后的余额。
+// 审计记录只能追加，不能修改或删除
+type AuditEntry struct {
+	ID     int    `json:"id"`
+	Action string `json:"action"`
+	// Actor 发起方，与交易的actor相同
+	Actor     string `json:"actor"`
+	RequestID string `json:"request_id,omitempty"`
+	ClientIP  string `json:"client_ip,omitempty"`
+	// UserID、Currency 为受影响的钱包，批量操作时为空
+	UserID   int    `json:"user_id,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	// BalanceBefore 为nil表示操作前钱包不存在；两者都为nil表示操作不改变余额
+	BalanceBefore *decimal.Decimal `json:"balance_before,omitempty"`
+	BalanceAfter  *decimal.Decimal `json:"balance_after,omitempty"`
+	// Details 操作的其他信息，如金额、原因、预授权ID、状态变化
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// AuditFilter 描述审计日志的查询条件，零值字段表示不过滤，结果按ID降序排列
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	RequestID string
+	UserID    int
+	// From、To 为记录时间范围，From包含、To不包含
+	From *time.Time
+	To   *time.Time
+	// BeforeID 大于0时只返回ID小于它的记录，用于分页
+	BeforeID int
+	Limit    int
+}
+
+// AuditPage 是一页审计记录，NextCursor为空表示没有更多记录
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// EncodeAuditCursor 将上一页最后一条记录的ID编码为对客户端不透明的游标
+func EncodeAuditCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("audit|" + strconv.Itoa(id)))
+}
+
+// DecodeAuditCursor 解析EncodeAuditCursor生成的游标，返回记录ID
+func DecodeAuditCursor(s string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(raw) <= len("audit|") || string(raw[:len("audit|")]) != "audit|" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	id, err := strconv.Atoi(string(raw[len("audit|"):]))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	return id, nil
+}
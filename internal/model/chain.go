@@ -0,0 +1,123 @@
+package model
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TransactionHash 计算交易在哈希链中的摘要：对prevHash与交易内容（不含ID与Hash本身）的规范JSON编码取SHA-256。
+// 交易时间按UTC微秒精度参与计算，与数据库存储的精度一致
+func TransactionHash(prevHash string, t Transaction) string {
+	content, _ := json.Marshal([]interface{}{
+		prevHash,
+		t.UserID,
+		t.Currency,
+		t.TransactionType,
+		NormalizeAmount(t.Amount, t.Currency).String(),
+		t.TransactionTime.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		t.EntryID,
+		t.ReversalOf,
+		t.Reason,
+		t.Actor,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainHead 是某个钱包哈希链的最新一环
+type ChainHead struct {
+	UserID   int    `json:"user_id"`
+	Currency string `json:"currency"`
+	// TransactionID 为链头交易的ID
+	TransactionID int    `json:"transaction_id"`
+	Hash          string `json:"hash"`
+}
+
+// ChainHeadsDigest 计算全部钱包链头的摘要，与顺序无关
+func ChainHeadsDigest(heads []ChainHead) string {
+	lines := make([]string, 0, len(heads))
+	for _, head := range heads {
+		lines = append(lines, strconv.Itoa(head.UserID)+"|"+head.Currency+"|"+head.Hash+"\n")
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LedgerCheckpoint 是对某一时刻全部钱包链头的签名。即使有人重新计算了整条哈希链，
+// 没有签名私钥也无法伪造与之一致的检查点
+type LedgerCheckpoint struct {
+	ID int `json:"id"`
+	// LastTransactionID 检查点覆盖ID不大于它的全部交易
+	LastTransactionID int    `json:"last_transaction_id"`
+	HeadsDigest       string `json:"heads_digest"`
+	// KeyID 为签名公钥的指纹，见CheckpointKeyID
+	KeyID string `json:"key_id"`
+	// Signature 为Ed25519签名的base64编码
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// checkpointMessage 返回检查点被签名的内容
+func (c LedgerCheckpoint) checkpointMessage() []byte {
+	return []byte(fmt.Sprintf("wallet-ledger-checkpoint|%d|%s", c.LastTransactionID, c.HeadsDigest))
+}
+
+// Sign 用私钥为检查点签名，设置KeyID与Signature
+func (c *LedgerCheckpoint) Sign(key ed25519.PrivateKey) {
+	c.KeyID = CheckpointKeyID(key.Public().(ed25519.PublicKey))
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, c.checkpointMessage()))
+}
+
+// VerifySignature 报告检查点的签名能否被公钥验证
+func (c LedgerCheckpoint) VerifySignature(key ed25519.PublicKey) bool {
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || c.KeyID != CheckpointKeyID(key) {
+		return false
+	}
+	return ed25519.Verify(key, c.checkpointMessage(), signature)
+}
+
+// CheckpointKeyID 返回公钥的指纹，用于区分轮换前后的签名密钥
+func CheckpointKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ChainBreak 描述哈希链上第一个断开的位置
+type ChainBreak struct {
+	// TransactionID 为断开处的交易，检查点不一致时为检查点覆盖的最后一笔交易
+	TransactionID int    `json:"transaction_id,omitempty"`
+	UserID        int    `json:"user_id,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	CheckpointID  int    `json:"checkpoint_id,omitempty"`
+	Reason        string `json:"reason"`
+	Expected      string `json:"expected,omitempty"`
+	Actual        string `json:"actual,omitempty"`
+}
+
+// ChainReport 是哈希链校验的结果
+type ChainReport struct {
+	// TransactionsChecked 为校验过的链上交易数，Unchained 为启用哈希链之前写入、没有摘要的交易数
+	TransactionsChecked int `json:"transactions_checked"`
+	Unchained           int `json:"unchained"`
+	// CheckpointsVerified 为摘要与签名都通过校验的检查点数
+	CheckpointsVerified int `json:"checkpoints_verified"`
+	// Break 为nil表示哈希链完整
+	Break *ChainBreak `json:"break,omitempty"`
+}
+
+// Intact 报告哈希链是否完整
+func (r ChainReport) Intact() bool {
+	return r.Break == nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"sort"
+
+	"wallet-service/pkg/decimal"
+)
+
+// DefaultCurrency 未指定币种时使用的默认币种
+const DefaultCurrency = "CNY"
+
+// MaxCurrencyScale 支持币种中最大的小数位数，与数据库中NUMERIC(20, 3)列保持一致
+const MaxCurrencyScale int32 = 3
+
+// currencyScales 支持的ISO-4217币种及其最小货币单位的小数位数
+var currencyScales = map[string]int32{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// CurrencyScale 返回币种允许的小数位数，币种不受支持时ok为false
+func CurrencyScale(currency string) (scale int32, ok bool) {
+	scale, ok = currencyScales[currency]
+	return scale, ok
+}
+
+// IsSupportedCurrency 判断是否为受支持的ISO-4217币种代码
+func IsSupportedCurrency(currency string) bool {
+	_, ok := currencyScales[currency]
+	return ok
+}
+
+// SupportedCurrencies 返回按字母排序的受支持币种列表
+func SupportedCurrencies() []string {
+	currencies := make([]string, 0, len(currencyScales))
+	for code := range currencyScales {
+		currencies = append(currencies, code)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// NormalizeAmount 将金额调整为币种的标准小数位数，如CNY的10.5调整为10.50；
+// 调用前应已确认金额精度不超过币种允许的位数，无法按该位数表示的金额原样返回
+func NormalizeAmount(amount decimal.Decimal, currency string) decimal.Decimal {
+	scale, ok := currencyScales[currency]
+	if !ok {
+		return amount
+	}
+	normalized, err := amount.Round(scale, decimal.RoundHalfEven)
+	if err != nil {
+		return amount
+	}
+	return normalized
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// 领域事件类型
+const (
+	EventWalletCreated       = "WalletCreated"
+	EventWalletStatusChanged = "WalletStatusChanged"
+	EventFundsDeposited      = "FundsDeposited"
+	EventFundsWithdrawn      = "FundsWithdrawn"
+	EventTransferCompleted   = "TransferCompleted"
+	EventFundsAdjusted       = "FundsAdjusted"
+	EventTransactionReversed = "TransactionReversed"
+	EventCurrencyConverted   = "CurrencyConverted"
+)
+
+// EventTypes 是全部领域事件类型
+var EventTypes = []string{
+	EventWalletCreated, EventWalletStatusChanged, EventFundsDeposited, EventFundsWithdrawn,
+	EventTransferCompleted, EventFundsAdjusted, EventTransactionReversed, EventCurrencyConverted,
+}
+
+// IsEventType 判断eventType是否为已定义的领域事件类型
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 是写入发件箱（outbox）的领域事件，与引起它的余额或状态变更在同一事务中写入，
+// 由转发进程按ID顺序至少发布一次，消费方应按ID去重
+type Event struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	// UserID、Currency 为事件所属的钱包，转账与换汇为付款方的钱包
+	UserID   int    `json:"user_id"`
+	Currency string `json:"currency"`
+	// CounterpartyUserID 为转账、跨用户换汇与转账冲正的对方用户，没有对方时为0
+	CounterpartyUserID int             `json:"counterparty_user_id,omitempty"`
+	Actor              string          `json:"actor"`
+	Payload            json.RawMessage `json:"payload"`
+	OccurredAt         time.Time       `json:"occurred_at"`
+	// Attempts 为发布失败的次数，LastError 为最近一次失败的原因，PublishedAt 为nil时尚未发布
+	Attempts    int        `json:"-"`
+	LastError   string     `json:"-"`
+	PublishedAt *time.Time `json:"-"`
+}
+
+// WalletStatusChangedEvent 是WalletStatusChanged事件的内容
+type WalletStatusChangedEvent struct {
+	From   WalletStatus `json:"from"`
+	To     WalletStatus `json:"to"`
+	Reason string       `json:"reason"`
+}
+
+// FundsEvent 是FundsDeposited、FundsWithdrawn与FundsAdjusted事件的内容，调账扣减时Amount为负数
+type FundsEvent struct {
+	EntryID      int              `json:"entry_id"`
+	Amount       decimal.Decimal  `json:"amount"`
+	Fee          *decimal.Decimal `json:"fee,omitempty"`
+	BalanceAfter decimal.Decimal  `json:"balance_after"`
+	Reason       string           `json:"reason,omitempty"`
+}
+
+// TransferEvent 是TransferCompleted事件的内容
+type TransferEvent struct {
+	EntryID    int              `json:"entry_id"`
+	FromUserID int              `json:"from_user_id"`
+	ToUserID   int              `json:"to_user_id"`
+	Amount     decimal.Decimal  `json:"amount"`
+	Fee        *decimal.Decimal `json:"fee,omitempty"`
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+
+	"wallet-service/pkg/decimal"
+)
+
+// 收取手续费的操作类型
+const (
+	FeeOperationWithdrawal = "withdrawal"
+	FeeOperationTransfer   = "transfer"
+)
+
+// FeeAnyCurrency 是费率表中适用于未单独配置币种的键
+const FeeAnyCurrency = "*"
+
+// FeeTier 是阶梯费率的一档，适用于金额不超过UpTo的操作，UpTo为nil的一档适用于其余金额
+type FeeTier struct {
+	UpTo    *decimal.Decimal `json:"up_to,omitempty"`
+	Flat    decimal.Decimal  `json:"flat"`
+	Percent decimal.Decimal  `json:"percent"`
+}
+
+// FeeRule 是一种操作在一个币种上的收费规则：固定金额加金额乘以比例（如0.01表示1%），
+// 设置了Tiers时按金额所在的档位取固定金额与比例；结果再限制在[Min, Max]之间
+type FeeRule struct {
+	Flat    decimal.Decimal  `json:"flat"`
+	Percent decimal.Decimal  `json:"percent"`
+	Tiers   []FeeTier        `json:"tiers,omitempty"`
+	Min     *decimal.Decimal `json:"min,omitempty"`
+	Max     *decimal.Decimal `json:"max,omitempty"`
+}
+
+// Calculate 计算amount的手续费，按币种小数位数四舍五入
+func (r FeeRule) Calculate(amount decimal.Decimal, currency string) decimal.Decimal {
+	scale, _ := CurrencyScale(currency)
+	flat, percent := r.Flat, r.Percent
+	for _, tier := range r.Tiers {
+		if tier.UpTo == nil || !amount.GreaterThan(*tier.UpTo) {
+			flat, percent = tier.Flat, tier.Percent
+			break
+		}
+	}
+	fee := flat.Add(amount.MulRound(percent, scale, decimal.RoundHalfUp))
+	if r.Min != nil && fee.LessThan(*r.Min) {
+		fee = *r.Min
+	}
+	if r.Max != nil && fee.GreaterThan(*r.Max) {
+		fee = *r.Max
+	}
+	if rounded, err := fee.Round(scale, decimal.RoundHalfUp); err == nil {
+		fee = rounded
+	}
+	return fee
+}
+
+// Validate 校验金额不为负数、比例小于1、最低收费不高于最高收费，且阶梯按UpTo升序、只有最后一档可以不设上限
+func (r FeeRule) Validate() error {
+	one := decimal.NewFromInt(1)
+	check := func(name string, flat, percent decimal.Decimal) error {
+		if flat.IsNegative() {
+			return fmt.Errorf("%s flat must not be negative", name)
+		}
+		if percent.IsNegative() || !percent.LessThan(one) {
+			return fmt.Errorf("%s percent must be in [0, 1)", name)
+		}
+		return nil
+	}
+	if err := check("rule", r.Flat, r.Percent); err != nil {
+		return err
+	}
+	for i, tier := range r.Tiers {
+		if err := check(fmt.Sprintf("tier %d", i+1), tier.Flat, tier.Percent); err != nil {
+			return err
+		}
+		switch {
+		case tier.UpTo == nil && i != len(r.Tiers)-1:
+			return fmt.Errorf("only the last tier may omit up_to")
+		case tier.UpTo != nil && i > 0 && !tier.UpTo.GreaterThan(*r.Tiers[i-1].UpTo):
+			return fmt.Errorf("tier %d up_to must be greater than the previous tier", i+1)
+		}
+	}
+	if (r.Min != nil && r.Min.IsNegative()) || (r.Max != nil && r.Max.IsNegative()) {
+		return fmt.Errorf("min and max must not be negative")
+	}
+	if r.Min != nil && r.Max != nil && r.Min.GreaterThan(*r.Max) {
+		return fmt.Errorf("min must not be greater than max")
+	}
+	return nil
+}
+
+// FeeSchedule 是费率表，第一层键为操作类型，第二层键为币种代码或FeeAnyCurrency
+type FeeSchedule map[string]map[string]FeeRule
+
+// Rule 返回操作在币种上适用的收费规则，币种没有单独配置时使用FeeAnyCurrency的规则
+func (s FeeSchedule) Rule(operation, currency string) (FeeRule, bool) {
+	rules := s[operation]
+	if rule, ok := rules[currency]; ok {
+		return rule, true
+	}
+	rule, ok := rules[FeeAnyCurrency]
+	return rule, ok
+}
+
+// Validate 校验费率表中的操作类型、币种与每条规则
+func (s FeeSchedule) Validate() error {
+	for operation, rules := range s {
+		if operation != FeeOperationWithdrawal && operation != FeeOperationTransfer {
+			return fmt.Errorf("unsupported fee operation %q", operation)
+		}
+		for currency, rule := range rules {
+			if currency != FeeAnyCurrency && !IsSupportedCurrency(currency) {
+				return fmt.Errorf("unsupported currency %q in %s fees", currency, operation)
+			}
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("%s fee for %s: %w", operation, currency, err)
+			}
+		}
+	}
+	return nil
+}
+
+// FeeQuote 是手续费试算结果，Total为付款方实际扣除的金额
+type FeeQuote struct {
+	Operation string          `json:"operation"`
+	Currency  string          `json:"currency"`
+	Amount    decimal.Decimal `json:"amount"`
+	Fee       decimal.Decimal `json:"fee"`
+	Total     decimal.Decimal `json:"total"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// FXRateScale 汇率保留的小数位数
+const FXRateScale int32 = 8
+
+// AccountSystemFX 换汇的对手方系统账户，按币种分别记账，点差收益沉淀在该账户中
+const AccountSystemFX = "system:fx"
+
+// FXRate 是汇率源给出的中间价：1单位From可兑换Rate单位To
+type FXRate struct {
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Rate decimal.Decimal `json:"rate"`
+	AsOf time.Time       `json:"as_of"`
+}
+
+// FXQuote 是锁定了汇率的报价，在ExpiresAt之前可用于一次换汇
+type FXQuote struct {
+	ID   string `json:"id"`
+	From string `json:"from_currency"`
+	To   string `json:"to_currency"`
+	// Rate 为中间价，Spread 为点差比例（如0.005表示0.5%），
+	// EffectiveRate = Rate × (1 - Spread) 为实际成交汇率
+	Rate          decimal.Decimal `json:"rate"`
+	Spread        decimal.Decimal `json:"spread"`
+	EffectiveRate decimal.Decimal `json:"effective_rate"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ExpiresAt     time.Time       `json:"expires_at"`
+	// UsedAt 为报价被使用的时间，未使用时为nil
+	UsedAt *time.Time `json:"used_at,omitempty"`
+}
+
+// Expired 判断报价在now时是否已过期
+func (q FXQuote) Expired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+// FXDetails 记录在换汇凭证上的汇率信息
+type FXDetails struct {
+	QuoteID string          `json:"quote_id,omitempty"`
+	Rate    decimal.Decimal `json:"rate"`
+	Spread  decimal.Decimal `json:"spread"`
+}
+
+// Conversion 是一次换汇的结果，卖出与买入两条腿分别对应一张记账凭证
+type Conversion struct {
+	UserID int `json:"user_id"`
+	// ToUserID 为买入币种入账的用户，自身换汇时与UserID相同
+	ToUserID      int             `json:"to_user_id"`
+	QuoteID       string          `json:"quote_id,omitempty"`
+	FromCurrency  string          `json:"from_currency"`
+	ToCurrency    string          `json:"to_currency"`
+	SourceAmount  decimal.Decimal `json:"source_amount"`
+	TargetAmount  decimal.Decimal `json:"target_amount"`
+	Rate          decimal.Decimal `json:"rate"`
+	Spread        decimal.Decimal `json:"spread"`
+	EffectiveRate decimal.Decimal `json:"effective_rate"`
+	SellEntryID   int             `json:"sell_entry_id"`
+	BuyEntryID    int             `json:"buy_entry_id"`
+	// Fee 跨币种转账时转出方以卖出币种另行支付的转账手续费，没有手续费时为nil
+	Fee *decimal.Decimal `json:"fee,omitempty"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// ErrInvalidCursor 表示分页游标无法解析
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// HistoryCursor 指向上一页最后一条交易，交易历史按（transaction_time，id）降序排列，
+// 下一页从严格小于该位置的记录开始
+type HistoryCursor struct {
+	Time time.Time
+	ID   int
+}
+
+// Encode 将游标编码为对客户端不透明的字符串
+func (c HistoryCursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeHistoryCursor 解析Encode生成的游标
+func DecodeHistoryCursor(s string) (*HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCursor, s)
+	}
+	return &HistoryCursor{Time: t, ID: id}, nil
+}
+
+// HistoryFilter 描述交易历史的查询条件，零值字段表示不过滤
+type HistoryFilter struct {
+	// Types 为交易类型，为空时返回全部类型
+	Types []string
+	// MinAmount、MaxAmount 为金额范围，两端均包含
+	MinAmount *decimal.Decimal
+	MaxAmount *decimal.Decimal
+	// From、To 为交易时间范围，From包含、To不包含
+	From *time.Time
+	To   *time.Time
+	// Cursor 为nil时从最新的交易开始
+	Cursor *HistoryCursor
+	// Limit 为最多返回的条数
+	Limit int
+	// Ascending 为true时按（transaction_time，id）升序返回，游标之后的记录为严格大于游标位置的记录
+	Ascending bool
+}
+
+// TransactionPage 是一页交易历史，NextCursor为空表示没有更多记录
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
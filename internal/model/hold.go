@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// HoldStatus 预授权的状态，只有authorized状态的预授权会占用可用余额
+type HoldStatus string
+
+const (
+	HoldAuthorized HoldStatus = "authorized"
+	HoldCaptured   HoldStatus = "captured"
+	HoldVoided     HoldStatus = "voided"
+	HoldExpired    HoldStatus = "expired"
+)
+
+// Hold 是对钱包资金的预授权冻结：冻结期间减少可用余额但不改变账面余额，
+// 也不产生记账凭证；请款（capture）时才转为一笔取款或转账
+type Hold struct {
+	ID       int             `json:"id"`
+	UserID   int             `json:"user_id"`
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+	// PayeeUserID 为请款时的收款用户，为0时请款转为取款
+	PayeeUserID int        `json:"payee_user_id,omitempty"`
+	Status      HoldStatus `json:"status"`
+	// CapturedAmount 为实际请款金额，部分请款时剩余部分随之释放
+	CapturedAmount decimal.Decimal `json:"captured_amount"`
+	// EntryID 为请款生成的记账凭证ID
+	EntryID   int       `json:"entry_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Active 判断预授权在now时是否仍占用资金
+func (h Hold) Active(now time.Time) bool {
+	return h.Status == HoldAuthorized && now.Before(h.ExpiresAt)
+}
+
+// Balance 是钱包的余额视图：Ledger为账面余额，Held为未过期预授权的冻结合计，
+// Available = Ledger - Held 为可用于取款、转账和新预授权的余额
+type Balance struct {
+	UserID    int             `json:"user_id"`
+	Currency  string          `json:"currency"`
+	Ledger    decimal.Decimal `json:"ledger"`
+	Held      decimal.Decimal `json:"held"`
+	Available decimal.Decimal `json:"available"`
+}
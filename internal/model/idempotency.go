@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyRecord 记录一次带Idempotency-Key请求的指纹与响应，用于重放重试请求
+type IdempotencyRecord struct {
+	Key string `json:"key"`
+	// RequestHash 为请求方法、路径与请求体的SHA-256摘要，用于识别同一个键下的不同请求
+	RequestHash string `json:"request_hash"`
+	// StatusCode 为0表示首个请求仍在处理中
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type"`
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
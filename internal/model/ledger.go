@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// PostingDirection 表示分录的借贷方向
+type PostingDirection string
+
+const (
+	Debit  PostingDirection = "debit"
+	Credit PostingDirection = "credit"
+)
+
+// 系统账户编码
+const (
+	// AccountSystemCash 系统现金账户，存取款的对手方
+	AccountSystemCash = "system:cash"
+	// AccountSystemFees 手续费收入账户
+	AccountSystemFees = "system:fees"
+	// AccountSystemSuspense 待处理（挂账）账户，用于人工调账
+	AccountSystemSuspense = "system:suspense"
+)
+
+// WalletAccount 返回用户某币种钱包在账本中的账户编码，如 "wallet:1:CNY"
+func WalletAccount(userID int, currency string) string {
+	return fmt.Sprintf("wallet:%d:%s", userID, currency)
+}
+
+// Posting 是记账凭证中的一条分录，Amount恒为正数，方向由Direction表示；
+// 系统账户按币种分别记账，账户余额以（Account，Currency）汇总
+type Posting struct {
+	ID        int              `json:"id"`
+	EntryID   int              `json:"entry_id"`
+	Account   string           `json:"account"`
+	Currency  string           `json:"currency"`
+	Direction PostingDirection `json:"direction"`
+	Amount    decimal.Decimal  `json:"amount"`
+}
+
+// JournalEntry 是一张记账凭证，每个币种的借方合计必须等于贷方合计
+type JournalEntry struct {
+	ID          int       `json:"id"`
+	EntryType   string    `json:"entry_type"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	Postings    []Posting `json:"postings"`
+	// FX 为换汇凭证记录的汇率与点差，其它凭证为nil
+	FX *FXDetails `json:"fx,omitempty"`
+}
+
+// ErrUnbalancedEntry 表示凭证借贷不平衡或分录不合法
+var ErrUnbalancedEntry = errors.New("unbalanced journal entry")
+
+// Validate 校验凭证至少包含一借一贷、每条分录金额为正，且每个币种的借贷合计相等
+func (e JournalEntry) Validate() error {
+	net := make(map[string]decimal.Decimal)
+	var hasDebit, hasCredit bool
+	for _, p := range e.Postings {
+		if p.Account == "" {
+			return fmt.Errorf("%w: posting without account", ErrUnbalancedEntry)
+		}
+		if !IsSupportedCurrency(p.Currency) {
+			return fmt.Errorf("%w: posting to %s has unsupported currency %q", ErrUnbalancedEntry, p.Account, p.Currency)
+		}
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: posting to %s has non-positive amount %s", ErrUnbalancedEntry, p.Account, p.Amount)
+		}
+		switch p.Direction {
+		case Debit:
+			net[p.Currency] = net[p.Currency].Add(p.Amount)
+			hasDebit = true
+		case Credit:
+			net[p.Currency] = net[p.Currency].Sub(p.Amount)
+			hasCredit = true
+		default:
+			return fmt.Errorf("%w: posting to %s has invalid direction %q", ErrUnbalancedEntry, p.Account, p.Direction)
+		}
+	}
+	if !hasDebit || !hasCredit {
+		return fmt.Errorf("%w: entry needs at least one debit and one credit", ErrUnbalancedEntry)
+	}
+	for currency, diff := range net {
+		if !diff.IsZero() {
+			return fmt.Errorf("%w: %s debits exceed credits by %s", ErrUnbalancedEntry, currency, diff)
+		}
+	}
+	return nil
+}
+
+// LedgerMismatch 描述钱包余额与账本分录汇总不一致的情况
+type LedgerMismatch struct {
+	UserID        int             `json:"user_id"`
+	Currency      string          `json:"currency"`
+	WalletBalance decimal.Decimal `json:"wallet_balance"`
+	LedgerBalance decimal.Decimal `json:"ledger_balance"`
+}
+
+// TrialBalance 是某个币种全部分录的借贷合计
+type TrialBalance struct {
+	Currency     string          `json:"currency"`
+	TotalDebits  decimal.Decimal `json:"total_debits"`
+	TotalCredits decimal.Decimal `json:"total_credits"`
+}
+
+// LedgerReport 是账本核对的结果
+type LedgerReport struct {
+	TrialBalances []TrialBalance   `json:"trial_balances"`
+	Mismatches    []LedgerMismatch `json:"mismatches"`
+}
+
+// Balanced 判断每个币种借贷平衡且所有钱包余额与分录一致
+func (r LedgerReport) Balanced() bool {
+	for _, tb := range r.TrialBalances {
+		if !tb.TotalDebits.Equal(tb.TotalCredits) {
+			return false
+		}
+	}
+	return len(r.Mismatches) == 0
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// WalletLimits 是钱包的限额，字段为nil时表示该项不单独设置，沿用默认限额
+type WalletLimits struct {
+	// MaxSingleWithdrawal 单笔出账（取款或转出）的最大金额
+	MaxSingleWithdrawal *decimal.Decimal `json:"max_single_withdrawal,omitempty"`
+	// DailyOutgoing、MonthlyOutgoing 为自然日、自然月（UTC）内出账金额合计的上限
+	DailyOutgoing   *decimal.Decimal `json:"daily_outgoing,omitempty"`
+	MonthlyOutgoing *decimal.Decimal `json:"monthly_outgoing,omitempty"`
+	// MaxBalance 钱包余额的上限
+	MaxBalance *decimal.Decimal `json:"max_balance,omitempty"`
+	// MaxTransfersPerHour 最近一小时内转出笔数的上限
+	MaxTransfersPerHour *int `json:"max_transfers_per_hour,omitempty"`
+}
+
+// IsEmpty 报告是否没有设置任何限额
+func (l WalletLimits) IsEmpty() bool {
+	return l.MaxSingleWithdrawal == nil && l.DailyOutgoing == nil && l.MonthlyOutgoing == nil && l.MaxBalance == nil && l.MaxTransfersPerHour == nil
+}
+
+// Amounts 返回各金额限额的名称与取值，名称与JSON字段一致
+func (l WalletLimits) Amounts() map[string]*decimal.Decimal {
+	return map[string]*decimal.Decimal{
+		"max_single_withdrawal": l.MaxSingleWithdrawal,
+		"daily_outgoing":        l.DailyOutgoing,
+		"monthly_outgoing":      l.MonthlyOutgoing,
+		"max_balance":           l.MaxBalance,
+	}
+}
+
+// Merge 返回以l中已设置的项覆盖defaults后的限额
+func (l WalletLimits) Merge(defaults WalletLimits) WalletLimits {
+	merged := defaults
+	if l.MaxSingleWithdrawal != nil {
+		merged.MaxSingleWithdrawal = l.MaxSingleWithdrawal
+	}
+	if l.DailyOutgoing != nil {
+		merged.DailyOutgoing = l.DailyOutgoing
+	}
+	if l.MonthlyOutgoing != nil {
+		merged.MonthlyOutgoing = l.MonthlyOutgoing
+	}
+	if l.MaxBalance != nil {
+		merged.MaxBalance = l.MaxBalance
+	}
+	if l.MaxTransfersPerHour != nil {
+		merged.MaxTransfersPerHour = l.MaxTransfersPerHour
+	}
+	return merged
+}
+
+// LimitWindows 返回now所在的UTC自然日与自然月的起点，以及滚动一小时窗口的起点
+func LimitWindows(now time.Time) (day, month, hour time.Time) {
+	now = now.UTC()
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return day, month, now.Add(-time.Hour)
+}
+
+// OutgoingUsage 是钱包在各限额窗口内已发生的出账，出账指取款（withdrawal）与转出（transfer_out）
+type OutgoingUsage struct {
+	// DailyOutgoing、MonthlyOutgoing 为当日、当月出账金额合计
+	DailyOutgoing   decimal.Decimal `json:"daily_outgoing"`
+	MonthlyOutgoing decimal.Decimal `json:"monthly_outgoing"`
+	// TransfersLastHour 为最近一小时内转出的笔数
+	TransfersLastHour int `json:"transfers_last_hour"`
+}
+
+// LimitUsage 是钱包当前生效的限额、已用额度与剩余额度，Remaining中未设置限额的项为nil
+type LimitUsage struct {
+	UserID    int             `json:"user_id"`
+	Currency  string          `json:"currency"`
+	Balance   decimal.Decimal `json:"balance"`
+	Limits    WalletLimits    `json:"limits"`
+	Usage     OutgoingUsage   `json:"usage"`
+	Remaining WalletLimits    `json:"remaining"`
+}
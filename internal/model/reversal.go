@@ -0,0 +1,17 @@
+package model
+
+import "wallet-service/pkg/decimal"
+
+// Reversal 是一次冲正或退款的结果。冲正生成一张与原凭证方向相反的凭证，
+// 每条冲正交易通过ReversalOf指向被冲正的原交易
+type Reversal struct {
+	// TransactionID 为被冲正的原交易ID，转账以转出记录为准
+	TransactionID int             `json:"transaction_id"`
+	Currency      string          `json:"currency"`
+	Amount        decimal.Decimal `json:"amount"`
+	// Remaining 为本次冲正后原交易还可冲正的金额
+	Remaining    decimal.Decimal `json:"remaining"`
+	Reason       string          `json:"reason"`
+	EntryID      int             `json:"entry_id"`
+	Transactions []Transaction   `json:"transactions"`
+}
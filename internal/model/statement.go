@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"wallet-service/pkg/decimal"
+)
+
+// StatementHeader 是对账单的抬头，期间为[From, To)
+type StatementHeader struct {
+	UserID         int             `json:"user_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+}
+
+// StatementLine 是对账单中的一笔交易，Amount入账为正、出账为负，Balance为该笔交易后的余额
+type StatementLine struct {
+	TransactionID   int             `json:"transaction_id"`
+	TransactionTime time.Time       `json:"transaction_time"`
+	TransactionType string          `json:"transaction_type"`
+	Amount          decimal.Decimal `json:"amount"`
+	Balance         decimal.Decimal `json:"balance"`
+	ReversalOf      int             `json:"reversal_of,omitempty"`
+	Reason          string          `json:"reason,omitempty"`
+}
+
+// StatementSummary 是对账单的汇总，ClosingBalance = OpeningBalance + TotalCredits - TotalDebits
+type StatementSummary struct {
+	OpeningBalance   decimal.Decimal `json:"opening_balance"`
+	TotalCredits     decimal.Decimal `json:"total_credits"`
+	TotalDebits      decimal.Decimal `json:"total_debits"`
+	ClosingBalance   decimal.Decimal `json:"closing_balance"`
+	TransactionCount int             `json:"transaction_count"`
+}
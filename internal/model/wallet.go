@@ -1,17 +1,90 @@
 package model
 
-import "time"
+import (
+	"time"
 
+	"wallet-service/pkg/decimal"
+)
+
+// WalletStatus 钱包状态，只有active状态的钱包允许资金变动
+type WalletStatus string
+
+const (
+	WalletActive WalletStatus = "active"
+	WalletFrozen WalletStatus = "frozen"
+	// WalletClosed 已销户，是终态
+	WalletClosed WalletStatus = "closed"
+)
+
+// walletTransitions 钱包状态允许的转换：active与frozen之间可以互相转换，只有active的钱包可以销户
+var walletTransitions = map[WalletStatus][]WalletStatus{
+	WalletActive: {WalletFrozen, WalletClosed},
+	WalletFrozen: {WalletActive},
+}
+
+// CanTransitionTo 报告钱包能否从状态s转换为next
+func (s WalletStatus) CanTransitionTo(next WalletStatus) bool {
+	for _, allowed := range walletTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Wallet 以（用户ID，币种）唯一标识，一个用户可以持有多个币种的钱包
 type Wallet struct {
-	UserID      int       `json:"user_id"`
-	Balance     float64   `json:"balance"`
-	LastUpdated time.Time `json:"last_updated"`
+	UserID      int             `json:"user_id"`
+	Currency    string          `json:"currency"`
+	Balance     decimal.Decimal `json:"balance"`
+	Status      WalletStatus    `json:"status"`
+	LastUpdated time.Time       `json:"last_updated"`
+	// OwnerMetadata 为开户时记录的持有人信息，如姓名、外部客户编号
+	OwnerMetadata map[string]string `json:"owner_metadata,omitempty"`
+}
+
+// WalletSpec 描述要显式创建的钱包
+type WalletSpec struct {
+	UserID        int               `json:"user_id"`
+	Currency      string            `json:"currency"`
+	OwnerMetadata map[string]string `json:"owner_metadata,omitempty"`
+	// Limits 为钱包的初始限额，未设置的项沿用默认限额
+	Limits WalletLimits `json:"limits"`
 }
 
 type Transaction struct {
-	ID              int       `json:"id"`
-	UserID          int       `json:"user_id"`
-	TransactionType string    `json:"transaction_type"`
-	Amount          float64   `json:"amount"`
-	TransactionTime time.Time `json:"transaction_time"`
+	ID              int             `json:"id"`
+	UserID          int             `json:"user_id"`
+	Currency        string          `json:"currency"`
+	TransactionType string          `json:"transaction_type"`
+	Amount          decimal.Decimal `json:"amount"`
+	TransactionTime time.Time       `json:"transaction_time"`
+	// EntryID 为对应的记账凭证ID，同一笔转账的转出、转入记录共享一个凭证
+	EntryID int `json:"entry_id,omitempty"`
+	// ReversalOf 为冲正交易所冲正的原交易ID，Reason 为冲正原因
+	ReversalOf int    `json:"reversal_of,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// Actor 为发起该交易的调用方，如 user:42、service:support-tool、operator:alice，进程内调用为system
+	Actor string `json:"actor,omitempty"`
+	// PrevHash 为同一钱包上一笔交易的Hash，Hash 为本交易内容与PrevHash的摘要，见TransactionHash
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// creditTransactionTypes 是使钱包余额增加的交易类型，其余类型使余额减少
+var creditTransactionTypes = []string{"deposit", "transfer_in", "fx_in", "withdrawal_reversal", "refund_in", "adjustment_credit"}
+
+// CreditTransactionTypes 返回使钱包余额增加的交易类型
+func CreditTransactionTypes() []string {
+	return append([]string(nil), creditTransactionTypes...)
+}
+
+// SignedAmount 返回交易对钱包余额的影响：入账为正，出账为负
+func (t Transaction) SignedAmount() decimal.Decimal {
+	for _, credit := range creditTransactionTypes {
+		if t.TransactionType == credit {
+			return t.Amount
+		}
+	}
+	return t.Amount.Neg()
 }
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEndpoint 是合作方注册的事件推送地址
+type WebhookEndpoint struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// EventTypes 为订阅的事件类型，为空时订阅全部事件
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description,omitempty"`
+	// Owner 为注册该端点的调用方，合作方只能管理自己注册的端点
+	Owner string `json:"owner"`
+	// Scope 为端点可以接收的事件范围，OwnerUserID 为范围是user时所有者的用户ID
+	Scope       WebhookScope `json:"scope"`
+	OwnerUserID int          `json:"owner_user_id,omitempty"`
+	// Secret 为签名密钥，只在注册时返回一次
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookScope 是推送地址可以接收的事件范围，注册时按所有者的权限确定
+type WebhookScope string
+
+// 推送范围：all 全部事件（包括不属于任何钱包的事件），只授予推送管理员与进程内注册的端点；
+// wallets 任意钱包的事件，授予可以读取全部钱包的服务账号；user 只有所有者自己钱包的事件
+const (
+	WebhookScopeAll     WebhookScope = "all"
+	WebhookScopeWallets WebhookScope = "wallets"
+	WebhookScopeUser    WebhookScope = "user"
+)
+
+// Receives 判断端点是否接收事件：订阅了事件类型，且事件所属的钱包在端点的范围内，
+// user范围的端点还接收以所有者为对方的转账、换汇与冲正事件
+func (e WebhookEndpoint) Receives(event Event) bool {
+	if !e.Subscribes(event.Type) {
+		return false
+	}
+	switch e.Scope {
+	case WebhookScopeAll:
+		return true
+	case WebhookScopeWallets:
+		return event.UserID > 0
+	case WebhookScopeUser:
+		return e.OwnerUserID > 0 && (event.UserID == e.OwnerUserID || event.CounterpartyUserID == e.OwnerUserID)
+	}
+	return false
+}
+
+// Subscribes 判断端点是否订阅了eventType
+func (e WebhookEndpoint) Subscribes(eventType string) bool {
+	if len(e.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpointSpec 描述要注册的推送地址
+type WebhookEndpointSpec struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+}
+
+// WebhookDeliveryStatus 是推送的状态
+type WebhookDeliveryStatus string
+
+// 推送状态：pending 等待（重新）推送，succeeded 对方已以2xx响应，dead 失败次数达到上限或端点已停用，不再自动重试
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookDelivery 是一个事件向一个端点的推送，同一事件对同一端点只生成一次
+type WebhookDelivery struct {
+	ID         int    `json:"id"`
+	EndpointID int    `json:"endpoint_id"`
+	EventID    int    `json:"event_id"`
+	EventType  string `json:"event_type"`
+	// Payload 为推送的请求体，即事件的JSON编码，重试与重放时原样发送
+	Payload       json.RawMessage       `json:"-"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	NextAttemptAt time.Time             `json:"next_attempt_at"`
+	// LastStatusCode 为最近一次推送的HTTP状态码，请求未得到响应时为0
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryFilter 是查询推送记录的条件，零值字段不参与过滤
+type WebhookDeliveryFilter struct {
+	EndpointID int
+	Status     WebhookDeliveryStatus
+	Limit      int
+}
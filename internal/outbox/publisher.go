@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"wallet-service/internal/model"
+)
+
+// Publisher 把发件箱中的领域事件发送给下游，返回nil表示下游已接收该事件。
+// 转发进程在返回错误或进程崩溃后会重发同一事件，实现需容忍重复
+type Publisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// Fanout 返回依次发布到每个publisher的Publisher，任何一个失败时返回错误，整个事件稍后重发，
+// 已成功的publisher会再次收到该事件
+func Fanout(publishers ...Publisher) Publisher {
+	return fanout(publishers)
+}
+
+type fanout []Publisher
+
+func (f fanout) Publish(ctx context.Context, event model.Event) error {
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriterPublisher 把每个事件编码为一行JSON写入w，用于标准输出或追加写入文件
+type WriterPublisher struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterPublisher 创建写入w的Publisher
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{w: w}
+}
+
+// OpenFilePublisher 以追加方式打开path，返回写入该文件的Publisher与用于关闭文件的io.Closer
+func OpenFilePublisher(path string) (*WriterPublisher, io.Closer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open outbox file: %w", err)
+	}
+	return NewWriterPublisher(file), file, nil
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event model.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+// MemoryPublisher 把事件保存在内存中，用于测试或进程内的订阅方
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []model.Event
+	// failures 为接下来需要模拟失败的发布次数
+	failures int
+}
+
+// NewMemoryPublisher 创建空的MemoryPublisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// FailNext 使接下来的n次发布返回错误，用于测试重试
+func (p *MemoryPublisher) FailNext(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = n
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event model.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return fmt.Errorf("publish event %d: simulated failure", event.ID)
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events 返回已发布的全部事件，按发布顺序排列
+func (p *MemoryPublisher) Events() []model.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]model.Event(nil), p.events...)
+}
@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	_interface "wallet-service/internal/repository/interface"
+)
+
+// defaultBatchSize 每轮最多转发的事件数
+const defaultBatchSize = 100
+
+// Relay 把发件箱中尚未发布的事件按ID顺序交给Publisher，发布成功后才标记为已发布，
+// 因此每个事件至少发布一次：标记前进程崩溃或标记失败时，该事件会在下一轮重发
+type Relay struct {
+	repo      _interface.OutboxRepository
+	publisher Publisher
+	batchSize int
+}
+
+// NewRelay 创建转发repo中事件的Relay
+func NewRelay(repo _interface.OutboxRepository, publisher Publisher) *Relay {
+	return &Relay{repo: repo, publisher: publisher, batchSize: defaultBatchSize}
+}
+
+// RelayOnce 转发一批未发布的事件，返回成功发布的条数。某个事件发布失败时记录失败原因并结束本轮，
+// 其后的事件留到下一轮，保证下游按ID顺序收到事件
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.repo.ListUnpublishedEvents(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list unpublished events: %w", err)
+	}
+	for i, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			if recordErr := r.repo.RecordEventFailure(ctx, event.ID, err.Error()); recordErr != nil {
+				logrus.Errorf("Error recording failure of outbox event %d: %v", event.ID, recordErr)
+			}
+			return i, fmt.Errorf("publish event %d: %w", event.ID, err)
+		}
+		if err := r.repo.MarkEventPublished(ctx, event.ID, time.Now()); err != nil {
+			return i, fmt.Errorf("mark event %d published: %w", event.ID, err)
+		}
+	}
+	return len(events), nil
+}
+
+// Run 每隔interval转发一次，一轮发满一批时立即继续，直到ctx取消
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil {
+			logrus.Errorf("Error relaying outbox events: %v", err)
+		}
+		if err == nil && published == r.batchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,7 +2,10 @@ package _interface
 
 import (
 	"context"
+	"errors"
+	"time"
 	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
 )
 
 // ErrWalletNotFound 定义表示钱包不存在的错误常量
@@ -21,11 +24,146 @@ func NewWalletNotFoundError() error {
 	return WalletNotFoundError{}
 }
 
-// WalletRepository 定义了钱包相关操作的仓库接口
+// ErrWalletExists 表示要创建的钱包已存在
+var ErrWalletExists = errors.New("wallet already exists")
+
+// ErrFXQuoteNotFound 表示换汇报价不存在
+var ErrFXQuoteNotFound = errors.New("fx quote not found")
+
+// ErrTransactionNotFound 表示交易记录不存在
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrHoldNotFound 表示预授权不存在
+var ErrHoldNotFound = errors.New("hold not found")
+
+// ErrWebhookEndpointNotFound 表示推送地址不存在
+var ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+
+// ErrWebhookDeliveryNotFound 表示推送记录不存在
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// WalletRepository 定义了钱包相关操作的仓库接口，钱包以（userID，currency）唯一标识
 type WalletRepository interface {
-	GetWallet(ctx context.Context, userID int) (*model.Wallet, error)
-	UpdateWalletBalance(ctx context.Context, userID int, amount float64) error
+	// GetWallet 读取钱包，不存在时返回ErrWalletNotFound
+	GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+	// GetWalletForUpdate 读取钱包并加行锁（SELECT ... FOR UPDATE），只应在WithTx内调用，不存在时返回ErrWalletNotFound
+	GetWalletForUpdate(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+	// ListWallets 返回用户持有的全部币种钱包，按币种排序
+	ListWallets(ctx context.Context, userID int) ([]model.Wallet, error)
+	UpdateWalletBalance(ctx context.Context, userID int, currency string, amount decimal.Decimal) error
+	// InsertWallet 创建钱包，Status为空时为active，钱包已存在时返回ErrWalletExists
 	InsertWallet(ctx context.Context, wallet model.Wallet) error
+	// GetWalletLimits 读取钱包单独设置的限额，没有设置时返回空的WalletLimits
+	GetWalletLimits(ctx context.Context, userID int, currency string) (*model.WalletLimits, error)
+	// UpsertWalletLimits 写入钱包单独设置的限额，覆盖之前的设置
+	UpsertWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) error
+	// GetOutgoingUsage 按model.LimitWindows(now)统计钱包当日、当月的出账金额与最近一小时的转出笔数
+	GetOutgoingUsage(ctx context.Context, userID int, currency string, now time.Time) (*model.OutgoingUsage, error)
+	// UpdateWalletStatus 更新钱包状态，钱包不存在时返回ErrWalletNotFound
+	UpdateWalletStatus(ctx context.Context, userID int, currency string, status model.WalletStatus) error
 	InsertTransaction(ctx context.Context, transaction model.Transaction) error
-	GetTransactionHistory(ctx context.Context, userID int) ([]model.Transaction, error)
+	// GetTransactionHistory 按（transaction_time，id）降序（filter.Ascending时升序）返回钱包满足filter的交易记录，最多filter.Limit条
+	GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error)
+	// GetBalanceBefore 按交易记录计算钱包在before之前的余额
+	GetBalanceBefore(ctx context.Context, userID int, currency string, before time.Time) (decimal.Decimal, error)
+	// GetTransaction 读取交易记录，不存在时返回ErrTransactionNotFound
+	GetTransaction(ctx context.Context, id int) (*model.Transaction, error)
+	// GetTransactionForUpdate 读取交易记录并加行锁，只应在WithTx内调用，不存在时返回ErrTransactionNotFound
+	GetTransactionForUpdate(ctx context.Context, id int) (*model.Transaction, error)
+	// ListTransactionsByEntry 返回共享同一记账凭证的全部交易记录，按ID排序
+	ListTransactionsByEntry(ctx context.Context, entryID int) ([]model.Transaction, error)
+	// SumReversals 返回冲正交易ID为id的原交易的冲正金额合计
+	SumReversals(ctx context.Context, id int) (decimal.Decimal, error)
+	// InsertJournalEntry 写入记账凭证及其全部分录，返回凭证ID
+	InsertJournalEntry(ctx context.Context, entry model.JournalEntry) (int, error)
+	// GetTrialBalance 按币种返回全部分录的借方合计与贷方合计
+	GetTrialBalance(ctx context.Context) ([]model.TrialBalance, error)
+	// ListLedgerMismatches 返回余额与账本分录汇总（贷方减借方）不一致的钱包
+	ListLedgerMismatches(ctx context.Context) ([]model.LedgerMismatch, error)
+	// InsertFXQuote 保存换汇报价
+	InsertFXQuote(ctx context.Context, quote model.FXQuote) error
+	// GetFXQuoteForUpdate 读取换汇报价并加行锁，只应在WithTx内调用，不存在时返回ErrFXQuoteNotFound
+	GetFXQuoteForUpdate(ctx context.Context, id string) (*model.FXQuote, error)
+	// MarkFXQuoteUsed 将报价标记为已使用，报价只能使用一次
+	MarkFXQuoteUsed(ctx context.Context, id string, usedAt time.Time) error
+	// InsertHold 保存预授权并返回其ID
+	InsertHold(ctx context.Context, hold model.Hold) (int, error)
+	// GetHold 读取预授权，不存在时返回ErrHoldNotFound
+	GetHold(ctx context.Context, id int) (*model.Hold, error)
+	// GetHoldForUpdate 读取预授权并加行锁，只应在WithTx内调用，不存在时返回ErrHoldNotFound
+	GetHoldForUpdate(ctx context.Context, id int) (*model.Hold, error)
+	// UpdateHold 更新预授权的状态、请款金额与凭证ID
+	UpdateHold(ctx context.Context, hold model.Hold) error
+	// SumActiveHolds 返回钱包在now时仍处于authorized且未过期的预授权金额合计
+	SumActiveHolds(ctx context.Context, userID int, currency string, now time.Time) (decimal.Decimal, error)
+	// ExpireHolds 将在now之前到期的authorized预授权标记为expired，返回更新的条数
+	ExpireHolds(ctx context.Context, now time.Time) (int64, error)
+	// GetLastTransactionHash 返回钱包最新一笔交易的Hash，没有交易时返回空字符串；调用方需持有钱包行锁
+	GetLastTransactionHash(ctx context.Context, userID int, currency string) (string, error)
+	// ListTransactionsAfter 按ID升序返回ID大于afterID的交易，最多limit条，用于遍历哈希链
+	ListTransactionsAfter(ctx context.Context, afterID, limit int) ([]model.Transaction, error)
+	// GetLastTransactionIDBefore 返回交易时间早于before的最大交易ID，没有时返回0
+	GetLastTransactionIDBefore(ctx context.Context, before time.Time) (int, error)
+	// ListChainHeads 返回每个钱包在ID不大于uptoID的交易中最新一笔已上链交易
+	ListChainHeads(ctx context.Context, uptoID int) ([]model.ChainHead, error)
+	// InsertLedgerCheckpoint 保存签名检查点并返回其ID
+	InsertLedgerCheckpoint(ctx context.Context, checkpoint model.LedgerCheckpoint) (int, error)
+	// ListLedgerCheckpoints 按覆盖的最后交易ID升序返回全部检查点
+	ListLedgerCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error)
+	// InsertAuditEntry 追加一条审计记录，审计记录写入后不能修改或删除
+	InsertAuditEntry(ctx context.Context, entry model.AuditEntry) error
+	// ListAuditEntries 按ID降序返回满足filter的审计记录，最多filter.Limit条
+	ListAuditEntries(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+	// InsertOutboxEvent 向发件箱追加一条领域事件，应与引起它的变更在同一事务中调用
+	InsertOutboxEvent(ctx context.Context, event model.Event) error
+	// WithTx 在单个数据库事务中执行fn，fn返回错误时回滚，否则提交；
+	// fn收到的repo绑定到该事务，已在事务中时直接复用当前事务
+	WithTx(ctx context.Context, fn func(repo WalletRepository) error) error
+}
+
+// IdempotencyRepository 定义了幂等键的存储接口
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey 以处理中状态占用幂等键，键已存在且未过期时返回false
+	ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (bool, error)
+	// GetIdempotencyRecord 查询幂等键记录，不存在时返回nil
+	GetIdempotencyRecord(ctx context.Context, key string) (*model.IdempotencyRecord, error)
+	// CompleteIdempotencyKey 保存首个请求的响应，供后续重试重放
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// ReleaseIdempotencyKey 删除处理中的幂等键，使首个请求失败后可以用同一个键重试
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	// DeleteExpiredIdempotencyKeys 清理在before之前过期的幂等键，返回删除的条数
+	DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OutboxRepository 定义了发件箱转发进程使用的存储接口
+type OutboxRepository interface {
+	// ListUnpublishedEvents 按ID升序返回尚未发布的事件，最多limit条
+	ListUnpublishedEvents(ctx context.Context, limit int) ([]model.Event, error)
+	// MarkEventPublished 将事件标记为已在publishedAt发布
+	MarkEventPublished(ctx context.Context, id int, publishedAt time.Time) error
+	// RecordEventFailure 累加事件的发布失败次数并记录失败原因
+	RecordEventFailure(ctx context.Context, id int, message string) error
+}
+
+// WebhookRepository 定义了事件推送地址与推送记录的存储接口
+type WebhookRepository interface {
+	// InsertWebhookEndpoint 保存推送地址并返回其ID
+	InsertWebhookEndpoint(ctx context.Context, endpoint model.WebhookEndpoint) (int, error)
+	// GetWebhookEndpoint 读取推送地址（含签名密钥），不存在时返回ErrWebhookEndpointNotFound
+	GetWebhookEndpoint(ctx context.Context, id int) (*model.WebhookEndpoint, error)
+	// ListWebhookEndpoints 按ID升序返回owner注册的推送地址（含签名密钥），owner为空时返回全部
+	ListWebhookEndpoints(ctx context.Context, owner string) ([]model.WebhookEndpoint, error)
+	// DisableWebhookEndpoint 停用推送地址，不存在时返回ErrWebhookEndpointNotFound
+	DisableWebhookEndpoint(ctx context.Context, id int) error
+	// InsertWebhookDelivery 保存待推送记录，同一端点与事件的推送已存在时忽略
+	InsertWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+	// ClaimDueWebhookDeliveries 领取在now之前到期的pending推送，最多limit条，并把它们的下次推送时间推迟到leaseUntil，
+	// 使其它实例在此之前不会重复领取；领取后进程崩溃的推送在leaseUntil后被重新领取
+	ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error)
+	// UpdateWebhookDelivery 更新推送的状态、次数、下次推送时间与最近一次结果
+	UpdateWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+	// GetWebhookDelivery 读取推送记录，不存在时返回ErrWebhookDeliveryNotFound
+	GetWebhookDelivery(ctx context.Context, id int) (*model.WebhookDelivery, error)
+	// ListWebhookDeliveries 按ID降序返回满足filter的推送记录
+	ListWebhookDeliveries(ctx context.Context, filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error)
 }
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"wallet-service/internal/model"
+)
+
+const auditColumns = "id, action, actor, request_id, client_ip, COALESCE(user_id, 0), COALESCE(currency, ''), balance_before, balance_after, details, created_at"
+
+func (r *PostgresRepository) InsertAuditEntry(ctx context.Context, entry model.AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	if entry.Details == nil {
+		details = []byte("{}")
+	}
+	query := `INSERT INTO audit_log (action, actor, request_id, client_ip, user_id, currency, balance_before, balance_after, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = r.db.ExecContext(ctx, query, entry.Action, entry.Actor, entry.RequestID, entry.ClientIP, nullableID(entry.UserID),
+		nullableString(entry.Currency), nullableDecimal(entry.BalanceBefore), nullableDecimal(entry.BalanceAfter), string(details), entry.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) ListAuditEntries(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	var query strings.Builder
+	var args []interface{}
+	// where 追加一个查询条件，cond中的?替换为参数占位符
+	where := func(cond string, v interface{}) {
+		args = append(args, v)
+		if len(args) == 1 {
+			query.WriteString(" WHERE ")
+		} else {
+			query.WriteString(" AND ")
+		}
+		query.WriteString(strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	query.WriteString("SELECT " + auditColumns + " FROM audit_log")
+	if filter.Actor != "" {
+		where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		where("request_id = ?", filter.RequestID)
+	}
+	if filter.UserID > 0 {
+		where("user_id = ?", filter.UserID)
+	}
+	if filter.From != nil {
+		where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < ?", *filter.To)
+	}
+	if filter.BeforeID > 0 {
+		where("id < ?", filter.BeforeID)
+	}
+	query.WriteString(" ORDER BY id DESC")
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		var entry model.AuditEntry
+		var details []byte
+		err := rows.Scan(&entry.ID, &entry.Action, &entry.Actor, &entry.RequestID, &entry.ClientIP, &entry.UserID, &entry.Currency,
+			&entry.BalanceBefore, &entry.BalanceAfter, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, err
+			}
+		}
+		if len(entry.Details) == 0 {
+			entry.Details = nil
+		}
+		if entry.BalanceBefore != nil {
+			*entry.BalanceBefore = model.NormalizeAmount(*entry.BalanceBefore, entry.Currency)
+		}
+		if entry.BalanceAfter != nil {
+			*entry.BalanceAfter = model.NormalizeAmount(*entry.BalanceAfter, entry.Currency)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+)
+
+func (r *PostgresRepository) GetLastTransactionHash(ctx context.Context, userID int, currency string) (string, error) {
+	query := "SELECT hash FROM transactions WHERE user_id = $1 AND currency = $2 ORDER BY id DESC LIMIT 1"
+	var hash string
+	err := r.db.QueryRowContext(ctx, query, userID, currency).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+func (r *PostgresRepository) ListTransactionsAfter(ctx context.Context, afterID, limit int) ([]model.Transaction, error) {
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE id > $1 ORDER BY id LIMIT $2"
+	return r.queryTransactions(ctx, query, afterID, limit)
+}
+
+func (r *PostgresRepository) GetLastTransactionIDBefore(ctx context.Context, before time.Time) (int, error) {
+	query := "SELECT COALESCE(MAX(id), 0) FROM transactions WHERE transaction_time < $1"
+	var id int
+	err := r.db.QueryRowContext(ctx, query, before).Scan(&id)
+	return id, err
+}
+
+func (r *PostgresRepository) ListChainHeads(ctx context.Context, uptoID int) ([]model.ChainHead, error) {
+	query := `SELECT DISTINCT ON (user_id, currency) user_id, currency, id, hash FROM transactions
+		WHERE id <= $1 AND hash <> '' ORDER BY user_id, currency, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, uptoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heads []model.ChainHead
+	for rows.Next() {
+		var head model.ChainHead
+		if err := rows.Scan(&head.UserID, &head.Currency, &head.TransactionID, &head.Hash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+const checkpointColumns = "id, last_transaction_id, heads_digest, key_id, signature, created_at"
+
+func (r *PostgresRepository) InsertLedgerCheckpoint(ctx context.Context, checkpoint model.LedgerCheckpoint) (int, error) {
+	query := `INSERT INTO ledger_checkpoints (last_transaction_id, heads_digest, key_id, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query, checkpoint.LastTransactionID, checkpoint.HeadsDigest, checkpoint.KeyID,
+		checkpoint.Signature, checkpoint.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *PostgresRepository) ListLedgerCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error) {
+	query := "SELECT " + checkpointColumns + " FROM ledger_checkpoints ORDER BY last_transaction_id, id"
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []model.LedgerCheckpoint
+	for rows.Next() {
+		var c model.LedgerCheckpoint
+		if err := rows.Scan(&c.ID, &c.LastTransactionID, &c.HeadsDigest, &c.KeyID, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
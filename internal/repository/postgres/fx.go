@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+func (r *PostgresRepository) InsertFXQuote(ctx context.Context, quote model.FXQuote) error {
+	query := `INSERT INTO fx_quotes (id, from_currency, to_currency, rate, spread, effective_rate, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := r.db.ExecContext(ctx, query, quote.ID, quote.From, quote.To, quote.Rate, quote.Spread, quote.EffectiveRate, quote.CreatedAt, quote.ExpiresAt)
+	return err
+}
+
+func (r *PostgresRepository) GetFXQuoteForUpdate(ctx context.Context, id string) (*model.FXQuote, error) {
+	query := `SELECT id, from_currency, to_currency, rate, spread, effective_rate, created_at, expires_at, used_at
+		FROM fx_quotes WHERE id = $1 FOR UPDATE`
+	var quote model.FXQuote
+	var usedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&quote.ID, &quote.From, &quote.To, &quote.Rate, &quote.Spread,
+		&quote.EffectiveRate, &quote.CreatedAt, &quote.ExpiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, _interface.ErrFXQuoteNotFound
+		}
+		return nil, err
+	}
+	if usedAt.Valid {
+		quote.UsedAt = &usedAt.Time
+	}
+	return &quote, nil
+}
+
+func (r *PostgresRepository) MarkFXQuoteUsed(ctx context.Context, id string, usedAt time.Time) error {
+	query := "UPDATE fx_quotes SET used_at = $1 WHERE id = $2 AND used_at IS NULL"
+	_, err := r.db.ExecContext(ctx, query, usedAt, id)
+	return err
+}
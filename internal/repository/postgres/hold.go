@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+const holdColumns = "id, user_id, currency, amount, COALESCE(payee_user_id, 0), status, captured_amount, COALESCE(entry_id, 0), created_at, expires_at, updated_at"
+
+func (r *PostgresRepository) InsertHold(ctx context.Context, hold model.Hold) (int, error) {
+	query := `INSERT INTO holds (user_id, currency, amount, payee_user_id, status, captured_amount, created_at, expires_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	var id int
+	err := r.db.QueryRowContext(ctx, query, hold.UserID, hold.Currency, hold.Amount, nullableID(hold.PayeeUserID), string(hold.Status),
+		hold.CapturedAmount, hold.CreatedAt, hold.ExpiresAt, hold.UpdatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *PostgresRepository) GetHold(ctx context.Context, id int) (*model.Hold, error) {
+	query := "SELECT " + holdColumns + " FROM holds WHERE id = $1"
+	return r.scanHold(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresRepository) GetHoldForUpdate(ctx context.Context, id int) (*model.Hold, error) {
+	query := "SELECT " + holdColumns + " FROM holds WHERE id = $1 FOR UPDATE"
+	return r.scanHold(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresRepository) scanHold(row *sql.Row) (*model.Hold, error) {
+	var hold model.Hold
+	var status string
+	err := row.Scan(&hold.ID, &hold.UserID, &hold.Currency, &hold.Amount, &hold.PayeeUserID, &status,
+		&hold.CapturedAmount, &hold.EntryID, &hold.CreatedAt, &hold.ExpiresAt, &hold.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, _interface.ErrHoldNotFound
+		}
+		return nil, err
+	}
+	hold.Status = model.HoldStatus(status)
+	hold.Amount = model.NormalizeAmount(hold.Amount, hold.Currency)
+	hold.CapturedAmount = model.NormalizeAmount(hold.CapturedAmount, hold.Currency)
+	return &hold, nil
+}
+
+func (r *PostgresRepository) UpdateHold(ctx context.Context, hold model.Hold) error {
+	query := "UPDATE holds SET status = $1, captured_amount = $2, entry_id = $3, updated_at = $4 WHERE id = $5"
+	_, err := r.db.ExecContext(ctx, query, string(hold.Status), hold.CapturedAmount, nullableID(hold.EntryID), hold.UpdatedAt, hold.ID)
+	return err
+}
+
+func (r *PostgresRepository) SumActiveHolds(ctx context.Context, userID int, currency string, now time.Time) (decimal.Decimal, error) {
+	query := `SELECT COALESCE(SUM(amount), 0) FROM holds
+		WHERE user_id = $1 AND currency = $2 AND status = 'authorized' AND expires_at > $3`
+	var total decimal.Decimal
+	if err := r.db.QueryRowContext(ctx, query, userID, currency, now).Scan(&total); err != nil {
+		return decimal.Zero, err
+	}
+	return model.NormalizeAmount(total, currency), nil
+}
+
+func (r *PostgresRepository) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	query := "UPDATE holds SET status = 'expired', updated_at = $1 WHERE status = 'authorized' AND expires_at <= $1"
+	result, err := r.db.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
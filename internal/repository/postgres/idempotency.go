@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+func NewPostgresIdempotencyRepository(db *sql.DB) _interface.IdempotencyRepository {
+	return &PostgresRepository{db: db, conn: db}
+}
+
+func (r *PostgresRepository) ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (bool, error) {
+	// 已过期的旧记录直接被新请求覆盖
+	query := `INSERT INTO idempotency_keys (key, request_hash, status_code, content_type, response_body, created_at, expires_at)
+		VALUES ($1, $2, 0, '', NULL, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = 0, content_type = '',
+			response_body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`
+	result, err := r.db.ExecContext(ctx, query, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *PostgresRepository) GetIdempotencyRecord(ctx context.Context, key string) (*model.IdempotencyRecord, error) {
+	query := "SELECT key, request_hash, status_code, content_type, response_body, created_at, expires_at FROM idempotency_keys WHERE key = $1"
+	var record model.IdempotencyRecord
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&record.Key, &record.RequestHash, &record.StatusCode,
+		&record.ContentType, &record.ResponseBody, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *PostgresRepository) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	query := "UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3 WHERE key = $4"
+	_, err := r.db.ExecContext(ctx, query, statusCode, contentType, body, key)
+	return err
+}
+
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE key = $1 AND status_code = 0"
+	_, err := r.db.ExecContext(ctx, query, key)
+	return err
+}
+
+func (r *PostgresRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"wallet-service/internal/model"
+)
+
+func (r *PostgresRepository) InsertJournalEntry(ctx context.Context, entry model.JournalEntry) (int, error) {
+	query := "INSERT INTO journal_entries (entry_type, description, created_at, fx_quote_id, fx_rate, fx_spread) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+	var fxQuoteID, fxRate, fxSpread interface{}
+	if entry.FX != nil {
+		fxQuoteID, fxRate, fxSpread = nullableString(entry.FX.QuoteID), entry.FX.Rate, entry.FX.Spread
+	}
+	var entryID int
+	err := r.db.QueryRowContext(ctx, query, entry.EntryType, entry.Description, entry.CreatedAt, fxQuoteID, fxRate, fxSpread).Scan(&entryID)
+	if err != nil {
+		return 0, err
+	}
+
+	postingQuery := "INSERT INTO postings (entry_id, account, currency, direction, amount) VALUES ($1, $2, $3, $4, $5)"
+	for _, posting := range entry.Postings {
+		_, err = r.db.ExecContext(ctx, postingQuery, entryID, posting.Account, posting.Currency, string(posting.Direction), posting.Amount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return entryID, nil
+}
+
+func (r *PostgresRepository) GetTrialBalance(ctx context.Context) ([]model.TrialBalance, error) {
+	query := `SELECT currency, COALESCE(SUM(amount) FILTER (WHERE direction = 'debit'), 0),
+		COALESCE(SUM(amount) FILTER (WHERE direction = 'credit'), 0)
+		FROM postings GROUP BY currency ORDER BY currency`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []model.TrialBalance
+	for rows.Next() {
+		var tb model.TrialBalance
+		if err := rows.Scan(&tb.Currency, &tb.TotalDebits, &tb.TotalCredits); err != nil {
+			return nil, err
+		}
+		balances = append(balances, tb)
+	}
+	return balances, rows.Err()
+}
+
+func (r *PostgresRepository) ListLedgerMismatches(ctx context.Context) ([]model.LedgerMismatch, error) {
+	query := `SELECT w.user_id, w.currency, w.balance, COALESCE(l.balance, 0)
+		FROM wallets w
+		LEFT JOIN (
+			SELECT account, currency, SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END) AS balance
+			FROM postings GROUP BY account, currency
+		) l ON l.account = 'wallet:' || w.user_id || ':' || w.currency AND l.currency = w.currency
+		WHERE w.balance <> COALESCE(l.balance, 0)
+		ORDER BY w.user_id, w.currency`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []model.LedgerMismatch
+	for rows.Next() {
+		var mismatch model.LedgerMismatch
+		if err := rows.Scan(&mismatch.UserID, &mismatch.Currency, &mismatch.WalletBalance, &mismatch.LedgerBalance); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches, rows.Err()
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+func (r *PostgresRepository) GetWalletLimits(ctx context.Context, userID int, currency string) (*model.WalletLimits, error) {
+	query := `SELECT max_single_withdrawal, daily_outgoing, monthly_outgoing, max_balance, max_transfers_per_hour
+		FROM wallet_limits WHERE user_id = $1 AND currency = $2`
+	var limits model.WalletLimits
+	var transfers sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, userID, currency).Scan(&limits.MaxSingleWithdrawal, &limits.DailyOutgoing,
+		&limits.MonthlyOutgoing, &limits.MaxBalance, &transfers)
+	if err == sql.ErrNoRows {
+		return &limits, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, amount := range []*decimal.Decimal{limits.MaxSingleWithdrawal, limits.DailyOutgoing, limits.MonthlyOutgoing, limits.MaxBalance} {
+		if amount != nil {
+			*amount = model.NormalizeAmount(*amount, currency)
+		}
+	}
+	if transfers.Valid {
+		n := int(transfers.Int64)
+		limits.MaxTransfersPerHour = &n
+	}
+	return &limits, nil
+}
+
+func (r *PostgresRepository) UpsertWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) error {
+	query := `INSERT INTO wallet_limits (user_id, currency, max_single_withdrawal, daily_outgoing, monthly_outgoing, max_balance, max_transfers_per_hour, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, currency) DO UPDATE SET max_single_withdrawal = EXCLUDED.max_single_withdrawal,
+			daily_outgoing = EXCLUDED.daily_outgoing, monthly_outgoing = EXCLUDED.monthly_outgoing, max_balance = EXCLUDED.max_balance,
+			max_transfers_per_hour = EXCLUDED.max_transfers_per_hour, updated_at = EXCLUDED.updated_at`
+	var transfers interface{}
+	if limits.MaxTransfersPerHour != nil {
+		transfers = *limits.MaxTransfersPerHour
+	}
+	_, err := r.db.ExecContext(ctx, query, userID, currency, nullableDecimal(limits.MaxSingleWithdrawal), nullableDecimal(limits.DailyOutgoing),
+		nullableDecimal(limits.MonthlyOutgoing), nullableDecimal(limits.MaxBalance), transfers, time.Now())
+	return err
+}
+
+func (r *PostgresRepository) GetOutgoingUsage(ctx context.Context, userID int, currency string, now time.Time) (*model.OutgoingUsage, error) {
+	day, month, hour := model.LimitWindows(now)
+	// 每月第一个小时内，滚动一小时窗口早于当月起点
+	since := month
+	if hour.Before(since) {
+		since = hour
+	}
+	query := `SELECT COALESCE(SUM(amount) FILTER (WHERE transaction_time >= $3), 0),
+			COALESCE(SUM(amount) FILTER (WHERE transaction_time >= $4), 0),
+			COUNT(*) FILTER (WHERE transaction_type = 'transfer_out' AND transaction_time >= $5)
+		FROM transactions
+		WHERE user_id = $1 AND currency = $2 AND transaction_type IN ('withdrawal', 'transfer_out', 'fx_out', 'fee') AND transaction_time >= $6`
+	var usage model.OutgoingUsage
+	err := r.db.QueryRowContext(ctx, query, userID, currency, day, month, hour, since).Scan(&usage.DailyOutgoing, &usage.MonthlyOutgoing, &usage.TransfersLastHour)
+	if err != nil {
+		return nil, err
+	}
+	usage.DailyOutgoing = model.NormalizeAmount(usage.DailyOutgoing, currency)
+	usage.MonthlyOutgoing = model.NormalizeAmount(usage.MonthlyOutgoing, currency)
+	return &usage, nil
+}
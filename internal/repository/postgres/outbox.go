@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+func NewPostgresOutboxRepository(db *sql.DB) _interface.OutboxRepository {
+	return &PostgresRepository{db: db, conn: db}
+}
+
+func (r *PostgresRepository) InsertOutboxEvent(ctx context.Context, event model.Event) error {
+	payload := string(event.Payload)
+	if payload == "" {
+		payload = "{}"
+	}
+	query := `INSERT INTO outbox_events (event_type, user_id, currency, counterparty_user_id, actor, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.ExecContext(ctx, query, event.Type, event.UserID, event.Currency, event.CounterpartyUserID, event.Actor, payload, event.OccurredAt)
+	return err
+}
+
+func (r *PostgresRepository) ListUnpublishedEvents(ctx context.Context, limit int) ([]model.Event, error) {
+	query := `SELECT id, event_type, user_id, currency, counterparty_user_id, actor, payload, occurred_at, attempts, last_error
+		FROM outbox_events WHERE published_at IS NULL ORDER BY id LIMIT $1`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		var event model.Event
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.Currency, &event.CounterpartyUserID, &event.Actor, &payload,
+			&event.OccurredAt, &event.Attempts, &event.LastError); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (r *PostgresRepository) MarkEventPublished(ctx context.Context, id int, publishedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE outbox_events SET published_at = $1 WHERE id = $2", publishedAt, id)
+	return err
+}
+
+func (r *PostgresRepository) RecordEventFailure(ctx context.Context, id int, message string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2", message, id)
+	return err
+}
@@ -3,52 +3,224 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 	"wallet-service/internal/model"
 	_interface "wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+
+	"github.com/lib/pq"
 )
 
+// dbExecutor 抽象了*sql.DB与*sql.Tx共有的查询方法，使同一套SQL既能在事务外也能在事务内执行
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// uniqueViolation 是PostgreSQL唯一约束冲突的错误码
+const uniqueViolation = "23505"
+
 type PostgresRepository struct {
-	db *sql.DB
+	db dbExecutor
+	// conn 为连接池，仅在事务外的仓库实例上非nil，用于开启新事务
+	conn *sql.DB
 }
 
 func NewPostgresRepository(db *sql.DB) _interface.WalletRepository {
-	return &PostgresRepository{db: db}
+	return &PostgresRepository{db: db, conn: db}
 }
 
-func (r *PostgresRepository) GetWallet(ctx context.Context, userID int) (*model.Wallet, error) {
-	query := "SELECT user_id, balance, last_updated FROM wallets WHERE user_id = $1"
-	row := r.db.QueryRowContext(ctx, query, userID)
+// WithTx 开启事务并把绑定该事务的仓库交给fn，fn出错或panic时回滚，否则提交
+func (r *PostgresRepository) WithTx(ctx context.Context, fn func(repo _interface.WalletRepository) error) (err error) {
+	if r.conn == nil {
+		// 已处于事务中，直接复用
+		return fn(r)
+	}
 
-	var wallet model.Wallet
-	err := row.Scan(&wallet.UserID, &wallet.Balance, &wallet.LastUpdated)
+	tx, err := r.conn.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("rollback transaction failed: %v", rbErr)
+			}
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("commit transaction: %w", err)
 		}
+	}()
+
+	return fn(&PostgresRepository{db: tx})
+}
+
+const walletColumns = "user_id, currency, balance, status, last_updated, owner_metadata"
+
+func (r *PostgresRepository) GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	query := "SELECT " + walletColumns + " FROM wallets WHERE user_id = $1 AND currency = $2"
+	return r.scanWallet(r.db.QueryRowContext(ctx, query, userID, currency))
+}
+
+func (r *PostgresRepository) GetWalletForUpdate(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	query := "SELECT " + walletColumns + " FROM wallets WHERE user_id = $1 AND currency = $2 FOR UPDATE"
+	return r.scanWallet(r.db.QueryRowContext(ctx, query, userID, currency))
+}
+
+func (r *PostgresRepository) ListWallets(ctx context.Context, userID int) ([]model.Wallet, error) {
+	query := "SELECT " + walletColumns + " FROM wallets WHERE user_id = $1 ORDER BY currency"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var wallets []model.Wallet
+	for rows.Next() {
+		wallet, err := scanWalletRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, *wallet)
+	}
+	return wallets, rows.Err()
+}
+
+func (r *PostgresRepository) scanWallet(row *sql.Row) (*model.Wallet, error) {
+	wallet, err := scanWalletRow(row)
+	if err == sql.ErrNoRows {
+		return nil, _interface.ErrWalletNotFound
+	}
+	return wallet, err
+}
+
+// scanWalletRow 按walletColumns的顺序读取一行钱包
+func scanWalletRow(row interface {
+	Scan(dest ...interface{}) error
+}) (*model.Wallet, error) {
+	var wallet model.Wallet
+	var metadata []byte
+	if err := row.Scan(&wallet.UserID, &wallet.Currency, &wallet.Balance, &wallet.Status, &wallet.LastUpdated, &metadata); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &wallet.OwnerMetadata); err != nil {
+			return nil, err
+		}
+	}
+	if len(wallet.OwnerMetadata) == 0 {
+		wallet.OwnerMetadata = nil
+	}
+	// 数据库按最大精度存储，读出后调整为币种的标准小数位数
+	wallet.Balance = model.NormalizeAmount(wallet.Balance, wallet.Currency)
 
 	return &wallet, nil
 }
 
-func (p *PostgresRepository) UpdateWalletBalance(ctx context.Context, userID int, amount float64) error {
-	sql := "UPDATE wallets SET balance = balance + $1, last_updated = $2 WHERE user_id = $3"
-	log.Printf("Actual parameters: amount=%v, time=%v, userID=%d", amount, time.Now(), userID) // 添加日志打印
-	_, err := p.db.ExecContext(ctx, sql, amount, time.Now(), userID)
+func (p *PostgresRepository) UpdateWalletBalance(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+	sql := "UPDATE wallets SET balance = balance + $1, last_updated = $2 WHERE user_id = $3 AND currency = $4"
+	_, err := p.db.ExecContext(ctx, sql, amount, time.Now(), userID, currency)
 	return err
 }
 
+const transactionColumns = "id, user_id, currency, transaction_type, amount, transaction_time, COALESCE(entry_id, 0), COALESCE(reversal_of, 0), reason, actor, prev_hash, hash"
+
 func (r *PostgresRepository) InsertTransaction(ctx context.Context, transaction model.Transaction) error {
-	query := "INSERT INTO transactions (user_id, transaction_type, amount, transaction_time) VALUES ($1, $2, $3, $4)"
-	_, err := r.db.ExecContext(ctx, query, transaction.UserID, transaction.TransactionType, transaction.Amount, transaction.TransactionTime)
+	query := `INSERT INTO transactions (user_id, currency, transaction_type, amount, transaction_time, entry_id, reversal_of, reason, actor, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.db.ExecContext(ctx, query, transaction.UserID, transaction.Currency, transaction.TransactionType, transaction.Amount, transaction.TransactionTime,
+		nullableID(transaction.EntryID), nullableID(transaction.ReversalOf), transaction.Reason, transaction.Actor, transaction.PrevHash, transaction.Hash)
 	return err
 }
 
-func (r *PostgresRepository) GetTransactionHistory(ctx context.Context, userID int) ([]model.Transaction, error) {
-	query := "SELECT id, user_id, transaction_type, amount, transaction_time FROM transactions WHERE user_id = $1 ORDER BY transaction_time DESC"
-	rows, err := r.db.QueryContext(ctx, query, userID)
+func (r *PostgresRepository) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
+	var query strings.Builder
+	args := []interface{}{userID, currency}
+	// arg 追加一个查询参数并返回其占位符
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	query.WriteString("SELECT " + transactionColumns + " FROM transactions WHERE user_id = $1 AND currency = $2")
+	if len(filter.Types) > 0 {
+		query.WriteString(" AND transaction_type = ANY(" + arg(pq.Array(filter.Types)) + ")")
+	}
+	if filter.MinAmount != nil {
+		query.WriteString(" AND amount >= " + arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		query.WriteString(" AND amount <= " + arg(*filter.MaxAmount))
+	}
+	if filter.From != nil {
+		query.WriteString(" AND transaction_time >= " + arg(*filter.From))
+	}
+	if filter.To != nil {
+		query.WriteString(" AND transaction_time < " + arg(*filter.To))
+	}
+	comparison, order := "<", "DESC"
+	if filter.Ascending {
+		comparison, order = ">", "ASC"
+	}
+	if filter.Cursor != nil {
+		// 行比较与排序方向一致，可以直接使用(user_id, currency, transaction_time, id)索引
+		query.WriteString(" AND (transaction_time, id) " + comparison + " (" + arg(filter.Cursor.Time) + ", " + arg(filter.Cursor.ID) + ")")
+	}
+	query.WriteString(" ORDER BY transaction_time " + order + ", id " + order)
+	if filter.Limit > 0 {
+		query.WriteString(" LIMIT " + arg(filter.Limit))
+	}
+	return r.queryTransactions(ctx, query.String(), args...)
+}
+
+func (r *PostgresRepository) GetTransaction(ctx context.Context, id int) (*model.Transaction, error) {
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE id = $1"
+	return r.scanTransaction(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresRepository) GetTransactionForUpdate(ctx context.Context, id int) (*model.Transaction, error) {
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE id = $1 FOR UPDATE"
+	return r.scanTransaction(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *PostgresRepository) ListTransactionsByEntry(ctx context.Context, entryID int) ([]model.Transaction, error) {
+	query := "SELECT " + transactionColumns + " FROM transactions WHERE entry_id = $1 ORDER BY id"
+	return r.queryTransactions(ctx, query, entryID)
+}
+
+func (r *PostgresRepository) GetBalanceBefore(ctx context.Context, userID int, currency string, before time.Time) (decimal.Decimal, error) {
+	query := `SELECT COALESCE(SUM(CASE WHEN transaction_type = ANY($4) THEN amount ELSE -amount END), 0) FROM transactions
+		WHERE user_id = $1 AND currency = $2 AND transaction_time < $3`
+	var balance decimal.Decimal
+	if err := r.db.QueryRowContext(ctx, query, userID, currency, before, pq.Array(model.CreditTransactionTypes())).Scan(&balance); err != nil {
+		return decimal.Zero, err
+	}
+	return model.NormalizeAmount(balance, currency), nil
+}
+
+func (r *PostgresRepository) SumReversals(ctx context.Context, id int) (decimal.Decimal, error) {
+	query := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE reversal_of = $1"
+	var total decimal.Decimal
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&total); err != nil {
+		return decimal.Zero, err
+	}
+	return total, nil
+}
+
+func (r *PostgresRepository) queryTransactions(ctx context.Context, query string, args ...interface{}) ([]model.Transaction, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -57,18 +229,86 @@ func (r *PostgresRepository) GetTransactionHistory(ctx context.Context, userID i
 	var history []model.Transaction
 	for rows.Next() {
 		var transaction model.Transaction
-		err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.TransactionType, &transaction.Amount, &transaction.TransactionTime)
+		err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.Currency, &transaction.TransactionType, &transaction.Amount,
+			&transaction.TransactionTime, &transaction.EntryID, &transaction.ReversalOf, &transaction.Reason, &transaction.Actor,
+			&transaction.PrevHash, &transaction.Hash)
 		if err != nil {
 			return nil, err
 		}
+		transaction.Amount = model.NormalizeAmount(transaction.Amount, transaction.Currency)
 		history = append(history, transaction)
 	}
 
-	return history, nil
+	return history, rows.Err()
+}
+
+func (r *PostgresRepository) scanTransaction(row *sql.Row) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := row.Scan(&transaction.ID, &transaction.UserID, &transaction.Currency, &transaction.TransactionType, &transaction.Amount,
+		&transaction.TransactionTime, &transaction.EntryID, &transaction.ReversalOf, &transaction.Reason, &transaction.Actor,
+		&transaction.PrevHash, &transaction.Hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, _interface.ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	transaction.Amount = model.NormalizeAmount(transaction.Amount, transaction.Currency)
+	return &transaction, nil
 }
 
 func (p *PostgresRepository) InsertWallet(ctx context.Context, wallet model.Wallet) error {
-	sql := "INSERT INTO wallets (user_id, balance, last_updated) VALUES ($1, $2, $3)"
-	_, err := p.db.ExecContext(ctx, sql, wallet.UserID, wallet.Balance, wallet.LastUpdated)
+	if wallet.Status == "" {
+		wallet.Status = model.WalletActive
+	}
+	metadata, err := json.Marshal(wallet.OwnerMetadata)
+	if err != nil {
+		return err
+	}
+	if wallet.OwnerMetadata == nil {
+		metadata = []byte("{}")
+	}
+	sql := "INSERT INTO wallets (user_id, currency, balance, status, last_updated, owner_metadata) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err = p.db.ExecContext(ctx, sql, wallet.UserID, wallet.Currency, wallet.Balance, string(wallet.Status), wallet.LastUpdated, metadata)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return _interface.ErrWalletExists
+	}
 	return err
 }
+
+func (p *PostgresRepository) UpdateWalletStatus(ctx context.Context, userID int, currency string, status model.WalletStatus) error {
+	sql := "UPDATE wallets SET status = $1, last_updated = $2 WHERE user_id = $3 AND currency = $4"
+	result, err := p.db.ExecContext(ctx, sql, string(status), time.Now(), userID, currency)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return _interface.ErrWalletNotFound
+	}
+	return err
+}
+
+// nullableID 将未设置（为0）的外键ID转换为NULL
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullableString 将空字符串转换为NULL
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullableDecimal 将nil金额转换为NULL
+func nullableDecimal(d *decimal.Decimal) interface{} {
+	if d == nil {
+		return nil
+	}
+	return *d
+}
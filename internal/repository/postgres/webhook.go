@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+func NewPostgresWebhookRepository(db *sql.DB) _interface.WebhookRepository {
+	return &PostgresRepository{db: db, conn: db}
+}
+
+const webhookEndpointColumns = "id, url, event_types, description, owner, scope, owner_user_id, secret, active, created_at"
+
+const webhookDeliveryColumns = "id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+func (r *PostgresRepository) InsertWebhookEndpoint(ctx context.Context, endpoint model.WebhookEndpoint) (int, error) {
+	eventTypes, err := json.Marshal(endpoint.EventTypes)
+	if err != nil {
+		return 0, err
+	}
+	if endpoint.EventTypes == nil {
+		eventTypes = []byte("[]")
+	}
+	query := `INSERT INTO webhook_endpoints (url, event_types, description, owner, scope, owner_user_id, secret, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	var id int
+	err = r.db.QueryRowContext(ctx, query, endpoint.URL, string(eventTypes), endpoint.Description, endpoint.Owner,
+		string(endpoint.Scope), endpoint.OwnerUserID, endpoint.Secret, endpoint.Active, endpoint.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (r *PostgresRepository) GetWebhookEndpoint(ctx context.Context, id int) (*model.WebhookEndpoint, error) {
+	query := "SELECT " + webhookEndpointColumns + " FROM webhook_endpoints WHERE id = $1"
+	endpoint, err := scanWebhookEndpoint(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, _interface.ErrWebhookEndpointNotFound
+	}
+	return endpoint, err
+}
+
+func (r *PostgresRepository) ListWebhookEndpoints(ctx context.Context, owner string) ([]model.WebhookEndpoint, error) {
+	query := "SELECT " + webhookEndpointColumns + " FROM webhook_endpoints"
+	var args []interface{}
+	if owner != "" {
+		query += " WHERE owner = $1"
+		args = append(args, owner)
+	}
+	rows, err := r.db.QueryContext(ctx, query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []model.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *PostgresRepository) DisableWebhookEndpoint(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE webhook_endpoints SET active = FALSE WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return _interface.ErrWebhookEndpointNotFound
+	}
+	return err
+}
+
+// scanWebhookEndpoint 按webhookEndpointColumns的顺序读取一行推送地址
+func scanWebhookEndpoint(row interface {
+	Scan(dest ...interface{}) error
+}) (*model.WebhookEndpoint, error) {
+	var endpoint model.WebhookEndpoint
+	var eventTypes []byte
+	var scope string
+	err := row.Scan(&endpoint.ID, &endpoint.URL, &eventTypes, &endpoint.Description, &endpoint.Owner,
+		&scope, &endpoint.OwnerUserID, &endpoint.Secret, &endpoint.Active, &endpoint.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(eventTypes, &endpoint.EventTypes); err != nil {
+		return nil, err
+	}
+	endpoint.Scope = model.WebhookScope(scope)
+	return &endpoint, nil
+}
+
+func (r *PostgresRepository) InsertWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (endpoint_id, event_id) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, delivery.EndpointID, delivery.EventID, delivery.EventType, string(delivery.Payload),
+		string(delivery.Status), delivery.NextAttemptAt, delivery.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) ClaimDueWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]model.WebhookDelivery, error) {
+	// SKIP LOCKED 使多个实例同时领取时互不阻塞，也不会领到同一条推送
+	query := `UPDATE webhook_deliveries SET next_attempt_at = $1
+		WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= $2
+			ORDER BY next_attempt_at, id LIMIT $3 FOR UPDATE SKIP LOCKED)
+		RETURNING ` + webhookDeliveryColumns
+	return r.queryWebhookDeliveries(ctx, query, leaseUntil, now, limit)
+}
+
+func (r *PostgresRepository) UpdateWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6
+		WHERE id = $7`
+	_, err := r.db.ExecContext(ctx, query, string(delivery.Status), delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode,
+		delivery.LastError, delivery.DeliveredAt, delivery.ID)
+	return err
+}
+
+func (r *PostgresRepository) GetWebhookDelivery(ctx context.Context, id int) (*model.WebhookDelivery, error) {
+	deliveries, err := r.queryWebhookDeliveries(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, _interface.ErrWebhookDeliveryNotFound
+	}
+	return &deliveries[0], nil
+}
+
+func (r *PostgresRepository) ListWebhookDeliveries(ctx context.Context, filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	var conds []string
+	var args []interface{}
+	if filter.EndpointID > 0 {
+		args = append(args, filter.EndpointID)
+		conds = append(conds, "endpoint_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conds = append(conds, "status = $"+strconv.Itoa(len(args)))
+	}
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	return r.queryWebhookDeliveries(ctx, query, args...)
+}
+
+// queryWebhookDeliveries 执行返回webhookDeliveryColumns的查询
+func (r *PostgresRepository) queryWebhookDeliveries(ctx context.Context, query string, args ...interface{}) ([]model.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+		var payload []byte
+		var status string
+		var deliveredAt sql.NullTime
+		err := rows.Scan(&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &payload, &status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &deliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = payload
+		delivery.Status = model.WebhookDeliveryStatus(status)
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
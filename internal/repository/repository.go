@@ -9,3 +9,15 @@ import (
 func NewRepository(db *sql.DB) _interface.WalletRepository {
 	return postgres.NewPostgresRepository(db)
 }
+
+func NewIdempotencyRepository(db *sql.DB) _interface.IdempotencyRepository {
+	return postgres.NewPostgresIdempotencyRepository(db)
+}
+
+func NewOutboxRepository(db *sql.DB) _interface.OutboxRepository {
+	return postgres.NewPostgresOutboxRepository(db)
+}
+
+func NewWebhookRepository(db *sql.DB) _interface.WebhookRepository {
+	return postgres.NewPostgresWebhookRepository(db)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// Adjust 人工调账：amount为正时由挂账账户贷记钱包，为负时从钱包扣回挂账账户，生成adjustment凭证
+// 与adjustment_credit/adjustment_debit交易。调账用于纠正错误，冻结的钱包同样允许；扣减仍以可用余额为限
+func (s *walletServiceImpl) Adjust(ctx context.Context, userID int, currency string, amount decimal.Decimal, reason string) (*model.Transaction, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: adjustment of user ID %d", ErrReasonRequired, userID)
+	}
+	normalized, err := validateAmount(currency, amount.Abs())
+	if err != nil {
+		logrus.Errorf("Invalid adjustment amount: %s %s for user ID: %d", amount, currency, userID)
+		return nil, fmt.Errorf("Invalid adjustment amount: %w", err)
+	}
+	signed := normalized
+	if amount.IsNegative() {
+		signed = normalized.Neg()
+	}
+
+	transaction := model.Transaction{
+		UserID:          userID,
+		Currency:        currency,
+		TransactionType: "adjustment_credit",
+		Amount:          normalized,
+		Reason:          reason,
+	}
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		key := walletKey{UserID: userID, Currency: currency}
+		wallets, err := lockWallets(ctx, repo, key)
+		if err != nil {
+			return err
+		}
+		wallet := wallets[key]
+		if wallet == nil {
+			return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+		}
+		if err := ensureOpen(wallet); err != nil {
+			return err
+		}
+
+		walletAccount := model.WalletAccount(userID, currency)
+		postings := []model.Posting{debit(model.AccountSystemSuspense, currency, normalized), credit(walletAccount, currency, normalized)}
+		if signed.IsNegative() {
+			available, err := availableBalance(ctx, repo, wallet)
+			if err != nil {
+				return err
+			}
+			if available.LessThan(normalized) {
+				logrus.Errorf("Insufficient balance to adjust user ID %d. Available balance: %s, Adjustment amount: %s", userID, available, signed)
+				return fmt.Errorf("%w: user ID %d", ErrInsufficientFunds, userID)
+			}
+			transaction.TransactionType = "adjustment_debit"
+			postings = []model.Posting{debit(walletAccount, currency, normalized), credit(model.AccountSystemSuspense, currency, normalized)}
+		} else if err := checkBalanceCeiling(userID, currency, wallet.Balance, normalized); err != nil {
+			return err
+		}
+
+		if err := repo.UpdateWalletBalance(ctx, userID, currency, signed); err != nil {
+			logrus.Errorf("Error updating wallet balance during adjustment for user ID %d: %v", userID, err)
+			return err
+		}
+		entryID, err := s.postEntry(ctx, repo, "adjustment", fmt.Sprintf("adjustment of user %d: %s", userID, reason), postings...)
+		if err != nil {
+			return err
+		}
+
+		transaction.EntryID = entryID
+		transaction.TransactionTime = time.Now()
+		transaction.Actor = actor(ctx)
+		if err := repo.InsertTransaction(ctx, transaction); err != nil {
+			logrus.Errorf("Error inserting %s transaction for user ID %d: %v", transaction.TransactionType, userID, err)
+			return err
+		}
+		return recordEvent(ctx, repo, model.EventFundsAdjusted, userID, currency, model.FundsEvent{
+			EntryID:      entryID,
+			Amount:       signed,
+			BalanceAfter: wallet.Balance.Add(signed),
+			Reason:       reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Adjustment posted for user ID %d. Amount: %s %s, reason: %s", userID, signed, currency, reason)
+	return &transaction, nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+	"wallet-service/internal/audit"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// auditAction 是正在执行的服务操作，由authorizedService放入context，auditRepository据此生成审计记录
+type auditAction struct {
+	name    string
+	details map[string]string
+}
+
+// auditActionKey 是auditAction在context中的键
+type auditActionKey struct{}
+
+// withAuditAction 返回标记了操作名的context，kv为成对的键与值，会记录在该操作产生的每条审计记录上，值为空的键被忽略
+func withAuditAction(ctx context.Context, name string, kv ...string) context.Context {
+	action := auditAction{name: name, details: make(map[string]string, len(kv)/2)}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			action.details[kv[i]] = kv[i+1]
+		}
+	}
+	return context.WithValue(ctx, auditActionKey{}, action)
+}
+
+// newAuditEntry 以context中的调用方、请求元数据与操作名创建审计记录，extra追加到操作的details之后
+func newAuditEntry(ctx context.Context, extra map[string]string) model.AuditEntry {
+	action, ok := ctx.Value(auditActionKey{}).(auditAction)
+	if !ok {
+		action.name = "unspecified"
+	}
+	request := audit.FromContext(ctx)
+	entry := model.AuditEntry{
+		Action:    action.name,
+		Actor:     actor(ctx),
+		RequestID: request.ID,
+		ClientIP:  request.ClientIP,
+		CreatedAt: time.Now(),
+	}
+	if len(action.details)+len(extra) > 0 {
+		entry.Details = make(map[string]string, len(action.details)+len(extra))
+		for k, v := range action.details {
+			entry.Details[k] = v
+		}
+		for k, v := range extra {
+			entry.Details[k] = v
+		}
+	}
+	return entry
+}
+
+// auditRepository 在每次改变钱包或预授权状态时，于同一事务中追加一条审计记录，
+// 审计记录写入失败时整个操作回滚。变更前的状态读自服务已在事务中加锁的行
+type auditRepository struct {
+	_interface.WalletRepository
+}
+
+func (r *auditRepository) WithTx(ctx context.Context, fn func(repo _interface.WalletRepository) error) error {
+	return r.WalletRepository.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		return fn(&auditRepository{WalletRepository: repo})
+	})
+}
+
+func (r *auditRepository) InsertWallet(ctx context.Context, wallet model.Wallet) error {
+	if err := r.WalletRepository.InsertWallet(ctx, wallet); err != nil {
+		return err
+	}
+	entry := newAuditEntry(ctx, map[string]string{"event": "wallet_created"})
+	entry.UserID, entry.Currency = wallet.UserID, wallet.Currency
+	entry.BalanceAfter = &wallet.Balance
+	return r.insert(ctx, entry)
+}
+
+func (r *auditRepository) UpdateWalletBalance(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+	before, _, err := r.walletState(ctx, userID, currency)
+	if err != nil {
+		return err
+	}
+	if err := r.WalletRepository.UpdateWalletBalance(ctx, userID, currency, amount); err != nil {
+		return err
+	}
+	entry := newAuditEntry(ctx, map[string]string{"amount": amount.String()})
+	entry.UserID, entry.Currency = userID, currency
+	if before != nil {
+		after := before.Add(amount)
+		entry.BalanceBefore, entry.BalanceAfter = before, &after
+	}
+	return r.insert(ctx, entry)
+}
+
+// UpsertWalletLimits 记录写入后钱包单独设置的各项限额
+func (r *auditRepository) UpsertWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) error {
+	if err := r.WalletRepository.UpsertWalletLimits(ctx, userID, currency, limits); err != nil {
+		return err
+	}
+	details := map[string]string{"event": "limits_updated"}
+	for name, amount := range limits.Amounts() {
+		if amount != nil {
+			details[name] = amount.String()
+		}
+	}
+	if limits.MaxTransfersPerHour != nil {
+		details["max_transfers_per_hour"] = strconv.Itoa(*limits.MaxTransfersPerHour)
+	}
+	entry := newAuditEntry(ctx, details)
+	entry.UserID, entry.Currency = userID, currency
+	return r.insert(ctx, entry)
+}
+
+func (r *auditRepository) UpdateWalletStatus(ctx context.Context, userID int, currency string, status model.WalletStatus) error {
+	balance, previous, err := r.walletState(ctx, userID, currency)
+	if err != nil {
+		return err
+	}
+	if err := r.WalletRepository.UpdateWalletStatus(ctx, userID, currency, status); err != nil {
+		return err
+	}
+	entry := newAuditEntry(ctx, map[string]string{"status_from": string(previous), "status_to": string(status)})
+	entry.UserID, entry.Currency = userID, currency
+	entry.BalanceBefore, entry.BalanceAfter = balance, balance
+	return r.insert(ctx, entry)
+}
+
+func (r *auditRepository) InsertHold(ctx context.Context, hold model.Hold) (int, error) {
+	id, err := r.WalletRepository.InsertHold(ctx, hold)
+	if err != nil {
+		return 0, err
+	}
+	entry := newAuditEntry(ctx, map[string]string{"hold_id": strconv.Itoa(id), "amount": hold.Amount.String(), "status_to": string(hold.Status)})
+	entry.UserID, entry.Currency = hold.UserID, hold.Currency
+	return id, r.insert(ctx, entry)
+}
+
+// UpdateHold 只在预授权状态变化时记录，请款后回写凭证ID不单独记录
+func (r *auditRepository) UpdateHold(ctx context.Context, hold model.Hold) error {
+	previous, err := r.WalletRepository.GetHold(ctx, hold.ID)
+	if err != nil {
+		return err
+	}
+	previousStatus := previous.Status
+	if err := r.WalletRepository.UpdateHold(ctx, hold); err != nil {
+		return err
+	}
+	if previousStatus == hold.Status {
+		return nil
+	}
+	entry := newAuditEntry(ctx, map[string]string{"hold_id": strconv.Itoa(hold.ID), "status_from": string(previousStatus), "status_to": string(hold.Status)})
+	entry.UserID, entry.Currency = hold.UserID, hold.Currency
+	return r.insert(ctx, entry)
+}
+
+// ExpireHolds 批量更新与审计记录在同一事务中提交，没有到期的预授权时不记录
+func (r *auditRepository) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+	err := r.WalletRepository.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		var err error
+		expired, err = repo.ExpireHolds(ctx, now)
+		if err != nil || expired == 0 {
+			return err
+		}
+		return repo.InsertAuditEntry(ctx, newAuditEntry(ctx, map[string]string{"expired": strconv.FormatInt(expired, 10)}))
+	})
+	return expired, err
+}
+
+// walletState 返回钱包当前的余额与状态，余额以副本返回，不受随后的更新影响
+func (r *auditRepository) walletState(ctx context.Context, userID int, currency string) (*decimal.Decimal, model.WalletStatus, error) {
+	wallet, err := r.WalletRepository.GetWallet(ctx, userID, currency)
+	if err != nil || wallet == nil {
+		return nil, "", err
+	}
+	balance := wallet.Balance
+	return &balance, wallet.Status, nil
+}
+
+func (r *auditRepository) insert(ctx context.Context, entry model.AuditEntry) error {
+	if err := r.WalletRepository.InsertAuditEntry(ctx, entry); err != nil {
+		logrus.Errorf("Error writing audit entry for %s by %s: %v", entry.Action, entry.Actor, err)
+		return err
+	}
+	return nil
+}
+
+// ListAuditLog 分页查询审计日志，从新到旧排列
+func (s *walletServiceImpl) ListAuditLog(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	switch {
+	case filter.Limit < 0 || filter.Limit > MaxHistoryLimit:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFilter, MaxHistoryLimit)
+	case filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To):
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultHistoryLimit
+	}
+
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	entries, err := s.repo.ListAuditEntries(ctx, filter)
+	if err != nil {
+		logrus.Errorf("Error listing audit log: %v", err)
+		return nil, err
+	}
+
+	page := &model.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = model.EncodeAuditCursor(page.Entries[limit-1].ID)
+	}
+	if page.Entries == nil {
+		page.Entries = []model.AuditEntry{}
+	}
+	return page, nil
+}
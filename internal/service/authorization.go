@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// systemActor 是没有调用方的进程内调用（如后台任务）记录的发起方
+const systemActor = "system"
+
+// actor 返回context中调用方的标识，用于记录在交易上
+func actor(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.Subject
+	}
+	return systemActor
+}
+
+// authorize 检查context中的调用方能否以scope权限操作userIDs的全部钱包，未指定钱包时只检查权限范围。
+// context中没有调用方时视为进程内的受信任调用，不做检查
+func authorize(ctx context.Context, scope string, userIDs ...int) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil {
+		return nil
+	}
+	if !principal.HasScope(scope) {
+		return fmt.Errorf("%w: %s requires %s permission", ErrForbidden, principal.Subject, scope)
+	}
+	for _, userID := range userIDs {
+		if !principal.CanAccessWallet(userID, scope) {
+			return fmt.Errorf("%w: %s cannot %s on wallet of user %d", ErrForbidden, principal.Subject, scope, userID)
+		}
+	}
+	return nil
+}
+
+// authorizedService 在walletServiceImpl之外按调用方的权限与角色检查每个操作，
+// 通过后为变更类操作标记审计日志的操作名，再交给next执行；NewWalletService返回的服务总是经过这一层
+type authorizedService struct {
+	next *walletServiceImpl
+}
+
+func (s *authorizedService) Deposit(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeDeposit, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "deposit")
+	return s.next.Deposit(ctx, userID, currency, amount)
+}
+
+func (s *authorizedService) Withdraw(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeWithdraw, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "withdraw")
+	return s.next.Withdraw(ctx, userID, currency, amount)
+}
+
+func (s *authorizedService) Transfer(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error {
+	if err := authorize(ctx, auth.ScopeTransfer, fromUserID); err != nil {
+		return err
+	}
+	ctx = withAuditAction(ctx, "transfer", "to_user_id", strconv.Itoa(toUserID))
+	return s.next.Transfer(ctx, fromUserID, toUserID, currency, amount)
+}
+
+func (s *authorizedService) GetBalance(ctx context.Context, userID int, currency string) (*model.Balance, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GetBalance(ctx, userID, currency)
+}
+
+func (s *authorizedService) GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GetWallet(ctx, userID, currency)
+}
+
+func (s *authorizedService) ListWallets(ctx context.Context, userID int) ([]model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.ListWallets(ctx, userID)
+}
+
+func (s *authorizedService) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) (*model.TransactionPage, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GetTransactionHistory(ctx, userID, currency, filter)
+}
+
+func (s *authorizedService) VerifyLedger(ctx context.Context) (*model.LedgerReport, error) {
+	if err := authorize(ctx, auth.ScopeLedgerRead); err != nil {
+		return nil, err
+	}
+	return s.next.VerifyLedger(ctx)
+}
+
+func (s *authorizedService) VerifyHashChain(ctx context.Context) (*model.ChainReport, error) {
+	if err := authorize(ctx, auth.ScopeLedgerRead); err != nil {
+		return nil, err
+	}
+	return s.next.VerifyHashChain(ctx)
+}
+
+// CreateCheckpoint 只签名已有的交易，不改变账本，与核对账本使用相同的权限
+func (s *authorizedService) CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	if err := authorize(ctx, auth.ScopeLedgerRead); err != nil {
+		return nil, err
+	}
+	return s.next.CreateCheckpoint(ctx)
+}
+
+func (s *authorizedService) GenerateStatement(ctx context.Context, userID int, currency string, from, to time.Time, w StatementWriter) (*model.StatementSummary, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GenerateStatement(ctx, userID, currency, from, to, w)
+}
+
+// QuoteFee 要求调用方拥有执行该操作的权限，不限定钱包
+func (s *authorizedService) QuoteFee(ctx context.Context, operation, currency string, amount decimal.Decimal) (*model.FeeQuote, error) {
+	scope := auth.ScopeWithdraw
+	if operation == model.FeeOperationTransfer {
+		scope = auth.ScopeTransfer
+	}
+	if err := authorize(ctx, scope); err != nil {
+		return nil, err
+	}
+	return s.next.QuoteFee(ctx, operation, currency, amount)
+}
+
+func (s *authorizedService) QuoteFX(ctx context.Context, from, to string) (*model.FXQuote, error) {
+	if err := authorize(ctx, auth.ScopeConvert); err != nil {
+		return nil, err
+	}
+	return s.next.QuoteFX(ctx, from, to)
+}
+
+func (s *authorizedService) Convert(ctx context.Context, userID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	if err := authorize(ctx, auth.ScopeConvert, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "fx.convert", "quote_id", quoteID)
+	return s.next.Convert(ctx, userID, from, to, amount, quoteID)
+}
+
+func (s *authorizedService) TransferWithConversion(ctx context.Context, fromUserID, toUserID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	if err := authorize(ctx, auth.ScopeTransfer, fromUserID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "fx.transfer", "to_user_id", strconv.Itoa(toUserID), "quote_id", quoteID)
+	return s.next.TransferWithConversion(ctx, fromUserID, toUserID, from, to, amount, quoteID)
+}
+
+func (s *authorizedService) Reverse(ctx context.Context, txID int, reason string) (*model.Reversal, error) {
+	if err := authorize(ctx, auth.ScopeReverse); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "transaction.reverse", "transaction_id", strconv.Itoa(txID), "reason", reason)
+	return s.next.Reverse(ctx, txID, reason)
+}
+
+func (s *authorizedService) Refund(ctx context.Context, txID int, amount decimal.Decimal, reason string) (*model.Reversal, error) {
+	if err := authorize(ctx, auth.ScopeReverse); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "transaction.refund", "transaction_id", strconv.Itoa(txID), "reason", reason)
+	return s.next.Refund(ctx, txID, amount, reason)
+}
+
+func (s *authorizedService) Authorize(ctx context.Context, userID int, currency string, amount decimal.Decimal, payeeUserID int, ttl time.Duration) (*model.Hold, error) {
+	if err := authorize(ctx, auth.ScopeHolds, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "hold.authorize")
+	return s.next.Authorize(ctx, userID, currency, amount, payeeUserID, ttl)
+}
+
+func (s *authorizedService) Capture(ctx context.Context, holdID int, amount decimal.Decimal) (*model.Hold, error) {
+	if err := s.authorizeHold(ctx, auth.ScopeHolds, holdID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "hold.capture")
+	return s.next.Capture(ctx, holdID, amount)
+}
+
+func (s *authorizedService) Void(ctx context.Context, holdID int) (*model.Hold, error) {
+	if err := s.authorizeHold(ctx, auth.ScopeHolds, holdID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "hold.void")
+	return s.next.Void(ctx, holdID)
+}
+
+func (s *authorizedService) GetHold(ctx context.Context, holdID int) (*model.Hold, error) {
+	if err := s.authorizeHold(ctx, auth.ScopeWalletsRead, holdID); err != nil {
+		return nil, err
+	}
+	return s.next.GetHold(ctx, holdID)
+}
+
+// ExpireHolds 由后台任务调用，只允许进程内调用或拥有冲正权限的后台人员执行
+func (s *authorizedService) ExpireHolds(ctx context.Context) (int64, error) {
+	if err := authorize(ctx, auth.ScopeReverse); err != nil {
+		return 0, err
+	}
+	ctx = withAuditAction(ctx, "hold.expire")
+	return s.next.ExpireHolds(ctx)
+}
+
+func (s *authorizedService) Adjust(ctx context.Context, userID int, currency string, amount decimal.Decimal, reason string) (*model.Transaction, error) {
+	if err := authorize(ctx, auth.ScopeAdjust, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "wallet.adjust", "reason", reason)
+	return s.next.Adjust(ctx, userID, currency, amount, reason)
+}
+
+func (s *authorizedService) FreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeFreeze, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "wallet.freeze", "reason", reason)
+	return s.next.FreezeWallet(ctx, userID, currency, reason)
+}
+
+func (s *authorizedService) UnfreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeFreeze, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "wallet.unfreeze", "reason", reason)
+	return s.next.UnfreezeWallet(ctx, userID, currency, reason)
+}
+
+func (s *authorizedService) CreateWallet(ctx context.Context, spec model.WalletSpec) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeWalletsCreate, spec.UserID); err != nil {
+		return nil, err
+	}
+	// 开户时设置初始限额等同于修改限额，需要与SetWalletLimits相同的权限
+	if !spec.Limits.IsEmpty() {
+		if err := authorize(ctx, auth.ScopeLimits, spec.UserID); err != nil {
+			return nil, err
+		}
+	}
+	ctx = withAuditAction(ctx, "wallet.create")
+	return s.next.CreateWallet(ctx, spec)
+}
+
+func (s *authorizedService) GetLimitUsage(ctx context.Context, userID int, currency string) (*model.LimitUsage, error) {
+	if err := authorize(ctx, auth.ScopeWalletsRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GetLimitUsage(ctx, userID, currency)
+}
+
+func (s *authorizedService) SetWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) (*model.WalletLimits, error) {
+	if err := authorize(ctx, auth.ScopeLimits, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "wallet.limits")
+	return s.next.SetWalletLimits(ctx, userID, currency, limits)
+}
+
+func (s *authorizedService) CloseWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	if err := authorize(ctx, auth.ScopeClose, userID); err != nil {
+		return nil, err
+	}
+	ctx = withAuditAction(ctx, "wallet.close", "reason", reason)
+	return s.next.CloseWallet(ctx, userID, currency, reason)
+}
+
+func (s *authorizedService) ListAuditLog(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	if err := authorize(ctx, auth.ScopeAuditRead); err != nil {
+		return nil, err
+	}
+	return s.next.ListAuditLog(ctx, filter)
+}
+
+// authorizeHold 检查调用方能否以scope权限操作预授权，终端用户必须是付款方或收款方
+func (s *authorizedService) authorizeHold(ctx context.Context, scope string, holdID int) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || !principal.IsUser() {
+		return authorize(ctx, scope)
+	}
+	hold, err := s.next.GetHold(ctx, holdID)
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, scope, hold.UserID); err != nil && (hold.PayeeUserID == 0 || authorize(ctx, scope, hold.PayeeUserID) != nil) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+
+	"wallet-service/pkg/decimal"
+)
+
+// Error 是带有稳定错误码的领域错误。服务层返回的业务错误都包装了下列哨兵之一，
+// 调用方可用errors.Is判断具体原因，或用errors.As取出*Error读取Code
+type Error struct {
+	Code    string
+	Message string
+}
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// 领域错误目录，Code一经发布不再修改
+var (
+	// ErrWalletNotFound 钱包不存在
+	ErrWalletNotFound = &Error{Code: "wallet_not_found", Message: "wallet not found"}
+	// ErrWalletExists 要创建的钱包已存在
+	ErrWalletExists = &Error{Code: "wallet_exists", Message: "wallet already exists"}
+	// ErrInvalidLimits 限额为负数或精度超出币种的小数位数
+	ErrInvalidLimits = &Error{Code: "invalid_limits", Message: "invalid wallet limits"}
+	// ErrInvalidMetadata 持有人信息的键值数量或长度超出限制
+	ErrInvalidMetadata = &Error{Code: "invalid_metadata", Message: "invalid owner metadata"}
+	// ErrInsufficientFunds 余额不足
+	ErrInsufficientFunds = &Error{Code: "insufficient_funds", Message: "insufficient balance"}
+	// ErrWalletFrozen 钱包已冻结，不允许资金变动
+	ErrWalletFrozen = &Error{Code: "wallet_frozen", Message: "wallet is frozen"}
+	// ErrWalletClosed 钱包已销户，不允许任何资金变动
+	ErrWalletClosed = &Error{Code: "wallet_closed", Message: "wallet is closed"}
+	// ErrInvalidStatusTransition 钱包当前状态不能转换为目标状态，如解冻未冻结的钱包或操作已销户的钱包
+	ErrInvalidStatusTransition = &Error{Code: "invalid_status_transition", Message: "invalid wallet status transition"}
+	// ErrWalletNotEmpty 钱包仍有余额或冻结金额，不能销户
+	ErrWalletNotEmpty = &Error{Code: "wallet_not_empty", Message: "wallet balance must be zero"}
+	// ErrInvalidAmount 金额非正数或精度超出限制
+	ErrInvalidAmount = &Error{Code: "invalid_amount", Message: "invalid amount"}
+	// ErrSameWallet 转出与转入为同一个钱包
+	ErrSameWallet = &Error{Code: "same_wallet", Message: "cannot transfer to the same wallet"}
+	// ErrLimitExceeded 超出交易限额，具体信息见LimitExceededError
+	ErrLimitExceeded = &Error{Code: "limit_exceeded", Message: "limit exceeded"}
+	// ErrUnsupportedOperation 手续费试算的操作类型不是withdrawal或transfer
+	ErrUnsupportedOperation = &Error{Code: "unsupported_operation", Message: "unsupported fee operation"}
+	// ErrDuplicateRequest 重复的请求
+	ErrDuplicateRequest = &Error{Code: "duplicate_request", Message: "duplicate request"}
+	// ErrUnsupportedCurrency 币种代码不是受支持的ISO-4217代码
+	ErrUnsupportedCurrency = &Error{Code: "unsupported_currency", Message: "unsupported currency"}
+	// ErrCurrencyMismatch 转出与转入币种不一致且未要求换汇
+	ErrCurrencyMismatch = &Error{Code: "currency_mismatch", Message: "currency mismatch"}
+	// ErrRateUnavailable 无法获取汇率，如汇率源不可用或不支持该币种对
+	ErrRateUnavailable = &Error{Code: "rate_unavailable", Message: "fx rate unavailable"}
+	// ErrQuoteNotFound 换汇报价不存在
+	ErrQuoteNotFound = &Error{Code: "quote_not_found", Message: "fx quote not found"}
+	// ErrQuoteExpired 换汇报价已过期或已被使用
+	ErrQuoteExpired = &Error{Code: "quote_expired", Message: "fx quote expired"}
+	// ErrTransactionNotFound 交易记录不存在
+	ErrTransactionNotFound = &Error{Code: "transaction_not_found", Message: "transaction not found"}
+	// ErrNotReversible 交易类型不支持冲正，或不支持部分退款
+	ErrNotReversible = &Error{Code: "not_reversible", Message: "transaction cannot be reversed"}
+	// ErrAlreadyReversed 交易已被全额冲正
+	ErrAlreadyReversed = &Error{Code: "already_reversed", Message: "transaction already reversed"}
+	// ErrReasonRequired 操作需要填写原因
+	ErrReasonRequired = &Error{Code: "reason_required", Message: "reason is required"}
+	// ErrInvalidFilter 交易历史的查询条件或分页游标非法
+	ErrInvalidFilter = &Error{Code: "invalid_filter", Message: "invalid history filter"}
+	// ErrHoldNotFound 预授权不存在
+	ErrHoldNotFound = &Error{Code: "hold_not_found", Message: "hold not found"}
+	// ErrHoldNotActive 预授权已请款、已撤销或已过期，不能再操作
+	ErrHoldNotActive = &Error{Code: "hold_not_active", Message: "hold is not active"}
+	// ErrCheckpointKeyMissing 未配置签名密钥，不能生成哈希链检查点
+	ErrCheckpointKeyMissing = &Error{Code: "checkpoint_key_missing", Message: "ledger checkpoint signing key is not configured"}
+	// ErrForbidden 调用方没有执行该操作的权限
+	ErrForbidden = &Error{Code: "forbidden", Message: "forbidden"}
+	// ErrInvalidWebhook 推送地址不是http(s)地址、订阅了未定义的事件类型，或重放已停用地址的推送
+	ErrInvalidWebhook = &Error{Code: "invalid_webhook", Message: "invalid webhook endpoint"}
+	// ErrWebhookEndpointNotFound 推送地址不存在
+	ErrWebhookEndpointNotFound = &Error{Code: "webhook_endpoint_not_found", Message: "webhook endpoint not found"}
+	// ErrWebhookDeliveryNotFound 推送记录不存在
+	ErrWebhookDeliveryNotFound = &Error{Code: "webhook_delivery_not_found", Message: "webhook delivery not found"}
+)
+
+// LimitExceededError 描述被触发的限额及剩余额度，errors.Is(err, ErrLimitExceeded)对其成立
+type LimitExceededError struct {
+	// Limit 为限额名称，如 "max_single_withdrawal"
+	Limit     string
+	Max       decimal.Decimal
+	Remaining decimal.Decimal
+}
+
+// Error 实现error接口
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s: %s (max %s, remaining %s)", ErrLimitExceeded.Message, e.Limit, e.Max, e.Remaining)
+}
+
+// Unwrap 使LimitExceededError可被识别为ErrLimitExceeded
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Details 返回供API错误响应使用的结构化信息
+func (e *LimitExceededError) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"limit":     e.Limit,
+		"max":       e.Max,
+		"remaining": e.Remaining,
+	}
+}
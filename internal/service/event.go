@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// recordEvent 在调用方的事务内向发件箱写入一条领域事件，写入失败时整个操作回滚
+func recordEvent(ctx context.Context, repo _interface.WalletRepository, eventType string, userID int, currency string, payload interface{}) error {
+	return recordCounterpartyEvent(ctx, repo, eventType, userID, 0, currency, payload)
+}
+
+// recordCounterpartyEvent 与recordEvent相同，另外记录涉及的对方用户counterpartyUserID
+func recordCounterpartyEvent(ctx context.Context, repo _interface.WalletRepository, eventType string, userID, counterpartyUserID int, currency string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", eventType, err)
+	}
+	return repo.InsertOutboxEvent(ctx, model.Event{
+		Type:               eventType,
+		UserID:             userID,
+		Currency:           currency,
+		CounterpartyUserID: counterpartyUserID,
+		Actor:              actor(ctx),
+		Payload:            data,
+		OccurredAt:         time.Now(),
+	})
+}
+
+// eventFee 返回事件中记录的手续费，未收取手续费时为nil
+func eventFee(fee decimal.Decimal) *decimal.Decimal {
+	if !fee.IsPositive() {
+		return nil
+	}
+	return &fee
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// WithFeeSchedule 设置取款与转账的费率表，费率表须已通过model.FeeSchedule.Validate校验；未设置时不收取手续费
+func WithFeeSchedule(fees model.FeeSchedule) Option {
+	return func(s *walletServiceImpl) {
+		s.fees = fees
+	}
+}
+
+// fee 按费率表计算操作的手续费，没有适用的规则时为0，金额须已校验
+func (s *walletServiceImpl) fee(operation, currency string, amount decimal.Decimal) decimal.Decimal {
+	rule, ok := s.fees.Rule(operation, currency)
+	if !ok {
+		return model.NormalizeAmount(decimal.Zero, currency)
+	}
+	return rule.Calculate(amount, currency)
+}
+
+// chargeFee 在调用方的事务内向钱包扣收手续费：以单独的fee凭证借记钱包、贷记手续费收入账户，并记录fee交易。
+// 调用方须已持有钱包行锁并确认可用余额足以支付本金与手续费
+func (s *walletServiceImpl) chargeFee(ctx context.Context, repo _interface.WalletRepository, operation string, userID int, currency string, fee decimal.Decimal) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+	if err := repo.UpdateWalletBalance(ctx, userID, currency, fee.Neg()); err != nil {
+		logrus.Errorf("Error charging %s fee for user ID %d: %v", operation, userID, err)
+		return err
+	}
+
+	entryID, err := s.postEntry(ctx, repo, "fee", fmt.Sprintf("%s fee of user %d", operation, userID),
+		debit(model.WalletAccount(userID, currency), currency, fee), credit(model.AccountSystemFees, currency, fee))
+	if err != nil {
+		return err
+	}
+
+	transaction := model.Transaction{
+		UserID:          userID,
+		Currency:        currency,
+		TransactionType: "fee",
+		Amount:          fee,
+		TransactionTime: time.Now(),
+		EntryID:         entryID,
+		Actor:           actor(ctx),
+	}
+	if err := repo.InsertTransaction(ctx, transaction); err != nil {
+		logrus.Errorf("Error inserting fee transaction for user ID %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// QuoteFee 试算操作的手续费，不改变任何状态
+func (s *walletServiceImpl) QuoteFee(ctx context.Context, operation, currency string, amount decimal.Decimal) (*model.FeeQuote, error) {
+	if operation != model.FeeOperationWithdrawal && operation != model.FeeOperationTransfer {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedOperation, operation)
+	}
+	normalized, err := validateAmount(currency, amount)
+	if err != nil {
+		return nil, err
+	}
+	fee := s.fee(operation, currency, normalized)
+	return &model.FeeQuote{Operation: operation, Currency: currency, Amount: normalized, Fee: fee, Total: normalized.Add(fee)}, nil
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// FXRateProvider 提供币种对的中间价，实现见internal/fx
+type FXRateProvider interface {
+	// GetRate 返回1单位from可兑换的to数量
+	GetRate(ctx context.Context, from, to string) (model.FXRate, error)
+}
+
+// defaultFXQuoteTTL 报价锁定汇率的默认时长
+const defaultFXQuoteTTL = 30 * time.Second
+
+// WithFXRateProvider 启用换汇，spread为点差比例（如0.005表示0.5%），quoteTTL为报价锁定汇率的时长
+func WithFXRateProvider(provider FXRateProvider, spread decimal.Decimal, quoteTTL time.Duration) Option {
+	return func(s *walletServiceImpl) {
+		s.fxProvider = provider
+		s.fxSpread = spread
+		if quoteTTL > 0 {
+			s.fxQuoteTTL = quoteTTL
+		}
+	}
+}
+
+// conversionParams 描述一次换汇：从FromUserID的From钱包扣款，向ToUserID的To钱包入账
+type conversionParams struct {
+	FromUserID int
+	ToUserID   int
+	From       string
+	To         string
+	Amount     decimal.Decimal
+	// Fee 转出方以From币种另行支付的手续费，自身换汇时为0
+	Fee     decimal.Decimal
+	QuoteID string
+	// OutType、InType 为两条交易记录的类型
+	OutType string
+	InType  string
+	// CreateTarget 为true时入账钱包不存在则自动创建
+	CreateTarget bool
+}
+
+// QuoteFX 获取from→to的实时汇率并按配置的点差生成报价，报价在有效期内锁定汇率
+func (s *walletServiceImpl) QuoteFX(ctx context.Context, from, to string) (*model.FXQuote, error) {
+	quote, err := s.liveQuote(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newQuoteID()
+	if err != nil {
+		return nil, err
+	}
+	quote.ID = id
+	quote.ExpiresAt = quote.CreatedAt.Add(s.fxQuoteTTL)
+
+	if err := s.repo.InsertFXQuote(ctx, *quote); err != nil {
+		logrus.Errorf("Error saving fx quote %s/%s: %v", from, to, err)
+		return nil, err
+	}
+	logrus.Infof("FX quote %s issued for %s/%s at %s (spread %s), expires at %v", quote.ID, from, to, quote.EffectiveRate, quote.Spread, quote.ExpiresAt)
+	return quote, nil
+}
+
+// Convert 在同一用户的两个币种钱包之间换汇，quoteID为空时按实时汇率成交；
+// 卖出与买入两条腿在同一事务中提交，目标币种钱包不存在时自动创建
+func (s *walletServiceImpl) Convert(ctx context.Context, userID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	normalized, err := validateAmount(from, amount)
+	if err != nil {
+		logrus.Errorf("Invalid conversion amount: %s %s for user ID: %d", amount, from, userID)
+		return nil, fmt.Errorf("Invalid conversion amount: %w", err)
+	}
+	if err := validateCurrency(to); err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: cannot convert %s to itself", ErrSameWallet, from)
+	}
+
+	var conversion *model.Conversion
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		var err error
+		conversion, err = s.convertTx(ctx, repo, conversionParams{
+			FromUserID:   userID,
+			ToUserID:     userID,
+			From:         from,
+			To:           to,
+			Amount:       normalized,
+			QuoteID:      quoteID,
+			OutType:      "fx_out",
+			InType:       "fx_in",
+			CreateTarget: s.autoCreateWallets,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Conversion successful for user ID %d: %s %s -> %s %s at %s", userID, conversion.SourceAmount, from, conversion.TargetAmount, to, conversion.EffectiveRate)
+	return conversion, nil
+}
+
+// TransferWithConversion 跨币种转账：从转出方的from钱包扣款，换汇后记入转入方的to钱包，
+// amount以from币种计价，转入方必须已有to币种钱包
+func (s *walletServiceImpl) TransferWithConversion(ctx context.Context, fromUserID, toUserID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	normalized, err := validateAmount(from, amount)
+	if err != nil {
+		logrus.Errorf("Invalid transfer amount: %s %s from user ID %d to user ID %d", amount, from, fromUserID, toUserID)
+		return nil, fmt.Errorf("Invalid transfer amount: %w", err)
+	}
+	if err := validateCurrency(to); err != nil {
+		return nil, err
+	}
+	if fromUserID == toUserID {
+		return nil, fmt.Errorf("%w: user ID %d, use a conversion instead", ErrSameWallet, fromUserID)
+	}
+
+	// 无论是否换汇都按转出币种与金额收取转账手续费
+	fee := s.fee(model.FeeOperationTransfer, from, normalized)
+	var conversion *model.Conversion
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		if from == to {
+			// 同币种无需换汇，退化为普通转账
+			if _, err := s.transferTx(ctx, repo, fromUserID, toUserID, from, normalized, fee); err != nil {
+				return err
+			}
+			one := decimal.NewFromInt(1)
+			conversion = &model.Conversion{UserID: fromUserID, ToUserID: toUserID, FromCurrency: from, ToCurrency: to,
+				SourceAmount: normalized, TargetAmount: normalized, Rate: one, Spread: decimal.Zero, EffectiveRate: one, Fee: eventFee(fee)}
+			return nil
+		}
+		var err error
+		conversion, err = s.convertTx(ctx, repo, conversionParams{
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			From:       from,
+			To:         to,
+			Amount:     normalized,
+			Fee:        fee,
+			QuoteID:    quoteID,
+			OutType:    "transfer_out",
+			InType:     "transfer_in",
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Transfer with conversion successful from user ID %d to user ID %d: %s %s -> %s %s, fee: %s", fromUserID, toUserID, conversion.SourceAmount, from, conversion.TargetAmount, to, fee)
+	return conversion, nil
+}
+
+// convertTx 在调用方的事务内完成换汇。卖出腿借记转出钱包、贷记from币种的system:fx，
+// 买入腿借记to币种的system:fx、贷记入账钱包，两张凭证都记录成交汇率与点差；Fee不为0时另行向转出方扣收手续费
+func (s *walletServiceImpl) convertTx(ctx context.Context, repo _interface.WalletRepository, p conversionParams) (*model.Conversion, error) {
+	quote, err := s.lockQuote(ctx, repo, p.From, p.To, p.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	toScale, _ := model.CurrencyScale(p.To)
+	target, err := p.Amount.MulRoundErr(quote.EffectiveRate, toScale, decimal.RoundDown)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s is too large to convert to %s", ErrInvalidAmount, p.Amount, p.From, p.To)
+	}
+	if !target.IsPositive() {
+		return nil, fmt.Errorf("%w: %s %s converts to zero %s", ErrInvalidAmount, p.Amount, p.From, p.To)
+	}
+
+	fromKey := walletKey{UserID: p.FromUserID, Currency: p.From}
+	toKey := walletKey{UserID: p.ToUserID, Currency: p.To}
+	wallets, err := lockWallets(ctx, repo, fromKey, toKey)
+	if err != nil {
+		logrus.Errorf("Error locking wallets for conversion %s -> %s: %v", p.From, p.To, err)
+		return nil, err
+	}
+	fromWallet := wallets[fromKey]
+	if fromWallet == nil {
+		return nil, fmt.Errorf("%w: from user ID %d, currency %s", ErrWalletNotFound, p.FromUserID, p.From)
+	}
+	if err := ensureActive(fromWallet); err != nil {
+		return nil, err
+	}
+	available, err := availableBalance(ctx, repo, fromWallet)
+	if err != nil {
+		return nil, err
+	}
+	if available.LessThan(p.Amount.Add(p.Fee)) {
+		logrus.Errorf("Insufficient %s balance for user ID %d. Available balance: %s, Conversion amount: %s, fee: %s", p.From, p.FromUserID, available, p.Amount, p.Fee)
+		return nil, fmt.Errorf("%w: from user ID %d", ErrInsufficientFunds, p.FromUserID)
+	}
+	toWallet := wallets[toKey]
+	if toWallet == nil && !p.CreateTarget {
+		return nil, fmt.Errorf("%w: to user ID %d, currency %s", ErrWalletNotFound, p.ToUserID, p.To)
+	}
+	toBalance := decimal.Zero
+	if toWallet != nil {
+		if err := ensureActive(toWallet); err != nil {
+			return nil, err
+		}
+		toBalance = toWallet.Balance
+	}
+	// 换汇卖出腿连同手续费按出账计入转出钱包的限额，跨用户时还计入转账笔数；买入腿按入账检查余额上限
+	if err := s.checkOutgoingLimits(ctx, repo, p.FromUserID, p.From, p.Amount.Add(p.Fee), p.FromUserID != p.ToUserID); err != nil {
+		return nil, err
+	}
+	if err := s.checkMaxBalance(ctx, repo, p.ToUserID, p.To, toBalance, target); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := repo.UpdateWalletBalance(ctx, p.FromUserID, p.From, p.Amount.Neg()); err != nil {
+		logrus.Errorf("Error updating %s wallet balance during conversion for user ID %d: %v", p.From, p.FromUserID, err)
+		return nil, err
+	}
+	if toWallet == nil {
+		created := model.Wallet{UserID: p.ToUserID, Currency: p.To, Balance: target, Status: model.WalletActive, LastUpdated: now}
+		if err = repo.InsertWallet(ctx, created); err == nil {
+			err = recordEvent(ctx, repo, model.EventWalletCreated, p.ToUserID, p.To, created)
+		}
+	} else {
+		err = repo.UpdateWalletBalance(ctx, p.ToUserID, p.To, target)
+	}
+	if err != nil {
+		logrus.Errorf("Error crediting %s wallet during conversion for user ID %d: %v", p.To, p.ToUserID, err)
+		return nil, err
+	}
+
+	fx := &model.FXDetails{QuoteID: quote.ID, Rate: quote.Rate, Spread: quote.Spread}
+	description := fmt.Sprintf("fx %s %s -> %s %s at %s (mid %s, spread %s)", p.Amount, p.From, target, p.To, quote.EffectiveRate, quote.Rate, quote.Spread)
+	sellEntryID, err := s.insertEntry(ctx, repo, model.JournalEntry{
+		EntryType:   "fx_sell",
+		Description: description,
+		CreatedAt:   now,
+		Postings:    []model.Posting{debit(model.WalletAccount(p.FromUserID, p.From), p.From, p.Amount), credit(model.AccountSystemFX, p.From, p.Amount)},
+		FX:          fx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	buyEntryID, err := s.insertEntry(ctx, repo, model.JournalEntry{
+		EntryType:   "fx_buy",
+		Description: description,
+		CreatedAt:   now,
+		Postings:    []model.Posting{debit(model.AccountSystemFX, p.To, target), credit(model.WalletAccount(p.ToUserID, p.To), p.To, target)},
+		FX:          fx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []model.Transaction{
+		{UserID: p.FromUserID, Currency: p.From, TransactionType: p.OutType, Amount: p.Amount, TransactionTime: now, EntryID: sellEntryID},
+		{UserID: p.ToUserID, Currency: p.To, TransactionType: p.InType, Amount: target, TransactionTime: now, EntryID: buyEntryID},
+	}
+	for _, transaction := range transactions {
+		transaction.Actor = actor(ctx)
+		if err := repo.InsertTransaction(ctx, transaction); err != nil {
+			logrus.Errorf("Error inserting %s transaction for user ID %d: %v", transaction.TransactionType, transaction.UserID, err)
+			return nil, err
+		}
+	}
+	if err := s.chargeFee(ctx, repo, model.FeeOperationTransfer, p.FromUserID, p.From, p.Fee); err != nil {
+		return nil, err
+	}
+
+	conversion := &model.Conversion{
+		UserID:        p.FromUserID,
+		ToUserID:      p.ToUserID,
+		QuoteID:       quote.ID,
+		FromCurrency:  p.From,
+		ToCurrency:    p.To,
+		SourceAmount:  p.Amount,
+		TargetAmount:  target,
+		Rate:          quote.Rate,
+		Spread:        quote.Spread,
+		EffectiveRate: quote.EffectiveRate,
+		SellEntryID:   sellEntryID,
+		BuyEntryID:    buyEntryID,
+		Fee:           eventFee(p.Fee),
+	}
+	counterparty := 0
+	if p.ToUserID != p.FromUserID {
+		counterparty = p.ToUserID
+	}
+	if err := recordCounterpartyEvent(ctx, repo, model.EventCurrencyConverted, p.FromUserID, counterparty, p.From, conversion); err != nil {
+		return nil, err
+	}
+	return conversion, nil
+}
+
+// lockQuote 取得本次换汇使用的汇率：指定了报价时校验并消费该报价，否则按实时汇率生成临时报价
+func (s *walletServiceImpl) lockQuote(ctx context.Context, repo _interface.WalletRepository, from, to, quoteID string) (*model.FXQuote, error) {
+	if quoteID == "" {
+		return s.liveQuote(ctx, from, to)
+	}
+
+	quote, err := repo.GetFXQuoteForUpdate(ctx, quoteID)
+	if err != nil {
+		if errors.Is(err, _interface.ErrFXQuoteNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrQuoteNotFound, quoteID)
+		}
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case quote.From != from || quote.To != to:
+		return nil, fmt.Errorf("%w: quote %s is for %s/%s, not %s/%s", ErrCurrencyMismatch, quoteID, quote.From, quote.To, from, to)
+	case quote.UsedAt != nil:
+		return nil, fmt.Errorf("%w: quote %s has already been used", ErrQuoteExpired, quoteID)
+	case quote.Expired(now):
+		return nil, fmt.Errorf("%w: quote %s expired at %v", ErrQuoteExpired, quoteID, quote.ExpiresAt)
+	}
+	if err := repo.MarkFXQuoteUsed(ctx, quoteID, now); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// liveQuote 从汇率源获取中间价并计算扣除点差后的成交汇率，不保存
+func (s *walletServiceImpl) liveQuote(ctx context.Context, from, to string) (*model.FXQuote, error) {
+	if err := validateCurrency(from); err != nil {
+		return nil, err
+	}
+	if err := validateCurrency(to); err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: cannot quote %s against itself", ErrSameWallet, from)
+	}
+	if s.fxProvider == nil {
+		return nil, fmt.Errorf("%w: currency conversion is not configured", ErrRateUnavailable)
+	}
+
+	rate, err := s.fxProvider.GetRate(ctx, from, to)
+	if err != nil {
+		logrus.Errorf("Error getting fx rate %s/%s: %v", from, to, err)
+		return nil, fmt.Errorf("%w: %s/%s: %v", ErrRateUnavailable, from, to, err)
+	}
+	if !rate.Rate.IsPositive() {
+		return nil, fmt.Errorf("%w: provider returned non-positive rate %s for %s/%s", ErrRateUnavailable, rate.Rate, from, to)
+	}
+
+	// 成交汇率 = 中间价 × (1 - 点差)，向下舍入，舍入误差不会使用户多得
+	effective := rate.Rate.MulRound(decimal.NewFromInt(1).Sub(s.fxSpread), model.FXRateScale, decimal.RoundDown)
+	return &model.FXQuote{
+		From:          from,
+		To:            to,
+		Rate:          rate.Rate,
+		Spread:        s.fxSpread,
+		EffectiveRate: effective,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// newQuoteID 生成随机的报价ID
+func newQuoteID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate quote id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
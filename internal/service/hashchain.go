@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+)
+
+const (
+	// chainVerifyBatch 校验哈希链时每次读取的交易条数
+	chainVerifyBatch = 1000
+	// checkpointLag 检查点只覆盖早于该时长写入的交易，避免遗漏仍未提交的较小ID的交易
+	checkpointLag = time.Minute
+)
+
+// WithCheckpointKey 设置签名检查点使用的Ed25519私钥，未设置时不能生成检查点，校验时也不验证签名
+func WithCheckpointKey(key ed25519.PrivateKey) Option {
+	return func(s *walletServiceImpl) {
+		s.checkpointKey = key
+	}
+}
+
+// chainRepository 在写入交易时把它接到所属钱包的哈希链上：读取该钱包最新一笔交易的Hash作为PrevHash，
+// 再计算本交易的Hash。服务写入交易时总是持有钱包行锁，同一钱包的交易因此串行上链
+type chainRepository struct {
+	_interface.WalletRepository
+}
+
+func (r *chainRepository) WithTx(ctx context.Context, fn func(repo _interface.WalletRepository) error) error {
+	return r.WalletRepository.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		return fn(&chainRepository{WalletRepository: repo})
+	})
+}
+
+func (r *chainRepository) InsertTransaction(ctx context.Context, transaction model.Transaction) error {
+	prevHash, err := r.WalletRepository.GetLastTransactionHash(ctx, transaction.UserID, transaction.Currency)
+	if err != nil {
+		return err
+	}
+	// 数据库以微秒精度存储时间，先截断使读回的内容与计算摘要时一致
+	transaction.TransactionTime = transaction.TransactionTime.Truncate(time.Microsecond)
+	transaction.PrevHash = prevHash
+	transaction.Hash = model.TransactionHash(prevHash, transaction)
+	return r.WalletRepository.InsertTransaction(ctx, transaction)
+}
+
+// VerifyHashChain 按ID顺序遍历全部交易，逐笔重新计算摘要并核对与同一钱包上一笔交易的链接，
+// 经过每个检查点时核对当时的链头摘要与签名，返回第一个断开的位置
+func (s *walletServiceImpl) VerifyHashChain(ctx context.Context) (*model.ChainReport, error) {
+	checkpoints, err := s.repo.ListLedgerCheckpoints(ctx)
+	if err != nil {
+		logrus.Errorf("Error listing ledger checkpoints: %v", err)
+		return nil, err
+	}
+
+	report := &model.ChainReport{}
+	heads := make(map[walletKey]model.ChainHead)
+	next := 0
+	// verifyCheckpoints 核对覆盖范围不超过lastID的检查点
+	verifyCheckpoints := func(lastID int) {
+		for ; report.Break == nil && next < len(checkpoints) && checkpoints[next].LastTransactionID <= lastID; next++ {
+			report.Break = s.verifyCheckpoint(checkpoints[next], heads)
+			if report.Break == nil {
+				report.CheckpointsVerified++
+			}
+		}
+	}
+
+	afterID := 0
+	for report.Break == nil {
+		batch, err := s.repo.ListTransactionsAfter(ctx, afterID, chainVerifyBatch)
+		if err != nil {
+			logrus.Errorf("Error reading transactions after ID %d: %v", afterID, err)
+			return nil, err
+		}
+		for _, tx := range batch {
+			verifyCheckpoints(tx.ID - 1)
+			if report.Break != nil {
+				break
+			}
+			key := walletKey{UserID: tx.UserID, Currency: tx.Currency}
+			head, chained := heads[key]
+			if tx.Hash == "" {
+				if chained {
+					report.Break = chainBreak(tx, "transaction after the chain start has no hash", "", "")
+					break
+				}
+				report.Unchained++
+				continue
+			}
+			if tx.PrevHash != head.Hash {
+				report.Break = chainBreak(tx, "prev_hash does not match the previous transaction of the wallet", head.Hash, tx.PrevHash)
+				break
+			}
+			if expected := model.TransactionHash(tx.PrevHash, tx); expected != tx.Hash {
+				report.Break = chainBreak(tx, "transaction content does not match its hash", expected, tx.Hash)
+				break
+			}
+			heads[key] = model.ChainHead{UserID: tx.UserID, Currency: tx.Currency, TransactionID: tx.ID, Hash: tx.Hash}
+			report.TransactionsChecked++
+		}
+		if len(batch) < chainVerifyBatch {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+	// 剩余的检查点覆盖到最后一笔交易之后，末尾的交易被删除时在这里发现
+	verifyCheckpoints(math.MaxInt)
+
+	if report.Intact() {
+		logrus.Infof("Hash chain verified. Transactions: %d, unchained: %d, checkpoints: %d", report.TransactionsChecked, report.Unchained, report.CheckpointsVerified)
+	} else {
+		logrus.Errorf("Hash chain broken: %+v", *report.Break)
+	}
+	return report, nil
+}
+
+// verifyCheckpoint 用遍历到检查点位置时的链头核对检查点的摘要与签名，未配置密钥时只核对摘要
+func (s *walletServiceImpl) verifyCheckpoint(checkpoint model.LedgerCheckpoint, heads map[walletKey]model.ChainHead) *model.ChainBreak {
+	current := make([]model.ChainHead, 0, len(heads))
+	for _, head := range heads {
+		current = append(current, head)
+	}
+	if digest := model.ChainHeadsDigest(current); digest != checkpoint.HeadsDigest {
+		return &model.ChainBreak{TransactionID: checkpoint.LastTransactionID, CheckpointID: checkpoint.ID,
+			Reason: "chain heads do not match the checkpoint", Expected: checkpoint.HeadsDigest, Actual: digest}
+	}
+	if s.checkpointKey != nil && !checkpoint.VerifySignature(s.checkpointKey.Public().(ed25519.PublicKey)) {
+		return &model.ChainBreak{TransactionID: checkpoint.LastTransactionID, CheckpointID: checkpoint.ID,
+			Reason: "checkpoint signature is invalid or made with another key"}
+	}
+	return nil
+}
+
+// chainBreak 构造交易处的断链信息
+func chainBreak(tx model.Transaction, reason, expected, actual string) *model.ChainBreak {
+	return &model.ChainBreak{TransactionID: tx.ID, UserID: tx.UserID, Currency: tx.Currency, Reason: reason, Expected: expected, Actual: actual}
+}
+
+// CreateCheckpoint 对早于checkpointLag写入的交易生成签名检查点，自上一个检查点以来没有新交易时返回nil
+func (s *walletServiceImpl) CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	if s.checkpointKey == nil {
+		return nil, ErrCheckpointKeyMissing
+	}
+	lastID, err := s.repo.GetLastTransactionIDBefore(ctx, time.Now().Add(-checkpointLag))
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := s.repo.ListLedgerCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lastID == 0 || (len(checkpoints) > 0 && checkpoints[len(checkpoints)-1].LastTransactionID >= lastID) {
+		return nil, nil
+	}
+
+	heads, err := s.repo.ListChainHeads(ctx, lastID)
+	if err != nil {
+		logrus.Errorf("Error listing chain heads up to transaction %d: %v", lastID, err)
+		return nil, err
+	}
+	checkpoint := &model.LedgerCheckpoint{
+		LastTransactionID: lastID,
+		HeadsDigest:       model.ChainHeadsDigest(heads),
+		CreatedAt:         time.Now(),
+	}
+	checkpoint.Sign(s.checkpointKey)
+	checkpoint.ID, err = s.repo.InsertLedgerCheckpoint(ctx, *checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("insert ledger checkpoint: %w", err)
+	}
+
+	logrus.Infof("Ledger checkpoint %d signed up to transaction %d with key %s", checkpoint.ID, lastID, checkpoint.KeyID)
+	return checkpoint, nil
+}
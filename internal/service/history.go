@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"wallet-service/internal/model"
+)
+
+const (
+	// DefaultHistoryLimit 未指定页大小时每页返回的交易条数
+	DefaultHistoryLimit = 50
+	// MaxHistoryLimit 每页最多返回的交易条数
+	MaxHistoryLimit = 500
+)
+
+// transactionTypes 是可用于过滤交易历史的交易类型
+var transactionTypes = map[string]bool{
+	"deposit":             true,
+	"withdrawal":          true,
+	"transfer_in":         true,
+	"transfer_out":        true,
+	"fx_in":               true,
+	"fx_out":              true,
+	"deposit_reversal":    true,
+	"withdrawal_reversal": true,
+	"refund_in":           true,
+	"refund_out":          true,
+	"adjustment_credit":   true,
+	"adjustment_debit":    true,
+	"fee":                 true,
+}
+
+// GetTransactionHistory 获取指定用户某币种钱包的一页交易历史。多查询一条用于判断是否还有下一页，
+// 有下一页时以本页最后一条记录生成游标
+func (s *walletServiceImpl) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) (*model.TransactionPage, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	if err := validateHistoryFilter(&filter); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	filter.Limit = limit + 1
+	history, err := s.repo.GetTransactionHistory(ctx, userID, currency, filter)
+	if err != nil {
+		logrus.Errorf("Error getting transaction history for user ID %d: %v", userID, err)
+		return nil, err
+	}
+
+	page := &model.TransactionPage{Transactions: history}
+	if len(history) > limit {
+		page.Transactions = history[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = model.HistoryCursor{Time: last.TransactionTime, ID: last.ID}.Encode()
+	}
+	if page.Transactions == nil {
+		page.Transactions = []model.Transaction{}
+	}
+
+	logrus.Infof("Transaction history retrieved for user ID %d (%s). Number of transactions: %d", userID, currency, len(page.Transactions))
+	return page, nil
+}
+
+// validateHistoryFilter 校验查询条件并补全默认页大小
+func validateHistoryFilter(filter *model.HistoryFilter) error {
+	switch {
+	case filter.Limit < 0 || filter.Limit > MaxHistoryLimit:
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFilter, MaxHistoryLimit)
+	case filter.MinAmount != nil && filter.MinAmount.IsNegative():
+		return fmt.Errorf("%w: min_amount must not be negative", ErrInvalidFilter)
+	case filter.MinAmount != nil && filter.MaxAmount != nil && filter.MaxAmount.LessThan(*filter.MinAmount):
+		return fmt.Errorf("%w: max_amount is less than min_amount", ErrInvalidFilter)
+	case filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To):
+		return fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	for _, t := range filter.Types {
+		if !transactionTypes[t] {
+			return fmt.Errorf("%w: unknown transaction type %q", ErrInvalidFilter, t)
+		}
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultHistoryLimit
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// defaultHoldTTL 预授权的默认有效期，到期未请款的预授权自动失效
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+// WithHoldTTL 设置Authorize未指定有效期时使用的默认有效期
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(s *walletServiceImpl) {
+		if ttl > 0 {
+			s.holdTTL = ttl
+		}
+	}
+}
+
+// availableBalance 返回已加锁钱包的可用余额，即账面余额减去未过期预授权的冻结金额
+func availableBalance(ctx context.Context, repo _interface.WalletRepository, wallet *model.Wallet) (decimal.Decimal, error) {
+	held, err := repo.SumActiveHolds(ctx, wallet.UserID, wallet.Currency, time.Now())
+	if err != nil {
+		logrus.Errorf("Error summing active holds for user ID %d: %v", wallet.UserID, err)
+		return decimal.Zero, err
+	}
+	return wallet.Balance.Sub(held), nil
+}
+
+// Authorize 在持有钱包行锁的情况下检查可用余额与出账限额并写入预授权，不改变账面余额也不记账
+func (s *walletServiceImpl) Authorize(ctx context.Context, userID int, currency string, amount decimal.Decimal, payeeUserID int, ttl time.Duration) (*model.Hold, error) {
+	normalized, err := validateAmount(currency, amount)
+	if err != nil {
+		logrus.Errorf("Invalid authorization amount: %s %s for user ID: %d", amount, currency, userID)
+		return nil, fmt.Errorf("Invalid authorization amount: %w", err)
+	}
+	amount = normalized
+	if payeeUserID == userID {
+		return nil, fmt.Errorf("%w: user ID %d", ErrSameWallet, userID)
+	}
+	if ttl <= 0 {
+		ttl = s.holdTTL
+	}
+
+	var hold *model.Hold
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		key := walletKey{UserID: userID, Currency: currency}
+		wallets, err := lockWallets(ctx, repo, key)
+		if err != nil {
+			return err
+		}
+		wallet := wallets[key]
+		if wallet == nil {
+			return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+		}
+		if err := ensureActive(wallet); err != nil {
+			return err
+		}
+		// 收款方钱包须在授权时已存在，避免请款时才发现无法入账
+		if payeeUserID != 0 {
+			if _, err := repo.GetWallet(ctx, payeeUserID, currency); err != nil {
+				return s.handleWalletNotFoundError(payeeUserID, currency, err)
+			}
+		}
+
+		available, err := availableBalance(ctx, repo, wallet)
+		if err != nil {
+			return err
+		}
+		if available.LessThan(amount) {
+			logrus.Errorf("Insufficient balance for authorization on user ID %d. Available balance: %s, Authorization amount: %s", userID, available, amount)
+			return fmt.Errorf("%w: user ID %d", ErrInsufficientFunds, userID)
+		}
+		// 授权时按全额请款（含手续费）预先检查出账限额，避免冻结了注定无法请款的金额；
+		// 预授权本身不计入已用额度，请款时按实际请款金额再次检查
+		operation := model.FeeOperationWithdrawal
+		if payeeUserID != 0 {
+			operation = model.FeeOperationTransfer
+		}
+		if err := s.checkOutgoingLimits(ctx, repo, userID, currency, amount.Add(s.fee(operation, currency, amount)), payeeUserID != 0); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		hold = &model.Hold{
+			UserID:         userID,
+			Currency:       currency,
+			Amount:         amount,
+			PayeeUserID:    payeeUserID,
+			Status:         model.HoldAuthorized,
+			CapturedAmount: decimal.Zero,
+			CreatedAt:      now,
+			ExpiresAt:      now.Add(ttl),
+			UpdatedAt:      now,
+		}
+		hold.ID, err = repo.InsertHold(ctx, *hold)
+		if err != nil {
+			logrus.Errorf("Error inserting hold for user ID %d: %v", userID, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Authorization %d created for user ID %d. Amount: %s %s, expires at %v", hold.ID, userID, amount, currency, hold.ExpiresAt)
+	return hold, nil
+}
+
+// Capture 对预授权请款：先结束预授权释放冻结金额，再在同一事务中按普通取款或转账扣款并记账；
+// 按请款金额收取取款或转账手续费，预授权只冻结了本金，可用余额不足以支付手续费时请款失败
+func (s *walletServiceImpl) Capture(ctx context.Context, holdID int, amount decimal.Decimal) (*model.Hold, error) {
+	var hold *model.Hold
+	var fee decimal.Decimal
+	err := s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		var err error
+		hold, err = s.lockHold(ctx, repo, holdID)
+		if err != nil {
+			return err
+		}
+
+		captureAmount := amount
+		if captureAmount.IsZero() {
+			captureAmount = hold.Amount
+		}
+		normalized, err := validateAmount(hold.Currency, captureAmount)
+		if err != nil {
+			return fmt.Errorf("Invalid capture amount: %w", err)
+		}
+		if hold.Amount.LessThan(normalized) {
+			return fmt.Errorf("%w: capture amount %s exceeds authorized amount %s", ErrInvalidAmount, normalized, hold.Amount)
+		}
+
+		hold.Status = model.HoldCaptured
+		hold.CapturedAmount = normalized
+		hold.UpdatedAt = time.Now()
+		if err := repo.UpdateHold(ctx, *hold); err != nil {
+			logrus.Errorf("Error releasing hold %d before capture: %v", holdID, err)
+			return err
+		}
+
+		if hold.PayeeUserID == 0 {
+			fee = s.fee(model.FeeOperationWithdrawal, hold.Currency, normalized)
+			_, hold.EntryID, err = s.withdrawTx(ctx, repo, hold.UserID, hold.Currency, normalized, fee)
+		} else {
+			fee = s.fee(model.FeeOperationTransfer, hold.Currency, normalized)
+			hold.EntryID, err = s.transferTx(ctx, repo, hold.UserID, hold.PayeeUserID, hold.Currency, normalized, fee)
+		}
+		if err != nil {
+			return err
+		}
+		// 扣款成功后回写凭证ID
+		return repo.UpdateHold(ctx, *hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Hold %d captured for user ID %d. Captured amount: %s of %s %s, fee: %s", holdID, hold.UserID, hold.CapturedAmount, hold.Amount, hold.Currency, fee)
+	return hold, nil
+}
+
+// Void 撤销预授权，冻结金额立即回到可用余额
+func (s *walletServiceImpl) Void(ctx context.Context, holdID int) (*model.Hold, error) {
+	var hold *model.Hold
+	err := s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		var err error
+		hold, err = s.lockHold(ctx, repo, holdID)
+		if err != nil {
+			return err
+		}
+		hold.Status = model.HoldVoided
+		hold.UpdatedAt = time.Now()
+		return repo.UpdateHold(ctx, *hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Hold %d voided for user ID %d. Released amount: %s %s", holdID, hold.UserID, hold.Amount, hold.Currency)
+	return hold, nil
+}
+
+// GetHold 获取预授权，已到期但尚未被清理的预授权按expired返回
+func (s *walletServiceImpl) GetHold(ctx context.Context, holdID int) (*model.Hold, error) {
+	hold, err := s.repo.GetHold(ctx, holdID)
+	if err != nil {
+		if errors.Is(err, _interface.ErrHoldNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrHoldNotFound, holdID)
+		}
+		return nil, err
+	}
+	if hold.Status == model.HoldAuthorized && !hold.Active(time.Now()) {
+		hold.Status = model.HoldExpired
+	}
+	return hold, nil
+}
+
+// ExpireHolds 将已到期的预授权标记为过期。到期的预授权在查询可用余额时已不再计入，
+// 这里只是让其状态与之一致
+func (s *walletServiceImpl) ExpireHolds(ctx context.Context) (int64, error) {
+	expired, err := s.repo.ExpireHolds(ctx, time.Now())
+	if err != nil {
+		logrus.Errorf("Error expiring holds: %v", err)
+		return 0, err
+	}
+	if expired > 0 {
+		logrus.Infof("Expired %d holds", expired)
+	}
+	return expired, nil
+}
+
+// lockHold 在事务内锁定预授权，只有未到期的authorized预授权可以请款或撤销
+func (s *walletServiceImpl) lockHold(ctx context.Context, repo _interface.WalletRepository, holdID int) (*model.Hold, error) {
+	hold, err := repo.GetHoldForUpdate(ctx, holdID)
+	if err != nil {
+		if errors.Is(err, _interface.ErrHoldNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrHoldNotFound, holdID)
+		}
+		return nil, err
+	}
+	switch {
+	case hold.Status != model.HoldAuthorized:
+		return nil, fmt.Errorf("%w: hold %d is %s", ErrHoldNotActive, holdID, hold.Status)
+	case !hold.Active(time.Now()):
+		return nil, fmt.Errorf("%w: hold %d expired at %v", ErrHoldNotActive, holdID, hold.ExpiresAt)
+	}
+	return hold, nil
+}
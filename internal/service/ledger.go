@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// debit 构造一条借方分录
+func debit(account, currency string, amount decimal.Decimal) model.Posting {
+	return model.Posting{Account: account, Currency: currency, Direction: model.Debit, Amount: amount}
+}
+
+// credit 构造一条贷方分录
+func credit(account, currency string, amount decimal.Decimal) model.Posting {
+	return model.Posting{Account: account, Currency: currency, Direction: model.Credit, Amount: amount}
+}
+
+// postEntry 校验凭证借贷平衡后写入账本并返回凭证ID，不平衡的凭证一律拒绝，
+// 调用方需在同一事务中更新钱包余额，使余额与分录一同提交或回滚
+func (s *walletServiceImpl) postEntry(ctx context.Context, repo _interface.WalletRepository, entryType, description string, postings ...model.Posting) (int, error) {
+	return s.insertEntry(ctx, repo, model.JournalEntry{
+		EntryType:   entryType,
+		Description: description,
+		CreatedAt:   time.Now(),
+		Postings:    postings,
+	})
+}
+
+// insertEntry 校验并写入一张已构造好的凭证，供需要附加信息（如汇率）的凭证使用
+func (s *walletServiceImpl) insertEntry(ctx context.Context, repo _interface.WalletRepository, entry model.JournalEntry) (int, error) {
+	if err := entry.Validate(); err != nil {
+		logrus.Errorf("Refusing to post %s entry: %v", entry.EntryType, err)
+		return 0, err
+	}
+
+	entryID, err := repo.InsertJournalEntry(ctx, entry)
+	if err != nil {
+		logrus.Errorf("Error inserting %s journal entry: %v", entry.EntryType, err)
+		return 0, err
+	}
+	return entryID, nil
+}
+
+// VerifyLedger 核对账本：每个币种的分录借贷合计应相等，且每个钱包余额应等于其账户的贷方减借方
+func (s *walletServiceImpl) VerifyLedger(ctx context.Context) (*model.LedgerReport, error) {
+	trialBalances, err := s.repo.GetTrialBalance(ctx)
+	if err != nil {
+		logrus.Errorf("Error getting trial balance: %v", err)
+		return nil, err
+	}
+	mismatches, err := s.repo.ListLedgerMismatches(ctx)
+	if err != nil {
+		logrus.Errorf("Error listing ledger mismatches: %v", err)
+		return nil, err
+	}
+
+	report := &model.LedgerReport{TrialBalances: trialBalances, Mismatches: mismatches}
+	for _, tb := range trialBalances {
+		logrus.Infof("Trial balance for %s. Total debits: %s, total credits: %s", tb.Currency, tb.TotalDebits, tb.TotalCredits)
+	}
+	if report.Balanced() {
+		logrus.Infof("Ledger verified for %d currencies", len(trialBalances))
+	} else {
+		logrus.Errorf("Ledger verification failed. Mismatched wallets: %d", len(mismatches))
+	}
+	return report, nil
+}
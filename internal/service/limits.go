@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// DefaultLimitsFallback 是默认限额中适用于未单独配置币种的键
+const DefaultLimitsFallback = "*"
+
+// WithDefaultLimits 设置默认限额，键为币种代码或DefaultLimitsFallback，同一项以币种的配置优先
+func WithDefaultLimits(defaults map[string]model.WalletLimits) Option {
+	return func(s *walletServiceImpl) {
+		s.defaultLimits = defaults
+	}
+}
+
+// effectiveLimits 返回钱包当前生效的限额：钱包单独设置的限额，其次为币种的默认限额，最后为通用默认限额
+func (s *walletServiceImpl) effectiveLimits(ctx context.Context, repo _interface.WalletRepository, userID int, currency string) (model.WalletLimits, error) {
+	overrides, err := repo.GetWalletLimits(ctx, userID, currency)
+	if err != nil {
+		logrus.Errorf("Error reading %s wallet limits for user ID %d: %v", currency, userID, err)
+		return model.WalletLimits{}, err
+	}
+	defaults := s.defaultLimits[currency].Merge(s.defaultLimits[DefaultLimitsFallback])
+	return overrides.Merge(defaults), nil
+}
+
+// checkOutgoingLimits 在持有钱包行锁时检查本次出账是否超出单笔、当日与当月限额，amount须包含手续费，
+// transfer为true时还检查最近一小时的转出笔数
+func (s *walletServiceImpl) checkOutgoingLimits(ctx context.Context, repo _interface.WalletRepository, userID int, currency string, amount decimal.Decimal, transfer bool) error {
+	limits, err := s.effectiveLimits(ctx, repo, userID, currency)
+	if err != nil {
+		return err
+	}
+	if max := limits.MaxSingleWithdrawal; max != nil && amount.GreaterThan(*max) {
+		return limitExceeded(userID, currency, "max_single_withdrawal", *max, *max)
+	}
+	if limits.DailyOutgoing == nil && limits.MonthlyOutgoing == nil && (!transfer || limits.MaxTransfersPerHour == nil) {
+		return nil
+	}
+
+	usage, err := repo.GetOutgoingUsage(ctx, userID, currency, time.Now())
+	if err != nil {
+		logrus.Errorf("Error reading outgoing usage of %s wallet for user ID %d: %v", currency, userID, err)
+		return err
+	}
+	if max := limits.DailyOutgoing; max != nil {
+		if remaining := headroom(*max, usage.DailyOutgoing); amount.GreaterThan(remaining) {
+			return limitExceeded(userID, currency, "daily_outgoing", *max, remaining)
+		}
+	}
+	if max := limits.MonthlyOutgoing; max != nil {
+		if remaining := headroom(*max, usage.MonthlyOutgoing); amount.GreaterThan(remaining) {
+			return limitExceeded(userID, currency, "monthly_outgoing", *max, remaining)
+		}
+	}
+	if max := limits.MaxTransfersPerHour; transfer && max != nil && usage.TransfersLastHour >= *max {
+		return limitExceeded(userID, currency, "max_transfers_per_hour", decimal.NewFromInt(int64(*max)), decimal.Zero)
+	}
+	return nil
+}
+
+// balanceCeiling 是任何钱包余额的硬上限，与是否配置max_balance无关：余额的整数部分
+// 超过decimal.MaxIntegerDigits位后无法再从数据库读出，钱包将无法使用
+var balanceCeiling = decimal.MustParse(strings.Repeat("9", decimal.MaxIntegerDigits) + ".999")
+
+// checkBalanceCeiling 检查入账amount后余额是否超出balanceCeiling，balance为入账前的余额
+func checkBalanceCeiling(userID int, currency string, balance, amount decimal.Decimal) error {
+	if remaining := headroom(balanceCeiling, balance); amount.GreaterThan(remaining) {
+		return limitExceeded(userID, currency, "max_balance", balanceCeiling, remaining)
+	}
+	return nil
+}
+
+// checkMaxBalance 在持有钱包行锁时检查入账amount后余额是否超出上限，balance为入账前的余额
+func (s *walletServiceImpl) checkMaxBalance(ctx context.Context, repo _interface.WalletRepository, userID int, currency string, balance, amount decimal.Decimal) error {
+	if err := checkBalanceCeiling(userID, currency, balance, amount); err != nil {
+		return err
+	}
+	limits, err := s.effectiveLimits(ctx, repo, userID, currency)
+	if err != nil {
+		return err
+	}
+	if max := limits.MaxBalance; max != nil {
+		if remaining := headroom(*max, balance); amount.GreaterThan(remaining) {
+			return limitExceeded(userID, currency, "max_balance", *max, remaining)
+		}
+	}
+	return nil
+}
+
+// headroom 返回上限max扣除已用used后的剩余额度，已超出时为0
+func headroom(max, used decimal.Decimal) decimal.Decimal {
+	remaining := max.Sub(used)
+	if remaining.IsNegative() {
+		return decimal.Zero
+	}
+	return remaining
+}
+
+// limitExceeded 记录日志并返回描述被触发限额的LimitExceededError
+func limitExceeded(userID int, currency, limit string, max, remaining decimal.Decimal) error {
+	logrus.Errorf("Limit %s exceeded for %s wallet of user ID %d. Max: %s, remaining: %s", limit, currency, userID, max, remaining)
+	return &LimitExceededError{Limit: limit, Max: max, Remaining: remaining}
+}
+
+// GetLimitUsage 返回钱包当前生效的限额、各窗口内的已用额度与剩余额度
+func (s *walletServiceImpl) GetLimitUsage(ctx context.Context, userID int, currency string) (*model.LimitUsage, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	wallet, err := s.repo.GetWallet(ctx, userID, currency)
+	if err != nil {
+		return nil, s.handleWalletNotFoundError(userID, currency, err)
+	}
+	limits, err := s.effectiveLimits(ctx, s.repo, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.repo.GetOutgoingUsage(ctx, userID, currency, time.Now())
+	if err != nil {
+		logrus.Errorf("Error reading outgoing usage of %s wallet for user ID %d: %v", currency, userID, err)
+		return nil, err
+	}
+
+	result := &model.LimitUsage{UserID: userID, Currency: currency, Balance: wallet.Balance, Limits: limits, Usage: *usage}
+	remaining := func(max *decimal.Decimal, used decimal.Decimal) *decimal.Decimal {
+		if max == nil {
+			return nil
+		}
+		r := model.NormalizeAmount(headroom(*max, used), currency)
+		return &r
+	}
+	result.Remaining.MaxSingleWithdrawal = remaining(limits.MaxSingleWithdrawal, decimal.Zero)
+	result.Remaining.DailyOutgoing = remaining(limits.DailyOutgoing, usage.DailyOutgoing)
+	result.Remaining.MonthlyOutgoing = remaining(limits.MonthlyOutgoing, usage.MonthlyOutgoing)
+	result.Remaining.MaxBalance = remaining(limits.MaxBalance, wallet.Balance)
+	if max := limits.MaxTransfersPerHour; max != nil {
+		transfers := *max - usage.TransfersLastHour
+		if transfers < 0 {
+			transfers = 0
+		}
+		result.Remaining.MaxTransfersPerHour = &transfers
+	}
+	return result, nil
+}
+
+// SetWalletLimits 整体替换钱包单独设置的限额，未设置的项沿用默认限额
+func (s *walletServiceImpl) SetWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) (*model.WalletLimits, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	normalized, err := validateLimits(currency, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	key := walletKey{UserID: userID, Currency: currency}
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		wallets, err := lockWallets(ctx, repo, key)
+		if err != nil {
+			return err
+		}
+		if wallets[key] == nil {
+			return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+		}
+		if err := repo.UpsertWalletLimits(ctx, userID, currency, normalized); err != nil {
+			logrus.Errorf("Error updating %s wallet limits for user ID %d: %v", currency, userID, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("%s wallet limits updated for user ID %d", currency, userID)
+	return &normalized, nil
+}
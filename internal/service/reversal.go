@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+// Reverse 全额冲正一笔交易，转账已部分退款时冲正剩余部分。原交易收取的手续费不退还，
+// fee交易本身也不能冲正，需要退还时由运维人员调账
+func (s *walletServiceImpl) Reverse(ctx context.Context, txID int, reason string) (*model.Reversal, error) {
+	return s.reverse(ctx, txID, decimal.Zero, reason)
+}
+
+// Refund 对转账部分退款，由收款方退回给付款方，与Reverse相同不退还手续费
+func (s *walletServiceImpl) Refund(ctx context.Context, txID int, amount decimal.Decimal, reason string) (*model.Reversal, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: refund must be positive, got %s", ErrInvalidAmount, amount)
+	}
+	return s.reverse(ctx, txID, amount, reason)
+}
+
+// reverse 冲正txID对应的交易，amount为0时冲正全部剩余金额。对同一笔交易的冲正以原交易
+// （转账以转出记录）的行锁串行化，累计冲正金额不会超过原交易金额
+func (s *walletServiceImpl) reverse(ctx context.Context, txID int, amount decimal.Decimal, reason string) (*model.Reversal, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reversal of transaction %d", ErrReasonRequired, txID)
+	}
+
+	var reversal *model.Reversal
+	err := s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		legs, err := lockReversible(ctx, repo, txID)
+		if err != nil {
+			return err
+		}
+		anchor := legs[0]
+
+		reversed, err := repo.SumReversals(ctx, anchor.ID)
+		if err != nil {
+			logrus.Errorf("Error summing reversals of transaction %d: %v", anchor.ID, err)
+			return err
+		}
+		remaining := anchor.Amount.Sub(reversed)
+		if !remaining.IsPositive() {
+			return fmt.Errorf("%w: transaction %d", ErrAlreadyReversed, anchor.ID)
+		}
+
+		refund := remaining
+		if !amount.IsZero() {
+			if len(legs) != 2 {
+				return fmt.Errorf("%w: partial refunds are only supported for transfers", ErrNotReversible)
+			}
+			normalized, err := validateAmount(anchor.Currency, amount)
+			if err != nil {
+				return fmt.Errorf("Invalid refund amount: %w", err)
+			}
+			if remaining.LessThan(normalized) {
+				return fmt.Errorf("%w: refund %s exceeds refundable amount %s", ErrInvalidAmount, normalized, remaining)
+			}
+			refund = normalized
+		}
+
+		reversal, err = s.postReversal(ctx, repo, legs, refund, reason)
+		if err != nil {
+			return err
+		}
+		reversal.Remaining = remaining.Sub(refund)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Transaction %d reversed. Amount: %s %s, remaining: %s, reason: %s", reversal.TransactionID, reversal.Amount, reversal.Currency, reversal.Remaining, reason)
+	return reversal, nil
+}
+
+// lockReversible 找到可冲正的原交易并加锁。存款、取款返回自身；转账返回转出、转入两条记录，
+// 无论txID指向哪一条都锁定转出记录，避免并发冲正同一笔转账时加锁顺序不一致
+func lockReversible(ctx context.Context, repo _interface.WalletRepository, txID int) ([]model.Transaction, error) {
+	original, err := repo.GetTransaction(ctx, txID)
+	if err != nil {
+		if errors.Is(err, _interface.ErrTransactionNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrTransactionNotFound, txID)
+		}
+		return nil, err
+	}
+
+	var legs []model.Transaction
+	switch original.TransactionType {
+	case "deposit", "withdrawal":
+		legs = []model.Transaction{*original}
+	case "transfer_out", "transfer_in":
+		legs, err = transferLegs(ctx, repo, original)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s transaction %d", ErrNotReversible, original.TransactionType, txID)
+	}
+
+	if _, err := repo.GetTransactionForUpdate(ctx, legs[0].ID); err != nil {
+		return nil, err
+	}
+	return legs, nil
+}
+
+// transferLegs 通过共享的记账凭证找到同一笔同币种转账的转出与转入记录，
+// 换汇转账的两条腿分属不同凭证，不支持冲正
+func transferLegs(ctx context.Context, repo _interface.WalletRepository, original *model.Transaction) ([]model.Transaction, error) {
+	if original.EntryID == 0 {
+		return nil, fmt.Errorf("%w: transaction %d has no journal entry", ErrNotReversible, original.ID)
+	}
+	related, err := repo.ListTransactionsByEntry(ctx, original.EntryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out, in *model.Transaction
+	for i := range related {
+		switch related[i].TransactionType {
+		case "transfer_out":
+			out = &related[i]
+		case "transfer_in":
+			in = &related[i]
+		}
+	}
+	if out == nil || in == nil || out.Currency != in.Currency || !out.Amount.Equal(in.Amount) {
+		return nil, fmt.Errorf("%w: transaction %d is not a same-currency transfer", ErrNotReversible, original.ID)
+	}
+	return []model.Transaction{*out, *in}, nil
+}
+
+// postReversal 按原交易的反方向移动refund金额并记账：存款冲正从钱包扣回现金账户，
+// 取款冲正从现金账户退回钱包，转账退款由收款方退回付款方
+func (s *walletServiceImpl) postReversal(ctx context.Context, repo _interface.WalletRepository, legs []model.Transaction, refund decimal.Decimal, reason string) (*model.Reversal, error) {
+	first := legs[0]
+	currency := first.Currency
+	// payer、payee为资金流出、流入的用户，0表示现金账户
+	var payer, payee int
+	var transactions []model.Transaction
+	switch first.TransactionType {
+	case "deposit":
+		payer = first.UserID
+		transactions = []model.Transaction{reversalOf(first, "deposit_reversal", refund, reason)}
+	case "withdrawal":
+		payee = first.UserID
+		transactions = []model.Transaction{reversalOf(first, "withdrawal_reversal", refund, reason)}
+	default:
+		out, in := legs[0], legs[1]
+		payer, payee = in.UserID, out.UserID
+		transactions = []model.Transaction{
+			reversalOf(in, "refund_out", refund, reason),
+			reversalOf(out, "refund_in", refund, reason),
+		}
+	}
+
+	var keys []walletKey
+	for _, userID := range []int{payer, payee} {
+		if userID != 0 {
+			keys = append(keys, walletKey{UserID: userID, Currency: currency})
+		}
+	}
+	wallets, err := lockWallets(ctx, repo, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if wallets[key] == nil {
+			return nil, fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, key.UserID, key.Currency)
+		}
+		if err := ensureActive(wallets[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	if payer != 0 {
+		available, err := availableBalance(ctx, repo, wallets[walletKey{UserID: payer, Currency: currency}])
+		if err != nil {
+			return nil, err
+		}
+		if available.LessThan(refund) {
+			logrus.Errorf("Insufficient balance to reverse transaction %d on user ID %d. Available balance: %s, Reversal amount: %s", first.ID, payer, available, refund)
+			return nil, fmt.Errorf("%w: user ID %d", ErrInsufficientFunds, payer)
+		}
+		if err := repo.UpdateWalletBalance(ctx, payer, currency, refund.Neg()); err != nil {
+			return nil, err
+		}
+	}
+	if payee != 0 {
+		if err := checkBalanceCeiling(payee, currency, wallets[walletKey{UserID: payee, Currency: currency}].Balance, refund); err != nil {
+			return nil, err
+		}
+		if err := repo.UpdateWalletBalance(ctx, payee, currency, refund); err != nil {
+			return nil, err
+		}
+	}
+
+	account := func(userID int) string {
+		if userID == 0 {
+			return s.cashAccount
+		}
+		return model.WalletAccount(userID, currency)
+	}
+	entryID, err := s.postEntry(ctx, repo, "reversal", fmt.Sprintf("reversal of transaction %d: %s", first.ID, reason),
+		debit(account(payer), currency, refund), credit(account(payee), currency, refund))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range transactions {
+		transactions[i].EntryID = entryID
+		transactions[i].TransactionTime = now
+		transactions[i].Actor = actor(ctx)
+		if err := repo.InsertTransaction(ctx, transactions[i]); err != nil {
+			logrus.Errorf("Error inserting %s transaction for user ID %d: %v", transactions[i].TransactionType, transactions[i].UserID, err)
+			return nil, err
+		}
+	}
+
+	reversal := &model.Reversal{
+		TransactionID: first.ID,
+		Currency:      currency,
+		Amount:        refund,
+		Reason:        reason,
+		EntryID:       entryID,
+		Transactions:  transactions,
+	}
+	// 转账冲正的对方为另一方钱包，存取款冲正没有对方
+	counterparty := payer
+	if counterparty == first.UserID {
+		counterparty = payee
+	}
+	if err := recordCounterpartyEvent(ctx, repo, model.EventTransactionReversed, first.UserID, counterparty, currency, reversal); err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// reversalOf 构造冲正original的交易记录
+func reversalOf(original model.Transaction, transactionType string, amount decimal.Decimal, reason string) model.Transaction {
+	return model.Transaction{
+		UserID:          original.UserID,
+		Currency:        original.Currency,
+		TransactionType: transactionType,
+		Amount:          amount,
+		ReversalOf:      original.ID,
+		Reason:          reason,
+	}
+}
@@ -2,14 +2,70 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
 )
 
+// WalletService 定义钱包业务操作，钱包以（userID，currency）唯一标识，currency为ISO-4217代码
 type WalletService interface {
-	Deposit(ctx context.Context, userID int, amount float64) error
-	Withdraw(ctx context.Context, userID int, amount float64) error
-	Transfer(ctx context.Context, fromUserID, toUserID int, amount float64) error
-	GetBalance(ctx context.Context, userID int) (float64, error)
-	GetTransactionHistory(ctx context.Context, userID int) ([]model.Transaction, error)
+	// Deposit 存款，返回在同一事务内得到的存款后的钱包，钱包不存在且允许自动开户时同时开户
+	Deposit(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error)
+	// Withdraw 取款，返回在同一事务内得到的取款后的钱包
+	Withdraw(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error)
+	// Transfer 在同币种的两个钱包之间转账
+	Transfer(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error
+	// GetBalance 返回钱包的账面余额与扣除预授权冻结后的可用余额，钱包不存在时返回ErrWalletNotFound
+	GetBalance(ctx context.Context, userID int, currency string) (*model.Balance, error)
+	GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+	// ListWallets 返回用户持有的全部币种钱包
+	ListWallets(ctx context.Context, userID int) ([]model.Wallet, error)
+	// GetTransactionHistory 分页返回钱包的交易历史，从新到旧排列，filter.Limit为0时使用默认页大小
+	GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) (*model.TransactionPage, error)
+	VerifyLedger(ctx context.Context) (*model.LedgerReport, error)
+	// VerifyHashChain 校验交易哈希链与签名检查点，返回第一个断开的位置
+	VerifyHashChain(ctx context.Context) (*model.ChainReport, error)
+	// CreateCheckpoint 对当前的哈希链头生成签名检查点，没有新交易时返回nil
+	CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error)
+	// GenerateStatement 生成钱包在[from, to)期间的对账单并逐条写入w
+	GenerateStatement(ctx context.Context, userID int, currency string, from, to time.Time, w StatementWriter) (*model.StatementSummary, error)
+	// QuoteFee 按费率表试算取款（withdrawal）或转账（transfer）的手续费，不改变任何状态
+	QuoteFee(ctx context.Context, operation, currency string, amount decimal.Decimal) (*model.FeeQuote, error)
+	// QuoteFX 生成锁定汇率的换汇报价
+	QuoteFX(ctx context.Context, from, to string) (*model.FXQuote, error)
+	// Convert 在同一用户的两个币种钱包之间换汇，quoteID为空时按实时汇率成交
+	Convert(ctx context.Context, userID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error)
+	// TransferWithConversion 跨币种转账，amount以from币种计价
+	TransferWithConversion(ctx context.Context, fromUserID, toUserID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error)
+	// Reverse 全额冲正一笔存款、取款或转账（剩余未退款部分），生成方向相反的凭证与冲正交易
+	Reverse(ctx context.Context, txID int, reason string) (*model.Reversal, error)
+	// Refund 对转账部分退款，累计退款金额不能超过原转账金额
+	Refund(ctx context.Context, txID int, amount decimal.Decimal, reason string) (*model.Reversal, error)
+	// Authorize 冻结可用余额生成预授权，payeeUserID为0时请款转为取款，否则转为向该用户的转账；
+	// ttl为0时使用默认有效期
+	Authorize(ctx context.Context, userID int, currency string, amount decimal.Decimal, payeeUserID int, ttl time.Duration) (*model.Hold, error)
+	// Capture 对预授权请款，amount为0时按全额请款，部分请款时剩余冻结金额随之释放
+	Capture(ctx context.Context, holdID int, amount decimal.Decimal) (*model.Hold, error)
+	// Void 撤销预授权并释放冻结金额
+	Void(ctx context.Context, holdID int) (*model.Hold, error)
+	GetHold(ctx context.Context, holdID int) (*model.Hold, error)
+	// ExpireHolds 将已到期的预授权标记为过期，返回处理的条数
+	ExpireHolds(ctx context.Context) (int64, error)
+	// Adjust 人工调账，amount为正时入账、为负时扣减，reason必填
+	Adjust(ctx context.Context, userID int, currency string, amount decimal.Decimal, reason string) (*model.Transaction, error)
+	// FreezeWallet 冻结钱包，冻结后不允许资金变动，reason必填
+	FreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error)
+	// UnfreezeWallet 解冻钱包，reason必填
+	UnfreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error)
+	// CreateWallet 显式开户，记录持有人信息与初始限额，钱包已存在时返回ErrWalletExists
+	CreateWallet(ctx context.Context, spec model.WalletSpec) (*model.Wallet, error)
+	// CloseWallet 销户，要求钱包为active且余额为0，销户后不能恢复，reason必填
+	CloseWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error)
+	// GetLimitUsage 返回钱包当前生效的限额、各窗口内的已用额度与剩余额度
+	GetLimitUsage(ctx context.Context, userID int, currency string) (*model.LimitUsage, error)
+	// SetWalletLimits 整体替换钱包单独设置的限额，未设置的项沿用默认限额
+	SetWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) (*model.WalletLimits, error)
+	// ListAuditLog 分页查询审计日志，从新到旧排列，filter.Limit为0时使用默认页大小
+	ListAuditLog(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error)
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// statementPageSize 生成对账单时每次从仓库读取的交易条数，内存占用与期间长度无关
+const statementPageSize = 500
+
+// StatementWriter 按顺序接收对账单的抬头、每笔交易与汇总，实现见internal/statement
+type StatementWriter interface {
+	WriteHeader(header model.StatementHeader) error
+	WriteLine(line model.StatementLine) error
+	WriteFooter(summary model.StatementSummary) error
+}
+
+// GenerateStatement 生成钱包在[from, to)期间的对账单：期初余额、按时间先后的每笔交易及其后的余额、期末余额。
+// 交易按页从仓库读取并逐笔写出，不在内存中缓存整个期间
+func (s *walletServiceImpl) GenerateStatement(ctx context.Context, userID int, currency string, from, to time.Time, w StatementWriter) (*model.StatementSummary, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: statement period start %v is not before end %v", ErrInvalidFilter, from, to)
+	}
+	if _, err := s.repo.GetWallet(ctx, userID, currency); err != nil {
+		return nil, s.handleWalletNotFoundError(userID, currency, err)
+	}
+
+	opening, err := s.repo.GetBalanceBefore(ctx, userID, currency, from)
+	if err != nil {
+		logrus.Errorf("Error getting opening balance for user ID %d: %v", userID, err)
+		return nil, err
+	}
+	header := model.StatementHeader{UserID: userID, Currency: currency, From: from, To: to, OpeningBalance: opening, GeneratedAt: time.Now()}
+	if err := w.WriteHeader(header); err != nil {
+		return nil, err
+	}
+
+	summary := model.StatementSummary{OpeningBalance: opening, TotalCredits: decimal.Zero, TotalDebits: decimal.Zero, ClosingBalance: opening}
+	filter := model.HistoryFilter{From: &from, To: &to, Limit: statementPageSize, Ascending: true}
+	for {
+		page, err := s.repo.GetTransactionHistory(ctx, userID, currency, filter)
+		if err != nil {
+			logrus.Errorf("Error reading transactions for statement of user ID %d: %v", userID, err)
+			return nil, err
+		}
+		for _, transaction := range page {
+			signed := transaction.SignedAmount()
+			if signed.IsNegative() {
+				summary.TotalDebits = summary.TotalDebits.Add(transaction.Amount)
+			} else {
+				summary.TotalCredits = summary.TotalCredits.Add(transaction.Amount)
+			}
+			summary.ClosingBalance = summary.ClosingBalance.Add(signed)
+			summary.TransactionCount++
+
+			err := w.WriteLine(model.StatementLine{
+				TransactionID:   transaction.ID,
+				TransactionTime: transaction.TransactionTime,
+				TransactionType: transaction.TransactionType,
+				Amount:          signed,
+				Balance:         summary.ClosingBalance,
+				ReversalOf:      transaction.ReversalOf,
+				Reason:          transaction.Reason,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(page) < statementPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		filter.Cursor = &model.HistoryCursor{Time: last.TransactionTime, ID: last.ID}
+	}
+
+	if err := w.WriteFooter(summary); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Statement generated for user ID %d (%s) from %v to %v. Transactions: %d, closing balance: %s", userID, currency, from, to, summary.TransactionCount, summary.ClosingBalance)
+	return &summary, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
+)
+
+const (
+	// maxOwnerMetadataKeys 持有人信息最多的键数
+	maxOwnerMetadataKeys = 20
+	// maxOwnerMetadataKeyLength、maxOwnerMetadataValueLength 持有人信息键与值的最大长度
+	maxOwnerMetadataKeyLength   = 64
+	maxOwnerMetadataValueLength = 256
+)
+
+// WithAutoCreateWallets 设置存款与换汇在钱包不存在时是否自动创建钱包，默认不创建，返回ErrWalletNotFound
+func WithAutoCreateWallets(enabled bool) Option {
+	return func(s *walletServiceImpl) {
+		s.autoCreateWallets = enabled
+	}
+}
+
+// CreateWallet 创建余额为0的active钱包，并在同一事务中写入初始限额
+func (s *walletServiceImpl) CreateWallet(ctx context.Context, spec model.WalletSpec) (*model.Wallet, error) {
+	if err := validateCurrency(spec.Currency); err != nil {
+		return nil, err
+	}
+	if err := validateOwnerMetadata(spec.OwnerMetadata); err != nil {
+		return nil, err
+	}
+	limits, err := validateLimits(spec.Currency, spec.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := &model.Wallet{
+		UserID:        spec.UserID,
+		Currency:      spec.Currency,
+		Balance:       model.NormalizeAmount(decimal.Zero, spec.Currency),
+		Status:        model.WalletActive,
+		LastUpdated:   time.Now(),
+		OwnerMetadata: spec.OwnerMetadata,
+	}
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		if err := repo.InsertWallet(ctx, *wallet); err != nil {
+			if errors.Is(err, _interface.ErrWalletExists) {
+				return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletExists, spec.UserID, spec.Currency)
+			}
+			logrus.Errorf("Error creating %s wallet for user ID %d: %v", spec.Currency, spec.UserID, err)
+			return err
+		}
+		if err := recordEvent(ctx, repo, model.EventWalletCreated, spec.UserID, spec.Currency, wallet); err != nil {
+			return err
+		}
+		if limits.IsEmpty() {
+			return nil
+		}
+		return repo.UpsertWalletLimits(ctx, spec.UserID, spec.Currency, limits)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("%s wallet created for user ID %d", spec.Currency, spec.UserID)
+	return wallet, nil
+}
+
+// validateOwnerMetadata 校验持有人信息的键值数量与长度
+func validateOwnerMetadata(metadata map[string]string) error {
+	if len(metadata) > maxOwnerMetadataKeys {
+		return fmt.Errorf("%w: at most %d keys, got %d", ErrInvalidMetadata, maxOwnerMetadataKeys, len(metadata))
+	}
+	for key, value := range metadata {
+		if strings.TrimSpace(key) == "" || len(key) > maxOwnerMetadataKeyLength {
+			return fmt.Errorf("%w: key %q must be 1 to %d characters", ErrInvalidMetadata, key, maxOwnerMetadataKeyLength)
+		}
+		if len(value) > maxOwnerMetadataValueLength {
+			return fmt.Errorf("%w: value of %q exceeds %d characters", ErrInvalidMetadata, key, maxOwnerMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// validateLimits 校验限额不为负数且精度不超过币种的小数位数，返回调整为标准小数位数后的限额
+func validateLimits(currency string, limits model.WalletLimits) (model.WalletLimits, error) {
+	scale, _ := model.CurrencyScale(currency)
+	for name, amount := range limits.Amounts() {
+		if amount == nil {
+			continue
+		}
+		if amount.IsNegative() || !amount.FitsScale(scale) {
+			return limits, fmt.Errorf("%w: %s must be a non-negative amount with at most %d decimal places, got %s", ErrInvalidLimits, name, scale, amount)
+		}
+	}
+	if limits.MaxTransfersPerHour != nil && *limits.MaxTransfersPerHour < 0 {
+		return limits, fmt.Errorf("%w: max_transfers_per_hour must not be negative", ErrInvalidLimits)
+	}
+	normalized := limits
+	for _, field := range []**decimal.Decimal{&normalized.MaxSingleWithdrawal, &normalized.DailyOutgoing, &normalized.MonthlyOutgoing, &normalized.MaxBalance} {
+		if *field != nil {
+			amount := model.NormalizeAmount(**field, currency)
+			*field = &amount
+		}
+	}
+	return normalized, nil
+}
@@ -2,232 +2,516 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"sort"
 	"time"
 	"wallet-service/internal/model"
 	"wallet-service/internal/repository/interface"
+	"wallet-service/pkg/decimal"
 )
 
 // walletServiceImpl 结构体实现了WalletService接口
 type walletServiceImpl struct {
 	repo _interface.WalletRepository
+	// cashAccount 为存取款记账的对手方系统账户
+	cashAccount string
+
+	// fxProvider 为nil时不支持换汇
+	fxProvider FXRateProvider
+	fxSpread   decimal.Decimal
+	fxQuoteTTL time.Duration
+
+	// holdTTL 预授权的默认有效期
+	holdTTL time.Duration
+
+	// checkpointKey 为nil时不能生成哈希链检查点
+	checkpointKey ed25519.PrivateKey
+
+	// autoCreateWallets 为true时存款与换汇在钱包不存在时自动创建，兼容未显式开户的旧客户端
+	autoCreateWallets bool
+
+	// defaultLimits 各币种的默认限额，钱包单独设置的限额优先
+	defaultLimits map[string]model.WalletLimits
+
+	// fees 为nil时不收取手续费
+	fees model.FeeSchedule
+}
+
+// Option 用于在创建WalletService时调整可选配置
+type Option func(*walletServiceImpl)
+
+// WithCashAccount 设置存取款记账使用的系统账户，默认为model.AccountSystemCash
+func WithCashAccount(account string) Option {
+	return func(s *walletServiceImpl) {
+		if account != "" {
+			s.cashAccount = account
+		}
+	}
 }
 
 // NewWalletService 创建并返回一个WalletService实例
-func NewWalletService(repo _interface.WalletRepository) WalletService {
-	return &walletServiceImpl{repo: repo}
+func NewWalletService(repo _interface.WalletRepository, opts ...Option) WalletService {
+	s := &walletServiceImpl{repo: &auditRepository{WalletRepository: &chainRepository{WalletRepository: repo}}, cashAccount: model.AccountSystemCash, fxQuoteTTL: defaultFXQuoteTTL, holdTTL: defaultHoldTTL}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return &authorizedService{next: s}
 }
 
 // handleWalletNotFoundError 辅助函数，统一处理钱包不存在的错误情况
-func (s *walletServiceImpl) handleWalletNotFoundError(userID int, err error) error {
+func (s *walletServiceImpl) handleWalletNotFoundError(userID int, currency string, err error) error {
 	if errors.Is(err, _interface.ErrWalletNotFound) {
-		return fmt.Errorf("Wallet not found for user ID %d", userID)
+		return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
 	}
 	return err
 }
 
-// Deposit 实现存款功能
-func (s *walletServiceImpl) Deposit(ctx context.Context, userID int, amount float64) error {
-	if amount <= 0 {
-		logrus.Errorf("Invalid deposit amount: %f for user ID: %d", amount, userID)
-		return fmt.Errorf("Invalid deposit amount")
+// validateCurrency 校验币种为受支持的ISO-4217代码
+func validateCurrency(currency string) error {
+	if !model.IsSupportedCurrency(currency) {
+		return fmt.Errorf("%w: %q", ErrUnsupportedCurrency, currency)
 	}
+	return nil
+}
 
-	wallet, err := s.repo.GetWallet(ctx, userID)
+// validateAmount 校验币种受支持、金额为正数且精度不超过该币种的小数位数，
+// 返回调整为币种标准小数位数后的金额
+func validateAmount(currency string, amount decimal.Decimal) (decimal.Decimal, error) {
+	if err := validateCurrency(currency); err != nil {
+		return decimal.Zero, err
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: must be positive, got %s", ErrInvalidAmount, amount)
+	}
+	scale, _ := model.CurrencyScale(currency)
+	if !amount.FitsScale(scale) {
+		return decimal.Zero, fmt.Errorf("%w: %s has more than %d decimal places for %s", ErrInvalidAmount, amount, scale, currency)
+	}
+	return model.NormalizeAmount(amount, currency), nil
+}
+
+// walletKey 唯一标识一个钱包
+type walletKey struct {
+	UserID   int
+	Currency string
+}
+
+// lockWallets 在事务内按（用户ID，币种）升序对钱包加行锁，保证并发转账时加锁顺序一致以避免死锁；
+// 不存在的钱包在结果中对应nil
+func lockWallets(ctx context.Context, repo _interface.WalletRepository, keys ...walletKey) (map[walletKey]*model.Wallet, error) {
+	ordered := append([]walletKey(nil), keys...)
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].UserID != ordered[j].UserID {
+			return ordered[i].UserID < ordered[j].UserID
+		}
+		return ordered[i].Currency < ordered[j].Currency
+	})
+
+	wallets := make(map[walletKey]*model.Wallet, len(ordered))
+	for _, key := range ordered {
+		if _, locked := wallets[key]; locked {
+			continue
+		}
+		wallet, err := repo.GetWalletForUpdate(ctx, key.UserID, key.Currency)
+		if err != nil && !errors.Is(err, _interface.ErrWalletNotFound) {
+			return nil, err
+		}
+		wallets[key] = wallet
+	}
+	return wallets, nil
+}
+
+// Deposit 实现存款功能，返回存款后的钱包。钱包状态在事务内得到，
+// 调用方无需在提交后另行读取，读取失败也不会让已提交的存款看起来像是失败
+func (s *walletServiceImpl) Deposit(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	normalized, err := validateAmount(currency, amount)
 	if err != nil {
-		return s.handleWalletNotFoundError(userID, err)
+		logrus.Errorf("Invalid deposit amount: %s %s for user ID: %d", amount, currency, userID)
+		return nil, fmt.Errorf("Invalid deposit amount: %w", err)
 	}
-	if wallet == nil {
-		newWallet := model.Wallet{
-			UserID:      userID,
-			Balance:     amount,
-			LastUpdated: time.Now(),
+	amount = normalized
+
+	key := walletKey{UserID: userID, Currency: currency}
+	var updated *model.Wallet
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		wallets, err := lockWallets(ctx, repo, key)
+		if err != nil {
+			return s.handleWalletNotFoundError(userID, currency, err)
 		}
-		err = s.repo.InsertWallet(ctx, newWallet)
+		wallet := wallets[key]
+		if wallet == nil {
+			if !s.autoCreateWallets {
+				return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+			}
+			if err := s.checkMaxBalance(ctx, repo, userID, currency, decimal.Zero, amount); err != nil {
+				return err
+			}
+			newWallet := model.Wallet{
+				UserID:      userID,
+				Currency:    currency,
+				Balance:     amount,
+				LastUpdated: time.Now(),
+			}
+			err = repo.InsertWallet(ctx, newWallet)
+			if err != nil {
+				logrus.Errorf("Error creating new wallet with initial deposit for user ID %d: %v", userID, err)
+				return err
+			}
+			newWallet.Status = model.WalletActive
+			if err := recordEvent(ctx, repo, model.EventWalletCreated, userID, currency, newWallet); err != nil {
+				return err
+			}
+			updated = &newWallet
+		} else {
+			if err := ensureActive(wallet); err != nil {
+				return err
+			}
+			if err := s.checkMaxBalance(ctx, repo, userID, currency, wallet.Balance, amount); err != nil {
+				return err
+			}
+			logrus.Debugf("Going to update %s wallet balance for user ID %d. Current balance: %s, Deposit amount: %s", currency, userID, wallet.Balance, amount)
+			err = repo.UpdateWalletBalance(ctx, userID, currency, amount)
+			if err != nil {
+				logrus.Errorf("Error updating wallet balance for user ID %d: %v", userID, err)
+				return err
+			}
+			after := *wallet
+			after.Balance = wallet.Balance.Add(amount)
+			after.LastUpdated = time.Now()
+			updated = &after
+		}
+
+		// 记账：借记系统现金账户，贷记用户钱包
+		entryID, err := s.postEntry(ctx, repo, "deposit", fmt.Sprintf("deposit to user %d", userID),
+			debit(s.cashAccount, currency, amount), credit(model.WalletAccount(userID, currency), currency, amount))
 		if err != nil {
-			logrus.Errorf("Error creating new wallet with initial deposit for user ID %d: %v", userID, err)
 			return err
 		}
-	} else {
-		logrus.Debugf("Going to update wallet balance for user ID %d. Current balance: %f, Deposit amount: %f", userID, wallet.Balance, amount)
-		err = s.repo.UpdateWalletBalance(ctx, userID, amount)
+
+		// 记录交易
+		transaction := model.Transaction{
+			UserID:          userID,
+			Currency:        currency,
+			TransactionType: "deposit",
+			Amount:          amount,
+			TransactionTime: time.Now(),
+			EntryID:         entryID,
+			Actor:           actor(ctx),
+		}
+		err = repo.InsertTransaction(ctx, transaction)
 		if err != nil {
-			logrus.Errorf("Error updating wallet balance for user ID %d: %v", userID, err)
+			logrus.Errorf("Error inserting deposit transaction for user ID %d: %v", userID, err)
 			return err
 		}
-		logrus.Debugf("Wallet balance updated successfully for user ID %d. New balance: %f", userID, wallet.Balance+amount)
+		return recordEvent(ctx, repo, model.EventFundsDeposited, userID, currency, model.FundsEvent{
+			EntryID:      entryID,
+			Amount:       amount,
+			BalanceAfter: updated.Balance,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 记录交易
-	transaction := model.Transaction{
-		UserID:          userID,
-		TransactionType: "deposit",
-		Amount:          amount,
-		TransactionTime: time.Now(),
-	}
-	err = s.repo.InsertTransaction(ctx, transaction)
+	logrus.Infof("Deposit successful for user ID %d. New %s balance: %s", userID, currency, updated.Balance)
+	return updated, nil
+}
+
+// Withdraw 实现取款功能，返回取款后的钱包，钱包状态与Deposit一样在事务内得到
+func (s *walletServiceImpl) Withdraw(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	normalized, err := validateAmount(currency, amount)
 	if err != nil {
-		logrus.Errorf("Error inserting deposit transaction for user ID %d: %v", userID, err)
+		logrus.Errorf("Invalid withdrawal amount: %s %s for user ID: %d", amount, currency, userID)
+		return nil, fmt.Errorf("Invalid withdrawal amount: %w", err)
+	}
+	amount = normalized
+
+	fee := s.fee(model.FeeOperationWithdrawal, currency, amount)
+	var updated *model.Wallet
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		updated, _, err = s.withdrawTx(ctx, repo, userID, currency, amount, fee)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	logrus.Infof("Deposit successful for user ID %d. New balance: %f", userID, wallet.Balance+amount)
-	return nil
+	logrus.Infof("Withdrawal successful for user ID %d. Withdrawal amount: %s %s, fee: %s", userID, amount, currency, fee)
+	return updated, nil
 }
 
-// Withdraw 实现取款功能
-func (s *walletServiceImpl) Withdraw(ctx context.Context, userID int, amount float64) error {
-	if amount <= 0 {
-		logrus.Errorf("Invalid withdrawal amount: %f for user ID: %d", amount, userID)
-		return fmt.Errorf("Invalid withdrawal amount")
-	}
-
-	wallet, err := s.repo.GetWallet(ctx, userID)
+// withdrawTx 在调用方的事务内完成取款，返回取款后的钱包与凭证ID，金额须已校验；fee不为0时另行扣收手续费
+func (s *walletServiceImpl) withdrawTx(ctx context.Context, repo _interface.WalletRepository, userID int, currency string, amount, fee decimal.Decimal) (*model.Wallet, int, error) {
+	key := walletKey{UserID: userID, Currency: currency}
+	wallets, err := lockWallets(ctx, repo, key)
 	if err != nil {
-		return s.handleWalletNotFoundError(userID, err)
+		return nil, 0, s.handleWalletNotFoundError(userID, currency, err)
 	}
+	wallet := wallets[key]
 	if wallet == nil {
-		logrus.Errorf("Wallet not found for user ID %d", userID)
-		return fmt.Errorf("Wallet not found")
+		logrus.Errorf("%s wallet not found for user ID %d", currency, userID)
+		return nil, 0, fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+	}
+	if err := ensureActive(wallet); err != nil {
+		return nil, 0, err
 	}
 
-	if wallet.Balance < amount {
-		logrus.Errorf("Insufficient balance for user ID %d. Current balance: %f, Withdrawal amount: %f", userID, wallet.Balance, amount)
-		return fmt.Errorf("Insufficient balance")
+	// 可用余额检查在持有行锁的情况下进行，并发取款无法同时通过
+	available, err := availableBalance(ctx, repo, wallet)
+	if err != nil {
+		return nil, 0, err
+	}
+	if available.LessThan(amount.Add(fee)) {
+		logrus.Errorf("Insufficient balance for user ID %d. Available balance: %s, Withdrawal amount: %s, fee: %s", userID, available, amount, fee)
+		return nil, 0, fmt.Errorf("%w: user ID %d", ErrInsufficientFunds, userID)
+	}
+	if err := s.checkOutgoingLimits(ctx, repo, userID, currency, amount.Add(fee), false); err != nil {
+		return nil, 0, err
 	}
 
-	err = s.repo.UpdateWalletBalance(ctx, userID, -amount)
+	err = repo.UpdateWalletBalance(ctx, userID, currency, amount.Neg())
 	if err != nil {
 		logrus.Errorf("Error updating wallet balance during withdrawal for user ID %d: %v", userID, err)
-		return err
+		return nil, 0, err
+	}
+
+	// 记账：借记用户钱包，贷记系统现金账户
+	entryID, err := s.postEntry(ctx, repo, "withdrawal", fmt.Sprintf("withdrawal from user %d", userID),
+		debit(model.WalletAccount(userID, currency), currency, amount), credit(s.cashAccount, currency, amount))
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// 记录交易
 	transaction := model.Transaction{
 		UserID:          userID,
+		Currency:        currency,
 		TransactionType: "withdrawal",
 		Amount:          amount,
 		TransactionTime: time.Now(),
+		EntryID:         entryID,
+		Actor:           actor(ctx),
 	}
-	err = s.repo.InsertTransaction(ctx, transaction)
+	err = repo.InsertTransaction(ctx, transaction)
 	if err != nil {
 		logrus.Errorf("Error inserting withdrawal transaction for userID %d: %v", userID, err)
+		return nil, 0, err
+	}
+	if err := s.chargeFee(ctx, repo, model.FeeOperationWithdrawal, userID, currency, fee); err != nil {
+		return nil, 0, err
+	}
+	after := *wallet
+	after.Balance = wallet.Balance.Sub(amount).Sub(fee)
+	after.LastUpdated = time.Now()
+	err = recordEvent(ctx, repo, model.EventFundsWithdrawn, userID, currency, model.FundsEvent{
+		EntryID:      entryID,
+		Amount:       amount,
+		Fee:          eventFee(fee),
+		BalanceAfter: after.Balance,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &after, entryID, nil
+}
+
+// Transfer 实现同币种转账功能，扣款、入账与两条交易记录在同一事务中提交或回滚
+func (s *walletServiceImpl) Transfer(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error {
+	normalized, err := validateAmount(currency, amount)
+	if err != nil {
+		logrus.Errorf("Invalid transfer amount: %s %s from user ID %d to user ID %d", amount, currency, fromUserID, toUserID)
+		return fmt.Errorf("Invalid transfer amount: %w", err)
+	}
+	amount = normalized
+	if fromUserID == toUserID {
+		logrus.Errorf("Transfer from user ID %d to itself rejected", fromUserID)
+		return fmt.Errorf("%w: user ID %d", ErrSameWallet, fromUserID)
+	}
+
+	fee := s.fee(model.FeeOperationTransfer, currency, amount)
+	err = s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		_, err := s.transferTx(ctx, repo, fromUserID, toUserID, currency, amount, fee)
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	logrus.Infof("Withdrawal successful for user ID %d. New balance: %f", userID, wallet.Balance-amount)
+	logrus.Infof("Transfer successful from user ID %d to user ID %d. Transfer amount: %s %s, fee: %s", fromUserID, toUserID, amount, currency, fee)
 	return nil
 }
 
-// Transfer 实现转账功能
-func (s *walletServiceImpl) Transfer(ctx context.Context, fromUserID, toUserID int, amount float64) error {
-	if amount <= 0 {
-		logrus.Errorf("Invalid transfer amount: %f from user ID %d to user ID %d", amount, fromUserID, toUserID)
-		return fmt.Errorf("Invalid transfer amount")
+// transferTx 在调用方的事务内完成同币种转账并返回凭证ID，金额须已校验；fee不为0时另行向转出方扣收手续费
+func (s *walletServiceImpl) transferTx(ctx context.Context, repo _interface.WalletRepository, fromUserID, toUserID int, currency string, amount, fee decimal.Decimal) (int, error) {
+	fromKey := walletKey{UserID: fromUserID, Currency: currency}
+	toKey := walletKey{UserID: toUserID, Currency: currency}
+	// 按用户ID顺序锁定双方钱包
+	wallets, err := lockWallets(ctx, repo, fromKey, toKey)
+	if err != nil {
+		logrus.Errorf("Error locking wallets for transfer from user ID %d to user ID %d: %v", fromUserID, toUserID, err)
+		return 0, err
 	}
 
 	// 获取转出钱包
-	fromWallet, err := s.repo.GetWallet(ctx, fromUserID)
-	if err != nil {
-		logrus.Errorf("Error getting from wallet: %v", err)
-		return s.handleWalletNotFoundError(fromUserID, err)
-	}
+	fromWallet := wallets[fromKey]
 	if fromWallet == nil {
-		logrus.Errorf("From wallet not found for user ID %d", fromUserID)
-		logrus.Debugf("GetWallet returned nil for fromUserID %d. Check if this is correct in the test scenario.", fromUserID)
-		return fmt.Errorf("From wallet not found")
+		logrus.Errorf("From %s wallet not found for user ID %d", currency, fromUserID)
+		return 0, fmt.Errorf("%w: from user ID %d, currency %s", ErrWalletNotFound, fromUserID, currency)
 	}
-	logrus.Debugf("FromWallet details: UserID: %d, Balance: %f, LastUpdated: %v", fromWallet.UserID, fromWallet.Balance, fromWallet.LastUpdated)
+	logrus.Debugf("FromWallet details: UserID: %d, Currency: %s, Balance: %s, LastUpdated: %v", fromWallet.UserID, fromWallet.Currency, fromWallet.Balance, fromWallet.LastUpdated)
 
 	// 获取转入钱包
-	toWallet, err := s.repo.GetWallet(ctx, toUserID)
-	if err != nil {
-		logrus.Errorf("Error getting to wallet: %v", err)
-		return s.handleWalletNotFoundError(toUserID, err)
-	}
+	toWallet := wallets[toKey]
 	if toWallet == nil {
-		logrus.Errorf("To wallet not found for user ID %d", toUserID)
-		logrus.Debugf("GetWallet returned nil for toUserID %d. Check if this is correct in the test scenario.", toUserID)
-		return fmt.Errorf("To wallet not found")
+		logrus.Errorf("To %s wallet not found for user ID %d", currency, toUserID)
+		return 0, fmt.Errorf("%w: to user ID %d, currency %s", ErrWalletNotFound, toUserID, currency)
+	}
+	logrus.Debugf("ToWallet details: UserID: %d, Currency: %s, Balance: %s, LastUpdated: %v", toWallet.UserID, toWallet.Currency, toWallet.Balance, toWallet.LastUpdated)
+	for _, wallet := range []*model.Wallet{fromWallet, toWallet} {
+		if err := ensureActive(wallet); err != nil {
+			return 0, err
+		}
 	}
-	logrus.Debugf("ToWallet details: UserID: %d, Balance: %f, LastUpdated: %v", toWallet.UserID, toWallet.Balance, toWallet.LastUpdated)
 
-	// 检查转出钱包余额是否足够
-	if fromWallet.Balance < amount {
-		logrus.Errorf("Insufficient balance for from user ID %d. Current balance: %f, Transfer amount: %f", fromUserID, fromWallet.Balance, amount)
-		logrus.Debugf("FromWallet balance details for insufficient balance check: %+v", fromWallet)
-		return fmt.Errorf("Insufficient balance")
+	// 检查转出钱包可用余额是否足够
+	available, err := availableBalance(ctx, repo, fromWallet)
+	if err != nil {
+		return 0, err
+	}
+	if available.LessThan(amount.Add(fee)) {
+		logrus.Errorf("Insufficient balance for from user ID %d. Available balance: %s, Transfer amount: %s, fee: %s", fromUserID, available, amount, fee)
+		return 0, fmt.Errorf("%w: from user ID %d", ErrInsufficientFunds, fromUserID)
+	}
+	if err := s.checkOutgoingLimits(ctx, repo, fromUserID, currency, amount.Add(fee), true); err != nil {
+		return 0, err
+	}
+	if err := s.checkMaxBalance(ctx, repo, toUserID, currency, toWallet.Balance, amount); err != nil {
+		return 0, err
 	}
 
 	// 扣除转出钱包金额
-	err = s.repo.UpdateWalletBalance(ctx, fromUserID, -amount)
+	err = repo.UpdateWalletBalance(ctx, fromUserID, currency, amount.Neg())
 	if err != nil {
-		logrus.Errorf("Error updating from wallet balance during transfer for user ID %d: %v. Returning this error to the test case.", fromUserID, err)
-		return err
+		logrus.Errorf("Error updating from wallet balance during transfer for user ID %d: %v", fromUserID, err)
+		return 0, err
 	}
 
 	// 增加转入钱包金额
-	err = s.repo.UpdateWalletBalance(ctx, toUserID, amount)
+	err = repo.UpdateWalletBalance(ctx, toUserID, currency, amount)
 	if err != nil {
-		logrus.Errorf("Error updating to wallet balance during transfer for user ID %d: %v. Returning this error to the test case.", toUserID, err)
-		return err
+		logrus.Errorf("Error updating to wallet balance during transfer for user ID %d: %v", toUserID, err)
+		return 0, err
 	}
 
+	// 记账：借记转出钱包，贷记转入钱包，两条交易记录共享同一凭证
+	entryID, err := s.postEntry(ctx, repo, "transfer", fmt.Sprintf("transfer from user %d to user %d", fromUserID, toUserID),
+		debit(model.WalletAccount(fromUserID, currency), currency, amount), credit(model.WalletAccount(toUserID, currency), currency, amount))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
 	// 记录转出交易
 	fromTransaction := model.Transaction{
 		UserID:          fromUserID,
+		Currency:        currency,
 		TransactionType: "transfer_out",
 		Amount:          amount,
-		TransactionTime: time.Now(),
+		TransactionTime: now,
+		EntryID:         entryID,
+		Actor:           actor(ctx),
 	}
-	err = s.repo.InsertTransaction(ctx, fromTransaction)
+	err = repo.InsertTransaction(ctx, fromTransaction)
 	if err != nil {
-		logrus.Errorf("Error inserting transfer out transaction for user ID %d: %v. Returning this error to the test case.", fromUserID, err)
-		return err
+		logrus.Errorf("Error inserting transfer out transaction for user ID %d: %v", fromUserID, err)
+		return 0, err
 	}
 
 	// 记录转入交易
 	toTransaction := model.Transaction{
 		UserID:          toUserID,
+		Currency:        currency,
 		TransactionType: "transfer_in",
 		Amount:          amount,
-		TransactionTime: time.Now(),
+		TransactionTime: now,
+		EntryID:         entryID,
+		Actor:           actor(ctx),
 	}
-	err = s.repo.InsertTransaction(ctx, toTransaction)
+	err = repo.InsertTransaction(ctx, toTransaction)
 	if err != nil {
-		logrus.Errorf("Error inserting transfer in transaction for user ID %d: %v. Returning this error to the test case.", toUserID, err)
-		return err
+		logrus.Errorf("Error inserting transfer in transaction for user ID %d: %v", toUserID, err)
+		return 0, err
 	}
-
-	logrus.Infof("Transfer successful from user ID %d to user ID %d. Transfer amount: %f", fromUserID, toUserID, amount)
-	return nil
+	if err := s.chargeFee(ctx, repo, model.FeeOperationTransfer, fromUserID, currency, fee); err != nil {
+		return 0, err
+	}
+	err = recordCounterpartyEvent(ctx, repo, model.EventTransferCompleted, fromUserID, toUserID, currency, model.TransferEvent{
+		EntryID:    entryID,
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Amount:     amount,
+		Fee:        eventFee(fee),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return entryID, nil
 }
 
-// GetBalance 获取指定用户的钱包余额
-func (s *walletServiceImpl) GetBalance(ctx context.Context, userID int) (float64, error) {
-	wallet, err := s.repo.GetWallet(ctx, userID)
+// GetBalance 获取指定用户某币种钱包的账面余额与可用余额
+func (s *walletServiceImpl) GetBalance(ctx context.Context, userID int, currency string) (*model.Balance, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	balance := &model.Balance{UserID: userID, Currency: currency}
+	wallet, err := s.repo.GetWallet(ctx, userID, currency)
 	if err != nil {
-		return 0, s.handleWalletNotFoundError(userID, err)
+		return nil, s.handleWalletNotFoundError(userID, currency, err)
 	}
 	if wallet == nil {
-		logrus.Infof("Wallet not found for user ID %d. Returning balance 0", userID)
-		return 0, nil
+		return nil, fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
 	}
 
-	logrus.Infof("Balance retrieved for user ID %d. Balance: %f", userID, wallet.Balance)
-	return wallet.Balance, nil
+	held, err := s.repo.SumActiveHolds(ctx, userID, currency, time.Now())
+	if err != nil {
+		logrus.Errorf("Error summing active holds for user ID %d: %v", userID, err)
+		return nil, err
+	}
+	balance.Ledger = wallet.Balance
+	balance.Held = held
+	balance.Available = wallet.Balance.Sub(held)
+
+	logrus.Infof("Balance retrieved for user ID %d. Ledger: %s %s, available: %s %s", userID, balance.Ledger, currency, balance.Available, currency)
+	return balance, nil
 }
 
-// GetTransactionHistory 获取指定用户的交易历史记录
-func (s *walletServiceImpl) GetTransactionHistory(ctx context.Context, userID int) ([]model.Transaction, error) {
-	history, err := s.repo.GetTransactionHistory(ctx, userID)
-	if err != nil {
-		logrus.Errorf("Error getting transaction history for user ID %d: %v", userID, err)
+// GetWallet 获取指定用户某币种的钱包，钱包不存在时返回ErrWalletNotFound
+func (s *walletServiceImpl) GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	if err := validateCurrency(currency); err != nil {
 		return nil, err
 	}
+	wallet, err := s.repo.GetWallet(ctx, userID, currency)
+	if err != nil {
+		return nil, s.handleWalletNotFoundError(userID, currency, err)
+	}
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+	}
+	return wallet, nil
+}
 
-	logrus.Infof("Transaction history retrieved for user ID %d. Number of transactions: %d", userID, len(history))
-	return history, nil
+// ListWallets 获取指定用户的全部币种钱包，用户没有任何钱包时返回ErrWalletNotFound
+func (s *walletServiceImpl) ListWallets(ctx context.Context, userID int) ([]model.Wallet, error) {
+	wallets, err := s.repo.ListWallets(ctx, userID)
+	if err != nil {
+		logrus.Errorf("Error listing wallets for user ID %d: %v", userID, err)
+		return nil, err
+	}
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("%w: user ID %d", ErrWalletNotFound, userID)
+	}
+	return wallets, nil
 }
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"wallet-service/internal/model"
+	"wallet-service/internal/repository/interface"
+)
+
+// FreezeWallet 冻结钱包，冻结后钱包不能存取款、转账、换汇或预授权，人工调账除外
+func (s *walletServiceImpl) FreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	return s.setWalletStatus(ctx, userID, currency, model.WalletFrozen, reason)
+}
+
+// UnfreezeWallet 解冻钱包
+func (s *walletServiceImpl) UnfreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	return s.setWalletStatus(ctx, userID, currency, model.WalletActive, reason)
+}
+
+// CloseWallet 销户，只有余额与冻结金额都为0的active钱包可以销户，销户后不能再恢复
+func (s *walletServiceImpl) CloseWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	return s.setWalletStatus(ctx, userID, currency, model.WalletClosed, reason)
+}
+
+// setWalletStatus 持有钱包行锁按状态机更新状态，与进行中的资金变动串行化；状态未变化时直接返回
+func (s *walletServiceImpl) setWalletStatus(ctx context.Context, userID int, currency string, status model.WalletStatus, reason string) (*model.Wallet, error) {
+	if err := validateCurrency(currency); err != nil {
+		return nil, err
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: changing wallet status to %s", ErrReasonRequired, status)
+	}
+
+	var wallet *model.Wallet
+	err := s.repo.WithTx(ctx, func(repo _interface.WalletRepository) error {
+		key := walletKey{UserID: userID, Currency: currency}
+		wallets, err := lockWallets(ctx, repo, key)
+		if err != nil {
+			return err
+		}
+		wallet = wallets[key]
+		if wallet == nil {
+			return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletNotFound, userID, currency)
+		}
+		if wallet.Status == status {
+			return nil
+		}
+		if !wallet.Status.CanTransitionTo(status) {
+			return fmt.Errorf("%w: %s wallet of user ID %d cannot change from %s to %s", ErrInvalidStatusTransition, currency, userID, wallet.Status, status)
+		}
+		if status == model.WalletClosed {
+			available, err := availableBalance(ctx, repo, wallet)
+			if err != nil {
+				return err
+			}
+			if !wallet.Balance.IsZero() || !available.IsZero() {
+				return fmt.Errorf("%w: user ID %d, currency %s, balance %s, available %s", ErrWalletNotEmpty, userID, currency, wallet.Balance, available)
+			}
+		}
+		if err := repo.UpdateWalletStatus(ctx, userID, currency, status); err != nil {
+			logrus.Errorf("Error updating %s wallet status for user ID %d: %v", currency, userID, err)
+			return s.handleWalletNotFoundError(userID, currency, err)
+		}
+		event := model.WalletStatusChangedEvent{From: wallet.Status, To: status, Reason: reason}
+		wallet.Status = status
+		return recordEvent(ctx, repo, model.EventWalletStatusChanged, userID, currency, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("%s wallet of user ID %d is now %s. Reason: %s", currency, userID, status, reason)
+	return wallet, nil
+}
+
+// ensureActive 检查钱包允许资金变动，冻结的钱包返回ErrWalletFrozen，已销户的钱包返回ErrWalletClosed
+func ensureActive(wallet *model.Wallet) error {
+	if wallet.Status == model.WalletFrozen {
+		return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletFrozen, wallet.UserID, wallet.Currency)
+	}
+	return ensureOpen(wallet)
+}
+
+// ensureOpen 检查钱包未销户，用于冻结状态下仍允许的人工调账
+func ensureOpen(wallet *model.Wallet) error {
+	if wallet.Status == model.WalletClosed {
+		return fmt.Errorf("%w: user ID %d, currency %s", ErrWalletClosed, wallet.UserID, wallet.Currency)
+	}
+	return nil
+}
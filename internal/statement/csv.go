@@ -0,0 +1,51 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"wallet-service/internal/model"
+)
+
+// CSVWriter 以CSV格式写出对账单：首行为表头，期初、期末余额各占一行，
+// 每笔交易一行，amount入账为正、出账为负
+type CSVWriter struct {
+	w        *csv.Writer
+	currency string
+}
+
+// NewCSVWriter 创建写入w的CSVWriter
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteHeader 写出表头与期初余额行
+func (c *CSVWriter) WriteHeader(header model.StatementHeader) error {
+	c.currency = header.Currency
+	c.w.Write([]string{"time", "transaction_id", "type", "amount", "currency", "balance", "reversal_of", "reason"})
+	c.w.Write([]string{header.From.UTC().Format(time.RFC3339), "", "opening_balance", "", header.Currency, header.OpeningBalance.String(), "", ""})
+	return c.w.Error()
+}
+
+// WriteLine 写出一笔交易，csv.Writer缓冲满后即写入底层Writer
+func (c *CSVWriter) WriteLine(line model.StatementLine) error {
+	return c.w.Write([]string{
+		line.TransactionTime.UTC().Format(time.RFC3339Nano),
+		strconv.Itoa(line.TransactionID),
+		line.TransactionType,
+		line.Amount.String(),
+		c.currency,
+		line.Balance.String(),
+		formatID(line.ReversalOf),
+		line.Reason,
+	})
+}
+
+// WriteFooter 写出期末余额行并刷新缓冲
+func (c *CSVWriter) WriteFooter(summary model.StatementSummary) error {
+	c.w.Write([]string{"", "", "closing_balance", "", c.currency, summary.ClosingBalance.String(), "", ""})
+	c.w.Flush()
+	return c.w.Error()
+}
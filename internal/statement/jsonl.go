@@ -0,0 +1,43 @@
+package statement
+
+import (
+	"encoding/json"
+	"io"
+
+	"wallet-service/internal/model"
+)
+
+// JSONLWriter 以JSON Lines格式写出对账单，每行一个对象，record字段区分
+// header、transaction与summary
+type JSONLWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONLWriter 创建写入w的JSONLWriter
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteHeader 写出抬头
+func (j *JSONLWriter) WriteHeader(header model.StatementHeader) error {
+	return j.enc.Encode(struct {
+		Record string `json:"record"`
+		model.StatementHeader
+	}{"header", header})
+}
+
+// WriteLine 写出一笔交易
+func (j *JSONLWriter) WriteLine(line model.StatementLine) error {
+	return j.enc.Encode(struct {
+		Record string `json:"record"`
+		model.StatementLine
+	}{"transaction", line})
+}
+
+// WriteFooter 写出汇总
+func (j *JSONLWriter) WriteFooter(summary model.StatementSummary) error {
+	return j.enc.Encode(struct {
+		Record string `json:"record"`
+		model.StatementSummary
+	}{"summary", summary})
+}
@@ -0,0 +1,175 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"wallet-service/internal/model"
+)
+
+// PDF版式：A4纸、Courier等宽字体，列按空格对齐
+const (
+	pdfPageWidth     = 595
+	pdfPageHeight    = 842
+	pdfMargin        = 40
+	pdfFontSize      = 8
+	pdfLeading       = 11
+	pdfLinesPerPage  = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	pdfMaxLineLength = 107
+	pdfWrapIndent    = "    "
+	pdfTimeLayout    = "2006-01-02 15:04:05"
+)
+
+// 固定的对象编号，页面与内容流从pdfFirstDynamicObject开始编号
+const (
+	pdfCatalogObject      = 1
+	pdfPagesObject        = 2
+	pdfFontObject         = 3
+	pdfFirstDynamicObject = 4
+)
+
+// PDFWriter 以PDF格式写出对账单。每写满一页就输出该页的内容流，内存中最多保留一页文本；
+// 页面树与交叉引用表在WriteFooter时写出。内置的Courier字体只支持ASCII，其他字符显示为"?"
+type PDFWriter struct {
+	w        io.Writer
+	err      error
+	written  int64
+	offsets  map[int]int64
+	nextID   int
+	pageIDs  []int
+	lines    []string
+	currency string
+}
+
+// NewPDFWriter 创建写入w的PDFWriter
+func NewPDFWriter(w io.Writer) *PDFWriter {
+	return &PDFWriter{w: w, offsets: make(map[int]int64), nextID: pdfFirstDynamicObject}
+}
+
+// WriteHeader 写出文件头、目录与字体对象，并把抬头加入第一页
+func (p *PDFWriter) WriteHeader(header model.StatementHeader) error {
+	p.currency = header.Currency
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	p.object(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject))
+	p.object(pdfFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	p.addLine(fmt.Sprintf("Statement for wallet %d (%s)", header.UserID, header.Currency))
+	p.addLine(fmt.Sprintf("Period: %s - %s (UTC)", header.From.UTC().Format(pdfTimeLayout), header.To.UTC().Format(pdfTimeLayout)))
+	p.addLine(fmt.Sprintf("Generated: %s (UTC)", header.GeneratedAt.UTC().Format(pdfTimeLayout)))
+	p.addLine("")
+	p.addLine(fmt.Sprintf("%-19s %8s %-19s %16s %16s  %s", "Time", "ID", "Type", "Amount", "Balance", "Note"))
+	p.addLine(fmt.Sprintf("%-19s %8s %-19s %16s %16s", "", "", "Opening balance", "", header.OpeningBalance))
+	return p.err
+}
+
+// WriteLine 把一笔交易加入当前页，页满时先输出当前页
+func (p *PDFWriter) WriteLine(line model.StatementLine) error {
+	note := ""
+	if line.ReversalOf != 0 {
+		note = fmt.Sprintf("reversal of %d: %s", line.ReversalOf, line.Reason)
+	}
+	p.addLine(fmt.Sprintf("%-19s %8d %-19s %16s %16s  %s", line.TransactionTime.UTC().Format(pdfTimeLayout), line.TransactionID,
+		line.TransactionType, line.Amount, line.Balance, note))
+	return p.err
+}
+
+// WriteFooter 写出汇总与最后一页，再写出页面树、交叉引用表与文件尾
+func (p *PDFWriter) WriteFooter(summary model.StatementSummary) error {
+	p.addLine(fmt.Sprintf("%-19s %8s %-19s %16s %16s", "", "", "Closing balance", "", summary.ClosingBalance))
+	p.addLine("")
+	p.addLine(fmt.Sprintf("Transactions: %d  Credits: %s %s  Debits: %s %s", summary.TransactionCount, summary.TotalCredits, p.currency, summary.TotalDebits, p.currency))
+	p.flushPage()
+
+	kids := make([]string, len(p.pageIDs))
+	for i, id := range p.pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.object(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pageIDs)))
+
+	xref := p.written
+	size := p.nextID
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", size)
+	for id := 1; id < size; id++ {
+		p.printf("%010d 00000 n \n", p.offsets[id])
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, pdfCatalogObject, xref)
+	return p.err
+}
+
+// addLine 把一行文本加入当前页，超出页宽的部分缩进后折到下一行
+func (p *PDFWriter) addLine(text string) {
+	runes := []rune(text)
+	for first := true; first || len(runes) > 0; first = false {
+		n := pdfMaxLineLength
+		if !first {
+			runes = append([]rune(pdfWrapIndent), runes...)
+		}
+		if len(runes) < n {
+			n = len(runes)
+		}
+		if len(p.lines) == pdfLinesPerPage {
+			p.flushPage()
+		}
+		p.lines = append(p.lines, string(runes[:n]))
+		runes = runes[n:]
+	}
+}
+
+// flushPage 输出当前页的内容流与页面对象
+func (p *PDFWriter) flushPage() {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+	for _, line := range p.lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	content.WriteString("ET")
+	p.lines = p.lines[:0]
+
+	contentID := p.allocate()
+	p.object(contentID, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	pageID := p.allocate()
+	p.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfFontObject, contentID))
+	p.pageIDs = append(p.pageIDs, pageID)
+}
+
+// allocate 分配下一个对象编号
+func (p *PDFWriter) allocate() int {
+	id := p.nextID
+	p.nextID++
+	return id
+}
+
+// object 写出一个间接对象并记录其偏移量
+func (p *PDFWriter) object(id int, body string) {
+	p.offsets[id] = p.written
+	p.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// printf 写入底层Writer，出错后不再写入，错误由各Write方法返回
+func (p *PDFWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.written += int64(n)
+	p.err = err
+}
+
+// pdfEscape 转义PDF字符串中的特殊字符，把非ASCII字符替换为"?"
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"wallet-service/internal/service"
+)
+
+// ErrUnsupportedFormat 表示不支持的对账单格式
+var ErrUnsupportedFormat = errors.New("unsupported statement format")
+
+// 支持的对账单格式
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatPDF   = "pdf"
+)
+
+// contentTypes 各格式对应的Content-Type
+var contentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJSONL: "application/x-ndjson",
+	FormatPDF:   "application/pdf",
+}
+
+// NewWriter 创建把对账单以format格式写入w的StatementWriter，写出的内容不在内存中累积
+func NewWriter(format string, w io.Writer) (service.StatementWriter, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatJSONL:
+		return NewJSONLWriter(w), nil
+	case FormatPDF:
+		return NewPDFWriter(w), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
+// ContentType 返回format对应的Content-Type，不支持的格式返回空字符串
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// DateLayout 对账单期间除RFC3339外还接受的日期格式，按UTC零点解析
+const DateLayout = "2006-01-02"
+
+// ParseTime 解析对账单期间的起止时间，接受RFC3339时间或YYYY-MM-DD日期
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(DateLayout, value)
+}
+
+// formatID 将可选的交易ID格式化为字符串，0输出为空
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
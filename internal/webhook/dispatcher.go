@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+// 推送的默认配置
+const (
+	defaultMaxAttempts = 8
+	defaultBackoffBase = 30 * time.Second
+	defaultBackoffMax  = 6 * time.Hour
+	defaultTimeout     = 10 * time.Second
+	defaultBatchSize   = 50
+	// leaseMargin 为推送租期在一批推送最长耗时之外预留的时间，用于读取推送地址与写回推送结果
+	leaseMargin = time.Minute
+	// maxDrainBody 为复用连接而读取并丢弃的响应体最大字节数，响应体不会被记录
+	maxDrainBody = 4 << 10
+)
+
+// Dispatcher 把到期的推送以签名的POST请求发送到推送地址：2xx响应视为成功，
+// 其它结果按指数退避重试，失败次数达到上限后转为dead，只能通过重放再次推送
+type Dispatcher struct {
+	repo        _interface.WebhookRepository
+	client      *http.Client
+	timeout     time.Duration
+	maxAttempts int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	batchSize   int
+}
+
+// Option 用于在创建Dispatcher时调整可选配置
+type Option func(*Dispatcher)
+
+// WithTimeout 设置单次推送的超时时间，默认为10s；推送租期随之调整
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.timeout = timeout
+		}
+	}
+}
+
+// WithHTTPClient 替换发送推送使用的HTTP客户端。默认客户端只连接公网地址且不跟随重定向，
+// 替换后不再检查目标地址，仅用于测试
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		if client != nil {
+			d.client = client
+		}
+	}
+}
+
+// WithMaxAttempts 设置推送转为dead前的最多推送次数，默认为8次
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.maxAttempts = n
+		}
+	}
+}
+
+// WithBackoff 设置重试间隔：第n次失败后等待base×2^(n-1)，最长为max；默认为30s与6h
+func WithBackoff(base, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		if base > 0 {
+			d.backoffBase = base
+		}
+		if max > 0 {
+			d.backoffMax = max
+		}
+	}
+}
+
+// NewDispatcher 创建Dispatcher
+func NewDispatcher(repo _interface.WebhookRepository, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		repo:        repo,
+		timeout:     defaultTimeout,
+		maxAttempts: defaultMaxAttempts,
+		backoffBase: defaultBackoffBase,
+		backoffMax:  defaultBackoffMax,
+		batchSize:   defaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.client == nil {
+		d.client = newHTTPClient(d.timeout)
+	}
+	return d
+}
+
+// Backoff 返回第attempts次失败后到下次推送的等待时间
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	wait := d.backoffBase
+	for i := 1; i < attempts && wait < d.backoffMax; i++ {
+		wait *= 2
+	}
+	if wait > d.backoffMax {
+		wait = d.backoffMax
+	}
+	return wait
+}
+
+// Lease 返回领取一批推送后其它实例不会重复领取的时长。每次推送最多耗时timeout，
+// 租期覆盖整批推送全部超时的情况，避免推送未完成时被其它实例重复领取
+func (d *Dispatcher) Lease() time.Duration {
+	return time.Duration(d.batchSize)*d.timeout + leaseMargin
+}
+
+// DeliverDue 领取并推送一批到期的推送，返回本轮推送的条数
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	deliveries, err := d.repo.ClaimDueWebhookDeliveries(ctx, now, now.Add(d.Lease()), d.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	endpoints := make(map[int]*model.WebhookEndpoint)
+	for _, delivery := range deliveries {
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = d.repo.GetWebhookEndpoint(ctx, delivery.EndpointID)
+			if err != nil {
+				return 0, fmt.Errorf("get webhook endpoint %d: %w", delivery.EndpointID, err)
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+		d.deliver(ctx, &delivery, endpoint)
+		if err := d.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			return 0, fmt.Errorf("update webhook delivery %d: %w", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver 推送一次并按结果更新delivery的状态
+func (d *Dispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery, endpoint *model.WebhookEndpoint) {
+	if !endpoint.Active {
+		delivery.Status = model.WebhookDead
+		delivery.LastError = "endpoint disabled"
+		return
+	}
+
+	delivery.Attempts++
+	statusCode, err := d.post(ctx, delivery, endpoint)
+	delivery.LastStatusCode = statusCode
+	now := time.Now()
+	if err == nil {
+		delivery.Status = model.WebhookSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = model.WebhookDead
+		logrus.Warnf("Webhook delivery %d to endpoint %d is dead after %d attempts: %v", delivery.ID, endpoint.ID, delivery.Attempts, err)
+		return
+	}
+	delivery.NextAttemptAt = now.Add(d.Backoff(delivery.Attempts))
+	logrus.Infof("Webhook delivery %d to endpoint %d failed (attempt %d), retrying at %v: %v", delivery.ID, endpoint.ID, delivery.Attempts, delivery.NextAttemptAt, err)
+}
+
+// post 发送签名的推送请求，返回响应状态码；非2xx响应返回错误。响应体不会被记录，
+// 以免推送地址的所有者借推送读取其它服务的响应
+func (d *Dispatcher) post(ctx context.Context, delivery *model.WebhookDelivery, endpoint *model.WebhookEndpoint) (int, error) {
+	// 超时同时作用于替换的HTTP客户端，保证单次推送不超过租期的计算依据
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, signaturePrefix+Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Run 每隔interval推送一次到期的推送，一轮领满一批时立即继续，直到ctx取消
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		delivered, err := d.DeliverDue(ctx)
+		if err != nil {
+			logrus.Errorf("Error dispatching webhooks: %v", err)
+		}
+		if err == nil && delivered == d.batchSize && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"wallet-service/internal/audit"
+	"wallet-service/internal/auth"
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+	"wallet-service/internal/service"
+)
+
+// systemOwner 是进程内注册的推送地址的所有者
+const systemOwner = "system"
+
+// secretPrefix 是签名密钥的前缀，便于在配置与日志中识别
+const secretPrefix = "whsec_"
+
+// Manager 管理推送地址与推送记录。context中有调用方时，只有拥有ScopeWebhooksAdmin的调用方
+// 可以访问他人注册的推送地址与重放推送；没有调用方时视为进程内的受信任调用
+type Manager struct {
+	repo _interface.WebhookRepository
+	// allowPrivate 为true时允许注册指向本机与内网地址的推送地址
+	allowPrivate bool
+	// auditLog 不为nil时为注册、停用推送地址与重放推送追加审计记录
+	auditLog AuditWriter
+}
+
+// AuditWriter 追加审计记录，由钱包仓库实现
+type AuditWriter interface {
+	InsertAuditEntry(ctx context.Context, entry model.AuditEntry) error
+}
+
+// ManagerOption 用于在创建Manager时调整可选配置
+type ManagerOption func(*Manager)
+
+// WithPrivateAddresses 允许注册指向本机与内网地址的推送地址，仅用于测试与本地开发；
+// 推送时是否能连接这些地址由Dispatcher的HTTP客户端决定
+func WithPrivateAddresses() ManagerOption {
+	return func(m *Manager) {
+		m.allowPrivate = true
+	}
+}
+
+// WithAuditLog 为注册、停用推送地址与重放推送追加审计记录，记录调用方与请求元数据
+func WithAuditLog(writer AuditWriter) ManagerOption {
+	return func(m *Manager) {
+		m.auditLog = writer
+	}
+}
+
+// NewManager 创建Manager
+func NewManager(repo _interface.WebhookRepository, opts ...ManagerOption) *Manager {
+	m := &Manager{repo: repo}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// RegisterEndpoint 注册推送地址并生成签名密钥，密钥只在返回值中出现一次；
+// 推送地址只接收调用方有权读取的钱包的事件
+func (m *Manager) RegisterEndpoint(ctx context.Context, spec model.WebhookEndpointSpec) (*model.WebhookEndpoint, error) {
+	scope, ownerUserID, err := endpointScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validateURL(spec.URL); err != nil {
+		return nil, err
+	}
+	eventTypes, err := normalizeEventTypes(spec.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &model.WebhookEndpoint{
+		URL:         spec.URL,
+		EventTypes:  eventTypes,
+		Description: strings.TrimSpace(spec.Description),
+		Owner:       owner(ctx),
+		Scope:       scope,
+		OwnerUserID: ownerUserID,
+		Secret:      secret,
+		Active:      true,
+		CreatedAt:   time.Now(),
+	}
+	endpoint.ID, err = m.repo.InsertWebhookEndpoint(ctx, *endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.audit(ctx, "webhook.register", "endpoint_id", strconv.Itoa(endpoint.ID), "url", endpoint.URL, "scope", string(endpoint.Scope)); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// ListEndpoints 返回调用方注册的推送地址，管理员返回全部，不含签名密钥
+func (m *Manager) ListEndpoints(ctx context.Context) ([]model.WebhookEndpoint, error) {
+	filter := owner(ctx)
+	if isAdmin(ctx) {
+		filter = ""
+	}
+	endpoints, err := m.repo.ListWebhookEndpoints(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+// DisableEndpoint 停用推送地址，尚未成功的推送在下次推送时转为dead
+func (m *Manager) DisableEndpoint(ctx context.Context, id int) (*model.WebhookEndpoint, error) {
+	endpoint, err := m.endpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.DisableWebhookEndpoint(ctx, id); err != nil {
+		return nil, notFound(err, id)
+	}
+	if err := m.audit(ctx, "webhook.disable", "endpoint_id", strconv.Itoa(id), "url", endpoint.URL); err != nil {
+		return nil, err
+	}
+	endpoint.Active = false
+	return endpoint, nil
+}
+
+// ListDeliveries 按ID降序返回推送地址的推送记录，status为空时不过滤
+func (m *Manager) ListDeliveries(ctx context.Context, endpointID int, status model.WebhookDeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	if _, err := m.endpoint(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	return m.repo.ListWebhookDeliveries(ctx, model.WebhookDeliveryFilter{EndpointID: endpointID, Status: status, Limit: limit})
+}
+
+// ReplayDelivery 把推送重置为立即推送并清零失败次数，可用于dead的推送或要求对方重新接收已成功的推送；
+// 只有管理员可以重放，推送地址须仍处于启用状态
+func (m *Manager) ReplayDelivery(ctx context.Context, id int) (*model.WebhookDelivery, error) {
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("%w: %s permission is required", service.ErrForbidden, auth.ScopeWebhooksAdmin)
+	}
+	delivery, err := m.repo.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, _interface.ErrWebhookDeliveryNotFound) {
+			return nil, fmt.Errorf("%w: delivery ID %d", service.ErrWebhookDeliveryNotFound, id)
+		}
+		return nil, err
+	}
+	endpoint, err := m.endpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.Active {
+		return nil, fmt.Errorf("%w: endpoint %d is disabled", service.ErrInvalidWebhook, endpoint.ID)
+	}
+
+	delivery.Status = model.WebhookPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := m.repo.UpdateWebhookDelivery(ctx, *delivery); err != nil {
+		return nil, err
+	}
+	if err := m.audit(ctx, "webhook.replay", "delivery_id", strconv.Itoa(id), "endpoint_id", strconv.Itoa(endpoint.ID),
+		"event_id", strconv.Itoa(delivery.EventID)); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// audit 以context中的调用方与请求元数据追加一条审计记录，kv为成对的键与值。推送地址与审计记录不在同一事务中，
+// 写入失败时变更已经生效，返回错误使调用方知道审计记录缺失
+func (m *Manager) audit(ctx context.Context, action string, kv ...string) error {
+	if m.auditLog == nil {
+		return nil
+	}
+	request := audit.FromContext(ctx)
+	entry := model.AuditEntry{
+		Action:    action,
+		Actor:     owner(ctx),
+		RequestID: request.ID,
+		ClientIP:  request.ClientIP,
+		Details:   make(map[string]string, len(kv)/2),
+		CreatedAt: time.Now(),
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		entry.Details[kv[i]] = kv[i+1]
+	}
+	if err := m.auditLog.InsertAuditEntry(ctx, entry); err != nil {
+		logrus.Errorf("Error writing %s audit entry: %v", action, err)
+		return err
+	}
+	return nil
+}
+
+// endpoint 读取推送地址并检查调用方是其所有者或管理员，返回值不含签名密钥
+func (m *Manager) endpoint(ctx context.Context, id int) (*model.WebhookEndpoint, error) {
+	endpoint, err := m.repo.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return nil, notFound(err, id)
+	}
+	if endpoint.Owner != owner(ctx) && !isAdmin(ctx) {
+		return nil, fmt.Errorf("%w: webhook endpoint %d belongs to another owner", service.ErrForbidden, id)
+	}
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+// notFound 把仓库层的推送地址不存在错误转换为领域错误
+func notFound(err error, id int) error {
+	if errors.Is(err, _interface.ErrWebhookEndpointNotFound) {
+		return fmt.Errorf("%w: endpoint ID %d", service.ErrWebhookEndpointNotFound, id)
+	}
+	return err
+}
+
+// owner 返回context中调用方的标识，没有调用方时为systemOwner
+func owner(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.Subject
+	}
+	return systemOwner
+}
+
+// endpointScope 按调用方的权限确定推送地址可以接收的事件范围：推送管理员与进程内调用接收全部事件，
+// 其他调用方须能读取钱包，终端用户只接收自己钱包的事件
+func endpointScope(ctx context.Context) (model.WebhookScope, int, error) {
+	principal := auth.FromContext(ctx)
+	switch {
+	case isAdmin(ctx):
+		return model.WebhookScopeAll, 0, nil
+	case !principal.HasScope(auth.ScopeWalletsRead):
+		return "", 0, fmt.Errorf("%w: %s permission is required to receive wallet events", service.ErrForbidden, auth.ScopeWalletsRead)
+	case principal.IsUser():
+		return model.WebhookScopeUser, principal.UserID, nil
+	}
+	return model.WebhookScopeWallets, 0, nil
+}
+
+// isAdmin 报告调用方能否管理全部推送地址
+func isAdmin(ctx context.Context) bool {
+	principal := auth.FromContext(ctx)
+	return principal == nil || principal.HasScope(auth.ScopeWebhooksAdmin)
+}
+
+// validateURL 校验推送地址为带主机名、不含用户信息的https地址，且不指向本机或内网地址
+func (m *Manager) validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("%w: url must be an absolute https URL, got %q", service.ErrInvalidWebhook, raw)
+	}
+	if m.allowPrivate {
+		return nil
+	}
+	if err := checkHost(u.Hostname()); err != nil {
+		return fmt.Errorf("%w: url must not point to a loopback, private or link-local address: %v", service.ErrInvalidWebhook, err)
+	}
+	return nil
+}
+
+// normalizeEventTypes 校验事件类型均已定义并去除重复
+func normalizeEventTypes(eventTypes []string) ([]string, error) {
+	seen := make(map[string]bool, len(eventTypes))
+	normalized := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !model.IsEventType(eventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", service.ErrInvalidWebhook, eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			normalized = append(normalized, eventType)
+		}
+	}
+	return normalized, nil
+}
+
+// newSecret 生成随机的签名密钥
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
+)
+
+// Publisher 实现outbox.Publisher：为订阅了事件且事件所属钱包在其范围内的每个启用的推送地址
+// 生成一条待推送记录，实际推送由Dispatcher完成。同一事件被重复转发时不会重复生成推送
+type Publisher struct {
+	repo _interface.WebhookRepository
+}
+
+// NewPublisher 创建Publisher
+func NewPublisher(repo _interface.WebhookRepository) *Publisher {
+	return &Publisher{repo: repo}
+}
+
+func (p *Publisher) Publish(ctx context.Context, event model.Event) error {
+	endpoints, err := p.repo.ListWebhookEndpoints(ctx, "")
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, endpoint := range endpoints {
+		if !endpoint.Active || !endpoint.Receives(event) {
+			continue
+		}
+		err := p.repo.InsertWebhookDelivery(ctx, model.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       body,
+			Status:        model.WebhookPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
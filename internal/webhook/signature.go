@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 推送请求携带的请求头
+const (
+	// HeaderEvent 为事件类型
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery 为推送ID，重试与重放时不变，接收方可据此去重
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderTimestamp 为本次推送的Unix秒时间戳，每次推送重新生成
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature 为 "sha256=" 加签名的十六进制值
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix 是HeaderSignature中签名值的前缀
+const signaturePrefix = "sha256="
+
+var (
+	// ErrInvalidSignature 表示签名缺失或与请求体不符
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleTimestamp 表示时间戳缺失或与当前时间的偏差超出容忍范围，可能是重放攻击
+	ErrStaleTimestamp = errors.New("stale webhook timestamp")
+)
+
+// Sign 返回以secret计算的HMAC-SHA256(时间戳 + "." + 请求体)的十六进制值
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 供接收方校验推送请求：时间戳与now的偏差不超过tolerance，且签名与请求体一致
+func Verify(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > tolerance || skew < -tolerance {
+		return ErrStaleTimestamp
+	}
+	signature := header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(strings.TrimPrefix(signature, signaturePrefix)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -1,19 +1,63 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
 
 	"wallet-service/internal/api"
+	"wallet-service/internal/auth"
+	"wallet-service/internal/cli"
 	"wallet-service/internal/config"
 	"wallet-service/internal/database"
+	"wallet-service/internal/fx"
+	"wallet-service/internal/grpcapi"
 	"wallet-service/internal/logger"
+	"wallet-service/internal/model"
+	"wallet-service/internal/outbox"
 	"wallet-service/internal/repository"
+	_interface "wallet-service/internal/repository/interface"
 	"wallet-service/internal/service"
+	"wallet-service/internal/webhook"
 )
 
+// usage 是命令行用法说明，不带命令时启动HTTP服务
+const usage = `usage: main [command] [arguments]
+
+commands:
+  serve
+  migrate up | down [steps] | status
+` + cli.Usage
+
 func main() {
+	logger.InitLogger()
+
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+		return
+	}
+	if command != "serve" {
+		// 运维命令的结果写到标准输出，日志改写到标准错误
+		logger.Log.SetOutput(os.Stderr)
+	}
+
 	// 加载配置
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -30,35 +74,334 @@ func main() {
 		if db == nil {
 			logger.Log.Errorf("数据库连接对象为nil，具体错误: %v，请检查数据库连接逻辑", err)
 		}
-		return
+		os.Exit(1)
+	}
+
+	err = run(context.Background(), cfg, db, command, args)
+	db.Close()
+	if err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			fmt.Fprint(os.Stderr, usage)
+		}
+		logger.Log.Errorf("%s失败: %v", command, err)
+		os.Exit(1)
+	}
+}
+
+// run 执行命令，serve与migrate之外的命令交给cli.Admin
+func run(ctx context.Context, cfg *config.Config, db *sql.DB, command string, args []string) error {
+	switch command {
+	case "serve":
+		if len(args) > 0 {
+			return fmt.Errorf("%w: serve takes no arguments", cli.ErrUsage)
+		}
+		return serve(cfg, db)
+	case "migrate":
+		err := runMigrate(ctx, db, args)
+		if errors.Is(err, errMigrateUsage) {
+			return fmt.Errorf("%w: %v", cli.ErrUsage, err)
+		}
+		return err
+	}
+	walletService, err := newWalletService(cfg, db)
+	if err != nil {
+		return err
+	}
+	// 运维命令以当前系统用户的身份执行，产生的交易记录为operator:{用户名}
+	ctx = auth.NewContext(ctx, auth.Operator(operatorName()))
+	return cli.NewAdmin(walletService, os.Stdout).Run(ctx, append([]string{command}, args...))
+}
+
+// operatorName 返回执行运维命令的系统用户名
+func operatorName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
 	}
-	defer db.Close()
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
 
-	// 创建存储库和服务实例（可能会用到数据库连接等配置）
+// newWalletService 根据配置创建钱包服务
+func newWalletService(cfg *config.Config, db *sql.DB) (service.WalletService, error) {
 	repo := repository.NewRepository(db)
-	if repo == nil {
-		logger.Log.Errorf("存储库实例为nil，请检查存储库创建逻辑")
-		return
+	serviceOpts := []service.Option{service.WithCashAccount(cfg.LedgerCashAccount), service.WithHoldTTL(cfg.HoldTTL), service.WithAutoCreateWallets(cfg.AutoCreateWallets)}
+	fxProvider, err := newFXRateProvider(cfg.FX)
+	if err != nil {
+		return nil, fmt.Errorf("加载汇率源失败: %w", err)
 	}
-	walletService := service.NewWalletService(repo)
-	if walletService == nil {
-		logger.Log.Errorf("钱包服务实例为nil，请检查服务创建逻辑")
-		return
+	if fxProvider != nil {
+		serviceOpts = append(serviceOpts, service.WithFXRateProvider(fxProvider, cfg.FX.Spread, cfg.FX.QuoteTTL))
+	} else {
+		logger.Log.Warn("未配置FX_RATES_URL或FX_RATES_FILE，换汇功能不可用")
 	}
+	if cfg.Ledger.CheckpointKeyFile != "" {
+		key, err := loadCheckpointKey(cfg.Ledger.CheckpointKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载检查点签名密钥失败: %w", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithCheckpointKey(key))
+	}
+	if cfg.LimitsFile != "" {
+		defaults, err := loadDefaultLimits(cfg.LimitsFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载默认限额失败: %w", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithDefaultLimits(defaults))
+	}
+	if cfg.FeesFile != "" {
+		fees, err := loadFeeSchedule(cfg.FeesFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载费率表失败: %w", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithFeeSchedule(fees))
+	}
+	return service.NewWalletService(repo, serviceOpts...), nil
+}
 
-	// 创建API实例
-	api := api.NewAPI(walletService)
-	if api == nil {
-		logger.Log.Errorf("API实例为nil，请检查API创建逻辑")
-		return
+// loadFeeSchedule 读取并校验费率表，JSON对象的第一层键为操作类型（withdrawal、transfer），第二层键为币种代码或"*"，如
+// {"withdrawal": {"*": {"flat": "1.00", "percent": "0.005", "min": "1.00", "max": "50.00"}},
+// "transfer": {"USD": {"tiers": [{"up_to": "1000", "percent": "0.01"}, {"flat": "5.00"}]}}}
+func loadFeeSchedule(path string) (model.FeeSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fees model.FeeSchedule
+	if err := json.Unmarshal(data, &fees); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := fees.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fees, nil
+}
+
+// loadDefaultLimits 读取默认限额配置，JSON对象的键为币种代码或"*"，值与钱包限额的字段相同，如
+// {"*": {"max_single_withdrawal": "10000"}, "JPY": {"max_single_withdrawal": "1000000", "max_transfers_per_hour": 20}}
+func loadDefaultLimits(path string) (map[string]model.WalletLimits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var defaults map[string]model.WalletLimits
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for currency, limits := range defaults {
+		if currency != service.DefaultLimitsFallback && !model.IsSupportedCurrency(currency) {
+			return nil, fmt.Errorf("unsupported currency %q in %s", currency, path)
+		}
+		for name, amount := range limits.Amounts() {
+			if amount != nil && amount.IsNegative() {
+				return nil, fmt.Errorf("%s of %s must not be negative in %s", name, currency, path)
+			}
+		}
+		if limits.MaxTransfersPerHour != nil && *limits.MaxTransfersPerHour < 0 {
+			return nil, fmt.Errorf("max_transfers_per_hour of %s must not be negative in %s", currency, path)
+		}
+	}
+	return defaults, nil
+}
+
+// loadCheckpointKey 读取Ed25519签名私钥，支持PKCS#8 PEM（如 openssl genpkey -algorithm ed25519 生成）与base64编码的32字节种子
+func loadCheckpointKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an Ed25519 private key", path)
+		}
+		return key, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s must be a PKCS#8 PEM key or a base64 encoded %d-byte seed", path, ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// serve 按配置执行迁移后启动HTTP服务
+func serve(cfg *config.Config, db *sql.DB) error {
+	if cfg.AutoMigrate {
+		if err := migrateUp(context.Background(), db); err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+	}
+
+	walletService, err := newWalletService(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	// 定期将到期的预授权标记为过期
+	go expireHolds(walletService)
+
+	// 配置了签名密钥时定期为哈希链生成检查点
+	if cfg.Ledger.CheckpointKeyFile != "" {
+		go createCheckpoints(walletService, cfg.Ledger.CheckpointInterval)
+	} else {
+		logger.Log.Warn("未配置LEDGER_CHECKPOINT_KEY_FILE，不生成哈希链检查点")
+	}
+
+	// 把发件箱中的领域事件转发给事件推送，配置了发布方式时同时转发给下游
+	webhookRepo := repository.NewWebhookRepository(db)
+	publishers := []outbox.Publisher{webhook.NewPublisher(webhookRepo)}
+	publisher, closePublisher, err := newOutboxPublisher(cfg.Outbox)
+	if err != nil {
+		return fmt.Errorf("创建领域事件发布器失败: %w", err)
+	}
+	if publisher != nil {
+		defer closePublisher()
+		publishers = append(publishers, publisher)
+	}
+	relay := outbox.NewRelay(repository.NewOutboxRepository(db), outbox.Fanout(publishers...))
+	go relay.Run(context.Background(), cfg.Outbox.RelayInterval)
+
+	// 定期推送到期的事件推送
+	dispatcher := webhook.NewDispatcher(webhookRepo,
+		webhook.WithTimeout(cfg.Webhook.Timeout),
+		webhook.WithMaxAttempts(cfg.Webhook.MaxAttempts),
+		webhook.WithBackoff(cfg.Webhook.BackoffBase, cfg.Webhook.BackoffMax),
+	)
+	go dispatcher.Run(context.Background(), cfg.Webhook.DispatchInterval)
+
+	// 幂等键存储，并定期清理过期的幂等键
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	go purgeExpiredIdempotencyKeys(idempotencyRepo)
+
+	apiOpts := []api.Option{
+		api.WithIdempotency(idempotencyRepo, cfg.IdempotencyTTL),
+		api.WithDefaultCurrency(cfg.DefaultCurrency),
+		api.WithTrustedProxies(cfg.TrustedProxies),
+		api.WithWebhooks(webhook.NewManager(webhookRepo, webhook.WithAuditLog(repository.NewRepository(db)))),
+	}
+	authenticator, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("加载认证配置失败: %w", err)
+	}
+	if authenticator != nil {
+		apiOpts = append(apiOpts, api.WithAuthenticator(authenticator))
+	} else {
+		logger.Log.Warn("AUTH_DISABLED=true，API未启用认证，任何调用方都可以操作任意钱包")
 	}
 
+	// 配置了端口时在单独的端口上提供gRPC接口，与HTTP接口共用同一个钱包服务与认证配置
+	errs := make(chan error, 2)
+	if cfg.GRPCPort != 0 {
+		grpcOpts := []grpcapi.Option{grpcapi.WithDefaultCurrency(cfg.DefaultCurrency)}
+		if authenticator != nil {
+			grpcOpts = append(grpcOpts, grpcapi.WithAuthenticator(authenticator))
+		}
+		grpcServer := grpcapi.NewServer(walletService, grpcOpts...).GRPCServer()
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("监听gRPC端口失败: %w", err)
+		}
+		logger.Log.Infof("gRPC服务启动，监听地址: %s", listener.Addr())
+		go func() { errs <- grpcServer.Serve(listener) }()
+	}
+
+	// 创建API实例
+	api := api.NewAPI(walletService, apiOpts...)
+
 	// 定义HTTP路由并启动服务器（使用cfg.ServerPort中的端口号）
 	router := api.Routes()
 	addr := fmt.Sprintf(":%d", cfg.ServerPort)
 	logger.Log.Infof("服务器启动，监听地址: %s", addr)
-	err = http.ListenAndServe(addr, router)
+	go func() { errs <- http.ListenAndServe(addr, router) }()
+	return <-errs
+}
+
+// newFXRateProvider 根据配置创建汇率源，优先使用外部汇率服务，都未配置时返回nil
+func newFXRateProvider(cfg config.FXConfig) (service.FXRateProvider, error) {
+	switch {
+	case cfg.RatesURL != "":
+		return fx.NewHTTPProvider(cfg.RatesURL, nil), nil
+	case cfg.RatesFile != "":
+		provider, err := fx.LoadFileProvider(cfg.RatesFile)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+	return nil, nil
+}
+
+// newOutboxPublisher 根据配置创建领域事件发布器与关闭它的函数，未配置时返回nil
+func newOutboxPublisher(cfg config.OutboxConfig) (outbox.Publisher, func(), error) {
+	switch cfg.Publisher {
+	case "stdout":
+		return outbox.NewWriterPublisher(os.Stdout), func() {}, nil
+	case "file":
+		publisher, file, err := outbox.OpenFilePublisher(cfg.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		return publisher, func() { file.Close() }, nil
+	}
+	return nil, nil, nil
+}
+
+// newAuthenticator 根据配置创建认证器，显式关闭认证时返回nil；未配置密钥且未关闭认证时返回错误
+func newAuthenticator(cfg config.AuthConfig) (*auth.Verifier, error) {
+	if cfg.ConfigFile == "" {
+		if cfg.Disabled {
+			return nil, nil
+		}
+		return nil, errors.New("AUTH_CONFIG_FILE is required unless AUTH_DISABLED=true")
+	}
+	authCfg, err := auth.LoadConfigFile(cfg.ConfigFile)
 	if err != nil {
-		logger.Log.Errorf("启动服务器失败: %v", err)
+		return nil, err
+	}
+	verifier, err := auth.NewVerifier(authCfg)
+	if err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// purgeExpiredIdempotencyKeys 每小时删除一次已过期的幂等键
+func purgeExpiredIdempotencyKeys(repo _interface.IdempotencyRepository) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		deleted, err := repo.DeleteExpiredIdempotencyKeys(context.Background(), time.Now())
+		if err != nil {
+			logger.Log.Errorf("清理过期幂等键失败: %v", err)
+			continue
+		}
+		logger.Log.Infof("已清理%d个过期幂等键", deleted)
+	}
+}
+
+// createCheckpoints 按interval定期为哈希链生成签名检查点
+func createCheckpoints(walletService service.WalletService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := walletService.CreateCheckpoint(context.Background()); err != nil {
+			logger.Log.Errorf("生成哈希链检查点失败: %v", err)
+		}
+	}
+}
+
+// expireHolds 每分钟将已到期的预授权标记为过期一次
+func expireHolds(walletService service.WalletService) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := walletService.ExpireHolds(context.Background()); err != nil {
+			logger.Log.Errorf("处理到期预授权失败: %v", err)
+		}
 	}
 }
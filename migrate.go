@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"wallet-service/internal/logger"
+	"wallet-service/internal/migration"
+)
+
+// errMigrateUsage 参数不正确时返回的用法说明
+var errMigrateUsage = errors.New("usage: migrate up | down [steps] | status")
+
+// runMigrate 执行migrate子命令：up执行全部未执行的迁移，down回滚最近steps个迁移（默认1个），
+// status列出每个迁移的执行状态
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+	migrations, err := migration.Embedded()
+	if err != nil {
+		return err
+	}
+	migrator := migration.NewMigrator(db, migrations)
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errMigrateUsage
+		}
+		return migrateUp(ctx, db)
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return errMigrateUsage
+		}
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive integer: %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Log.Infof("已回滚迁移 %04d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+		return err
+	}
+	return errMigrateUsage
+}
+
+// migrateUp 执行全部未执行的内嵌迁移
+func migrateUp(ctx context.Context, db *sql.DB) error {
+	migrations, err := migration.Embedded()
+	if err != nil {
+		return err
+	}
+	applied, err := migration.NewMigrator(db, migrations).Up(ctx)
+	for _, m := range applied {
+		logger.Log.Infof("已执行迁移 %04d_%s", m.Version, m.Name)
+	}
+	if err == nil && len(applied) == 0 {
+		logger.Log.Info("数据库结构已是最新版本")
+	}
+	return err
+}
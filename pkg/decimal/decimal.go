@@ -0,0 +1,409 @@
+// Package decimal 提供精确的十进制定点数，用于金额计算，避免浮点数带来的精度问题
+package decimal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDigits 十进制数允许的最大有效位数，保证系数始终能放入int64
+const MaxDigits = 18
+
+// MaxIntegerDigits Parse接受的整数部分最大位数。整数部分不超过15位时，按最多3位小数表示的系数
+// 仍在MaxDigits以内，也不会超出数据库中NUMERIC(20, 3)列的范围
+const MaxIntegerDigits = 15
+
+var (
+	// ErrInvalid 表示字符串不是合法的十进制数
+	ErrInvalid = errors.New("decimal: invalid number")
+	// ErrOverflow 表示结果超出了可表示的范围
+	ErrOverflow = errors.New("decimal: value out of range")
+	// ErrDivisionByZero 表示除数为0
+	ErrDivisionByZero = errors.New("decimal: division by zero")
+)
+
+// RoundingMode 定义舍入规则
+type RoundingMode int
+
+const (
+	// RoundHalfEven 四舍六入五成双（银行家舍入）
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp 四舍五入，.5远离零
+	RoundHalfUp
+	// RoundDown 向零截断
+	RoundDown
+	// RoundUp 远离零进位
+	RoundUp
+)
+
+// Decimal 以 coef × 10^(-scale) 表示一个十进制数，零值即为0
+type Decimal struct {
+	coef  int64
+	scale int32
+}
+
+// Zero 数值0
+var Zero = Decimal{}
+
+// New 用系数和小数位数构造Decimal，例如 New(1234, 2) 表示 12.34
+func New(coef int64, scale int32) Decimal {
+	if scale < 0 || scale > MaxDigits {
+		panic(fmt.Sprintf("decimal: scale %d out of range", scale))
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// NewFromInt 用整数构造Decimal
+func NewFromInt(v int64) Decimal {
+	return Decimal{coef: v}
+}
+
+// Parse 解析形如 "-12.34" 的十进制字符串，不接受科学计数法；
+// 整数部分超过MaxIntegerDigits位或有效位数超过MaxDigits位时返回ErrOverflow
+func Parse(s string) (Decimal, error) {
+	str := s
+	neg := false
+	if len(str) > 0 && (str[0] == '-' || str[0] == '+') {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Zero, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	for _, part := range []string{intPart, fracPart} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return Zero, fmt.Errorf("%w: %q", ErrInvalid, s)
+			}
+		}
+	}
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if len(strings.TrimLeft(intPart, "0")) > MaxIntegerDigits || len(digits) > MaxDigits || len(fracPart) > MaxDigits {
+		return Zero, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	var coef int64
+	if digits != "" {
+		v, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return Zero, fmt.Errorf("%w: %q", ErrOverflow, s)
+		}
+		coef = v
+	}
+	if neg {
+		coef = -coef
+	}
+	return Decimal{coef: coef, scale: int32(len(fracPart))}, nil
+}
+
+// MustParse 与Parse相同，解析失败时panic，仅用于常量与测试
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Scale 返回小数位数
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign 返回符号：负数为-1，零为0，正数为1
+func (d Decimal) Sign() int {
+	switch {
+	case d.coef < 0:
+		return -1
+	case d.coef > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero 判断是否为0
+func (d Decimal) IsZero() bool {
+	return d.coef == 0
+}
+
+// IsPositive 判断是否大于0
+func (d Decimal) IsPositive() bool {
+	return d.coef > 0
+}
+
+// IsNegative 判断是否小于0
+func (d Decimal) IsNegative() bool {
+	return d.coef < 0
+}
+
+// Add 返回 d + other，结果超出可表示的范围时panic，操作数可能来自外部输入时使用AddErr
+func (d Decimal) Add(other Decimal) Decimal {
+	return must(d.AddErr(other))
+}
+
+// AddErr 返回 d + other，结果超出可表示的范围时返回ErrOverflow
+func (d Decimal) AddErr(other Decimal) (Decimal, error) {
+	scale := maxScale(d.scale, other.scale)
+	sum := new(big.Int).Add(d.bigAt(scale), other.bigAt(scale))
+	return fromBig(sum, scale)
+}
+
+// Sub 返回 d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return d.Add(other.Neg())
+}
+
+// Neg 返回 -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: -d.coef, scale: d.scale}
+}
+
+// Abs 返回 |d|
+func (d Decimal) Abs() Decimal {
+	if d.coef < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Cmp 比较d与other：d<other返回-1，相等返回0，d>other返回1
+func (d Decimal) Cmp(other Decimal) int {
+	scale := maxScale(d.scale, other.scale)
+	return d.bigAt(scale).Cmp(other.bigAt(scale))
+}
+
+// Equal 判断数值是否相等（忽略小数位数差异，1.0等于1.00）
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// LessThan 判断 d < other
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+// GreaterThan 判断 d > other
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+// FitsScale 判断d能否不丢失精度地用scale位小数表示，按scale位小数表示超出范围时返回false
+func (d Decimal) FitsScale(scale int32) bool {
+	rounded, err := d.Round(scale, RoundDown)
+	return err == nil && rounded.Equal(d)
+}
+
+// Round 按指定舍入规则将d调整为scale位小数，结果超出可表示的范围时返回ErrOverflow
+func (d Decimal) Round(scale int32, mode RoundingMode) (Decimal, error) {
+	if scale < 0 {
+		return Zero, fmt.Errorf("%w: scale %d", ErrOverflow, scale)
+	}
+	if scale >= d.scale {
+		return fromBig(d.bigAt(scale), scale)
+	}
+	divisor := pow10(d.scale - scale)
+	return fromBig(roundQuo(big.NewInt(d.coef), divisor, mode), scale)
+}
+
+// MulRound 计算 d × other，并按mode舍入到scale位小数，结果超出可表示的范围时panic，
+// 操作数可能来自外部输入时使用MulRoundErr
+func (d Decimal) MulRound(other Decimal, scale int32, mode RoundingMode) Decimal {
+	return must(d.MulRoundErr(other, scale, mode))
+}
+
+// MulRoundErr 计算 d × other，并按mode舍入到scale位小数，结果超出可表示的范围时返回ErrOverflow
+func (d Decimal) MulRoundErr(other Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	product := new(big.Int).Mul(big.NewInt(d.coef), big.NewInt(other.coef))
+	return roundBig(product, d.scale+other.scale, scale, mode)
+}
+
+// QuoRound 计算 d ÷ other，并按mode舍入到scale位小数；other为0或结果超出可表示的范围时panic，
+// 操作数可能来自外部输入时使用QuoRoundErr
+func (d Decimal) QuoRound(other Decimal, scale int32, mode RoundingMode) Decimal {
+	if other.coef == 0 {
+		panic("decimal: division by zero")
+	}
+	return must(d.QuoRoundErr(other, scale, mode))
+}
+
+// QuoRoundErr 计算 d ÷ other，并按mode舍入到scale位小数；other为0时返回ErrDivisionByZero，
+// 结果超出可表示的范围时返回ErrOverflow
+func (d Decimal) QuoRoundErr(other Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if other.coef == 0 {
+		return Zero, ErrDivisionByZero
+	}
+	// d/other = (d.coef × 10^(other.scale+scale-d.scale)) / other.coef × 10^(-scale)
+	num := big.NewInt(d.coef)
+	den := big.NewInt(other.coef)
+	shift := other.scale + scale - d.scale
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return fromBig(roundQuo(num, den, mode), scale)
+}
+
+// roundBig 将scale为from的大整数系数舍入到to位小数
+func roundBig(coef *big.Int, from, to int32, mode RoundingMode) (Decimal, error) {
+	if to >= from {
+		return fromBig(new(big.Int).Mul(coef, pow10(to-from)), to)
+	}
+	return fromBig(roundQuo(coef, pow10(from-to), mode), to)
+}
+
+// String 以定点形式输出，保留全部小数位，例如 "12.30"
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.coef, 10)
+	neg := false
+	if d.coef < 0 {
+		neg = true
+		digits = digits[1:]
+	}
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+// StringFixed 以scale位小数输出，位数不足时补零、超出时四舍五入；无法按scale位小数表示时原样输出
+func (d Decimal) StringFixed(scale int32) string {
+	rounded, err := d.Round(scale, RoundHalfUp)
+	if err != nil {
+		return d.String()
+	}
+	return rounded.String()
+}
+
+// MarshalJSON 以字符串形式输出，避免JSON客户端将金额解析为浮点数
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON 同时接受 "12.34" 与 12.34 两种写法，均按十进制文本解析
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value 实现driver.Valuer，以文本形式写入NUMERIC列
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan 实现sql.Scanner，从NUMERIC列读取
+func (d *Decimal) Scan(value interface{}) error {
+	var (
+		parsed Decimal
+		err    error
+	)
+	switch v := value.(type) {
+	case nil:
+		parsed = Zero
+	case []byte:
+		parsed, err = Parse(string(v))
+	case string:
+		parsed, err = Parse(v)
+	case int64:
+		parsed = NewFromInt(v)
+	case float64:
+		parsed, err = Parse(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("decimal: cannot scan %T", value)
+	}
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) bigAt(scale int32) *big.Int {
+	v := big.NewInt(d.coef)
+	if scale > d.scale {
+		v.Mul(v, pow10(scale-d.scale))
+	}
+	return v
+}
+
+// fromBig 用大整数系数构造Decimal，系数超出int64或scale超过MaxDigits时返回ErrOverflow
+func fromBig(v *big.Int, scale int32) (Decimal, error) {
+	if !v.IsInt64() || scale > MaxDigits {
+		return Zero, ErrOverflow
+	}
+	return Decimal{coef: v.Int64(), scale: scale}, nil
+}
+
+// must 在err不为nil时panic，用于输入已由调用方限定范围的算术运算
+func must(d Decimal, err error) Decimal {
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// roundQuo 计算 num/den 并按mode舍入到整数
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	sign := int64(num.Sign() * den.Sign())
+	twiceRem := new(big.Int).Abs(r)
+	twiceRem.Lsh(twiceRem, 1)
+	half := twiceRem.Cmp(new(big.Int).Abs(den))
+
+	roundAway := false
+	switch mode {
+	case RoundHalfUp:
+		roundAway = half >= 0
+	case RoundHalfEven:
+		roundAway = half > 0 || (half == 0 && q.Bit(0) == 1)
+	case RoundUp:
+		roundAway = true
+	case RoundDown:
+	}
+	if roundAway {
+		q.Add(q, big.NewInt(sign))
+	}
+	return q
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func maxScale(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,965 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wallet-service/internal/api"
+	"wallet-service/internal/audit"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+// MockWalletService 用于API测试的钱包服务，记录各方法的调用次数
+type MockWalletService struct {
+	depositFunc   func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error)
+	withdrawFunc  func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error)
+	transferFunc  func(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error
+	getWalletFunc func(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+
+	depositCalls int
+	// lastHistoryFilter 记录最近一次查询交易历史的条件
+	lastHistoryFilter model.HistoryFilter
+	// lastAuditFilter 记录最近一次查询审计日志的条件
+	lastAuditFilter model.AuditFilter
+	// lastWalletSpec 记录最近一次开户的参数
+	lastWalletSpec model.WalletSpec
+	// lastLimits 记录最近一次设置的钱包限额
+	lastLimits model.WalletLimits
+}
+
+func (m *MockWalletService) Deposit(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	m.depositCalls++
+	if m.depositFunc != nil {
+		return m.depositFunc(ctx, userID, currency, amount)
+	}
+	return &model.Wallet{UserID: userID, Currency: currency, Balance: amount, Status: model.WalletActive}, nil
+}
+
+func (m *MockWalletService) Withdraw(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+	if m.withdrawFunc != nil {
+		return m.withdrawFunc(ctx, userID, currency, amount)
+	}
+	return &model.Wallet{UserID: userID, Currency: currency, Status: model.WalletActive}, nil
+}
+
+func (m *MockWalletService) Transfer(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error {
+	if m.transferFunc != nil {
+		return m.transferFunc(ctx, fromUserID, toUserID, currency, amount)
+	}
+	return nil
+}
+
+func (m *MockWalletService) GetBalance(ctx context.Context, userID int, currency string) (*model.Balance, error) {
+	return &model.Balance{UserID: userID, Currency: currency, Ledger: decimal.MustParse("100.00"), Held: decimal.MustParse("40.00"), Available: decimal.MustParse("60.00")}, nil
+}
+
+func (m *MockWalletService) GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	if m.getWalletFunc != nil {
+		return m.getWalletFunc(ctx, userID, currency)
+	}
+	return &model.Wallet{UserID: userID, Currency: currency}, nil
+}
+
+func (m *MockWalletService) ListWallets(ctx context.Context, userID int) ([]model.Wallet, error) {
+	return []model.Wallet{{UserID: userID, Currency: model.DefaultCurrency}}, nil
+}
+
+func (m *MockWalletService) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) (*model.TransactionPage, error) {
+	m.lastHistoryFilter = filter
+	page := &model.TransactionPage{Transactions: []model.Transaction{{ID: 7, UserID: userID, Currency: currency, TransactionType: "refund_in", Amount: decimal.MustParse("5.00"), ReversalOf: 3, Reason: "退款"}}}
+	if filter.Cursor == nil {
+		page.NextCursor = model.HistoryCursor{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 7}.Encode()
+	}
+	return page, nil
+}
+
+func (m *MockWalletService) VerifyLedger(ctx context.Context) (*model.LedgerReport, error) {
+	return &model.LedgerReport{}, nil
+}
+
+func (m *MockWalletService) VerifyHashChain(ctx context.Context) (*model.ChainReport, error) {
+	return &model.ChainReport{TransactionsChecked: 3, CheckpointsVerified: 1}, nil
+}
+
+func (m *MockWalletService) CreateCheckpoint(ctx context.Context) (*model.LedgerCheckpoint, error) {
+	return &model.LedgerCheckpoint{ID: 2, LastTransactionID: 3, KeyID: "0123456789abcdef"}, nil
+}
+
+func (m *MockWalletService) QuoteFee(ctx context.Context, operation, currency string, amount decimal.Decimal) (*model.FeeQuote, error) {
+	fee := decimal.MustParse("1.00")
+	return &model.FeeQuote{Operation: operation, Currency: currency, Amount: amount, Fee: fee, Total: amount.Add(fee)}, nil
+}
+
+func (m *MockWalletService) QuoteFX(ctx context.Context, from, to string) (*model.FXQuote, error) {
+	if from == "JPY" {
+		return nil, fmt.Errorf("%w: %s/%s", service.ErrRateUnavailable, from, to)
+	}
+	return &model.FXQuote{ID: "q1", From: from, To: to, Rate: decimal.MustParse("0.14"), Spread: decimal.Zero, EffectiveRate: decimal.MustParse("0.14")}, nil
+}
+
+func (m *MockWalletService) Convert(ctx context.Context, userID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	if quoteID == "expired" {
+		return nil, fmt.Errorf("%w: quote %s", service.ErrQuoteExpired, quoteID)
+	}
+	return &model.Conversion{UserID: userID, ToUserID: userID, QuoteID: quoteID, FromCurrency: from, ToCurrency: to, SourceAmount: amount}, nil
+}
+
+func (m *MockWalletService) TransferWithConversion(ctx context.Context, fromUserID, toUserID int, from, to string, amount decimal.Decimal, quoteID string) (*model.Conversion, error) {
+	return &model.Conversion{UserID: fromUserID, ToUserID: toUserID, QuoteID: quoteID, FromCurrency: from, ToCurrency: to,
+		SourceAmount: amount, TargetAmount: decimal.MustParse("14.00")}, nil
+}
+
+func (m *MockWalletService) Authorize(ctx context.Context, userID int, currency string, amount decimal.Decimal, payeeUserID int, ttl time.Duration) (*model.Hold, error) {
+	return &model.Hold{ID: 1, UserID: userID, Currency: currency, Amount: amount, PayeeUserID: payeeUserID, Status: model.HoldAuthorized,
+		ExpiresAt: time.Now().Add(ttl)}, nil
+}
+
+func (m *MockWalletService) Capture(ctx context.Context, holdID int, amount decimal.Decimal) (*model.Hold, error) {
+	if holdID != 1 {
+		return nil, fmt.Errorf("%w: %d", service.ErrHoldNotFound, holdID)
+	}
+	if amount.IsZero() {
+		amount = decimal.MustParse("40.00")
+	}
+	return &model.Hold{ID: holdID, Amount: decimal.MustParse("40.00"), CapturedAmount: amount, Status: model.HoldCaptured}, nil
+}
+
+func (m *MockWalletService) Void(ctx context.Context, holdID int) (*model.Hold, error) {
+	return nil, fmt.Errorf("%w: hold %d is captured", service.ErrHoldNotActive, holdID)
+}
+
+func (m *MockWalletService) GetHold(ctx context.Context, holdID int) (*model.Hold, error) {
+	return &model.Hold{ID: holdID, UserID: 1, PayeeUserID: 2, Status: model.HoldAuthorized}, nil
+}
+
+func (m *MockWalletService) ExpireHolds(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockWalletService) Reverse(ctx context.Context, txID int, reason string) (*model.Reversal, error) {
+	if txID == 2 {
+		return nil, fmt.Errorf("%w: transaction %d", service.ErrAlreadyReversed, txID)
+	}
+	return &model.Reversal{TransactionID: txID, Amount: decimal.MustParse("10.00"), Reason: reason}, nil
+}
+
+func (m *MockWalletService) Refund(ctx context.Context, txID int, amount decimal.Decimal, reason string) (*model.Reversal, error) {
+	return &model.Reversal{TransactionID: txID, Amount: amount, Reason: reason}, nil
+}
+
+func (m *MockWalletService) GenerateStatement(ctx context.Context, userID int, currency string, from, to time.Time, w service.StatementWriter) (*model.StatementSummary, error) {
+	if userID == 404 {
+		return nil, fmt.Errorf("%w: user ID %d", service.ErrWalletNotFound, userID)
+	}
+	opening := decimal.MustParse("100.00")
+	if err := w.WriteHeader(model.StatementHeader{UserID: userID, Currency: currency, From: from, To: to, OpeningBalance: opening, GeneratedAt: to}); err != nil {
+		return nil, err
+	}
+	line := model.StatementLine{TransactionID: 7, TransactionTime: from.Add(time.Hour), TransactionType: "withdrawal", Amount: decimal.MustParse("-30.00"), Balance: decimal.MustParse("70.00")}
+	if err := w.WriteLine(line); err != nil {
+		return nil, err
+	}
+	summary := model.StatementSummary{OpeningBalance: opening, TotalCredits: decimal.Zero, TotalDebits: decimal.MustParse("30.00"), ClosingBalance: line.Balance, TransactionCount: 1}
+	if err := w.WriteFooter(summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (m *MockWalletService) Adjust(ctx context.Context, userID int, currency string, amount decimal.Decimal, reason string) (*model.Transaction, error) {
+	transactionType := "adjustment_credit"
+	if amount.IsNegative() {
+		transactionType = "adjustment_debit"
+	}
+	return &model.Transaction{UserID: userID, Currency: currency, TransactionType: transactionType, Amount: amount.Abs(), EntryID: 9, Reason: reason}, nil
+}
+
+func (m *MockWalletService) FreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	if reason == "" {
+		return nil, service.ErrReasonRequired
+	}
+	return &model.Wallet{UserID: userID, Currency: currency, Status: model.WalletFrozen}, nil
+}
+
+func (m *MockWalletService) UnfreezeWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	return &model.Wallet{UserID: userID, Currency: currency, Status: model.WalletActive}, nil
+}
+
+func (m *MockWalletService) CreateWallet(ctx context.Context, spec model.WalletSpec) (*model.Wallet, error) {
+	m.lastWalletSpec = spec
+	if spec.UserID == 1 {
+		return nil, fmt.Errorf("%w: user ID 1, currency %s", service.ErrWalletExists, spec.Currency)
+	}
+	return &model.Wallet{UserID: spec.UserID, Currency: spec.Currency, Balance: decimal.MustParse("0.00"), Status: model.WalletActive, OwnerMetadata: spec.OwnerMetadata}, nil
+}
+
+func (m *MockWalletService) CloseWallet(ctx context.Context, userID int, currency, reason string) (*model.Wallet, error) {
+	if userID == 1 {
+		return nil, fmt.Errorf("%w: balance 100.00", service.ErrWalletNotEmpty)
+	}
+	return &model.Wallet{UserID: userID, Currency: currency, Status: model.WalletClosed}, nil
+}
+
+func (m *MockWalletService) GetLimitUsage(ctx context.Context, userID int, currency string) (*model.LimitUsage, error) {
+	daily, remaining, transfers := decimal.MustParse("1000.00"), decimal.MustParse("750.00"), 10
+	return &model.LimitUsage{
+		UserID:    userID,
+		Currency:  currency,
+		Balance:   decimal.MustParse("100.00"),
+		Limits:    model.WalletLimits{DailyOutgoing: &daily, MaxTransfersPerHour: &transfers},
+		Usage:     model.OutgoingUsage{DailyOutgoing: decimal.MustParse("250.00"), MonthlyOutgoing: decimal.MustParse("250.00"), TransfersLastHour: 3},
+		Remaining: model.WalletLimits{DailyOutgoing: &remaining, MaxTransfersPerHour: &transfers},
+	}, nil
+}
+
+func (m *MockWalletService) SetWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) (*model.WalletLimits, error) {
+	m.lastLimits = limits
+	if userID == 404 {
+		return nil, fmt.Errorf("%w: user ID 404, currency %s", service.ErrWalletNotFound, currency)
+	}
+	return &limits, nil
+}
+
+func (m *MockWalletService) ListAuditLog(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	m.lastAuditFilter = filter
+	balance := decimal.MustParse("10.00")
+	entry := model.AuditEntry{ID: 7, Action: "deposit", Actor: "key:gateway", RequestID: "req-1", UserID: 1, Currency: "CNY", BalanceAfter: &balance}
+	return &model.AuditPage{Entries: []model.AuditEntry{entry}, NextCursor: model.EncodeAuditCursor(7)}, nil
+}
+
+// memoryIdempotencyRepository 基于内存的幂等键存储
+type memoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyRecord
+}
+
+func newMemoryIdempotencyRepository() *memoryIdempotencyRepository {
+	return &memoryIdempotencyRepository{records: make(map[string]*model.IdempotencyRecord)}
+}
+
+func (m *memoryIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.records[record.Key]; ok && existing.ExpiresAt.After(record.CreatedAt) {
+		return false, nil
+	}
+	m.records[record.Key] = &record
+	return true, nil
+}
+
+func (m *memoryIdempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string) (*model.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok {
+		copied := *record
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok {
+		record.StatusCode = statusCode
+		record.ContentType = contentType
+		record.ResponseBody = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[key]; ok && record.StatusCode == 0 {
+		delete(m.records, key)
+	}
+	return nil
+}
+
+func (m *memoryIdempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key, record := range m.records {
+		if !record.ExpiresAt.After(before) {
+			delete(m.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// doRequest 向路由发送请求并返回响应记录
+func doRequest(handler http.Handler, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	return doJSONRequest(handler, method, target, "", headers)
+}
+
+// doJSONRequest 向路由发送带JSON请求体的请求并返回响应记录
+func doJSONRequest(handler http.Handler, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// 测试带Idempotency-Key的重试只执行一次并重放首次响应
+func TestAPI_IdempotentDepositReplay(t *testing.T) {
+	walletService := &MockWalletService{}
+	idempotencyRepo := newMemoryIdempotencyRepository()
+	router := api.NewAPI(walletService, api.WithIdempotency(idempotencyRepo, time.Hour)).Routes()
+	headers := map[string]string{api.IdempotencyKeyHeader: "key-1"}
+
+	first := doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", headers)
+	if first.Code != http.StatusOK {
+		t.Fatalf("首次存款预期返回200，实际：%d %s", first.Code, first.Body.String())
+	}
+
+	retry := doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", headers)
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("重试预期重放首次响应，实际：%d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("重放响应应带有Idempotent-Replayed头")
+	}
+	if walletService.depositCalls != 1 {
+		t.Errorf("预期存款只执行1次，实际：%d", walletService.depositCalls)
+	}
+
+	// 同一个键用于不同请求时返回422
+	conflict := doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=20.00", headers)
+	if conflict.Code != http.StatusUnprocessableEntity {
+		t.Errorf("同一个键、不同请求预期返回422，实际：%d", conflict.Code)
+	}
+	if walletService.depositCalls != 1 {
+		t.Errorf("请求不一致时不应执行存款，实际调用次数：%d", walletService.depositCalls)
+	}
+
+	// 不带键的请求不受影响
+	doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", nil)
+	if walletService.depositCalls != 2 {
+		t.Errorf("不带幂等键的请求应正常执行，实际调用次数：%d", walletService.depositCalls)
+	}
+}
+
+// 测试处理中、过期与服务端错误几种情况下幂等键的行为
+func TestAPI_IdempotencyKeyStates(t *testing.T) {
+	walletService := &MockWalletService{}
+	idempotencyRepo := newMemoryIdempotencyRepository()
+	router := api.NewAPI(walletService, api.WithIdempotency(idempotencyRepo, time.Hour)).Routes()
+
+	// 首个请求仍在处理中时，重试返回409
+	now := time.Now()
+	idempotencyRepo.records["in-flight"] = &model.IdempotencyRecord{Key: "in-flight", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	rec := doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", map[string]string{api.IdempotencyKeyHeader: "in-flight"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("处理中的键预期返回409，实际：%d", rec.Code)
+	}
+
+	// 过期的键可以被新请求复用
+	idempotencyRepo.records["expired"] = &model.IdempotencyRecord{Key: "expired", RequestHash: "other", StatusCode: 200,
+		CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+	rec = doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", map[string]string{api.IdempotencyKeyHeader: "expired"})
+	if rec.Code != http.StatusOK || walletService.depositCalls != 1 {
+		t.Errorf("过期的键预期重新执行请求，实际：%d，调用次数：%d", rec.Code, walletService.depositCalls)
+	}
+
+	// 服务端错误不缓存，允许使用同一个键重试
+	walletService.depositFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+		return nil, context.DeadlineExceeded
+	}
+	headers := map[string]string{api.IdempotencyKeyHeader: "retry-after-error"}
+	rec = doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", headers)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("存款失败预期返回500，实际：%d", rec.Code)
+	}
+	walletService.depositFunc = nil
+	rec = doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", headers)
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("服务端错误后使用同一个键重试应重新执行，实际：%d", rec.Code)
+	}
+}
+
+// 测试存款提交后读取钱包失败不影响响应，使用同一个键重试时重放首次响应而不是再次存款
+func TestAPI_IdempotentDepositAfterReadFailure(t *testing.T) {
+	walletService := &MockWalletService{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			return nil, errors.New("read replica unavailable")
+		},
+	}
+	router := api.NewAPI(walletService, api.WithIdempotency(newMemoryIdempotencyRepository(), time.Hour)).Routes()
+	headers := map[string]string{api.IdempotencyKeyHeader: "deposit-after-read-failure"}
+
+	first := doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"10.00"}`, headers)
+	var wallet model.Wallet
+	if first.Code != http.StatusCreated || json.Unmarshal(first.Body.Bytes(), &wallet) != nil || wallet.Balance.String() != "10.00" {
+		t.Fatalf("存款成功后预期返回201与存款后的钱包，实际：%d %s", first.Code, first.Body.String())
+	}
+
+	retry := doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"10.00"}`, headers)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("重试预期重放首次响应，实际：%d %s", retry.Code, retry.Body.String())
+	}
+	if walletService.depositCalls != 1 {
+		t.Errorf("预期存款只执行1次，实际：%d", walletService.depositCalls)
+	}
+}
+
+// 测试旧版变更接口只接受POST
+func TestAPI_LegacyMutationsRequirePost(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService).Routes()
+
+	for _, target := range []string{"/deposit?user_id=1&amount=10.00", "/withdraw?user_id=1&amount=10.00", "/transfer?from_user_id=1&to_user_id=2&amount=10.00"} {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			rec := doRequest(router, method, target, nil)
+			if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("%s %s 预期返回405与Allow: POST，实际：%d %q", method, target, rec.Code, rec.Header().Get("Allow"))
+			}
+		}
+	}
+	if walletService.depositCalls != 0 {
+		t.Errorf("非POST请求不应执行存款，实际调用次数：%d", walletService.depositCalls)
+	}
+
+	rec := doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=10.00", nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST存款预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// 测试超过上限的请求体返回413而不是被截断后执行
+func TestAPI_IdempotentBodyTooLarge(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService, api.WithIdempotency(newMemoryIdempotencyRepository(), time.Hour)).Routes()
+
+	body := `{"amount":"10.00","currency":"CNY"` + strings.Repeat(" ", 1<<20) + `}`
+	rec := doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", body, map[string]string{api.IdempotencyKeyHeader: "large"})
+	if rec.Code != http.StatusRequestEntityTooLarge || walletService.depositCalls != 0 {
+		t.Errorf("超长的请求体预期返回413且不执行存款，实际：%d，调用次数：%d", rec.Code, walletService.depositCalls)
+	}
+}
+
+// 测试处理器panic且变更未提交时释放幂等键，使用同一个键重试会重新执行
+func TestAPI_IdempotencyKeyReleasedOnPanic(t *testing.T) {
+	walletService := &MockWalletService{
+		depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			panic("deposit failed")
+		},
+	}
+	router := api.NewAPI(walletService, api.WithIdempotency(newMemoryIdempotencyRepository(), time.Hour)).Routes()
+	headers := map[string]string{api.IdempotencyKeyHeader: "panic"}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("处理器的panic预期继续向上传递")
+			}
+		}()
+		doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"10.00"}`, headers)
+	}()
+
+	walletService.depositFunc = nil
+	rec := doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"10.00"}`, headers)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" || walletService.depositCalls != 2 {
+		t.Errorf("panic后使用同一个键重试应重新执行，实际：%d %s，调用次数：%d", rec.Code, rec.Body.String(), walletService.depositCalls)
+	}
+}
+
+// decodeErrorResponse 解析统一的错误响应体
+func decodeErrorResponse(t *testing.T, rec *httptest.ResponseRecorder) (code string, details map[string]interface{}) {
+	t.Helper()
+	var body struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Details map[string]interface{} `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("错误响应不是合法JSON：%v，%s", err, rec.Body.String())
+	}
+	if body.Message == "" {
+		t.Errorf("错误响应缺少message：%s", rec.Body.String())
+	}
+	return body.Code, body.Details
+}
+
+// 测试v1接口的路由、JSON请求体与响应
+func TestAPI_V1WalletEndpoints(t *testing.T) {
+	balance := decimal.MustParse("100.00")
+	walletService := &MockWalletService{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			if userID != 1 {
+				return nil, fmt.Errorf("%w: user ID %d", service.ErrWalletNotFound, userID)
+			}
+			return &model.Wallet{UserID: 1, Currency: currency, Balance: balance}, nil
+		},
+	}
+	walletService.depositFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+		balance = balance.Add(amount)
+		return &model.Wallet{UserID: userID, Currency: currency, Balance: balance}, nil
+	}
+	router := api.NewAPI(walletService).Routes()
+
+	rec := doRequest(router, http.MethodGet, "/v1/wallets/1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("查询钱包预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	var wallet model.Wallet
+	if err := json.Unmarshal(rec.Body.Bytes(), &wallet); err != nil || wallet.UserID != 1 || !wallet.Balance.Equal(balance) {
+		t.Errorf("查询钱包返回内容不正确：%s", rec.Body.String())
+	}
+
+	rec = doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"25.50"}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("存款预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &wallet); err != nil || wallet.Balance.String() != "125.50" {
+		t.Errorf("存款后返回的余额不正确：%s", rec.Body.String())
+	}
+
+	rec = doRequest(router, http.MethodGet, "/v1/wallets/2", nil)
+	if code, _ := decodeErrorResponse(t, rec); rec.Code != http.StatusNotFound || code != "wallet_not_found" {
+		t.Errorf("钱包不存在预期返回404 wallet_not_found，实际：%d %s", rec.Code, code)
+	}
+
+	rec = doRequest(router, http.MethodDelete, "/v1/wallets/1", nil)
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("不支持的方法预期返回405并带Allow头，实际：%d %q", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	rec = doRequest(router, http.MethodGet, "/v1/unknown", nil)
+	if code, _ := decodeErrorResponse(t, rec); rec.Code != http.StatusNotFound || code != "not_found" {
+		t.Errorf("未知路径预期返回404 not_found，实际：%d %s", rec.Code, code)
+	}
+}
+
+// 测试v1接口的参数校验与业务错误映射
+func TestAPI_V1Errors(t *testing.T) {
+	walletService := &MockWalletService{
+		withdrawFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			if userID == 2 {
+				return nil, &service.LimitExceededError{Limit: "max_single_withdrawal", Max: decimal.MustParse("0.50"), Remaining: decimal.MustParse("0.50")}
+			}
+			return nil, fmt.Errorf("%w: user ID %d", service.ErrInsufficientFunds, userID)
+		},
+	}
+	router := api.NewAPI(walletService).Routes()
+
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"缺少金额", "/v1/wallets/1/deposits", `{}`, http.StatusBadRequest, "validation_error", "amount"},
+		{"金额精度超限", "/v1/wallets/1/deposits", `{"amount":"1.001"}`, http.StatusBadRequest, "validation_error", "amount"},
+		{"负数金额", "/v1/wallets/1/deposits", `{"amount":"-1"}`, http.StatusBadRequest, "validation_error", "amount"},
+		{"未知字段", "/v1/wallets/1/deposits", `{"amount":"1","currency":"XXX","extra":true}`, http.StatusBadRequest, "validation_error", ""},
+		{"非法钱包ID", "/v1/wallets/abc/deposits", `{"amount":"1"}`, http.StatusBadRequest, "validation_error", "id"},
+		{"余额不足", "/v1/wallets/1/withdrawals", `{"amount":"1"}`, http.StatusUnprocessableEntity, "insufficient_funds", ""},
+		{"超出限额", "/v1/wallets/2/withdrawals", `{"amount":"1"}`, http.StatusUnprocessableEntity, "limit_exceeded", ""},
+		{"缺少转入方", "/v1/transfers", `{"from_user_id":1,"amount":"1"}`, http.StatusBadRequest, "validation_error", "to_user_id"},
+		{"不支持的币种", "/v1/wallets/1/deposits", `{"amount":"1","currency":"XXX"}`, http.StatusBadRequest, "unsupported_currency", ""},
+		{"JPY金额含小数", "/v1/wallets/1/deposits", `{"amount":"1.5","currency":"JPY"}`, http.StatusBadRequest, "validation_error", "amount"},
+		{"跨币种转账", "/v1/transfers", `{"from_user_id":1,"to_user_id":2,"amount":"1","currency":"CNY","to_currency":"USD"}`, http.StatusUnprocessableEntity, "currency_mismatch", ""},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, http.MethodPost, c.target, c.body, nil)
+		code, details := decodeErrorResponse(t, rec)
+		if rec.Code != c.status || code != c.code {
+			t.Errorf("%s：预期%d %s，实际：%d %s", c.name, c.status, c.code, rec.Code, code)
+		}
+		if c.code == "limit_exceeded" && (details["limit"] != "max_single_withdrawal" || details["remaining"] != "0.50") {
+			t.Errorf("%s：预期details包含限额信息，实际：%v", c.name, details)
+		}
+		if c.field != "" && details["field"] != c.field {
+			t.Errorf("%s：预期details.field为%s，实际：%v", c.name, c.field, details)
+		}
+	}
+}
+
+// 测试未指定币种时使用默认币种，指定币种时按请求传递
+func TestAPI_V1Currency(t *testing.T) {
+	var depositCurrencies, transferCurrencies []string
+	walletService := &MockWalletService{
+		depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			depositCurrencies = append(depositCurrencies, currency)
+			return &model.Wallet{UserID: userID, Currency: currency, Balance: amount}, nil
+		},
+		transferFunc: func(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error {
+			transferCurrencies = append(transferCurrencies, currency)
+			return nil
+		},
+	}
+	router := api.NewAPI(walletService, api.WithDefaultCurrency("USD")).Routes()
+
+	doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"1"}`, nil)
+	doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"1","currency":"jpy"}`, nil)
+	doRequest(router, http.MethodPost, "/deposit?user_id=1&amount=1&currency=EUR", nil)
+	if strings.Join(depositCurrencies, ",") != "USD,JPY,EUR" {
+		t.Errorf("预期存款币种依次为USD,JPY,EUR，实际：%v", depositCurrencies)
+	}
+
+	rec := doRequest(router, http.MethodGet, "/v1/wallets/1?currency=KWD", nil)
+	var wallet model.Wallet
+	if err := json.Unmarshal(rec.Body.Bytes(), &wallet); err != nil || wallet.Currency != "KWD" {
+		t.Errorf("查询钱包应按currency参数返回，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	// 同币种的to_currency不视为换汇
+	rec = doJSONRequest(router, http.MethodPost, "/v1/transfers", `{"from_user_id":1,"to_user_id":2,"amount":"5","currency":"EUR","to_currency":"EUR"}`, nil)
+	if rec.Code != http.StatusCreated || len(transferCurrencies) != 1 || transferCurrencies[0] != "EUR" {
+		t.Errorf("同币种转账预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"amount":"5.00"`) || !strings.Contains(rec.Body.String(), `"currency":"EUR"`) {
+		t.Errorf("转账响应应包含按币种格式化的金额与币种，实际：%s", rec.Body.String())
+	}
+
+	rec = doRequest(router, http.MethodGet, "/v1/wallets/1/balances", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"wallets"`) {
+		t.Errorf("查询全部币种钱包预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// 测试开户接口传递持有人信息与限额，钱包已存在返回409
+func TestAPI_V1CreateWallet(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService, api.WithDefaultCurrency("USD")).Routes()
+
+	rec := doJSONRequest(router, http.MethodPost, "/v1/wallets/7", `{"owner_metadata":{"name":"张三"},"limits":{"max_balance":"5000","max_transfers_per_hour":10}}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"owner_metadata":{"name":"张三"}`) {
+		t.Fatalf("开户预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	spec := walletService.lastWalletSpec
+	if spec.UserID != 7 || spec.Currency != "USD" || spec.Limits.MaxBalance.String() != "5000" || *spec.Limits.MaxTransfersPerHour != 10 {
+		t.Errorf("开户参数传递不正确：%+v", spec)
+	}
+
+	rec = doJSONRequest(router, http.MethodPost, "/v1/wallets/1", `{"currency":"CNY"}`, nil)
+	if code, _ := decodeErrorResponse(t, rec); rec.Code != http.StatusConflict || code != "wallet_exists" {
+		t.Errorf("钱包已存在预期返回409 wallet_exists，实际：%d %s", rec.Code, code)
+	}
+}
+
+// 测试查询限额使用情况与后台设置钱包限额的接口
+func TestAPI_V1Limits(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService).Routes()
+
+	rec := doJSONRequest(router, http.MethodGet, "/v1/wallets/1/limits?currency=CNY", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"remaining":{"daily_outgoing":"750.00","max_transfers_per_hour":10}`) ||
+		!strings.Contains(rec.Body.String(), `"transfers_last_hour":3`) {
+		t.Errorf("查询限额使用情况预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSONRequest(router, http.MethodPut, "/v1/admin/wallets/1/limits", `{"currency":"CNY","limits":{"daily_outgoing":"500","max_transfers_per_hour":5}}`, nil)
+	if rec.Code != http.StatusOK || walletService.lastLimits.DailyOutgoing.String() != "500" || *walletService.lastLimits.MaxTransfersPerHour != 5 {
+		t.Errorf("设置钱包限额预期返回200，实际：%d %s，%+v", rec.Code, rec.Body.String(), walletService.lastLimits)
+	}
+	rec = doJSONRequest(router, http.MethodPut, "/v1/admin/wallets/404/limits", `{"limits":{}}`, nil)
+	if code, _ := decodeErrorResponse(t, rec); rec.Code != http.StatusNotFound || code != "wallet_not_found" {
+		t.Errorf("钱包不存在预期返回404 wallet_not_found，实际：%d %s", rec.Code, code)
+	}
+}
+
+// 测试手续费试算接口
+func TestAPI_V1QuoteFee(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}, api.WithDefaultCurrency("USD")).Routes()
+
+	rec := doJSONRequest(router, http.MethodPost, "/v1/fees/quote", `{"operation":"transfer","amount":"100"}`, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"currency":"USD"`) || !strings.Contains(rec.Body.String(), `"total":"101.00"`) {
+		t.Errorf("手续费试算预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	for body, field := range map[string]string{
+		`{"operation":"deposit","amount":"100"}`:  "operation",
+		`{"operation":"withdrawal"}`:              "amount",
+		`{"operation":"withdrawal","amount":"0"}`: "amount",
+	} {
+		rec = doJSONRequest(router, http.MethodPost, "/v1/fees/quote", body, nil)
+		if code, details := decodeErrorResponse(t, rec); rec.Code != http.StatusBadRequest || code != "validation_error" || details["field"] != field {
+			t.Errorf("%s：预期返回400 validation_error（%s），实际：%d %s %v", body, field, rec.Code, code, details)
+		}
+	}
+}
+
+// 测试换汇报价、换汇与跨币种转账接口
+func TestAPI_V1FX(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}).Routes()
+
+	rec := doJSONRequest(router, http.MethodPost, "/v1/fx/quotes", `{"from_currency":"CNY","to_currency":"usd"}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"to_currency":"USD"`) {
+		t.Errorf("报价预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSONRequest(router, http.MethodPost, "/v1/wallets/1/conversions", `{"from_currency":"CNY","to_currency":"USD","amount":"100","quote_id":"q1"}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"quote_id":"q1"`) {
+		t.Errorf("换汇预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSONRequest(router, http.MethodPost, "/v1/transfers", `{"from_user_id":1,"to_user_id":2,"amount":"100","currency":"CNY","to_currency":"USD","convert":true}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"target_amount":"14.00"`) {
+		t.Errorf("跨币种转账预期返回201并附带换汇结果，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"缺少目标币种", "/v1/fx/quotes", `{"from_currency":"CNY"}`, http.StatusBadRequest, "validation_error"},
+		{"汇率不可用", "/v1/fx/quotes", `{"from_currency":"JPY","to_currency":"USD"}`, http.StatusServiceUnavailable, "rate_unavailable"},
+		{"报价过期", "/v1/wallets/1/conversions", `{"from_currency":"CNY","to_currency":"USD","amount":"1","quote_id":"expired"}`, http.StatusConflict, "quote_expired"},
+		{"报价未要求换汇", "/v1/transfers", `{"from_user_id":1,"to_user_id":2,"amount":"1","quote_id":"q1"}`, http.StatusBadRequest, "validation_error"},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, http.MethodPost, c.target, c.body, nil)
+		if code, _ := decodeErrorResponse(t, rec); rec.Code != c.status || code != c.code {
+			t.Errorf("%s：预期%d %s，实际：%d %s", c.name, c.status, c.code, rec.Code, code)
+		}
+	}
+}
+
+// 测试余额视图与预授权接口
+func TestAPI_V1Holds(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}).Routes()
+
+	rec := doJSONRequest(router, http.MethodGet, "/v1/wallets/1/balance?currency=CNY", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ledger":"100.00"`) || !strings.Contains(rec.Body.String(), `"available":"60.00"`) {
+		t.Errorf("余额接口预期返回账面与可用余额，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSONRequest(router, http.MethodPost, "/v1/wallets/1/holds", `{"amount":"40","currency":"CNY","payee_user_id":2,"ttl_seconds":60}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"status":"authorized"`) {
+		t.Errorf("预授权预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	// 请求体为空时全额请款
+	rec = doJSONRequest(router, http.MethodPost, "/v1/holds/1/capture", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"captured_amount":"40.00"`) {
+		t.Errorf("全额请款预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSONRequest(router, http.MethodPost, "/v1/holds/1/capture", `{"amount":"15.5"}`, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"captured_amount":"15.5"`) {
+		t.Errorf("部分请款预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"预授权金额缺失", http.MethodPost, "/v1/wallets/1/holds", `{"currency":"CNY"}`, http.StatusBadRequest, "validation_error"},
+		{"有效期为负", http.MethodPost, "/v1/wallets/1/holds", `{"amount":"1","ttl_seconds":-1}`, http.StatusBadRequest, "validation_error"},
+		{"预授权ID非法", http.MethodGet, "/v1/holds/abc", "", http.StatusBadRequest, "validation_error"},
+		{"请款金额非正", http.MethodPost, "/v1/holds/1/capture", `{"amount":"0"}`, http.StatusBadRequest, "validation_error"},
+		{"预授权不存在", http.MethodPost, "/v1/holds/2/capture", "", http.StatusNotFound, "hold_not_found"},
+		{"预授权已结束", http.MethodPost, "/v1/holds/1/void", "", http.StatusConflict, "hold_not_active"},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, c.method, c.target, c.body, nil)
+		if code, _ := decodeErrorResponse(t, rec); rec.Code != c.status || code != c.code {
+			t.Errorf("%s：预期%d %s，实际：%d %s", c.name, c.status, c.code, rec.Code, code)
+		}
+	}
+}
+
+// 测试冲正与退款接口
+func TestAPI_V1Reversals(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}).Routes()
+
+	rec := doJSONRequest(router, http.MethodPost, "/v1/transactions/1/reversals", `{"reason":"重复入账"}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"amount":"10.00"`) {
+		t.Errorf("冲正预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSONRequest(router, http.MethodPost, "/v1/transactions/1/reversals", `{"reason":"部分退款","amount":"2.5"}`, nil)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"amount":"2.5"`) {
+		t.Errorf("部分退款预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"缺少原因", "/v1/transactions/1/reversals", `{"reason":" "}`, http.StatusBadRequest, "validation_error"},
+		{"退款金额非正", "/v1/transactions/1/reversals", `{"reason":"x","amount":"-1"}`, http.StatusBadRequest, "validation_error"},
+		{"交易ID非法", "/v1/transactions/0/reversals", `{"reason":"x"}`, http.StatusBadRequest, "validation_error"},
+		{"重复冲正", "/v1/transactions/2/reversals", `{"reason":"x"}`, http.StatusConflict, "already_reversed"},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, http.MethodPost, c.target, c.body, nil)
+		if code, _ := decodeErrorResponse(t, rec); rec.Code != c.status || code != c.code {
+			t.Errorf("%s：预期%d %s，实际：%d %s", c.name, c.status, c.code, rec.Code, code)
+		}
+	}
+}
+
+// 测试交易历史的过滤参数与游标分页
+func TestAPI_V1TransactionHistory(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService).Routes()
+
+	rec := doJSONRequest(router, http.MethodGet, "/v1/wallets/1/transactions?type=deposit,refund_in&type=withdrawal&min_amount=1&max_amount=100.5&from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00%2B08:00&limit=20", "", nil)
+	var page model.TransactionPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); rec.Code != http.StatusOK || err != nil || page.NextCursor == "" || page.Transactions[0].ReversalOf != 3 {
+		t.Fatalf("交易历史预期返回一页记录与next_cursor，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	filter := walletService.lastHistoryFilter
+	if strings.Join(filter.Types, ",") != "deposit,refund_in,withdrawal" || filter.MinAmount.String() != "1" || filter.MaxAmount.String() != "100.5" ||
+		filter.From.Unix() != 1714521600 || filter.To.Unix() != 1717171200 || filter.Limit != 20 || filter.Cursor != nil {
+		t.Errorf("查询参数解析不正确：%+v", filter)
+	}
+
+	rec = doJSONRequest(router, http.MethodGet, "/v1/wallets/1/transactions?cursor="+page.NextCursor, "", nil)
+	if rec.Code != http.StatusOK || walletService.lastHistoryFilter.Cursor == nil || walletService.lastHistoryFilter.Cursor.ID != 7 {
+		t.Errorf("带游标的请求预期解析出游标，实际：%d %+v", rec.Code, walletService.lastHistoryFilter)
+	}
+	if strings.Contains(rec.Body.String(), "next_cursor") {
+		t.Errorf("最后一页不应返回next_cursor，实际：%s", rec.Body.String())
+	}
+
+	for field, query := range map[string]string{
+		"limit":      "limit=0",
+		"cursor":     "cursor=not-a-cursor",
+		"from":       "from=2024-05-01",
+		"min_amount": "min_amount=abc",
+	} {
+		rec := doJSONRequest(router, http.MethodGet, "/v1/wallets/1/transactions?"+query, "", nil)
+		code, details := decodeErrorResponse(t, rec)
+		if rec.Code != http.StatusBadRequest || code != "validation_error" || details["field"] != field {
+			t.Errorf("%s：预期400 validation_error，实际：%d %s %v", query, rec.Code, code, details)
+		}
+	}
+
+	// 旧接口同样只返回一页，并输出下一页游标
+	legacy := httptest.NewRecorder()
+	api.NewAPI(walletService).HistoryHandler(legacy, httptest.NewRequest(http.MethodGet, "/history?user_id=1", nil))
+	if body := legacy.Body.String(); !strings.Contains(body, "Reversal of: 3, Reason: 退款") || !strings.Contains(body, "Next cursor: ") {
+		t.Errorf("旧接口预期输出冲正关联与下一页游标，实际：%s", body)
+	}
+}
+
+// 测试对账单接口：格式与响应头、期间参数解析、生成前的错误以JSON返回
+func TestAPI_V1Statements(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}).Routes()
+
+	rec := doRequest(router, http.MethodGet, "/v1/wallets/1/statements?from=2024-05-01&to=2024-06-01", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("默认预期返回CSV对账单，实际：%d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != `attachment; filename="statement-1-CNY-2024-05-01-2024-06-01.csv"` {
+		t.Errorf("Content-Disposition不正确：%s", disposition)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "2024-05-01T01:00:00Z,7,withdrawal,-30.00,CNY,70.00,,") || !strings.Contains(body, "closing_balance") {
+		t.Errorf("CSV对账单内容不正确：%s", body)
+	}
+
+	rec = doRequest(router, http.MethodGet, "/v1/wallets/1/statements?currency=USD&from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z&format=jsonl", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" || strings.Count(rec.Body.String(), "\n") != 3 {
+		t.Errorf("JSONL对账单预期3行，实际：%d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(router, http.MethodGet, "/v1/wallets/1/statements?from=2024-05-01&to=2024-06-01&format=pdf", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rec.Body.String(), "%PDF-") {
+		t.Errorf("PDF对账单不正确：%d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	cases := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"格式不支持", "/v1/wallets/1/statements?from=2024-05-01&to=2024-06-01&format=xlsx", http.StatusBadRequest, "validation_error"},
+		{"缺少from", "/v1/wallets/1/statements?to=2024-06-01", http.StatusBadRequest, "validation_error"},
+		{"to格式错误", "/v1/wallets/1/statements?from=2024-05-01&to=06/01/2024", http.StatusBadRequest, "validation_error"},
+		{"钱包不存在", "/v1/wallets/404/statements?from=2024-05-01&to=2024-06-01", http.StatusNotFound, "wallet_not_found"},
+	}
+	for _, c := range cases {
+		rec := doRequest(router, http.MethodGet, c.target, nil)
+		if code, _ := decodeErrorResponse(t, rec); rec.Code != c.status || code != c.code {
+			t.Errorf("%s：预期%d %s，实际：%d %s", c.name, c.status, c.code, rec.Code, code)
+		}
+	}
+}
+
+// 测试请求ID与客户端IP：沿用合法的X-Request-ID，只信任受信任代理追加的X-Forwarded-For
+func TestAPI_RequestInfo(t *testing.T) {
+	var got audit.Request
+	walletService := &MockWalletService{depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+		got = audit.FromContext(ctx)
+		return &model.Wallet{UserID: userID, Currency: currency, Balance: amount}, nil
+	}}
+	_, proxies, _ := net.ParseCIDR("192.0.2.0/24")
+	router := api.NewAPI(walletService, api.WithTrustedProxies([]*net.IPNet{proxies})).Routes()
+
+	rec := doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"1"}`, map[string]string{
+		api.RequestIDHeader: "req-42",
+		"X-Forwarded-For":   "10.9.9.9, 198.51.100.4, 192.0.2.8",
+	})
+	if rec.Code != http.StatusCreated || rec.Header().Get(api.RequestIDHeader) != "req-42" {
+		t.Fatalf("预期沿用客户端的请求ID，实际：%d %q", rec.Code, rec.Header().Get(api.RequestIDHeader))
+	}
+	if got.ID != "req-42" || got.ClientIP != "198.51.100.4" {
+		t.Errorf("预期客户端IP为最右侧的不受信任地址，实际：%+v", got)
+	}
+
+	// httptest的请求来自192.0.2.1，不信任任何代理时忽略X-Forwarded-For
+	router = api.NewAPI(walletService).Routes()
+	rec = doJSONRequest(router, http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"1"}`, map[string]string{
+		api.RequestIDHeader: "bad id\n",
+		"X-Forwarded-For":   "10.9.9.9",
+	})
+	generated := rec.Header().Get(api.RequestIDHeader)
+	if generated == "" || generated == "bad id\n" || got.ID != generated || got.ClientIP != "192.0.2.1" {
+		t.Errorf("预期重新生成请求ID并使用对端地址，实际：%q %+v", generated, got)
+	}
+}
+
+// 测试审计日志接口解析查询参数
+func TestAPI_ListAuditLog(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService).Routes()
+
+	rec := doJSONRequest(router, http.MethodGet, "/v1/admin/audit?actor=key:gateway&action=deposit&user_id=1&limit=20&cursor="+model.EncodeAuditCursor(9), "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("预期返回200，实际：%d %s", rec.Code, rec.Body.String())
+	}
+	filter := walletService.lastAuditFilter
+	if filter.Actor != "key:gateway" || filter.Action != "deposit" || filter.UserID != 1 || filter.Limit != 20 || filter.BeforeID != 9 {
+		t.Errorf("查询条件解析不正确：%+v", filter)
+	}
+	var page model.AuditPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || len(page.Entries) != 1 || page.Entries[0].BalanceAfter.String() != "10.00" || page.NextCursor == "" {
+		t.Errorf("响应体不正确：%s", rec.Body.String())
+	}
+
+	for _, target := range []string{"/v1/admin/audit?user_id=abc", "/v1/admin/audit?from=yesterday", "/v1/admin/audit?cursor=xyz"} {
+		if rec := doJSONRequest(router, http.MethodGet, target, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s 预期返回400，实际：%d", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package unit
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet-service/internal/api"
+	"wallet-service/internal/auth"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// newTestVerifier 创建配置了支付网关API密钥、绑定1号用户的API密钥与HS256令牌密钥的Verifier
+func newTestVerifier(t *testing.T) *auth.Verifier {
+	verifier, err := auth.NewVerifier(auth.Config{
+		APIKeys: []auth.APIKey{
+			{ID: "gateway", Secret: "gateway-secret", Scopes: []string{auth.ScopeDeposit, auth.ScopeWalletsRead}},
+			{ID: "mobile-1", Secret: "mobile-secret", UserID: 1},
+		},
+		JWT: auth.JWTConfig{
+			Issuer:   "https://id.example.com",
+			Audience: "wallet-service",
+			Keys:     []auth.JWTKeyConfig{{ID: "k1", Algorithm: "HS256", Secret: testJWTSecret}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建Verifier时预期无错误，实际错误：%v", err)
+	}
+	return verifier
+}
+
+// signToken 用测试密钥签发令牌
+func signToken(t *testing.T, subject, scope string, expiresAt time.Time) string {
+	return signRoleToken(t, subject, scope, nil, expiresAt)
+}
+
+// signRoleToken 用测试密钥签发携带后台角色的令牌
+func signRoleToken(t *testing.T, subject, scope string, roles []string, expiresAt time.Time) string {
+	token, err := auth.SignHS256(auth.Claims{
+		Subject:   subject,
+		Issuer:    "https://id.example.com",
+		Audience:  auth.Audience{"wallet-service"},
+		ExpiresAt: expiresAt.Unix(),
+		Scope:     scope,
+		Roles:     roles,
+	}, "k1", testJWTSecret)
+	if err != nil {
+		t.Fatalf("签发令牌时预期无错误，实际错误：%v", err)
+	}
+	return token
+}
+
+// 测试HMAC签名的API密钥：签名覆盖方法、路径、时间戳与请求体
+func TestVerifier_HMAC(t *testing.T) {
+	verifier := newTestVerifier(t)
+	newRequest := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/v1/wallets/5/deposits", strings.NewReader(body))
+	}
+
+	req := newRequest(`{"amount":"10"}`)
+	if err := auth.SignRequest(req, "gateway", "gateway-secret", time.Now()); err != nil {
+		t.Fatalf("签名时预期无错误，实际错误：%v", err)
+	}
+	principal, err := verifier.Authenticate(req)
+	if err != nil || principal.Subject != "key:gateway" || principal.IsUser() || !principal.CanAccessWallet(5, auth.ScopeDeposit) {
+		t.Fatalf("预期认证为支付网关服务账号，实际：%+v，%v", principal, err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body["amount"] != "10" {
+		t.Errorf("认证后请求体应可再次读取，实际：%v，%v", body, err)
+	}
+
+	// 绑定用户的密钥只能访问该用户的钱包，且不能获得存款权限
+	req = newRequest("")
+	auth.SignRequest(req, "mobile-1", "mobile-secret", time.Now())
+	principal, err = verifier.Authenticate(req)
+	if err != nil || principal.UserID != 1 || principal.CanAccessWallet(2, auth.ScopeWalletsRead) || principal.HasScope(auth.ScopeDeposit) {
+		t.Errorf("预期认证为1号用户且只能访问自己的钱包，实际：%+v，%v", principal, err)
+	}
+
+	tampered := newRequest(`{"amount":"10"}`)
+	auth.SignRequest(tampered, "gateway", "gateway-secret", time.Now())
+	tampered.Body = newRequest(`{"amount":"1000"}`).Body
+	stale := newRequest("")
+	auth.SignRequest(stale, "gateway", "gateway-secret", time.Now().Add(-time.Hour))
+	unknown := newRequest("")
+	auth.SignRequest(unknown, "nobody", "gateway-secret", time.Now())
+	wrongSecret := newRequest("")
+	auth.SignRequest(wrongSecret, "gateway", "guess", time.Now())
+
+	cases := map[string]*http.Request{"篡改请求体": tampered, "时间戳过旧": stale, "密钥不存在": unknown, "密钥错误": wrongSecret}
+	for name, req := range cases {
+		if _, err := verifier.Authenticate(req); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Errorf("%s：预期返回ErrInvalidCredentials，实际：%v", name, err)
+		}
+	}
+	if _, err := verifier.Authenticate(newRequest("")); !errors.Is(err, auth.ErrMissingCredentials) {
+		t.Errorf("未携带凭证时预期返回ErrMissingCredentials，实际：%v", err)
+	}
+}
+
+// 测试JWT：数字sub为终端用户，其他sub为按scope授权的服务账号
+func TestVerifier_JWT(t *testing.T) {
+	verifier := newTestVerifier(t)
+	authenticate := func(token string) (*auth.Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/wallets/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return verifier.Authenticate(req)
+	}
+
+	principal, err := authenticate(signToken(t, "42", "", time.Now().Add(time.Hour)))
+	if err != nil || principal.Subject != "user:42" || !principal.CanAccessWallet(42, auth.ScopeWithdraw) || principal.CanAccessWallet(1, auth.ScopeWithdraw) {
+		t.Errorf("预期认证为42号用户并获得默认用户权限，实际：%+v，%v", principal, err)
+	}
+	// 终端用户令牌声明的权限超出UserScopes的部分被忽略
+	principal, err = authenticate(signToken(t, "42", "wallets:read transactions:reverse", time.Now().Add(time.Hour)))
+	if err != nil || !principal.HasScope(auth.ScopeWalletsRead) || principal.HasScope(auth.ScopeReverse) || principal.HasScope(auth.ScopeWithdraw) {
+		t.Errorf("预期终端用户只获得令牌中声明的用户权限，实际：%+v，%v", principal, err)
+	}
+	principal, err = authenticate(signToken(t, "support-tool", "transactions:reverse", time.Now().Add(time.Hour)))
+	if err != nil || principal.Subject != "service:support-tool" || !principal.CanAccessWallet(7, auth.ScopeReverse) || principal.HasScope(auth.ScopeDeposit) {
+		t.Errorf("预期认证为只有冲正权限的服务账号，实际：%+v，%v", principal, err)
+	}
+
+	valid := signToken(t, "42", "", time.Now().Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`))
+	otherAudience, _ := auth.SignHS256(auth.Claims{Subject: "42", Issuer: "https://id.example.com", Audience: auth.Audience{"other"}, ExpiresAt: time.Now().Add(time.Hour).Unix()}, "k1", testJWTSecret)
+	cases := map[string]string{
+		"令牌过期":     signToken(t, "42", "", time.Now().Add(-time.Hour)),
+		"签名错误":     parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")),
+		"alg为none": noneHeader + "." + parts[1] + ".",
+		"受众不符":     otherAudience,
+		"格式错误":     "not-a-token",
+	}
+	for name, token := range cases {
+		if _, err := authenticate(token); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Errorf("%s：预期返回ErrInvalidCredentials，实际：%v", name, err)
+		}
+	}
+}
+
+// 测试RS256令牌使用配置的公钥验签
+func TestVerifier_RS256(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成RSA密钥失败：%v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	verifier, err := auth.NewVerifier(auth.Config{JWT: auth.JWTConfig{Keys: []auth.JWTKeyConfig{
+		{ID: "rsa-1", Algorithm: "RS256", PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))},
+	}}})
+	if err != nil {
+		t.Fatalf("创建Verifier时预期无错误，实际错误：%v", err)
+	}
+
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := encode(map[string]string{"alg": "RS256", "kid": "rsa-1"}) + "." + encode(auth.Claims{Subject: "7", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	req := httptest.NewRequest(http.MethodGet, "/v1/wallets/7", nil)
+	req.Header.Set("Authorization", "Bearer "+signingInput+"."+base64.RawURLEncoding.EncodeToString(sig))
+	if principal, err := verifier.Authenticate(req); err != nil || principal.UserID != 7 {
+		t.Errorf("预期认证为7号用户，实际：%+v，%v", principal, err)
+	}
+
+	// 以公钥作为HS256密钥伪造的令牌必须被拒绝
+	forged, _ := auth.SignHS256(auth.Claims{Subject: "7", ExpiresAt: time.Now().Add(time.Hour).Unix()}, "rsa-1", string(der))
+	req.Header.Set("Authorization", "Bearer "+forged)
+	if _, err := verifier.Authenticate(req); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("算法与配置不符时预期返回ErrInvalidCredentials，实际：%v", err)
+	}
+}
+
+// 测试不合法的认证配置
+func TestNewVerifier_InvalidConfig(t *testing.T) {
+	cases := map[string]auth.Config{
+		"没有密钥":      {},
+		"权限未知":      {APIKeys: []auth.APIKey{{ID: "a", Secret: "s", Scopes: []string{"admin"}}}},
+		"密钥重复":      {APIKeys: []auth.APIKey{{ID: "a", Secret: "s"}, {ID: "a", Secret: "t"}}},
+		"角色未知":      {APIKeys: []auth.APIKey{{ID: "a", Secret: "s", Roles: []string{"root"}}}},
+		"终端用户带角色":   {APIKeys: []auth.APIKey{{ID: "a", Secret: "s", UserID: 1, Roles: []string{auth.RoleSupport}}}},
+		"HS256密钥过短": {JWT: auth.JWTConfig{Keys: []auth.JWTKeyConfig{{ID: "k", Algorithm: "HS256", Secret: "short"}}}},
+		"算法不支持":     {JWT: auth.JWTConfig{Keys: []auth.JWTKeyConfig{{ID: "k", Algorithm: "none"}}}},
+	}
+	for name, cfg := range cases {
+		if _, err := auth.NewVerifier(cfg); err == nil {
+			t.Errorf("%s：预期返回错误", name)
+		}
+	}
+}
+
+// 测试API的认证与授权：终端用户只能操作自己的钱包，服务账号按权限访问
+func TestAPI_Authorization(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService, api.WithAuthenticator(newTestVerifier(t))).Routes()
+	bearer := func(subject, scope string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + signToken(t, subject, scope, time.Now().Add(time.Hour))}
+	}
+	user1 := bearer("1", "")
+	support := bearer("support-tool", "transactions:reverse")
+
+	cases := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		headers map[string]string
+		status  int
+	}{
+		{"未携带凭证", http.MethodGet, "/v1/wallets/1", "", nil, http.StatusUnauthorized},
+		{"令牌无效", http.MethodGet, "/v1/wallets/1", "", map[string]string{"Authorization": "Bearer x.y.z"}, http.StatusUnauthorized},
+		{"旧接口未携带凭证", http.MethodPost, "/withdraw?user_id=5&amount=1", "", nil, http.StatusUnauthorized},
+		{"查询自己的钱包", http.MethodGet, "/v1/wallets/1", "", user1, http.StatusOK},
+		{"查询他人的钱包", http.MethodGet, "/v1/wallets/2/balance", "", user1, http.StatusForbidden},
+		{"从自己的钱包取款", http.MethodPost, "/v1/wallets/1/withdrawals", `{"amount":"1"}`, user1, http.StatusCreated},
+		{"从他人的钱包取款", http.MethodPost, "/v1/wallets/5/withdrawals", `{"amount":"1"}`, user1, http.StatusForbidden},
+		{"旧接口从他人的钱包取款", http.MethodPost, "/withdraw?user_id=5&amount=1", "", user1, http.StatusForbidden},
+		{"旧接口从他人的钱包转出", http.MethodPost, "/transfer?from_user_id=5&to_user_id=1&amount=1", "", user1, http.StatusForbidden},
+		{"从自己的钱包转出", http.MethodPost, "/v1/transfers", `{"from_user_id":1,"to_user_id":2,"amount":"1"}`, user1, http.StatusCreated},
+		{"从他人的钱包转出", http.MethodPost, "/v1/transfers", `{"from_user_id":5,"to_user_id":1,"amount":"1"}`, user1, http.StatusForbidden},
+		{"终端用户存款", http.MethodPost, "/v1/wallets/1/deposits", `{"amount":"1"}`, user1, http.StatusForbidden},
+		{"终端用户冲正", http.MethodPost, "/v1/transactions/3/reversals", `{"reason":"x"}`, user1, http.StatusForbidden},
+		{"付款方查询预授权", http.MethodGet, "/v1/holds/1", "", user1, http.StatusOK},
+		{"收款方请款", http.MethodPost, "/v1/holds/1/capture", "", bearer("2", ""), http.StatusOK},
+		{"无关用户撤销预授权", http.MethodPost, "/v1/holds/1/void", "", bearer("3", ""), http.StatusForbidden},
+		{"客服冲正", http.MethodPost, "/v1/transactions/3/reversals", `{"reason":"x"}`, support, http.StatusCreated},
+		{"客服无取款权限", http.MethodPost, "/v1/wallets/5/withdrawals", `{"amount":"1"}`, support, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, c.method, c.target, c.body, c.headers)
+		if rec.Code != c.status {
+			t.Errorf("%s：预期%d，实际：%d %s", c.name, c.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 支付网关使用HMAC签名的API密钥为任意用户存款
+	req := httptest.NewRequest(http.MethodPost, "/v1/wallets/5/deposits", strings.NewReader(`{"amount":"10"}`))
+	auth.SignRequest(req, "gateway", "gateway-secret", time.Now())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || walletService.depositCalls != 1 {
+		t.Errorf("支付网关存款预期返回201，实际：%d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// 测试后台角色展开为权限范围，终端用户的令牌不能携带角色
+func TestNewPrincipal_Roles(t *testing.T) {
+	finance := auth.NewPrincipal("service:backoffice", 0, []string{auth.ScopeDeposit}, []string{auth.RoleFinance, "root"})
+	for _, scope := range []string{auth.ScopeDeposit, auth.ScopeAdjust, auth.ScopeLimits, auth.ScopeReverse, auth.ScopeLedgerRead} {
+		if !finance.HasScope(scope) {
+			t.Errorf("财务角色预期拥有%s权限，实际：%v", scope, finance.Scopes)
+		}
+	}
+	if finance.HasScope(auth.ScopeFreeze) || len(finance.Roles) != 1 {
+		t.Errorf("财务角色不应拥有冻结权限，未知角色应被忽略，实际：%+v", finance)
+	}
+
+	verifier := newTestVerifier(t)
+	req := httptest.NewRequest(http.MethodGet, "/v1/wallets/1", nil)
+	req.Header.Set("Authorization", "Bearer "+signRoleToken(t, "1", "", []string{auth.RoleFinance}, time.Now().Add(time.Hour)))
+	principal, err := verifier.Authenticate(req)
+	if err != nil || len(principal.Roles) != 0 || principal.HasScope(auth.ScopeAdjust) {
+		t.Errorf("终端用户的令牌预期忽略角色，实际：%+v，%v", principal, err)
+	}
+}
+
+// 测试后台接口按角色授权：客服冻结钱包，财务调账，审计只读
+func TestAPI_AdminRoles(t *testing.T) {
+	router := api.NewAPI(&MockWalletService{}, api.WithAuthenticator(newTestVerifier(t))).Routes()
+	role := func(roles ...string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + signRoleToken(t, "backoffice", "", roles, time.Now().Add(time.Hour))}
+	}
+	support, finance, auditor := role(auth.RoleSupport), role(auth.RoleFinance), role(auth.RoleAuditor)
+	user1 := map[string]string{"Authorization": "Bearer " + signToken(t, "1", "", time.Now().Add(time.Hour))}
+
+	cases := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		headers map[string]string
+		status  int
+	}{
+		{"终端用户为自己开户", http.MethodPost, "/v1/wallets/1", `{"currency":"CNY"}`, user1, http.StatusConflict},
+		{"终端用户开户时设置限额", http.MethodPost, "/v1/wallets/1", `{"currency":"CNY","limits":{"daily_outgoing":"1000000"}}`, user1, http.StatusForbidden},
+		{"客服开户时设置限额", http.MethodPost, "/v1/wallets/5", `{"currency":"CNY","limits":{"daily_outgoing":"1000000"}}`, support, http.StatusForbidden},
+		{"客服与财务开户时设置限额", http.MethodPost, "/v1/wallets/5", `{"currency":"CNY","limits":{"daily_outgoing":"1000000"}}`, role(auth.RoleSupport, auth.RoleFinance), http.StatusCreated},
+		{"客服冻结钱包", http.MethodPost, "/v1/admin/wallets/5/freeze", `{"reason":"疑似盗用"}`, support, http.StatusOK},
+		{"客服解冻钱包", http.MethodPost, "/v1/admin/wallets/5/unfreeze", `{"reason":"核实完毕"}`, support, http.StatusOK},
+		{"冻结未填写原因", http.MethodPost, "/v1/admin/wallets/5/freeze", `{}`, support, http.StatusBadRequest},
+		{"客服销户", http.MethodPost, "/v1/admin/wallets/5/close", `{"reason":"客户注销"}`, support, http.StatusOK},
+		{"余额不为0不能销户", http.MethodPost, "/v1/admin/wallets/1/close", `{"reason":"客户注销"}`, support, http.StatusConflict},
+		{"财务不能销户", http.MethodPost, "/v1/admin/wallets/5/close", `{"reason":"x"}`, finance, http.StatusForbidden},
+		{"客服不能调账", http.MethodPost, "/v1/admin/wallets/5/adjustments", `{"amount":"10","reason":"补差"}`, support, http.StatusForbidden},
+		{"财务调账", http.MethodPost, "/v1/admin/wallets/5/adjustments", `{"amount":"-10","reason":"误入账"}`, finance, http.StatusCreated},
+		{"调账金额为0", http.MethodPost, "/v1/admin/wallets/5/adjustments", `{"amount":"0","reason":"补差"}`, finance, http.StatusBadRequest},
+		{"调账未填写原因", http.MethodPost, "/v1/admin/wallets/5/adjustments", `{"amount":"10"}`, finance, http.StatusBadRequest},
+		{"财务设置限额", http.MethodPut, "/v1/admin/wallets/5/limits", `{"limits":{"daily_outgoing":"500"}}`, finance, http.StatusOK},
+		{"客服不能设置限额", http.MethodPut, "/v1/admin/wallets/5/limits", `{"limits":{}}`, support, http.StatusForbidden},
+		{"终端用户查询自己的限额", http.MethodGet, "/v1/wallets/1/limits", "", user1, http.StatusOK},
+		{"终端用户不能设置自己的限额", http.MethodPut, "/v1/admin/wallets/1/limits", `{"limits":{}}`, user1, http.StatusForbidden},
+		{"财务不能冻结", http.MethodPost, "/v1/admin/wallets/5/freeze", `{"reason":"x"}`, finance, http.StatusForbidden},
+		{"审计核对账本", http.MethodGet, "/v1/admin/ledger", "", auditor, http.StatusOK},
+		{"审计校验哈希链", http.MethodGet, "/v1/admin/ledger/chain", "", auditor, http.StatusOK},
+		{"客服不能校验哈希链", http.MethodGet, "/v1/admin/ledger/chain", "", support, http.StatusForbidden},
+		{"审计查询钱包", http.MethodGet, "/v1/wallets/5", "", auditor, http.StatusOK},
+		{"审计不能冻结", http.MethodPost, "/v1/admin/wallets/5/freeze", `{"reason":"x"}`, auditor, http.StatusForbidden},
+		{"审计不能冲正", http.MethodPost, "/v1/transactions/3/reversals", `{"reason":"x"}`, auditor, http.StatusForbidden},
+		{"审计查询审计日志", http.MethodGet, "/v1/admin/audit", "", auditor, http.StatusOK},
+		{"财务不能查询审计日志", http.MethodGet, "/v1/admin/audit", "", finance, http.StatusForbidden},
+		{"客服不能查询审计日志", http.MethodGet, "/v1/admin/audit", "", support, http.StatusForbidden},
+		{"终端用户不能冻结自己的钱包", http.MethodPost, "/v1/admin/wallets/1/freeze", `{"reason":"x"}`, user1, http.StatusForbidden},
+		{"终端用户不能核对账本", http.MethodGet, "/v1/admin/ledger", "", user1, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := doJSONRequest(router, c.method, c.target, c.body, c.headers)
+		if rec.Code != c.status {
+			t.Errorf("%s：预期%d，实际：%d %s", c.name, c.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// 测试不同调用方的幂等键互不影响
+func TestAPI_IdempotencyKeyPerPrincipal(t *testing.T) {
+	walletService := &MockWalletService{}
+	router := api.NewAPI(walletService,
+		api.WithAuthenticator(newTestVerifier(t)),
+		api.WithIdempotency(newMemoryIdempotencyRepository(), time.Hour),
+	).Routes()
+
+	for _, subject := range []string{"1", "2"} {
+		headers := map[string]string{
+			"Authorization":          "Bearer " + signToken(t, subject, "", time.Now().Add(time.Hour)),
+			api.IdempotencyKeyHeader: "same-key",
+		}
+		rec := doJSONRequest(router, http.MethodPost, "/v1/wallets/"+subject+"/withdrawals", `{"amount":"1"}`, headers)
+		if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("用户%s使用相同的幂等键预期独立执行，实际：%d %s", subject, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wallet-service/internal/cli"
+	"wallet-service/internal/service"
+)
+
+// 测试运维命令的输出
+func TestAdmin_Commands(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"查看钱包", []string{"wallet", "show", "-user", "1"}, []string{"USER", "AVAILABLE", "STATUS", "1     CNY       100.00   40.00  60.00"}},
+		{"调减", []string{"wallet", "adjust", "-user", "1", "-currency", "CNY", "-amount", "-5.00", "-reason", "误入账"}, []string{"adjustment_debit 5.00 CNY posted to user 1 (entry 9): 误入账"}},
+		{"冻结", []string{"wallet", "freeze", "-user", "1", "-currency", "CNY", "-reason", "风控"}, []string{"CNY wallet of user 1 is frozen"}},
+		{"销户", []string{"wallet", "close", "-user", "2", "-currency", "CNY", "-reason", "客户注销"}, []string{"CNY wallet of user 2 is closed"}},
+		{"核对账本", []string{"ledger", "verify"}, []string{"CURRENCY", "3 transactions, 0 unchained, 1 checkpoints verified", "hash chain is intact"}},
+		{"生成检查点", []string{"ledger", "checkpoint"}, []string{"checkpoint 2 signed up to transaction 3 with key 0123456789abcdef"}},
+		{"导出对账单", []string{"export", "-user", "1", "-currency", "CNY", "-from", "2024-05-01", "-to", "2024-06-01"}, []string{"withdrawal", "-30.00"}},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		if err := cli.NewAdmin(&MockWalletService{}, &out).Run(context.Background(), c.args); err != nil {
+			t.Errorf("%s：预期无错误，实际错误：%v", c.name, err)
+			continue
+		}
+		for _, want := range c.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s：输出缺少%q，实际：\n%s", c.name, want, out.String())
+			}
+		}
+	}
+}
+
+// 测试参数错误返回ErrUsage，服务错误原样返回
+func TestAdmin_Errors(t *testing.T) {
+	admin := cli.NewAdmin(&MockWalletService{}, &bytes.Buffer{})
+	usageCases := [][]string{
+		{},
+		{"wallet"},
+		{"wallet", "delete", "-user", "1"},
+		{"wallet", "close", "-user", "2"},
+		{"wallet", "show"},
+		{"wallet", "freeze", "-user", "1"},
+		{"wallet", "adjust", "-user", "1", "-currency", "CNY", "-amount", "abc", "-reason", "x"},
+		{"ledger"},
+		{"ledger", "rebuild"},
+		{"export", "-user", "1", "-currency", "CNY", "-from", "2024-05-01", "-to", "2024-06-01", "-format", "xml"},
+	}
+	for _, args := range usageCases {
+		if err := admin.Run(context.Background(), args); !errors.Is(err, cli.ErrUsage) {
+			t.Errorf("%v：预期返回ErrUsage，实际：%v", args, err)
+		}
+	}
+
+	if err := admin.Run(context.Background(), []string{"wallet", "freeze", "-user", "1", "-currency", "CNY", "-reason", ""}); !errors.Is(err, service.ErrReasonRequired) {
+		t.Errorf("预期返回ErrReasonRequired，实际：%v", err)
+	}
+
+	// 导出失败时不保留不完整的文件
+	output := filepath.Join(t.TempDir(), "statement.csv")
+	err := admin.Run(context.Background(), []string{"export", "-user", "404", "-currency", "CNY", "-from", "2024-05-01", "-to", "2024-06-01", "-output", output})
+	if !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("预期返回ErrWalletNotFound，实际：%v", err)
+	}
+	if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+		t.Errorf("导出失败时预期删除输出文件，实际：%v", statErr)
+	}
+}
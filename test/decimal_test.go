@@ -0,0 +1,185 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// 测试十进制数解析与格式化
+func TestDecimal_ParseAndString(t *testing.T) {
+	cases := map[string]string{
+		"12.34":  "12.34",
+		"-0.5":   "-0.5",
+		"+7":     "7",
+		"0.010":  "0.010",
+		".25":    "0.25",
+		"100.":   "100",
+		"000.10": "0.10",
+	}
+	for input, want := range cases {
+		d, err := decimal.Parse(input)
+		if err != nil {
+			t.Errorf("解析%q时预期无错误，实际错误：%v", input, err)
+			continue
+		}
+		if d.String() != want {
+			t.Errorf("解析%q后预期输出%q，实际：%q", input, want, d.String())
+		}
+	}
+
+	for _, input := range []string{"", "-", ".", "1e5", "1.2.3", "abc", "NaN"} {
+		if _, err := decimal.Parse(input); !errors.Is(err, decimal.ErrInvalid) {
+			t.Errorf("解析%q时预期返回ErrInvalid，实际：%v", input, err)
+		}
+	}
+	if _, err := decimal.Parse("12345678901234567890"); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("超过最大位数时预期返回ErrOverflow，实际：%v", err)
+	}
+}
+
+// 测试加减法不存在浮点误差
+func TestDecimal_Arithmetic(t *testing.T) {
+	sum := decimal.MustParse("0.1").Add(decimal.MustParse("0.2"))
+	if !sum.Equal(decimal.MustParse("0.3")) {
+		t.Errorf("预期0.1+0.2=0.3，实际：%s", sum)
+	}
+	diff := decimal.MustParse("10").Sub(decimal.MustParse("0.01"))
+	if diff.String() != "9.99" {
+		t.Errorf("预期10-0.01=9.99，实际：%s", diff)
+	}
+	if !decimal.MustParse("1.0").Equal(decimal.MustParse("1.00")) {
+		t.Errorf("预期1.0与1.00相等")
+	}
+	if !decimal.MustParse("-1").LessThan(decimal.Zero) || decimal.Zero.IsPositive() {
+		t.Errorf("符号判断不正确")
+	}
+}
+
+// 测试精度校验与舍入规则
+func TestDecimal_Rounding(t *testing.T) {
+	if !decimal.MustParse("12.340").FitsScale(2) {
+		t.Errorf("12.340应能用2位小数表示")
+	}
+	if decimal.MustParse("12.345").FitsScale(2) {
+		t.Errorf("12.345不应能用2位小数表示")
+	}
+
+	cases := []struct {
+		input string
+		mode  decimal.RoundingMode
+		want  string
+	}{
+		{"2.345", decimal.RoundHalfEven, "2.34"},
+		{"2.355", decimal.RoundHalfEven, "2.36"},
+		{"2.345", decimal.RoundHalfUp, "2.35"},
+		{"-2.345", decimal.RoundHalfUp, "-2.35"},
+		{"2.349", decimal.RoundDown, "2.34"},
+		{"2.341", decimal.RoundUp, "2.35"},
+		{"2.3", decimal.RoundDown, "2.30"},
+	}
+	for _, c := range cases {
+		got, err := decimal.MustParse(c.input).Round(2, c.mode)
+		if err != nil || got.String() != c.want {
+			t.Errorf("%s按模式%d舍入预期%s，实际：%s", c.input, c.mode, c.want, got)
+		}
+	}
+
+	fee := decimal.MustParse("123.45").MulRound(decimal.MustParse("0.015"), 2, decimal.RoundHalfUp)
+	if fee.String() != "1.85" {
+		t.Errorf("预期123.45×0.015=1.85，实际：%s", fee)
+	}
+	third := decimal.MustParse("10").QuoRound(decimal.MustParse("3"), 4, decimal.RoundHalfEven)
+	if third.String() != "3.3333" {
+		t.Errorf("预期10÷3=3.3333，实际：%s", third)
+	}
+}
+
+// 测试超大金额在解析时被拒绝，舍入与精度校验在超出范围时返回错误而不是panic
+func TestDecimal_LargeValues(t *testing.T) {
+	for _, input := range []string{"9999999999999999", "99999999999999999", "999999999999999999", "9999999999999999999", "-99999999999999999", "10000000000000000.5"} {
+		if _, err := decimal.Parse(input); !errors.Is(err, decimal.ErrOverflow) {
+			t.Errorf("%s超出范围应返回ErrOverflow，实际：%v", input, err)
+		}
+	}
+
+	max := decimal.MustParse("999999999999999.999")
+	if !max.FitsScale(3) || max.FitsScale(2) {
+		t.Errorf("%s的精度判断不正确", max)
+	}
+	if got := model.NormalizeAmount(decimal.MustParse("999999999999999"), "KWD"); got.String() != "999999999999999.000" {
+		t.Errorf("预期999999999999999.000，实际：%s", got)
+	}
+
+	huge := decimal.New(math.MaxInt64, 0)
+	if huge.FitsScale(2) {
+		t.Errorf("%s无法用2位小数表示，FitsScale应返回false", huge)
+	}
+	if _, err := huge.Round(2, decimal.RoundHalfEven); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("预期ErrOverflow，实际：%v", err)
+	}
+	if got := model.NormalizeAmount(huge, "USD"); !got.Equal(huge) {
+		t.Errorf("无法调整的金额应原样返回，实际：%s", got)
+	}
+	if got := huge.StringFixed(2); got != huge.String() {
+		t.Errorf("无法调整的金额应原样输出，实际：%s", got)
+	}
+}
+
+// 测试算术运算的结果超出范围时，Err变体返回错误而不是panic
+func TestDecimal_CheckedArithmetic(t *testing.T) {
+	max := decimal.MustParse("999999999999999.999")
+	rate := decimal.MustParse("10000000000")
+	if _, err := max.MulRoundErr(rate, 2, decimal.RoundDown); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("乘积超出范围预期ErrOverflow，实际：%v", err)
+	}
+	if got, err := decimal.MustParse("12.5").MulRoundErr(decimal.MustParse("0.1"), 2, decimal.RoundHalfUp); err != nil || got.String() != "1.25" {
+		t.Errorf("预期1.25，实际：%s，%v", got, err)
+	}
+	huge := decimal.New(math.MaxInt64, 0)
+	if _, err := huge.AddErr(decimal.NewFromInt(1)); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("和超出范围预期ErrOverflow，实际：%v", err)
+	}
+	if _, err := max.QuoRoundErr(decimal.MustParse("0.001"), 3, decimal.RoundDown); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("商超出范围预期ErrOverflow，实际：%v", err)
+	}
+	if _, err := max.QuoRoundErr(decimal.Zero, 3, decimal.RoundDown); !errors.Is(err, decimal.ErrDivisionByZero) {
+		t.Errorf("除数为0预期ErrDivisionByZero，实际：%v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MulRound溢出时预期panic")
+		}
+	}()
+	max.MulRound(rate, 2, decimal.RoundDown)
+}
+
+// 测试JSON编解码以字符串形式传递金额
+func TestDecimal_JSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Amount decimal.Decimal `json:"amount"`
+	}{decimal.MustParse("12.50")})
+	if err != nil {
+		t.Fatalf("序列化时预期无错误，实际错误：%v", err)
+	}
+	if string(data) != `{"amount":"12.50"}` {
+		t.Errorf("序列化结果不正确：%s", data)
+	}
+
+	for _, body := range []string{`{"amount":"0.30"}`, `{"amount":0.30}`} {
+		var req struct {
+			Amount decimal.Decimal `json:"amount"`
+		}
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Errorf("反序列化%s时预期无错误，实际错误：%v", body, err)
+			continue
+		}
+		if req.Amount.String() != "0.30" {
+			t.Errorf("反序列化%s预期得到0.30，实际：%s", body, req.Amount)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+// decimalPtr 返回解析后的金额指针，便于构造可选字段
+func decimalPtr(s string) *decimal.Decimal {
+	d := decimal.MustParse(s)
+	return &d
+}
+
+// 测试固定金额、比例、阶梯与上下限的组合计算，结果按币种小数位数四舍五入
+func TestFeeRule_Calculate(t *testing.T) {
+	capped := model.FeeRule{Flat: decimal.MustParse("1"), Percent: decimal.MustParse("0.01"), Min: decimalPtr("2"), Max: decimalPtr("10")}
+	tiered := model.FeeRule{Tiers: []model.FeeTier{
+		{UpTo: decimalPtr("100"), Flat: decimal.MustParse("0.5")},
+		{UpTo: decimalPtr("1000"), Percent: decimal.MustParse("0.005")},
+		{Flat: decimal.MustParse("3"), Percent: decimal.MustParse("0.001")},
+	}}
+
+	cases := []struct {
+		name     string
+		rule     model.FeeRule
+		amount   string
+		currency string
+		want     string
+	}{
+		{"低于最低收费", capped, "50", "CNY", "2.00"},
+		{"固定加比例", capped, "250", "CNY", "3.50"},
+		{"超过最高收费", capped, "5000", "CNY", "10.00"},
+		{"比例四舍五入", capped, "123.45", "USD", "2.23"},
+		{"无小数位币种", model.FeeRule{Percent: decimal.MustParse("0.015")}, "1010", "JPY", "15"},
+		{"第一档含上限", tiered, "100", "CNY", "0.50"},
+		{"第二档", tiered, "500", "CNY", "2.50"},
+		{"最后一档", tiered, "2000", "CNY", "5.00"},
+	}
+	for _, c := range cases {
+		if fee := c.rule.Calculate(decimal.MustParse(c.amount), c.currency); fee.String() != c.want {
+			t.Errorf("%s：预期手续费%s，实际：%s", c.name, c.want, fee)
+		}
+	}
+}
+
+// 测试费率表校验拒绝未知的操作与币种、负数金额、不小于1的比例与乱序的阶梯
+func TestFeeSchedule_Validate(t *testing.T) {
+	valid := model.FeeSchedule{model.FeeOperationWithdrawal: {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("合法的费率表预期通过校验，实际：%v", err)
+	}
+	if rule, ok := valid.Rule(model.FeeOperationWithdrawal, "JPY"); !ok || rule.Flat.String() != "1" {
+		t.Errorf("币种没有单独配置时预期使用*的规则，实际：%+v，%v", rule, ok)
+	}
+	if _, ok := valid.Rule(model.FeeOperationTransfer, "CNY"); ok {
+		t.Errorf("没有配置的操作不应有规则")
+	}
+
+	invalid := map[string]model.FeeSchedule{
+		"未知操作":    {"deposit": {"*": {}}},
+		"未知币种":    {model.FeeOperationTransfer: {"XXX": {}}},
+		"负数固定金额":  {model.FeeOperationTransfer: {"*": {Flat: decimal.MustParse("-1")}}},
+		"比例不小于1":  {model.FeeOperationTransfer: {"*": {Percent: decimal.MustParse("1")}}},
+		"最低高于最高":  {model.FeeOperationTransfer: {"*": {Min: decimalPtr("5"), Max: decimalPtr("1")}}},
+		"阶梯上限未升序": {model.FeeOperationTransfer: {"*": {Tiers: []model.FeeTier{{UpTo: decimalPtr("100")}, {UpTo: decimalPtr("50")}}}}},
+		"中间档无上限":  {model.FeeOperationTransfer: {"*": {Tiers: []model.FeeTier{{}, {UpTo: decimalPtr("50")}}}}},
+	}
+	for name, fees := range invalid {
+		if err := fees.Validate(); err == nil {
+			t.Errorf("%s：预期校验失败", name)
+		}
+	}
+}
+
+// 测试取款与转账按费率表向付款方另行扣收手续费，手续费以单独的凭证记入手续费收入账户
+func TestWalletService_Fees(t *testing.T) {
+	walletService, mockRepo := newHoldTestService(service.WithFeeSchedule(model.FeeSchedule{
+		model.FeeOperationWithdrawal: {"CNY": {Flat: decimal.MustParse("1"), Percent: decimal.MustParse("0.01")}},
+		model.FeeOperationTransfer:   {model.FeeAnyCurrency: {Flat: decimal.MustParse("2")}},
+	}))
+	ctx := context.Background()
+
+	quote, err := walletService.QuoteFee(ctx, model.FeeOperationWithdrawal, "CNY", decimal.MustParse("50"))
+	if err != nil || quote.Fee.String() != "1.50" || quote.Total.String() != "51.50" {
+		t.Fatalf("手续费试算预期为1.50、合计51.50，实际：%+v，%v", quote, err)
+	}
+	if len(mockRepo.transactions) != 0 || mockRepo.txCount != 0 {
+		t.Errorf("试算不应改变任何状态")
+	}
+
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("50")); err != nil {
+		t.Fatalf("取款时预期无错误，实际错误：%v", err)
+	}
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("40")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+	from, _ := walletService.GetBalance(ctx, 1, "CNY")
+	to, _ := walletService.GetBalance(ctx, 2, "CNY")
+	if from.Ledger.String() != "6.50" || to.Ledger.String() != "40.00" {
+		t.Errorf("预期付款方余额6.50、收款方40.00，实际：%s、%s", from.Ledger, to.Ledger)
+	}
+
+	types := make([]string, 0, len(mockRepo.transactions))
+	for _, tx := range mockRepo.transactions {
+		types = append(types, tx.TransactionType+":"+tx.Amount.String())
+	}
+	want := []string{"withdrawal:50.00", "fee:1.50", "transfer_out:40.00", "transfer_in:40.00", "fee:2.00"}
+	if len(types) != len(want) {
+		t.Fatalf("交易记录预期为%v，实际：%v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("交易记录预期为%v，实际：%v", want, types)
+			break
+		}
+	}
+	fee := mockRepo.journalEntries[1]
+	if fee.EntryType != "fee" || fee.Postings[1].Account != model.AccountSystemFees || fee.Postings[1].Direction != model.Credit || mockRepo.transactions[1].EntryID == mockRepo.transactions[0].EntryID {
+		t.Errorf("手续费预期以单独的凭证贷记%s，实际：%+v", model.AccountSystemFees, fee)
+	}
+
+	// 可用余额须同时覆盖本金与手续费，失败时不扣收手续费
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("6")); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("余额不足以支付手续费时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+	if len(mockRepo.transactions) != 5 {
+		t.Errorf("失败的取款不应写入交易，实际：%d条", len(mockRepo.transactions))
+	}
+
+	if quote, err := walletService.QuoteFee(ctx, model.FeeOperationWithdrawal, "USD", decimal.MustParse("50")); err != nil || !quote.Fee.IsZero() {
+		t.Errorf("没有适用规则的币种预期手续费为0，实际：%+v，%v", quote, err)
+	}
+	if _, err := walletService.QuoteFee(ctx, "deposit", "CNY", decimal.MustParse("50")); !errors.Is(err, service.ErrUnsupportedOperation) {
+		t.Errorf("不收费的操作预期返回ErrUnsupportedOperation，实际：%v", err)
+	}
+}
+
+// 测试预授权请款按取款或转账收取手续费，可用余额不足以支付手续费时请款失败
+func TestWalletService_CaptureFees(t *testing.T) {
+	walletService, mockRepo := newHoldTestService(service.WithFeeSchedule(model.FeeSchedule{
+		model.FeeOperationWithdrawal: {"CNY": {Flat: decimal.MustParse("1")}},
+		model.FeeOperationTransfer:   {model.FeeAnyCurrency: {Flat: decimal.MustParse("2")}},
+	}))
+	ctx := context.Background()
+
+	withdrawal, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("60"), 0, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, withdrawal.ID, decimal.MustParse("30")); err != nil {
+		t.Fatalf("请款时预期无错误，实际错误：%v", err)
+	}
+	transfer, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("20"), 2, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, transfer.ID, decimal.Zero); err != nil {
+		t.Fatalf("请款时预期无错误，实际错误：%v", err)
+	}
+	from, _ := walletService.GetBalance(ctx, 1, "CNY")
+	to, _ := walletService.GetBalance(ctx, 2, "CNY")
+	if from.Ledger.String() != "47.00" || to.Ledger.String() != "20.00" {
+		t.Errorf("预期付款方余额47.00、收款方20.00，实际：%s、%s", from.Ledger, to.Ledger)
+	}
+	types := make([]string, 0, len(mockRepo.transactions))
+	for _, tx := range mockRepo.transactions {
+		types = append(types, tx.TransactionType+":"+tx.Amount.String())
+	}
+	want := []string{"withdrawal:30.00", "fee:1.00", "transfer_out:20.00", "transfer_in:20.00", "fee:2.00"}
+	if len(types) != len(want) {
+		t.Fatalf("交易记录预期为%v，实际：%v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("交易记录预期为%v，实际：%v", want, types)
+			break
+		}
+	}
+
+	// 预授权只冻结本金，余额不足以同时支付手续费时请款失败
+	all, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("47"), 0, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, all.ID, decimal.Zero); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("余额不足以支付手续费时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+	if len(mockRepo.transactions) != 5 {
+		t.Errorf("失败的请款不应写入交易，实际：%d条", len(mockRepo.transactions))
+	}
+}
+
+// 测试跨币种转账按转出币种收取转账手续费，自身换汇不收取手续费
+func TestWalletService_ConversionFees(t *testing.T) {
+	walletService, mockRepo, transactions := newFXTestService(service.WithFeeSchedule(model.FeeSchedule{
+		model.FeeOperationTransfer: {"CNY": {Flat: decimal.MustParse("3")}},
+	}))
+	ctx := context.Background()
+
+	conversion, err := walletService.TransferWithConversion(ctx, 1, 2, "CNY", "USD", decimal.MustParse("100"), "")
+	if err != nil {
+		t.Fatalf("跨币种转账预期无错误，实际错误：%v", err)
+	}
+	if conversion.Fee == nil || conversion.Fee.String() != "3.00" || conversion.TargetAmount.String() != "13.75" {
+		t.Errorf("跨币种转账预期收取3.00手续费，实际：%+v", conversion)
+	}
+	want := []string{"transfer_out:100.00", "transfer_in:13.75", "fee:3.00"}
+	if len(*transactions) != len(want) {
+		t.Fatalf("交易记录预期为%v，实际：%+v", want, *transactions)
+	}
+	for i, tx := range *transactions {
+		if tx.TransactionType+":"+tx.Amount.String() != want[i] {
+			t.Errorf("交易记录预期为%v，实际：%+v", want, *transactions)
+			break
+		}
+	}
+	fee := mockRepo.journalEntries[len(mockRepo.journalEntries)-1]
+	if fee.EntryType != "fee" || fee.Postings[0].Account != "wallet:1:CNY" || fee.Postings[1].Account != model.AccountSystemFees {
+		t.Errorf("手续费预期借记转出方CNY钱包、贷记%s，实际：%+v", model.AccountSystemFees, fee)
+	}
+
+	if conversion, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("100"), ""); err != nil || conversion.Fee != nil {
+		t.Errorf("自身换汇不应收取手续费，实际：%+v，%v", conversion, err)
+	}
+	// 可用余额须同时覆盖本金与手续费
+	if _, err := walletService.TransferWithConversion(ctx, 1, 2, "CNY", "USD", decimal.MustParse("999"), ""); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("余额不足以支付手续费时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+}
+
+// 测试冲正与部分退款只退回本金，手续费不退还且fee交易不能单独冲正
+func TestWalletService_ReversalKeepsFees(t *testing.T) {
+	walletService, mockRepo := newHoldTestService(service.WithFeeSchedule(model.FeeSchedule{
+		model.FeeOperationWithdrawal: {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}},
+		model.FeeOperationTransfer:   {model.FeeAnyCurrency: {Flat: decimal.MustParse("2")}},
+	}))
+	ctx := context.Background()
+
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("50")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+	if refund, err := walletService.Refund(ctx, 1, decimal.MustParse("20"), "商品缺货"); err != nil || refund.Amount.String() != "20.00" {
+		t.Fatalf("部分退款预期退回20.00，实际：%+v，%v", refund, err)
+	}
+	if rest, err := walletService.Reverse(ctx, 1, "取消订单"); err != nil || rest.Amount.String() != "30.00" {
+		t.Fatalf("冲正剩余部分预期为30.00，实际：%+v，%v", rest, err)
+	}
+	payer, _ := walletService.GetBalance(ctx, 1, "CNY")
+	payee, _ := walletService.GetBalance(ctx, 2, "CNY")
+	if payer.Ledger.String() != "98.00" || payee.Ledger.String() != "0.00" {
+		t.Errorf("转账全额退款后付款方预期只差手续费98.00、收款方0.00，实际：%s、%s", payer.Ledger, payee.Ledger)
+	}
+
+	if fee := mockRepo.transactions[2]; fee.TransactionType != "fee" {
+		t.Fatalf("第3条交易预期为转账手续费，实际：%+v", fee)
+	}
+	if _, err := walletService.Reverse(ctx, 3, "退还手续费"); !errors.Is(err, service.ErrNotReversible) {
+		t.Errorf("冲正手续费交易预期返回ErrNotReversible，实际：%v", err)
+	}
+
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("10")); err != nil {
+		t.Fatalf("取款时预期无错误，实际错误：%v", err)
+	}
+	withdrawal := mockRepo.transactions[len(mockRepo.transactions)-2]
+	if reversal, err := walletService.Reverse(ctx, withdrawal.ID, "银行退票"); err != nil || reversal.Amount.String() != "10.00" {
+		t.Fatalf("冲正取款预期退回10.00，实际：%+v，%v", reversal, err)
+	}
+	if balance, _ := walletService.GetBalance(ctx, 1, "CNY"); balance.Ledger.String() != "97.00" {
+		t.Errorf("取款冲正后预期只扣除取款手续费，余额97.00，实际：%s", balance.Ledger)
+	}
+}
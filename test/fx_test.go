@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wallet-service/internal/fx"
+	"wallet-service/pkg/decimal"
+)
+
+// 测试固定汇率表的正向、反向与同币种查询
+func TestStaticProvider_GetRate(t *testing.T) {
+	provider := fx.NewStaticProvider(map[string]decimal.Decimal{"usd/cny": decimal.MustParse("7.2")})
+	ctx := context.Background()
+
+	cases := []struct {
+		from, to string
+		want     string
+	}{
+		{"USD", "CNY", "7.2"},
+		{"CNY", "USD", "0.13888889"},
+		{"EUR", "EUR", "1"},
+	}
+	for _, c := range cases {
+		rate, err := provider.GetRate(ctx, c.from, c.to)
+		if err != nil || rate.Rate.String() != c.want {
+			t.Errorf("%s/%s：预期汇率%s，实际：%v，%v", c.from, c.to, c.want, rate.Rate, err)
+		}
+	}
+	if _, err := provider.GetRate(ctx, "EUR", "USD"); !errors.Is(err, fx.ErrRateNotFound) {
+		t.Errorf("未配置的币种对预期返回ErrRateNotFound，实际：%v", err)
+	}
+}
+
+// 测试从JSON文件加载汇率表
+func TestLoadFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rates.json")
+	if err := os.WriteFile(path, []byte(`{"EUR/USD": "1.08"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := fx.LoadFileProvider(path)
+	if err != nil {
+		t.Fatalf("加载汇率文件预期无错误，实际：%v", err)
+	}
+	if rate, err := provider.GetRate(context.Background(), "EUR", "USD"); err != nil || rate.Rate.String() != "1.08" {
+		t.Errorf("预期EUR/USD为1.08，实际：%v，%v", rate.Rate, err)
+	}
+
+	for name, content := range map[string]string{
+		"bad_pair.json": `{"EURUSD": "1.08"}`,
+		"negative.json": `{"EUR/USD": "-1"}`,
+		"invalid.json":  `{"EUR/USD": 1.08`,
+	} {
+		bad := filepath.Join(dir, name)
+		os.WriteFile(bad, []byte(content), 0o600)
+		if _, err := fx.LoadFileProvider(bad); err == nil {
+			t.Errorf("%s：预期加载失败", name)
+		}
+	}
+}
+
+// 测试HTTP汇率源与本地替身服务的协议一致
+func TestHTTPProvider_StandIn(t *testing.T) {
+	standIn := fx.NewStandInHandler(fx.NewStaticProvider(map[string]decimal.Decimal{"GBP/USD": decimal.MustParse("1.27")}))
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	provider := fx.NewHTTPProvider(server.URL+"/", server.Client())
+	rate, err := provider.GetRate(context.Background(), "GBP", "USD")
+	if err != nil || rate.Rate.String() != "1.27" || rate.From != "GBP" || rate.To != "USD" {
+		t.Errorf("预期GBP/USD为1.27，实际：%+v，%v", rate, err)
+	}
+	if _, err := provider.GetRate(context.Background(), "GBP", "JPY"); !errors.Is(err, fx.ErrRateNotFound) {
+		t.Errorf("替身服务没有的币种对预期返回ErrRateNotFound，实际：%v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"wallet-service/internal/auth"
+	"wallet-service/internal/grpcapi"
+	"wallet-service/internal/grpcapi/walletpb"
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
+)
+
+// newGRPCClient 在内存连接上启动gRPC服务并返回客户端，测试结束时关闭
+func newGRPCClient(t *testing.T, walletService service.WalletService, opts ...grpcapi.Option) walletpb.WalletServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpcapi.NewServer(walletService, opts...).GRPCServer()
+	go server.Serve(listener)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("创建gRPC客户端时预期无错误，实际错误：%v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+// bearer 返回携带令牌的调用context
+func bearer(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// errorReason 返回gRPC错误的状态码与ErrorInfo中的业务错误码
+func errorReason(err error) (codes.Code, string) {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return st.Code(), info.Reason
+		}
+	}
+	return st.Code(), ""
+}
+
+// panickingHistoryService 查询交易历史时panic，用于测试流式调用的panic恢复
+type panickingHistoryService struct {
+	*MockWalletService
+}
+
+func (panickingHistoryService) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) (*model.TransactionPage, error) {
+	panic("history unavailable")
+}
+
+// 测试处理调用时的panic被转换为Internal错误，服务继续处理后续调用
+func TestGRPC_RecoverPanic(t *testing.T) {
+	calls := 0
+	walletService := &MockWalletService{
+		depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			calls++
+			if calls == 1 {
+				panic("deposit failed")
+			}
+			return &model.Wallet{UserID: userID, Currency: currency, Balance: amount}, nil
+		},
+	}
+	client := newGRPCClient(t, panickingHistoryService{walletService})
+	ctx := context.Background()
+
+	_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "10"})
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal server error" {
+		t.Errorf("panic应转换为不暴露细节的Internal错误，实际：%v", err)
+	}
+	if _, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "10"}); err != nil {
+		t.Errorf("panic后服务应继续处理调用，实际错误：%v", err)
+	}
+
+	stream, err := client.StreamHistory(ctx, &walletpb.StreamHistoryRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("发起流式调用时预期无错误，实际错误：%v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Internal {
+		t.Errorf("流式调用中的panic应转换为Internal错误，实际：%v", err)
+	}
+}
+
+// 测试存款、取款、转账与查询余额调用服务层并返回结果
+func TestGRPC_WalletOperations(t *testing.T) {
+	var deposited decimal.Decimal
+	walletService := &MockWalletService{
+		depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			deposited = amount
+			return &model.Wallet{UserID: userID, Currency: currency, Balance: decimal.MustParse("110.00"), Status: model.WalletActive}, nil
+		},
+	}
+	client := newGRPCClient(t, walletService, grpcapi.WithDefaultCurrency("CNY"))
+	ctx := context.Background()
+
+	var header metadata.MD
+	wallet, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "10.00"}, grpc.Header(&header))
+	if err != nil || wallet.GetUserId() != 1 || wallet.GetCurrency() != "CNY" || wallet.GetBalance() != "110.00" || wallet.GetStatus() != "active" {
+		t.Fatalf("存款后预期返回钱包，实际：%v，%v", wallet, err)
+	}
+	if deposited.String() != "10.00" {
+		t.Errorf("预期存入10.00，实际：%s", deposited)
+	}
+	if len(header.Get(grpcapi.RequestIDMetadata)) != 1 {
+		t.Errorf("响应头预期携带请求ID，实际：%v", header)
+	}
+
+	if _, err := client.Withdraw(ctx, &walletpb.WithdrawRequest{UserId: 1, Currency: "usd", Amount: "5"}); err != nil {
+		t.Errorf("取款时预期无错误，实际错误：%v", err)
+	}
+	transfer, err := client.Transfer(ctx, &walletpb.TransferRequest{FromUserId: 1, ToUserId: 2, Currency: "USD", Amount: "5"})
+	if err != nil || transfer.GetAmount() != "5.00" || transfer.GetToUserId() != 2 {
+		t.Errorf("转账结果不正确：%v，%v", transfer, err)
+	}
+	balance, err := client.GetBalance(ctx, &walletpb.GetBalanceRequest{UserId: 1})
+	if err != nil || balance.GetLedger() != "100.00" || balance.GetHeld() != "40.00" || balance.GetAvailable() != "60.00" {
+		t.Errorf("余额结果不正确：%v，%v", balance, err)
+	}
+}
+
+// 测试参数校验与业务错误映射为gRPC状态码，ErrorInfo携带与HTTP接口相同的错误码
+func TestGRPC_ErrorMapping(t *testing.T) {
+	walletService := &MockWalletService{
+		withdrawFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			return nil, fmt.Errorf("%w: balance 1.00", service.ErrInsufficientFunds)
+		},
+		depositFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) (*model.Wallet, error) {
+			return nil, fmt.Errorf("%w: user %d", service.ErrWalletNotFound, userID)
+		},
+		transferFunc: func(ctx context.Context, fromUserID, toUserID int, currency string, amount decimal.Decimal) error {
+			return errors.New("connection reset")
+		},
+	}
+	client := newGRPCClient(t, walletService)
+	ctx := context.Background()
+
+	cases := []struct {
+		name   string
+		call   func() error
+		code   codes.Code
+		reason string
+	}{
+		{"余额不足", func() error {
+			_, err := client.Withdraw(ctx, &walletpb.WithdrawRequest{UserId: 1, Amount: "10"})
+			return err
+		}, codes.FailedPrecondition, "insufficient_funds"},
+		{"钱包不存在", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "10"})
+			return err
+		}, codes.NotFound, "wallet_not_found"},
+		{"金额不合法", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "abc"})
+			return err
+		}, codes.InvalidArgument, "validation_error"},
+		{"金额精度超出币种", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "1.001"})
+			return err
+		}, codes.InvalidArgument, "validation_error"},
+		{"不支持的币种", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Currency: "XXX", Amount: "1"})
+			return err
+		}, codes.InvalidArgument, "unsupported_currency"},
+		{"金额超出范围", func() error {
+			_, err := client.Deposit(ctx, &walletpb.DepositRequest{UserId: 1, Amount: "99999999999999999"})
+			return err
+		}, codes.InvalidArgument, "validation_error"},
+		{"钱包ID不合法", func() error {
+			_, err := client.GetBalance(ctx, &walletpb.GetBalanceRequest{UserId: 0})
+			return err
+		}, codes.InvalidArgument, "validation_error"},
+		{"未知错误", func() error {
+			_, err := client.Transfer(ctx, &walletpb.TransferRequest{FromUserId: 1, ToUserId: 2, Amount: "1"})
+			return err
+		}, codes.Internal, ""},
+	}
+	for _, c := range cases {
+		code, reason := errorReason(c.call())
+		if code != c.code || reason != c.reason {
+			t.Errorf("%s：预期%v %q，实际：%v %q", c.name, c.code, c.reason, code, reason)
+		}
+	}
+
+	_, err := client.Transfer(ctx, &walletpb.TransferRequest{FromUserId: 1, ToUserId: 2, Amount: "1"})
+	if status.Convert(err).Message() != "internal server error" {
+		t.Errorf("未知错误不应向客户端暴露细节，实际：%v", err)
+	}
+}
+
+// 测试启用认证时校验Bearer令牌，并按权限范围与钱包归属授权
+func TestGRPC_Authentication(t *testing.T) {
+	client := newGRPCClient(t, &MockWalletService{}, grpcapi.WithAuthenticator(newTestVerifier(t)))
+	expiresAt := time.Now().Add(time.Hour)
+	user1 := bearer(signToken(t, "1", "", expiresAt))
+	gateway := bearer(signToken(t, "gateway", auth.ScopeDeposit, expiresAt))
+
+	if _, err := client.GetBalance(context.Background(), &walletpb.GetBalanceRequest{UserId: 1}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("未携带令牌预期Unauthenticated，实际：%v", err)
+	}
+	if _, err := client.GetBalance(bearer("not-a-token"), &walletpb.GetBalanceRequest{UserId: 1}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("令牌不合法预期Unauthenticated，实际：%v", err)
+	}
+	if _, err := client.GetBalance(user1, &walletpb.GetBalanceRequest{UserId: 1}); err != nil {
+		t.Errorf("终端用户查询自己的余额预期成功，实际：%v", err)
+	}
+	if code, reason := errorReason(func() error {
+		_, err := client.GetBalance(user1, &walletpb.GetBalanceRequest{UserId: 2})
+		return err
+	}()); code != codes.PermissionDenied || reason != "forbidden" {
+		t.Errorf("终端用户查询他人余额预期PermissionDenied，实际：%v %q", code, reason)
+	}
+	if _, err := client.Deposit(user1, &walletpb.DepositRequest{UserId: 1, Amount: "1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("终端用户不能存款，预期PermissionDenied，实际：%v", err)
+	}
+	if _, err := client.Deposit(gateway, &walletpb.DepositRequest{UserId: 5, Amount: "1"}); err != nil {
+		t.Errorf("拥有存款权限的服务账号存款预期成功，实际：%v", err)
+	}
+
+	stream, err := client.StreamHistory(user1, &walletpb.StreamHistoryRequest{UserId: 2})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("终端用户推送他人的交易历史预期PermissionDenied，实际：%v", err)
+	}
+}
+
+// 测试交易历史按页读取后逐条推送，limit限制推送条数，cursor用于续传
+func TestGRPC_StreamHistory(t *testing.T) {
+	walletService := &MockWalletService{}
+	client := newGRPCClient(t, walletService)
+	ctx := context.Background()
+
+	receive := func(req *walletpb.StreamHistoryRequest) ([]*walletpb.Transaction, error) {
+		stream, err := client.StreamHistory(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		var txs []*walletpb.Transaction
+		for {
+			tx, err := stream.Recv()
+			if err == io.EOF {
+				return txs, nil
+			}
+			if err != nil {
+				return txs, err
+			}
+			txs = append(txs, tx)
+		}
+	}
+
+	txs, err := receive(&walletpb.StreamHistoryRequest{UserId: 1, Currency: "USD", Types: []string{"refund_in"}, MinAmount: "1"})
+	if err != nil || len(txs) != 2 {
+		t.Fatalf("预期跨两页推送2条交易，实际：%v，%v", txs, err)
+	}
+	if txs[0].GetId() != 7 || txs[0].GetAmount() != "5.00" || txs[0].GetReversalOf() != 3 || txs[0].GetCursor() == "" {
+		t.Errorf("推送的交易不正确：%v", txs[0])
+	}
+	filter := walletService.lastHistoryFilter
+	if filter.Cursor == nil || len(filter.Types) != 1 || filter.MinAmount == nil || filter.Limit != 100 {
+		t.Errorf("第二页应带上一页的游标与相同的过滤条件，实际：%+v", filter)
+	}
+
+	txs, err = receive(&walletpb.StreamHistoryRequest{UserId: 1, Limit: 1})
+	if err != nil || len(txs) != 1 || walletService.lastHistoryFilter.Limit != 1 {
+		t.Errorf("limit为1时预期只推送1条，实际：%v，%v", txs, err)
+	}
+
+	cursor := model.HistoryCursor{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 9}
+	if _, err := receive(&walletpb.StreamHistoryRequest{UserId: 1, Cursor: cursor.Encode()}); err != nil {
+		t.Fatalf("从游标续传时预期无错误，实际错误：%v", err)
+	}
+	if got := walletService.lastHistoryFilter.Cursor; got == nil || got.ID != 9 {
+		t.Errorf("预期从请求的游标开始查询，实际：%+v", got)
+	}
+	if _, err := receive(&walletpb.StreamHistoryRequest{UserId: 1, Cursor: "!!"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("游标不合法预期InvalidArgument，实际：%v", err)
+	}
+}
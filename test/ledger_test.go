@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"wallet-service/internal/model"
+	"wallet-service/pkg/decimal"
+)
+
+// 测试记账凭证的借贷平衡校验
+func TestJournalEntry_Validate(t *testing.T) {
+	ten := decimal.MustParse("10.00")
+	cases := []struct {
+		name     string
+		postings []model.Posting
+		valid    bool
+	}{
+		{"一借一贷", []model.Posting{
+			{Account: "system:cash", Currency: "CNY", Direction: model.Debit, Amount: ten},
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Credit, Amount: ten},
+		}, true},
+		{"一借多贷", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: ten},
+			{Account: "wallet:2:CNY", Currency: "CNY", Direction: model.Credit, Amount: decimal.MustParse("9.50")},
+			{Account: "system:fees", Currency: "CNY", Direction: model.Credit, Amount: decimal.MustParse("0.50")},
+		}, true},
+		{"借贷不等", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: ten},
+			{Account: "wallet:2:CNY", Currency: "CNY", Direction: model.Credit, Amount: decimal.MustParse("9.99")},
+		}, false},
+		{"只有借方", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: ten},
+		}, false},
+		{"金额为零", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: decimal.Zero},
+			{Account: "wallet:2:CNY", Currency: "CNY", Direction: model.Credit, Amount: decimal.Zero},
+		}, false},
+		{"两个币种各自平衡", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: ten},
+			{Account: "system:fx", Currency: "CNY", Direction: model.Credit, Amount: ten},
+			{Account: "system:fx", Currency: "USD", Direction: model.Debit, Amount: decimal.MustParse("1.40")},
+			{Account: "wallet:2:USD", Currency: "USD", Direction: model.Credit, Amount: decimal.MustParse("1.40")},
+		}, true},
+		{"跨币种借贷", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Debit, Amount: ten},
+			{Account: "wallet:2:USD", Currency: "USD", Direction: model.Credit, Amount: ten},
+		}, false},
+		{"缺少币种", []model.Posting{
+			{Account: "wallet:1:CNY", Direction: model.Debit, Amount: ten},
+			{Account: "wallet:2:CNY", Direction: model.Credit, Amount: ten},
+		}, false},
+		{"方向非法", []model.Posting{
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: "sideways", Amount: ten},
+			{Account: "wallet:2:CNY", Currency: "CNY", Direction: model.Credit, Amount: ten},
+		}, false},
+	}
+	for _, c := range cases {
+		err := model.JournalEntry{EntryType: "test", Postings: c.postings}.Validate()
+		if c.valid && err != nil {
+			t.Errorf("%s：预期校验通过，实际：%v", c.name, err)
+		}
+		if !c.valid && !errors.Is(err, model.ErrUnbalancedEntry) {
+			t.Errorf("%s：预期返回ErrUnbalancedEntry，实际：%v", c.name, err)
+		}
+	}
+}
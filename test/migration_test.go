@@ -0,0 +1,161 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"wallet-service/internal/migration"
+)
+
+// 测试内嵌迁移：版本从1开始连续，初始迁移包含约束
+func TestMigration_Embedded(t *testing.T) {
+	migrations, err := migration.Embedded()
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("预期加载内嵌迁移，实际：%v，%v", migrations, err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 || m.Up == "" || m.Down == "" {
+			t.Errorf("迁移版本应从1开始连续且包含up、down脚本，实际：%d_%s", m.Version, m.Name)
+		}
+	}
+	for _, constraint := range []string{"CHECK (balance >= 0)", "FOREIGN KEY (user_id, currency) REFERENCES wallets (user_id, currency)"} {
+		if !strings.Contains(migrations[0].Up, constraint) {
+			t.Errorf("初始迁移缺少约束：%s", constraint)
+		}
+	}
+}
+
+// 测试迁移文件的加载与校验
+func TestMigration_Load(t *testing.T) {
+	migrations, err := migration.Load(fstest.MapFS{
+		"0002_add_index.up.sql":      {Data: []byte("CREATE INDEX a ON t (b);")},
+		"0002_add_index.down.sql":    {Data: []byte("DROP INDEX a;")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (b INT);")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	})
+	if err != nil || len(migrations) != 2 || migrations[0].Name != "create_table" || migrations[1].Version != 2 {
+		t.Fatalf("预期按版本排序加载2个迁移，实际：%+v，%v", migrations, err)
+	}
+
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"缺少down脚本", fstest.MapFS{"0001_a.up.sql": {Data: []byte("SELECT 1;")}}},
+		{"文件名不合法", fstest.MapFS{"init.sql": {Data: []byte("SELECT 1;")}}},
+		{"同版本名称冲突", fstest.MapFS{"0001_a.up.sql": {Data: []byte("SELECT 1;")}, "0001_b.down.sql": {Data: []byte("SELECT 1;")}}},
+	}
+	for _, c := range cases {
+		if _, err := migration.Load(c.files); err == nil {
+			t.Errorf("%s：预期返回错误", c.name)
+		}
+	}
+}
+
+var testMigrations = []migration.Migration{
+	{Version: 1, Name: "create_table", Up: "CREATE TABLE t (b INT);", Down: "DROP TABLE t;"},
+	{Version: 2, Name: "add_index", Up: "CREATE INDEX a ON t (b);", Down: "DROP INDEX a;"},
+}
+
+// expectLockAndVersions 预期获取咨询锁、创建版本表并读取已执行的版本
+func expectLockAndVersions(mock sqlmock.Sqlmock, versions ...int) {
+	mock.ExpectExec("SELECT pg_advisory_lock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, version := range versions {
+		rows.AddRow(version, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations ORDER BY version").WillReturnRows(rows)
+}
+
+// 测试Up：持锁执行未执行的迁移，每个迁移与版本记录在同一事务中
+func TestMigrator_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	expectLockAndVersions(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE INDEX a ON t \\(b\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations \\(version, name, applied_at\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs(2, "add_index", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migration.NewMigrator(db, testMigrations).Up(context.Background())
+	if err != nil || len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("预期只执行版本2，实际：%+v，%v", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试迁移失败时回滚事务并释放锁
+func TestMigrator_UpFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	expectLockAndVersions(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE t").WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migration.NewMigrator(db, testMigrations).Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "migration 1_create_table up") || len(applied) != 0 {
+		t.Errorf("迁移失败时预期返回错误，实际：%+v，%v", applied, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试Down按版本倒序回滚，数据库中有未知版本时拒绝迁移
+func TestMigrator_DownAndUnknownVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+	migrator := migration.NewMigrator(db, testMigrations)
+
+	expectLockAndVersions(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP INDEX a;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	reverted, err := migrator.Down(context.Background(), 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("预期回滚版本2，实际：%+v，%v", reverted, err)
+	}
+
+	expectLockAndVersions(mock, 1, 3)
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := migrator.Up(context.Background()); !errors.Is(err, migration.ErrUnknownVersion) {
+		t.Errorf("存在未知版本时预期返回ErrUnknownVersion，实际：%v", err)
+	}
+
+	expectLockAndVersions(mock, 1)
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	statuses, err := migrator.Status(context.Background())
+	if err != nil || len(statuses) != 2 || statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("状态预期版本1已执行、版本2未执行，实际：%+v，%v", statuses, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/outbox"
+)
+
+// newOutboxEvents 返回写入了n个未发布事件的模拟仓库
+func newOutboxEvents(n int) *MockWalletRepository {
+	mockRepo := &MockWalletRepository{}
+	for i := 0; i < n; i++ {
+		_ = mockRepo.InsertOutboxEvent(context.Background(), model.Event{
+			Type:       model.EventFundsDeposited,
+			UserID:     i + 1,
+			Currency:   "CNY",
+			Payload:    json.RawMessage(`{}`),
+			OccurredAt: time.Now(),
+		})
+	}
+	return mockRepo
+}
+
+// 测试转发进程按ID顺序发布，发布失败时记录原因并在下一轮从失败的事件重发
+func TestRelay_RelayOnce(t *testing.T) {
+	mockRepo := newOutboxEvents(3)
+	publisher := outbox.NewMemoryPublisher()
+	relay := outbox.NewRelay(mockRepo, publisher)
+	ctx := context.Background()
+
+	publisher.FailNext(1)
+	published, err := relay.RelayOnce(ctx)
+	if err == nil || published != 0 || len(publisher.Events()) != 0 {
+		t.Fatalf("首个事件发布失败时预期结束本轮，实际：%d，%v", published, err)
+	}
+	if first := mockRepo.events[0]; first.Attempts != 1 || !strings.Contains(first.LastError, "simulated failure") || first.PublishedAt != nil {
+		t.Errorf("发布失败应记录失败次数与原因，实际：%+v", first)
+	}
+
+	published, err = relay.RelayOnce(ctx)
+	if err != nil || published != 3 {
+		t.Fatalf("重试时预期发布全部3个事件，实际：%d，%v", published, err)
+	}
+	for i, event := range publisher.Events() {
+		if event.ID != i+1 {
+			t.Errorf("预期按ID顺序发布，第%d个实际为事件%d", i+1, event.ID)
+		}
+		if mockRepo.events[i].PublishedAt == nil {
+			t.Errorf("事件%d发布后应标记为已发布", event.ID)
+		}
+	}
+	if published, err := relay.RelayOnce(ctx); err != nil || published != 0 || len(publisher.Events()) != 3 {
+		t.Errorf("已发布的事件不应重复发布，实际：%d，%v", published, err)
+	}
+}
+
+// 测试WriterPublisher把每个事件写为一行JSON
+func TestWriterPublisher_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := outbox.NewWriterPublisher(&buf)
+	occurredAt := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for id := 1; id <= 2; id++ {
+		event := model.Event{ID: id, Type: model.EventFundsWithdrawn, UserID: 7, Currency: "USD", Actor: "user:7",
+			Payload: json.RawMessage(`{"amount":"5.00"}`), OccurredAt: occurredAt, Attempts: 2}
+		if err := publisher.Publish(context.Background(), event); err != nil {
+			t.Fatalf("发布事件时预期无错误，实际错误：%v", err)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := `{"id":2,"type":"FundsWithdrawn","user_id":7,"currency":"USD","actor":"user:7","payload":{"amount":"5.00"},"occurred_at":"2024-05-01T08:00:00Z"}`
+	if len(lines) != 2 || lines[1] != want {
+		t.Errorf("预期每个事件一行JSON，实际：%q", buf.String())
+	}
+}
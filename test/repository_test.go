@@ -2,11 +2,16 @@ package unit
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"testing"
 	"time"
 	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
 	"wallet-service/internal/repository/postgres"
+	"wallet-service/pkg/decimal"
 )
 
 // 测试获取钱包功能
@@ -21,17 +26,18 @@ func TestPostgresRepository_GetWallet(t *testing.T) {
 	repo := postgres.NewPostgresRepository(db)
 
 	// 模拟查询钱包成功的情况
-	rows := sqlmock.NewRows([]string{"user_id", "balance", "last_updated"}).
-		AddRow(1, 100.00, time.Now())
-	mock.ExpectQuery("SELECT user_id, balance, last_updated FROM wallets WHERE user_id = \\$1").
-		WithArgs(1).WillReturnRows(rows)
+	// 数据库按3位小数存储，读出后应调整为币种的标准小数位数
+	rows := sqlmock.NewRows([]string{"user_id", "currency", "balance", "status", "last_updated", "owner_metadata"}).
+		AddRow(1, "CNY", "100.000", "frozen", time.Now(), []byte(`{"name":"张三"}`))
+	mock.ExpectQuery("SELECT user_id, currency, balance, status, last_updated, owner_metadata FROM wallets WHERE user_id = \\$1 AND currency = \\$2").
+		WithArgs(1, "CNY").WillReturnRows(rows)
 
-	wallet, err := repo.GetWallet(context.Background(), 1)
+	wallet, err := repo.GetWallet(context.Background(), 1, "CNY")
 	if err != nil {
-		t.Errorf("获取钱包时预期无错误，实际错误：%v", err)
+		t.Fatalf("获取钱包时预期无错误，实际错误：%v", err)
 	}
-	if wallet.UserID != 1 || wallet.Balance != 100.00 {
-		t.Errorf("预期钱包用户ID为1，余额为100.00，实际：%+v", wallet)
+	if wallet.UserID != 1 || wallet.Currency != "CNY" || wallet.Balance.String() != "100.00" || wallet.Status != model.WalletFrozen || wallet.OwnerMetadata["name"] != "张三" {
+		t.Errorf("预期钱包用户ID为1，余额为100.00 CNY，状态为frozen，持有人为张三，实际：%+v", wallet)
 	}
 
 	// 验证所有期望的操作都被执行
@@ -40,6 +46,29 @@ func TestPostgresRepository_GetWallet(t *testing.T) {
 	}
 }
 
+// 测试钱包不存在时返回ErrWalletNotFound
+func TestPostgresRepository_GetWalletNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	mock.ExpectQuery("SELECT user_id, currency, balance, status, last_updated, owner_metadata FROM wallets WHERE user_id = \\$1 AND currency = \\$2").
+		WithArgs(2, "USD").WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency", "balance", "status", "last_updated", "owner_metadata"}))
+
+	wallet, err := repo.GetWallet(context.Background(), 2, "USD")
+	if !errors.Is(err, _interface.ErrWalletNotFound) || wallet != nil {
+		t.Errorf("钱包不存在时预期返回ErrWalletNotFound，实际：%v，%+v", err, wallet)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
 // 测试更新钱包余额功能
 func TestPostgresRepository_UpdateWalletBalance(t *testing.T) {
 	// 创建模拟数据库连接和对象
@@ -52,10 +81,10 @@ func TestPostgresRepository_UpdateWalletBalance(t *testing.T) {
 	repo := postgres.NewPostgresRepository(db)
 
 	// 模拟更新钱包余额成功的情况
-	mock.ExpectExec("UPDATE wallets SET balance = balance + \\$1, last_updated = \\$2 WHERE user_id = \\$3").
-		WithArgs(50.00, time.Now(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE wallets SET balance = balance \\+ \\$1, last_updated = \\$2 WHERE user_id = \\$3 AND currency = \\$4").
+		WithArgs(decimal.MustParse("50.00"), sqlmock.AnyArg(), 1, "CNY").WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = repo.UpdateWalletBalance(context.Background(), 1, 50.00)
+	err = repo.UpdateWalletBalance(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err != nil {
 		t.Errorf("更新钱包余额时预期无错误，实际错误：%v", err)
 	}
@@ -66,6 +95,32 @@ func TestPostgresRepository_UpdateWalletBalance(t *testing.T) {
 	}
 }
 
+// 测试更新钱包状态，钱包不存在时返回ErrWalletNotFound
+func TestPostgresRepository_UpdateWalletStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	query := "UPDATE wallets SET status = \\$1, last_updated = \\$2 WHERE user_id = \\$3 AND currency = \\$4"
+	mock.ExpectExec(query).WithArgs("frozen", sqlmock.AnyArg(), 1, "CNY").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("active", sqlmock.AnyArg(), 2, "CNY").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.UpdateWalletStatus(context.Background(), 1, "CNY", model.WalletFrozen); err != nil {
+		t.Errorf("更新钱包状态时预期无错误，实际错误：%v", err)
+	}
+	if err := repo.UpdateWalletStatus(context.Background(), 2, "CNY", model.WalletActive); !errors.Is(err, _interface.ErrWalletNotFound) {
+		t.Errorf("钱包不存在时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
 // 测试插入交易记录功能
 func TestPostgresRepository_InsertTransaction(t *testing.T) {
 	// 创建模拟数据库连接和对象
@@ -78,14 +133,19 @@ func TestPostgresRepository_InsertTransaction(t *testing.T) {
 	repo := postgres.NewPostgresRepository(db)
 
 	// 模拟插入交易记录成功的情况
-	mock.ExpectExec("INSERT INTO transactions \\(user_id, transaction_type, amount, transaction_time\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\)").
-		WithArgs(1, "deposit", 100.00, time.Now()).WillReturnResult(sqlmock.NewResult(0, 1))
+	now := time.Now()
+	mock.ExpectExec("INSERT INTO transactions \\(user_id, currency, transaction_type, amount, transaction_time, entry_id, reversal_of, reason, actor, prev_hash, hash\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7, \\$8, \\$9, \\$10, \\$11\\)").
+		WithArgs(1, "USD", "deposit", decimal.MustParse("100.00"), now, 7, nil, "", "user:1", "", "h1").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	transaction := model.Transaction{
 		UserID:          1,
+		Currency:        "USD",
 		TransactionType: "deposit",
-		Amount:          100.00,
-		TransactionTime: time.Now(),
+		Amount:          decimal.MustParse("100.00"),
+		TransactionTime: now,
+		EntryID:         7,
+		Actor:           "user:1",
+		Hash:            "h1",
 	}
 	err = repo.InsertTransaction(context.Background(), transaction)
 	if err != nil {
@@ -110,18 +170,21 @@ func TestPostgresRepository_GetTransactionHistory(t *testing.T) {
 	repo := postgres.NewPostgresRepository(db)
 
 	// 模拟查询交易历史成功的情况
-	rows := sqlmock.NewRows([]string{"id", "user_id", "transaction_type", "amount", "transaction_time"}).
-		AddRow(1, 1, "deposit", 100.00, time.Now()).
-		AddRow(2, 1, "withdrawal", 50.00, time.Now())
-	mock.ExpectQuery("SELECT id, user_id, transaction_type, amount, transaction_time FROM transactions WHERE user_id = \\$1 ORDER BY transaction_time DESC").
-		WithArgs(1).WillReturnRows(rows)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "currency", "transaction_type", "amount", "transaction_time", "entry_id", "reversal_of", "reason", "actor", "prev_hash", "hash"}).
+		AddRow(1, 1, "JPY", "deposit", "1500.000", time.Now(), 1, 0, "", "key:gateway", "", "h1").
+		AddRow(2, 1, "JPY", "deposit_reversal", "500.000", time.Now(), 2, 1, "重复入账", "service:finance-tool", "h1", "h2")
+	mock.ExpectQuery("SELECT id, user_id, currency, transaction_type, amount, transaction_time, COALESCE\\(entry_id, 0\\), COALESCE\\(reversal_of, 0\\), reason, actor, prev_hash, hash FROM transactions WHERE user_id = \\$1 AND currency = \\$2 ORDER BY transaction_time DESC, id DESC$").
+		WithArgs(1, "JPY").WillReturnRows(rows)
 
-	history, err := repo.GetTransactionHistory(context.Background(), 1)
+	history, err := repo.GetTransactionHistory(context.Background(), 1, "JPY", model.HistoryFilter{})
 	if err != nil {
 		t.Errorf("获取交易历史时预期无错误，实际错误：%v", err)
 	}
-	if len(history) != 2 {
-		t.Errorf("预期交易历史有2条记录，实际：%d", len(history))
+	if len(history) != 2 || history[0].Currency != "JPY" || history[0].Amount.String() != "1500" {
+		t.Errorf("预期交易历史有2条JPY记录且金额无小数，实际：%+v", history)
+	}
+	if history[1].ReversalOf != 1 || history[1].Reason != "重复入账" || history[1].Actor != "service:finance-tool" {
+		t.Errorf("预期冲正记录指向原交易1并记录发起方，实际：%+v", history[1])
 	}
 
 	// 验证所有期望的操作都被执行
@@ -129,3 +192,505 @@ func TestPostgresRepository_GetTransactionHistory(t *testing.T) {
 		t.Errorf("未满足的期望：%v", err)
 	}
 }
+
+// 测试事务内加锁读取钱包并提交
+func TestPostgresRepository_WithTxCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	rows := sqlmock.NewRows([]string{"user_id", "currency", "balance", "status", "last_updated", "owner_metadata"}).
+		AddRow(1, "CNY", 100.00, "active", time.Now(), []byte(`{}`))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id, currency, balance, status, last_updated, owner_metadata FROM wallets WHERE user_id = \\$1 AND currency = \\$2 FOR UPDATE").
+		WithArgs(1, "CNY").WillReturnRows(rows)
+	mock.ExpectExec("UPDATE wallets SET balance = balance \\+ \\$1").
+		WithArgs(decimal.MustParse("-50.00"), sqlmock.AnyArg(), 1, "CNY").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithTx(context.Background(), func(txRepo _interface.WalletRepository) error {
+		wallet, err := txRepo.GetWalletForUpdate(context.Background(), 1, "CNY")
+		if err != nil {
+			return err
+		}
+		if !wallet.Balance.Equal(decimal.MustParse("100.00")) {
+			t.Errorf("预期余额为100.00，实际：%v", wallet.Balance)
+		}
+		// 嵌套调用应复用当前事务而不是开启新事务
+		return txRepo.WithTx(context.Background(), func(nested _interface.WalletRepository) error {
+			return nested.UpdateWalletBalance(context.Background(), 1, "CNY", decimal.MustParse("-50.00"))
+		})
+	})
+	if err != nil {
+		t.Errorf("事务执行时预期无错误，实际错误：%v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试事务内出错时回滚
+func TestPostgresRepository_WithTxRollback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE wallets SET balance = balance \\+ \\$1").
+		WithArgs(decimal.MustParse("-50.00"), sqlmock.AnyArg(), 1, "CNY").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO transactions").
+		WillReturnError(errors.New("模拟插入交易记录失败"))
+	mock.ExpectRollback()
+
+	err = repo.WithTx(context.Background(), func(txRepo _interface.WalletRepository) error {
+		if err := txRepo.UpdateWalletBalance(context.Background(), 1, "CNY", decimal.MustParse("-50.00")); err != nil {
+			return err
+		}
+		return txRepo.InsertTransaction(context.Background(), model.Transaction{UserID: 1, Currency: "CNY", TransactionType: "withdrawal", Amount: decimal.MustParse("50.00")})
+	})
+	if err == nil {
+		t.Errorf("事务内出错时预期返回错误，实际无错误")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试写入记账凭证及分录
+func TestPostgresRepository_InsertJournalEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO journal_entries \\(entry_type, description, created_at, fx_quote_id, fx_rate, fx_spread\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\) RETURNING id").
+		WithArgs("deposit", "deposit to user 1", now, nil, nil, nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec("INSERT INTO postings \\(entry_id, account, currency, direction, amount\\)").
+		WithArgs(9, "system:cash", "CNY", "debit", decimal.MustParse("10.00")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO postings \\(entry_id, account, currency, direction, amount\\)").
+		WithArgs(9, "wallet:1:CNY", "CNY", "credit", decimal.MustParse("10.00")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	entryID, err := repo.InsertJournalEntry(context.Background(), model.JournalEntry{
+		EntryType:   "deposit",
+		Description: "deposit to user 1",
+		CreatedAt:   now,
+		Postings: []model.Posting{
+			{Account: "system:cash", Currency: "CNY", Direction: model.Debit, Amount: decimal.MustParse("10.00")},
+			{Account: "wallet:1:CNY", Currency: "CNY", Direction: model.Credit, Amount: decimal.MustParse("10.00")},
+		},
+	})
+	if err != nil || entryID != 9 {
+		t.Errorf("写入凭证时预期返回ID 9，实际：%d，%v", entryID, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试汇总未过期预授权与到期预授权的批量过期
+func TestPostgresRepository_Holds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM holds\\s+WHERE user_id = \\$1 AND currency = \\$2 AND status = 'authorized' AND expires_at > \\$3").
+		WithArgs(1, "JPY", now).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("1500.000"))
+	mock.ExpectExec("UPDATE holds SET status = 'expired', updated_at = \\$1 WHERE status = 'authorized' AND expires_at <= \\$1").
+		WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	held, err := repo.SumActiveHolds(context.Background(), 1, "JPY", now)
+	if err != nil || held.String() != "1500" {
+		t.Errorf("预期冻结合计为1500 JPY，实际：%v，%v", held, err)
+	}
+	expired, err := repo.ExpireHolds(context.Background(), now)
+	if err != nil || expired != 3 {
+		t.Errorf("预期过期3个预授权，实际：%d，%v", expired, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试交易历史的过滤条件与游标被转换为参数化查询
+func TestPostgresRepository_GetTransactionHistoryFiltered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cursor := &model.HistoryCursor{Time: from.Add(time.Hour), ID: 42}
+	min := decimal.MustParse("10")
+	mock.ExpectQuery("FROM transactions WHERE user_id = \\$1 AND currency = \\$2 AND transaction_type = ANY\\(\\$3\\) AND amount >= \\$4 AND transaction_time >= \\$5 "+
+		"AND \\(transaction_time, id\\) < \\(\\$6, \\$7\\) ORDER BY transaction_time DESC, id DESC LIMIT \\$8").
+		WithArgs(1, "CNY", "{\"deposit\",\"refund_in\"}", min, from, cursor.Time, 42, 21).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "currency", "transaction_type", "amount", "transaction_time", "entry_id", "reversal_of", "reason", "actor", "prev_hash", "hash"}).
+			AddRow(41, 1, "CNY", "deposit", "12.000", from, 5, 0, "", "system", "", ""))
+
+	history, err := repo.GetTransactionHistory(context.Background(), 1, "CNY", model.HistoryFilter{
+		Types:     []string{"deposit", "refund_in"},
+		MinAmount: &min,
+		From:      &from,
+		Cursor:    cursor,
+		Limit:     21,
+	})
+	if err != nil || len(history) != 1 || history[0].ID != 41 || history[0].Amount.String() != "12.00" {
+		t.Errorf("预期返回1条过滤后的记录，实际：%+v，%v", history, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试对账单使用的正序查询与期初余额查询
+func TestPostgresRepository_StatementQueries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	cursor := &model.HistoryCursor{Time: from.Add(time.Hour), ID: 42}
+	mock.ExpectQuery("FROM transactions WHERE user_id = \\$1 AND currency = \\$2 AND transaction_time >= \\$3 AND transaction_time < \\$4 "+
+		"AND \\(transaction_time, id\\) > \\(\\$5, \\$6\\) ORDER BY transaction_time ASC, id ASC LIMIT \\$7").
+		WithArgs(1, "CNY", from, to, cursor.Time, 42, 500).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "currency", "transaction_type", "amount", "transaction_time", "entry_id", "reversal_of", "reason", "actor", "prev_hash", "hash"}))
+	if _, err := repo.GetTransactionHistory(context.Background(), 1, "CNY", model.HistoryFilter{From: &from, To: &to, Cursor: cursor, Limit: 500, Ascending: true}); err != nil {
+		t.Errorf("正序查询时预期无错误，实际错误：%v", err)
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(CASE WHEN transaction_type = ANY\\(\\$4\\) THEN amount ELSE -amount END\\), 0\\) FROM transactions").
+		WithArgs(1, "CNY", from, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("45.5"))
+	balance, err := repo.GetBalanceBefore(context.Background(), 1, "CNY", from)
+	if err != nil || balance.String() != "45.50" {
+		t.Errorf("期初余额预期为45.50，实际：%s，%v", balance, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试审计记录的写入与查询，余额为空的列写入NULL
+func TestPostgresRepository_AuditLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+	now := time.Now()
+	after := decimal.MustParse("10")
+
+	mock.ExpectExec("INSERT INTO audit_log \\(action, actor, request_id, client_ip, user_id, currency, balance_before, balance_after, details, created_at\\)").
+		WithArgs("deposit", "key:gateway", "req-1", "203.0.113.7", 1, "CNY", nil, after, `{"amount":"10"}`, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	err = repo.InsertAuditEntry(context.Background(), model.AuditEntry{Action: "deposit", Actor: "key:gateway", RequestID: "req-1", ClientIP: "203.0.113.7",
+		UserID: 1, Currency: "CNY", BalanceAfter: &after, Details: map[string]string{"amount": "10"}, CreatedAt: now})
+	if err != nil {
+		t.Fatalf("写入审计记录时预期无错误，实际错误：%v", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "action", "actor", "request_id", "client_ip", "user_id", "currency", "balance_before", "balance_after", "details", "created_at"}).
+		AddRow(5, "deposit", "key:gateway", "req-1", "203.0.113.7", 1, "CNY", nil, "10.000", []byte(`{"amount":"10"}`), now).
+		AddRow(4, "hold.expire", "system", "", "", 0, "", nil, nil, []byte(`{}`), now)
+	mock.ExpectQuery("SELECT .+ FROM audit_log WHERE actor = \\$1 AND user_id = \\$2 AND id < \\$3 ORDER BY id DESC LIMIT \\$4").
+		WithArgs("key:gateway", 1, 9, 2).WillReturnRows(rows)
+	entries, err := repo.ListAuditEntries(context.Background(), model.AuditFilter{Actor: "key:gateway", UserID: 1, BeforeID: 9, Limit: 2})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("查询审计记录预期返回2条，实际：%+v，%v", entries, err)
+	}
+	if entries[0].BalanceBefore != nil || entries[0].BalanceAfter.String() != "10.00" || entries[0].Details["amount"] != "10" {
+		t.Errorf("审计记录解析不正确：%+v", entries[0])
+	}
+	if entries[1].BalanceAfter != nil || entries[1].Details != nil || entries[1].UserID != 0 {
+		t.Errorf("批量操作的审计记录解析不正确：%+v", entries[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试哈希链与检查点的查询
+func TestPostgresRepository_HashChain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	mock.ExpectQuery("SELECT hash FROM transactions WHERE user_id = \\$1 AND currency = \\$2 ORDER BY id DESC LIMIT 1").
+		WithArgs(1, "CNY").WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	if hash, err := repo.GetLastTransactionHash(context.Background(), 1, "CNY"); err != nil || hash != "" {
+		t.Errorf("没有交易时预期返回空哈希，实际：%q，%v", hash, err)
+	}
+
+	mock.ExpectQuery("SELECT DISTINCT ON \\(user_id, currency\\) user_id, currency, id, hash FROM transactions").
+		WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency", "id", "hash"}).AddRow(1, "CNY", 9, "h9").AddRow(2, "CNY", 4, "h4"))
+	heads, err := repo.ListChainHeads(context.Background(), 9)
+	if err != nil || len(heads) != 2 || heads[0].Hash != "h9" || heads[1].TransactionID != 4 {
+		t.Errorf("链头解析不正确：%+v，%v", heads, err)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO ledger_checkpoints .+ RETURNING id").
+		WithArgs(9, "digest", "key", "sig", now).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	id, err := repo.InsertLedgerCheckpoint(context.Background(), model.LedgerCheckpoint{LastTransactionID: 9, HeadsDigest: "digest", KeyID: "key", Signature: "sig", CreatedAt: now})
+	if err != nil || id != 3 {
+		t.Errorf("写入检查点预期返回ID 3，实际：%d，%v", id, err)
+	}
+
+	mock.ExpectQuery("FROM ledger_checkpoints ORDER BY last_transaction_id, id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "last_transaction_id", "heads_digest", "key_id", "signature", "created_at"}).AddRow(3, 9, "digest", "key", "sig", now))
+	checkpoints, err := repo.ListLedgerCheckpoints(context.Background())
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].LastTransactionID != 9 {
+		t.Errorf("检查点解析不正确：%+v，%v", checkpoints, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试开户时钱包已存在返回ErrWalletExists，以及限额的读写
+func TestPostgresRepository_CreateWalletWithLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	now := time.Now()
+	insert := "INSERT INTO wallets \\(user_id, currency, balance, status, last_updated, owner_metadata\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)"
+	mock.ExpectExec(insert).WithArgs(1, "CNY", decimal.Zero, "active", now, []byte(`{"name":"张三"}`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insert).WithArgs(1, "CNY", decimal.Zero, "active", now, []byte(`{}`)).WillReturnError(&pq.Error{Code: "23505"})
+	if err := repo.InsertWallet(context.Background(), model.Wallet{UserID: 1, Currency: "CNY", LastUpdated: now, OwnerMetadata: map[string]string{"name": "张三"}}); err != nil {
+		t.Errorf("创建钱包时预期无错误，实际错误：%v", err)
+	}
+	if err := repo.InsertWallet(context.Background(), model.Wallet{UserID: 1, Currency: "CNY", LastUpdated: now}); !errors.Is(err, _interface.ErrWalletExists) {
+		t.Errorf("钱包已存在时预期返回ErrWalletExists，实际：%v", err)
+	}
+
+	max := decimal.MustParse("500")
+	transfers := 10
+	mock.ExpectExec("INSERT INTO wallet_limits .+ ON CONFLICT \\(user_id, currency\\) DO UPDATE").
+		WithArgs(1, "CNY", nil, nil, nil, max, 10, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.UpsertWalletLimits(context.Background(), 1, "CNY", model.WalletLimits{MaxBalance: &max, MaxTransfersPerHour: &transfers}); err != nil {
+		t.Errorf("写入限额时预期无错误，实际错误：%v", err)
+	}
+
+	limitColumns := []string{"max_single_withdrawal", "daily_outgoing", "monthly_outgoing", "max_balance", "max_transfers_per_hour"}
+	mock.ExpectQuery("FROM wallet_limits WHERE user_id = \\$1 AND currency = \\$2").
+		WithArgs(1, "CNY").WillReturnRows(sqlmock.NewRows(limitColumns).AddRow("100.000", nil, nil, "500.000", 10))
+	limits, err := repo.GetWalletLimits(context.Background(), 1, "CNY")
+	if err != nil || limits.MaxSingleWithdrawal.String() != "100.00" || limits.DailyOutgoing != nil || limits.MaxBalance.String() != "500.00" || *limits.MaxTransfersPerHour != 10 {
+		t.Errorf("限额解析不正确：%+v，%v", limits, err)
+	}
+	mock.ExpectQuery("FROM wallet_limits").WithArgs(2, "CNY").WillReturnRows(sqlmock.NewRows(limitColumns))
+	if limits, err := repo.GetWalletLimits(context.Background(), 2, "CNY"); err != nil || !limits.IsEmpty() {
+		t.Errorf("没有设置限额时预期返回空限额，实际：%+v，%v", limits, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试按自然日、自然月与滚动一小时窗口统计出账，每月第一个小时内从一小时前开始扫描
+func TestPostgresRepository_GetOutgoingUsage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+
+	now := time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	hour := now.Add(-time.Hour)
+	mock.ExpectQuery("FROM transactions\\s+WHERE user_id = \\$1 AND currency = \\$2 AND transaction_type IN \\('withdrawal', 'transfer_out', 'fx_out', 'fee'\\)").
+		WithArgs(1, "CNY", day, day, hour, hour).
+		WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "transfers"}).AddRow("30.5", "30.5", 2))
+	usage, err := repo.GetOutgoingUsage(context.Background(), 1, "CNY", now)
+	if err != nil || usage.DailyOutgoing.String() != "30.50" || usage.MonthlyOutgoing.String() != "30.50" || usage.TransfersLastHour != 2 {
+		t.Errorf("出账统计解析不正确：%+v，%v", usage, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试发件箱事件的写入、按ID读取未发布事件与发布结果的回写
+func TestPostgresRepository_Outbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresRepository(db)
+	outboxRepo := postgres.NewPostgresOutboxRepository(db)
+	ctx := context.Background()
+	occurredAt := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(model.EventFundsDeposited, 1, "CNY", 0, "user:1", `{"amount":"5.00"}`, occurredAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	event := model.Event{Type: model.EventFundsDeposited, UserID: 1, Currency: "CNY", Actor: "user:1",
+		Payload: json.RawMessage(`{"amount":"5.00"}`), OccurredAt: occurredAt}
+	if err := repo.InsertOutboxEvent(ctx, event); err != nil {
+		t.Fatalf("写入事件时预期无错误，实际错误：%v", err)
+	}
+
+	mock.ExpectQuery("FROM outbox_events WHERE published_at IS NULL ORDER BY id LIMIT \\$1").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "user_id", "currency", "counterparty_user_id", "actor", "payload", "occurred_at", "attempts", "last_error"}).
+			AddRow(1, model.EventFundsDeposited, 1, "CNY", 0, "user:1", []byte(`{"amount":"5.00"}`), occurredAt, 2, "timeout"))
+	events, err := outboxRepo.ListUnpublishedEvents(ctx, 10)
+	if err != nil || len(events) != 1 || events[0].ID != 1 || string(events[0].Payload) != `{"amount":"5.00"}` || events[0].Attempts != 2 || events[0].LastError != "timeout" {
+		t.Fatalf("未发布事件解析不正确：%+v，%v", events, err)
+	}
+
+	mock.ExpectExec("UPDATE outbox_events SET attempts = attempts \\+ 1, last_error = \\$1 WHERE id = \\$2").
+		WithArgs("connection refused", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := outboxRepo.RecordEventFailure(ctx, 1, "connection refused"); err != nil {
+		t.Errorf("记录发布失败时预期无错误，实际错误：%v", err)
+	}
+	mock.ExpectExec("UPDATE outbox_events SET published_at = \\$1 WHERE id = \\$2").
+		WithArgs(occurredAt, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := outboxRepo.MarkEventPublished(ctx, 1, occurredAt); err != nil {
+		t.Errorf("标记已发布时预期无错误，实际错误：%v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
+
+// 测试推送地址与推送记录的读写以及领取到期推送的SQL
+func TestPostgresRepository_Webhooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error opening mock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := postgres.NewPostgresWebhookRepository(db)
+	ctx := context.Background()
+	createdAt := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	leaseUntil := createdAt.Add(5 * time.Minute)
+
+	mock.ExpectQuery("INSERT INTO webhook_endpoints").
+		WithArgs("https://partner.example.com/hook", `["FundsDeposited"]`, "", "partner", "wallets", 0, "whsec_x", true, createdAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	id, err := repo.InsertWebhookEndpoint(ctx, model.WebhookEndpoint{URL: "https://partner.example.com/hook",
+		EventTypes: []string{model.EventFundsDeposited}, Owner: "partner", Scope: model.WebhookScopeWallets, Secret: "whsec_x", Active: true, CreatedAt: createdAt})
+	if err != nil || id != 3 {
+		t.Fatalf("保存推送地址预期返回ID 3，实际：%d，%v", id, err)
+	}
+
+	mock.ExpectQuery("FROM webhook_endpoints WHERE owner = \\$1 ORDER BY id").
+		WithArgs("partner").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "event_types", "description", "owner", "scope", "owner_user_id", "secret", "active", "created_at"}).
+			AddRow(3, "https://partner.example.com/hook", []byte(`["FundsDeposited"]`), "", "partner", "wallets", 0, "whsec_x", true, createdAt))
+	endpoints, err := repo.ListWebhookEndpoints(ctx, "partner")
+	if err != nil || len(endpoints) != 1 || !endpoints[0].Subscribes(model.EventFundsDeposited) || endpoints[0].Subscribes(model.EventFundsWithdrawn) ||
+		endpoints[0].Scope != model.WebhookScopeWallets {
+		t.Fatalf("推送地址解析不正确：%+v，%v", endpoints, err)
+	}
+
+	mock.ExpectExec("UPDATE webhook_endpoints SET active = FALSE WHERE id = \\$1").
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.DisableWebhookEndpoint(ctx, 9); !errors.Is(err, _interface.ErrWebhookEndpointNotFound) {
+		t.Errorf("停用不存在的推送地址预期ErrWebhookEndpointNotFound，实际：%v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO webhook_deliveries .* ON CONFLICT \\(endpoint_id, event_id\\) DO NOTHING").
+		WithArgs(3, 7, model.EventFundsDeposited, `{"id":7}`, "pending", createdAt, createdAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	err = repo.InsertWebhookDelivery(ctx, model.WebhookDelivery{EndpointID: 3, EventID: 7, EventType: model.EventFundsDeposited,
+		Payload: json.RawMessage(`{"id":7}`), Status: model.WebhookPending, NextAttemptAt: createdAt, CreatedAt: createdAt})
+	if err != nil {
+		t.Fatalf("保存推送记录时预期无错误，实际错误：%v", err)
+	}
+
+	deliveryColumns := []string{"id", "endpoint_id", "event_id", "event_type", "payload", "status", "attempts", "next_attempt_at",
+		"last_status_code", "last_error", "created_at", "delivered_at"}
+	mock.ExpectQuery("UPDATE webhook_deliveries SET next_attempt_at = \\$1 WHERE id IN \\(SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= \\$2 ORDER BY next_attempt_at, id LIMIT \\$3 FOR UPDATE SKIP LOCKED\\) RETURNING").
+		WithArgs(leaseUntil, createdAt, 50).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns).
+			AddRow(1, 3, 7, model.EventFundsDeposited, []byte(`{"id":7}`), "pending", 2, leaseUntil, 500, "timeout", createdAt, nil))
+	deliveries, err := repo.ClaimDueWebhookDeliveries(ctx, createdAt, leaseUntil, 50)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Status != model.WebhookPending || deliveries[0].Attempts != 2 ||
+		string(deliveries[0].Payload) != `{"id":7}` || deliveries[0].DeliveredAt != nil {
+		t.Fatalf("领取的推送解析不正确：%+v，%v", deliveries, err)
+	}
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$1, attempts = \\$2").
+		WithArgs("succeeded", 3, leaseUntil, 204, "", &createdAt, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	delivery := deliveries[0]
+	delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt = model.WebhookSucceeded, 3, 204, "", &createdAt
+	if err := repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		t.Errorf("更新推送记录时预期无错误，实际错误：%v", err)
+	}
+
+	mock.ExpectQuery("FROM webhook_deliveries WHERE endpoint_id = \\$1 AND status = \\$2 ORDER BY id DESC LIMIT \\$3").
+		WithArgs(3, "dead", 20).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns))
+	if deliveries, err := repo.ListWebhookDeliveries(ctx, model.WebhookDeliveryFilter{EndpointID: 3, Status: model.WebhookDead, Limit: 20}); err != nil || len(deliveries) != 0 {
+		t.Errorf("查询推送记录预期为空，实际：%+v，%v", deliveries, err)
+	}
+
+	mock.ExpectQuery("FROM webhook_deliveries WHERE id = \\$1").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns))
+	if _, err := repo.GetWebhookDelivery(ctx, 9); !errors.Is(err, _interface.ErrWebhookDeliveryNotFound) {
+		t.Errorf("读取不存在的推送预期ErrWebhookDeliveryNotFound，实际：%v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("未满足的期望：%v", err)
+	}
+}
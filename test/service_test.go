@@ -2,48 +2,107 @@ package unit
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
-	"log"
 	"testing"
 	"time"
+	"wallet-service/internal/audit"
+	"wallet-service/internal/auth"
+	"wallet-service/internal/fx"
 	"wallet-service/internal/model"
+	_interface "wallet-service/internal/repository/interface"
 	"wallet-service/internal/service"
+	"wallet-service/pkg/decimal"
 )
 
-// 这里简单定义一个类似ErrWalletNotFound的错误变量，你需根据实际情况替换为真实定义
-var ErrWalletNotFound = errors.New("wallet not found")
+// ErrWalletNotFound 使用仓库层定义的钱包不存在错误
+var ErrWalletNotFound = _interface.ErrWalletNotFound
 
 // 辅助函数，用于创建简单的钱包对象
-func createWallet(userID int, balance float64) *model.Wallet {
+func createWallet(userID int, balance string) *model.Wallet {
 	return &model.Wallet{
 		UserID:      userID,
-		Balance:     balance,
+		Currency:    "CNY",
+		Balance:     decimal.MustParse(balance),
+		Status:      model.WalletActive,
 		LastUpdated: time.Now(),
 	}
 }
 
 // MockWalletRepository 结构体用于模拟WalletRepository接口的实现
 type MockWalletRepository struct {
-	getWalletFunc             func(ctx context.Context, userID int) (*model.Wallet, error)
-	updateWalletBalanceFunc   func(ctx context.Context, userID int, amount float64) error
+	getWalletFunc             func(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+	getWalletForUpdateFunc    func(ctx context.Context, userID int, currency string) (*model.Wallet, error)
+	updateWalletBalanceFunc   func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error
 	insertTransactionFunc     func(ctx context.Context, transaction model.Transaction) error
 	insertWallet              func(ctx context.Context, wallet model.Wallet) error
-	getTransactionHistoryFunc func(ctx context.Context, userID int) ([]model.Transaction, error)
+	updateWalletStatusFunc    func(ctx context.Context, userID int, currency string, status model.WalletStatus) error
+	getTransactionHistoryFunc func(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error)
+
+	listWalletsFunc func(ctx context.Context, userID int) ([]model.Wallet, error)
+
+	getTrialBalanceFunc      func(ctx context.Context) ([]model.TrialBalance, error)
+	listLedgerMismatchesFunc func(ctx context.Context) ([]model.LedgerMismatch, error)
+
+	// fxQuotes 保存InsertFXQuote写入的报价
+	fxQuotes map[string]*model.FXQuote
+	// holds 保存InsertHold写入的预授权，键为预授权ID
+	holds map[int]*model.Hold
+
+	// lockedUserIDs 记录GetWalletForUpdate的调用顺序，txCount 记录WithTx的调用次数
+	lockedUserIDs []int
+	txCount       int
+	// journalEntries 记录写入的记账凭证
+	journalEntries []model.JournalEntry
+	// transactions 记录写入的交易记录，ID从1开始递增
+	transactions []model.Transaction
+	// auditEntries 记录追加的审计记录，ID从1开始递增
+	auditEntries []model.AuditEntry
+	// checkpoints 记录写入的哈希链检查点，ID从1开始递增
+	checkpoints []model.LedgerCheckpoint
+	// limits 保存UpsertWalletLimits写入的限额，键为"{用户ID}|{币种}"
+	limits map[string]model.WalletLimits
+	// events 记录写入发件箱的领域事件，ID从1开始递增；outboxErr 不为nil时写入事件返回该错误
+	events    []model.Event
+	outboxErr error
 }
 
 // GetWallet 方法实现了WalletRepository接口的GetWallet方法，通过调用内部的函数来获取钱包信息
-func (m *MockWalletRepository) GetWallet(ctx context.Context, userID int) (*model.Wallet, error) {
+func (m *MockWalletRepository) GetWallet(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 	if m.getWalletFunc != nil {
-		return m.getWalletFunc(ctx, userID)
+		return m.getWalletFunc(ctx, userID, currency)
+	}
+	return nil, nil
+}
+
+// GetWalletForUpdate 方法实现了WalletRepository接口的GetWalletForUpdate方法，未设置时退化为getWalletFunc
+func (m *MockWalletRepository) GetWalletForUpdate(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+	m.lockedUserIDs = append(m.lockedUserIDs, userID)
+	if m.getWalletForUpdateFunc != nil {
+		return m.getWalletForUpdateFunc(ctx, userID, currency)
+	}
+	return m.GetWallet(ctx, userID, currency)
+}
+
+// ListWallets 方法实现了WalletRepository接口的ListWallets方法
+func (m *MockWalletRepository) ListWallets(ctx context.Context, userID int) ([]model.Wallet, error) {
+	if m.listWalletsFunc != nil {
+		return m.listWalletsFunc(ctx, userID)
 	}
 	return nil, nil
 }
 
+// WithTx 方法实现了WalletRepository接口的WithTx方法，直接以自身作为事务内仓库执行fn
+func (m *MockWalletRepository) WithTx(ctx context.Context, fn func(repo _interface.WalletRepository) error) error {
+	m.txCount++
+	return fn(m)
+}
+
 // UpdateWalletBalance 方法实现了WalletRepository接口的UpdateWalletBalance方法，通过调用内部的函数来更新钱包余额
-func (m *MockWalletRepository) UpdateWalletBalance(ctx context.Context, userID int, amount float64) error {
+func (m *MockWalletRepository) UpdateWalletBalance(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
 	if m.updateWalletBalanceFunc != nil {
-		return m.updateWalletBalanceFunc(ctx, userID, amount)
+		return m.updateWalletBalanceFunc(ctx, userID, currency, amount)
 	}
 	return nil
 }
@@ -51,11 +110,51 @@ func (m *MockWalletRepository) UpdateWalletBalance(ctx context.Context, userID i
 // InsertTransaction 方法实现了WalletRepository接口的InsertTransaction方法，通过调用内部的函数来插入交易记录
 func (m *MockWalletRepository) InsertTransaction(ctx context.Context, transaction model.Transaction) error {
 	if m.insertTransactionFunc != nil {
-		return m.insertTransactionFunc(ctx, transaction)
+		if err := m.insertTransactionFunc(ctx, transaction); err != nil {
+			return err
+		}
 	}
+	transaction.ID = len(m.transactions) + 1
+	m.transactions = append(m.transactions, transaction)
 	return nil
 }
 
+// GetTransaction 方法实现了WalletRepository接口的GetTransaction方法，从已写入的交易记录中查找
+func (m *MockWalletRepository) GetTransaction(ctx context.Context, id int) (*model.Transaction, error) {
+	if id <= 0 || id > len(m.transactions) {
+		return nil, _interface.ErrTransactionNotFound
+	}
+	transaction := m.transactions[id-1]
+	return &transaction, nil
+}
+
+// GetTransactionForUpdate 方法实现了WalletRepository接口的GetTransactionForUpdate方法
+func (m *MockWalletRepository) GetTransactionForUpdate(ctx context.Context, id int) (*model.Transaction, error) {
+	return m.GetTransaction(ctx, id)
+}
+
+// ListTransactionsByEntry 方法实现了WalletRepository接口的ListTransactionsByEntry方法
+func (m *MockWalletRepository) ListTransactionsByEntry(ctx context.Context, entryID int) ([]model.Transaction, error) {
+	var related []model.Transaction
+	for _, transaction := range m.transactions {
+		if transaction.EntryID == entryID {
+			related = append(related, transaction)
+		}
+	}
+	return related, nil
+}
+
+// SumReversals 方法实现了WalletRepository接口的SumReversals方法
+func (m *MockWalletRepository) SumReversals(ctx context.Context, id int) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, transaction := range m.transactions {
+		if transaction.ReversalOf == id {
+			total = total.Add(transaction.Amount)
+		}
+	}
+	return total, nil
+}
+
 // InsertWallet 方法实现了WalletRepository接口的InsertWallet方法，通过调用内部的函数来插入钱包信息
 func (m *MockWalletRepository) InsertWallet(ctx context.Context, wallet model.Wallet) error {
 	if m.insertWallet != nil {
@@ -64,19 +163,295 @@ func (m *MockWalletRepository) InsertWallet(ctx context.Context, wallet model.Wa
 	return nil
 }
 
+// UpdateWalletStatus 方法实现了WalletRepository接口的UpdateWalletStatus方法
+func (m *MockWalletRepository) UpdateWalletStatus(ctx context.Context, userID int, currency string, status model.WalletStatus) error {
+	if m.updateWalletStatusFunc != nil {
+		return m.updateWalletStatusFunc(ctx, userID, currency, status)
+	}
+	return nil
+}
+
+// InsertJournalEntry 方法实现了WalletRepository接口的InsertJournalEntry方法，记录凭证并返回递增的凭证ID
+func (m *MockWalletRepository) InsertJournalEntry(ctx context.Context, entry model.JournalEntry) (int, error) {
+	m.journalEntries = append(m.journalEntries, entry)
+	return len(m.journalEntries), nil
+}
+
+// GetTrialBalance 方法实现了WalletRepository接口的GetTrialBalance方法
+func (m *MockWalletRepository) GetTrialBalance(ctx context.Context) ([]model.TrialBalance, error) {
+	if m.getTrialBalanceFunc != nil {
+		return m.getTrialBalanceFunc(ctx)
+	}
+	return nil, nil
+}
+
+// ListLedgerMismatches 方法实现了WalletRepository接口的ListLedgerMismatches方法
+func (m *MockWalletRepository) ListLedgerMismatches(ctx context.Context) ([]model.LedgerMismatch, error) {
+	if m.listLedgerMismatchesFunc != nil {
+		return m.listLedgerMismatchesFunc(ctx)
+	}
+	return nil, nil
+}
+
+// InsertFXQuote 方法实现了WalletRepository接口的InsertFXQuote方法，报价保存在内存中
+func (m *MockWalletRepository) InsertFXQuote(ctx context.Context, quote model.FXQuote) error {
+	if m.fxQuotes == nil {
+		m.fxQuotes = make(map[string]*model.FXQuote)
+	}
+	m.fxQuotes[quote.ID] = &quote
+	return nil
+}
+
+// GetFXQuoteForUpdate 方法实现了WalletRepository接口的GetFXQuoteForUpdate方法
+func (m *MockWalletRepository) GetFXQuoteForUpdate(ctx context.Context, id string) (*model.FXQuote, error) {
+	quote, ok := m.fxQuotes[id]
+	if !ok {
+		return nil, _interface.ErrFXQuoteNotFound
+	}
+	copied := *quote
+	return &copied, nil
+}
+
+// MarkFXQuoteUsed 方法实现了WalletRepository接口的MarkFXQuoteUsed方法
+func (m *MockWalletRepository) MarkFXQuoteUsed(ctx context.Context, id string, usedAt time.Time) error {
+	if quote, ok := m.fxQuotes[id]; ok {
+		quote.UsedAt = &usedAt
+	}
+	return nil
+}
+
+// InsertHold 方法实现了WalletRepository接口的InsertHold方法，预授权保存在内存中
+func (m *MockWalletRepository) InsertHold(ctx context.Context, hold model.Hold) (int, error) {
+	if m.holds == nil {
+		m.holds = make(map[int]*model.Hold)
+	}
+	hold.ID = len(m.holds) + 1
+	m.holds[hold.ID] = &hold
+	return hold.ID, nil
+}
+
+// GetHold 方法实现了WalletRepository接口的GetHold方法
+func (m *MockWalletRepository) GetHold(ctx context.Context, id int) (*model.Hold, error) {
+	hold, ok := m.holds[id]
+	if !ok {
+		return nil, _interface.ErrHoldNotFound
+	}
+	copied := *hold
+	return &copied, nil
+}
+
+// GetHoldForUpdate 方法实现了WalletRepository接口的GetHoldForUpdate方法
+func (m *MockWalletRepository) GetHoldForUpdate(ctx context.Context, id int) (*model.Hold, error) {
+	return m.GetHold(ctx, id)
+}
+
+// UpdateHold 方法实现了WalletRepository接口的UpdateHold方法
+func (m *MockWalletRepository) UpdateHold(ctx context.Context, hold model.Hold) error {
+	if _, ok := m.holds[hold.ID]; !ok {
+		return _interface.ErrHoldNotFound
+	}
+	m.holds[hold.ID] = &hold
+	return nil
+}
+
+// SumActiveHolds 方法实现了WalletRepository接口的SumActiveHolds方法
+func (m *MockWalletRepository) SumActiveHolds(ctx context.Context, userID int, currency string, now time.Time) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for _, hold := range m.holds {
+		if hold.UserID == userID && hold.Currency == currency && hold.Active(now) {
+			total = total.Add(hold.Amount)
+		}
+	}
+	return total, nil
+}
+
+// ExpireHolds 方法实现了WalletRepository接口的ExpireHolds方法
+func (m *MockWalletRepository) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+	for _, hold := range m.holds {
+		if hold.Status == model.HoldAuthorized && !now.Before(hold.ExpiresAt) {
+			hold.Status = model.HoldExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// InsertAuditEntry 方法实现了WalletRepository接口的InsertAuditEntry方法
+func (m *MockWalletRepository) InsertAuditEntry(ctx context.Context, entry model.AuditEntry) error {
+	entry.ID = len(m.auditEntries) + 1
+	m.auditEntries = append(m.auditEntries, entry)
+	return nil
+}
+
+// InsertOutboxEvent 方法实现了WalletRepository接口的InsertOutboxEvent方法
+func (m *MockWalletRepository) InsertOutboxEvent(ctx context.Context, event model.Event) error {
+	if m.outboxErr != nil {
+		return m.outboxErr
+	}
+	event.ID = len(m.events) + 1
+	m.events = append(m.events, event)
+	return nil
+}
+
+// ListUnpublishedEvents 方法实现了OutboxRepository接口的ListUnpublishedEvents方法
+func (m *MockWalletRepository) ListUnpublishedEvents(ctx context.Context, limit int) ([]model.Event, error) {
+	var events []model.Event
+	for _, event := range m.events {
+		if event.PublishedAt == nil && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// MarkEventPublished 方法实现了OutboxRepository接口的MarkEventPublished方法
+func (m *MockWalletRepository) MarkEventPublished(ctx context.Context, id int, publishedAt time.Time) error {
+	m.events[id-1].PublishedAt = &publishedAt
+	return nil
+}
+
+// RecordEventFailure 方法实现了OutboxRepository接口的RecordEventFailure方法
+func (m *MockWalletRepository) RecordEventFailure(ctx context.Context, id int, message string) error {
+	m.events[id-1].Attempts++
+	m.events[id-1].LastError = message
+	return nil
+}
+
+// ListAuditEntries 方法实现了WalletRepository接口的ListAuditEntries方法，按ID降序返回满足条件的审计记录
+func (m *MockWalletRepository) ListAuditEntries(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	for i := len(m.auditEntries) - 1; i >= 0 && (filter.Limit == 0 || len(entries) < filter.Limit); i-- {
+		entry := m.auditEntries[i]
+		if (filter.Action != "" && entry.Action != filter.Action) || (filter.UserID > 0 && entry.UserID != filter.UserID) ||
+			(filter.BeforeID > 0 && entry.ID >= filter.BeforeID) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetWalletLimits 方法实现了WalletRepository接口的GetWalletLimits方法
+func (m *MockWalletRepository) GetWalletLimits(ctx context.Context, userID int, currency string) (*model.WalletLimits, error) {
+	limits := m.limits[fmt.Sprintf("%d|%s", userID, currency)]
+	return &limits, nil
+}
+
+// UpsertWalletLimits 方法实现了WalletRepository接口的UpsertWalletLimits方法
+func (m *MockWalletRepository) UpsertWalletLimits(ctx context.Context, userID int, currency string, limits model.WalletLimits) error {
+	if m.limits == nil {
+		m.limits = make(map[string]model.WalletLimits)
+	}
+	m.limits[fmt.Sprintf("%d|%s", userID, currency)] = limits
+	return nil
+}
+
+// GetOutgoingUsage 方法实现了WalletRepository接口的GetOutgoingUsage方法，按已写入的交易记录统计
+func (m *MockWalletRepository) GetOutgoingUsage(ctx context.Context, userID int, currency string, now time.Time) (*model.OutgoingUsage, error) {
+	day, month, hour := model.LimitWindows(now)
+	usage := &model.OutgoingUsage{DailyOutgoing: decimal.Zero, MonthlyOutgoing: decimal.Zero}
+	for _, tx := range m.transactions {
+		if tx.UserID != userID || tx.Currency != currency || (tx.TransactionType != "withdrawal" && tx.TransactionType != "transfer_out" && tx.TransactionType != "fx_out" && tx.TransactionType != "fee") {
+			continue
+		}
+		if !tx.TransactionTime.Before(day) {
+			usage.DailyOutgoing = usage.DailyOutgoing.Add(tx.Amount)
+		}
+		if !tx.TransactionTime.Before(month) {
+			usage.MonthlyOutgoing = usage.MonthlyOutgoing.Add(tx.Amount)
+		}
+		if tx.TransactionType == "transfer_out" && !tx.TransactionTime.Before(hour) {
+			usage.TransfersLastHour++
+		}
+	}
+	return usage, nil
+}
+
+// GetLastTransactionHash 方法实现了WalletRepository接口的GetLastTransactionHash方法，返回钱包最后一笔交易的Hash
+func (m *MockWalletRepository) GetLastTransactionHash(ctx context.Context, userID int, currency string) (string, error) {
+	for i := len(m.transactions) - 1; i >= 0; i-- {
+		if m.transactions[i].UserID == userID && m.transactions[i].Currency == currency {
+			return m.transactions[i].Hash, nil
+		}
+	}
+	return "", nil
+}
+
+// ListTransactionsAfter 方法实现了WalletRepository接口的ListTransactionsAfter方法，按ID升序返回
+func (m *MockWalletRepository) ListTransactionsAfter(ctx context.Context, afterID, limit int) ([]model.Transaction, error) {
+	var transactions []model.Transaction
+	for _, transaction := range m.transactions {
+		if transaction.ID > afterID && len(transactions) < limit {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// GetLastTransactionIDBefore 方法实现了WalletRepository接口的GetLastTransactionIDBefore方法
+func (m *MockWalletRepository) GetLastTransactionIDBefore(ctx context.Context, before time.Time) (int, error) {
+	lastID := 0
+	for _, transaction := range m.transactions {
+		if transaction.TransactionTime.Before(before) && transaction.ID > lastID {
+			lastID = transaction.ID
+		}
+	}
+	return lastID, nil
+}
+
+// ListChainHeads 方法实现了WalletRepository接口的ListChainHeads方法，返回每个钱包在uptoID及之前的最后一笔已上链交易
+func (m *MockWalletRepository) ListChainHeads(ctx context.Context, uptoID int) ([]model.ChainHead, error) {
+	heads := make(map[string]model.ChainHead)
+	for _, transaction := range m.transactions {
+		if transaction.ID <= uptoID && transaction.Hash != "" {
+			key := fmt.Sprintf("%d|%s", transaction.UserID, transaction.Currency)
+			heads[key] = model.ChainHead{UserID: transaction.UserID, Currency: transaction.Currency, TransactionID: transaction.ID, Hash: transaction.Hash}
+		}
+	}
+	result := make([]model.ChainHead, 0, len(heads))
+	for _, head := range heads {
+		result = append(result, head)
+	}
+	return result, nil
+}
+
+// InsertLedgerCheckpoint 方法实现了WalletRepository接口的InsertLedgerCheckpoint方法
+func (m *MockWalletRepository) InsertLedgerCheckpoint(ctx context.Context, checkpoint model.LedgerCheckpoint) (int, error) {
+	checkpoint.ID = len(m.checkpoints) + 1
+	m.checkpoints = append(m.checkpoints, checkpoint)
+	return checkpoint.ID, nil
+}
+
+// ListLedgerCheckpoints 方法实现了WalletRepository接口的ListLedgerCheckpoints方法
+func (m *MockWalletRepository) ListLedgerCheckpoints(ctx context.Context) ([]model.LedgerCheckpoint, error) {
+	return m.checkpoints, nil
+}
+
 // GetTransactionHistory 方法实现了WalletRepository接口的GetTransactionHistory方法，通过调用内部的函数来获取交易历史记录
-func (m *MockWalletRepository) GetTransactionHistory(ctx context.Context, userID int) ([]model.Transaction, error) {
+func (m *MockWalletRepository) GetTransactionHistory(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
 	if m.getTransactionHistoryFunc != nil {
-		return m.getTransactionHistoryFunc(ctx, userID)
+		return m.getTransactionHistoryFunc(ctx, userID, currency, filter)
 	}
 	return nil, nil
 }
 
+// GetBalanceBefore 方法实现了WalletRepository接口的GetBalanceBefore方法，按已记录的交易计算
+func (m *MockWalletRepository) GetBalanceBefore(ctx context.Context, userID int, currency string, before time.Time) (decimal.Decimal, error) {
+	balance := decimal.Zero
+	for _, transaction := range m.transactions {
+		if transaction.UserID == userID && transaction.Currency == currency && transaction.TransactionTime.Before(before) {
+			balance = balance.Add(transaction.SignedAmount())
+		}
+	}
+	return balance, nil
+}
+
 // 测试存款功能
 func TestWalletService_Deposit(t *testing.T) {
-	// 模拟获取钱包不存在（即需要创建新钱包）的情况
+	// 模拟获取钱包不存在且开启了自动创建（即需要创建新钱包）的情况
 	mockRepo := &MockWalletRepository{
-		getWalletFunc: func(ctx context.Context, userID int) (*model.Wallet, error) {
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 			return nil, ErrWalletNotFound
 		},
 		insertWallet: func(ctx context.Context, wallet model.Wallet) error {
@@ -84,35 +459,49 @@ func TestWalletService_Deposit(t *testing.T) {
 		},
 	}
 
-	walletService := service.NewWalletService(mockRepo)
+	walletService := service.NewWalletService(mockRepo, service.WithAutoCreateWallets(true))
+
+	// 未开启自动创建时，钱包不存在的存款返回ErrWalletNotFound
+	if _, err := service.NewWalletService(mockRepo).Deposit(context.Background(), 1, "CNY", decimal.MustParse("100.00")); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("未开启自动创建时预期返回ErrWalletNotFound，实际：%v", err)
+	}
 
 	// 模拟插入新钱包和插入交易记录都成功的情况
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
 		return nil
 	}
 
-	err := walletService.Deposit(context.Background(), 1, 100.00)
+	updated, err := walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("100.00"))
 	if err != nil {
 		t.Errorf("存款时预期无错误，实际错误：%v", err)
+	} else if updated.UserID != 1 || updated.Currency != "CNY" || updated.Balance.String() != "100.00" || updated.Status != model.WalletActive {
+		t.Errorf("存款预期返回存款后的钱包，实际：%+v", updated)
+	}
+
+	// 超出金额精度或非正数的存款应被拒绝
+	for _, amount := range []string{"0.001", "0", "-1"} {
+		if _, err := walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse(amount)); err == nil {
+			t.Errorf("存款金额为%s时，预期应该返回错误，实际无错误", amount)
+		}
 	}
 
 	// 模拟获取钱包时出错的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		return nil, errors.New("模拟获取钱包出错")
 	}
-	err = walletService.Deposit(context.Background(), 1, 100.00)
+	_, err = walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("100.00"))
 	if err == nil {
 		t.Errorf("获取钱包出错时，预期应该返回错误，实际无错误")
 	}
 
 	// 模拟插入新钱包失败的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		return nil, ErrWalletNotFound
 	}
 	mockRepo.insertWallet = func(ctx context.Context, wallet model.Wallet) error {
 		return errors.New("模拟插入新钱包失败")
 	}
-	err = walletService.Deposit(context.Background(), 1, 100.00)
+	_, err = walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("100.00"))
 	if err == nil {
 		t.Errorf("插入新钱包失败时，预期应该返回错误，实际无错误")
 	}
@@ -121,7 +510,7 @@ func TestWalletService_Deposit(t *testing.T) {
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
 		return errors.New("模拟插入交易记录失败")
 	}
-	err = walletService.Deposit(context.Background(), 1, 100.00)
+	_, err = walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("100.00"))
 	if err == nil {
 		t.Errorf("插入交易记录失败时，预期应该返回错误，实际无错误")
 	}
@@ -130,9 +519,9 @@ func TestWalletService_Deposit(t *testing.T) {
 // 测试取款功能
 func TestWalletService_Withdraw(t *testing.T) {
 	// 模拟获取钱包成功且余额足够的情况
-	wallet := createWallet(1, 200.00)
+	wallet := createWallet(1, "200.00")
 	mockRepo := &MockWalletRepository{
-		getWalletFunc: func(ctx context.Context, userID int) (*model.Wallet, error) {
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 			if userID == 1 {
 				return wallet, nil
 			}
@@ -143,55 +532,57 @@ func TestWalletService_Withdraw(t *testing.T) {
 	walletService := service.NewWalletService(mockRepo)
 
 	// 模拟更新钱包余额和插入交易记录都成功的情况
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
 		return nil
 	}
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
 		return nil
 	}
 
-	err := walletService.Withdraw(context.Background(), 1, 50.00)
+	updated, err := walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err != nil {
 		t.Errorf("取款时预期无错误，实际错误：%v", err)
+	} else if updated.UserID != 1 || updated.Balance.String() != "150.00" {
+		t.Errorf("取款预期返回取款后的钱包，实际：%+v", updated)
 	}
 
 	// 模拟获取钱包时出错的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		return nil, errors.New("模拟获取钱包出错")
 	}
-	err = walletService.Withdraw(context.Background(), 1, 50.00)
+	_, err = walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err == nil {
 		t.Errorf("获取钱包出错时，预期 should 返回错误，实际无错误")
 	}
 
 	// 模拟钱包余额不足的情况
-	wallet.Balance = 30.00
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	wallet.Balance = decimal.MustParse("30.00")
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		return wallet, nil
 	}
-	err = walletService.Withdraw(context.Background(), 1, 50.00)
+	_, err = walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err == nil {
 		t.Errorf("钱包余额不足时，预期 should 返回错误，实际无错误")
 	}
 
 	// 模拟更新钱包余额失败的情况
-	wallet.Balance = 200.00
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
+	wallet.Balance = decimal.MustParse("200.00")
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
 		return errors.New("模拟更新钱包余额失败")
 	}
-	err = walletService.Withdraw(context.Background(), 1, 50.00)
+	_, err = walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err == nil {
 		t.Errorf("更新钱包余额失败时，预期 should 返回错误，实际无错误")
 	}
 
 	// 模拟插入交易记录失败的情况
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
 		return nil
 	}
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
 		return errors.New("模拟插入交易记录失败")
 	}
-	err = walletService.Withdraw(context.Background(), 1, 50.00)
+	_, err = walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50.00"))
 	if err == nil {
 		t.Errorf("插入交易记录失败时，预期 should 返回错误，实际无错误")
 	}
@@ -200,13 +591,15 @@ func TestWalletService_Withdraw(t *testing.T) {
 // 测试转账功能
 func TestWalletService_Transfer(t *testing.T) {
 	// 模拟获取转出钱包和转入钱包成功，且余额足够的情况
-	fromWallet := createWallet(1, 200.00)
-	toWallet := createWallet(2, 100.00)
+	fromWallet := createWallet(1, "200.00")
+	toWallet := createWallet(2, "100.00")
 	mockRepo := &MockWalletRepository{
-		getWalletFunc: func(ctx context.Context, userID int) (*model.Wallet, error) {
-			log.Printf("In getWalletFunc, userID: %d", userID)
-			if userID == 1 {
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			switch userID {
+			case 1:
 				return fromWallet, nil
+			case 2:
+				return toWallet, nil
 			}
 			return nil, nil
 		},
@@ -214,107 +607,155 @@ func TestWalletService_Transfer(t *testing.T) {
 
 	walletService := service.NewWalletService(mockRepo)
 
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
-		if userID == 2 {
-			return toWallet, nil
-		}
-		return nil, nil
-	}
-
 	// 模拟更新双方钱包余额和插入双方交易记录都成功的情况
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
+	var updates []decimal.Decimal
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+		updates = append(updates, amount)
 		return nil
 	}
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
 		return nil
 	}
 
-	err := walletService.Transfer(context.Background(), 1, 2, 50.00)
+	err := walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
 	if err != nil {
 		t.Errorf("转账时预期无错误，实际错误：%v", err)
 	}
+	if mockRepo.txCount != 1 {
+		t.Errorf("转账应在单个事务中完成，实际WithTx调用次数：%d", mockRepo.txCount)
+	}
+	if len(updates) != 2 || !updates[0].Equal(decimal.MustParse("-50")) || !updates[1].Equal(decimal.MustParse("50")) {
+		t.Errorf("预期依次扣减50并增加50，实际：%v", updates)
+	}
+
+	// 反向转账时仍应按用户ID升序加锁，避免死锁
+	mockRepo.lockedUserIDs = nil
+	err = walletService.Transfer(context.Background(), 2, 1, "CNY", decimal.MustParse("50.00"))
+	if err != nil {
+		t.Errorf("反向转账时预期无错误，实际错误：%v", err)
+	}
+	if len(mockRepo.lockedUserIDs) != 2 || mockRepo.lockedUserIDs[0] != 1 || mockRepo.lockedUserIDs[1] != 2 {
+		t.Errorf("预期加锁顺序为[1 2]，实际：%v", mockRepo.lockedUserIDs)
+	}
+
+	// 向自己转账应被拒绝
+	err = walletService.Transfer(context.Background(), 1, 1, "CNY", decimal.MustParse("50.00"))
+	if err == nil {
+		t.Errorf("向自己转账时，预期 should 返回错误，实际无错误")
+	}
 
 	// 模拟获取转出钱包时出错的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
-		return nil, errors.New("模拟获取转出钱包出错")
+	getFromErr := errors.New("模拟获取转出钱包出错")
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+		return nil, getFromErr
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟获取转出钱包出错")) {
-		t.Errorf("获取转出钱包出错时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, getFromErr) {
+		t.Errorf("获取转出钱包出错时，预期返回%v，实际：%v", getFromErr, err)
 	}
 
 	// 模拟获取转入钱包时出错的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	getToErr := errors.New("模拟获取转入钱包出错")
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		if userID == 2 {
-			return nil, errors.New("模拟获取转入钱包出错")
+			return nil, getToErr
 		}
-		return nil, nil
+		return fromWallet, nil
+	}
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, getToErr) {
+		t.Errorf("获取转入钱包出错时，预期返回%v，实际：%v", getToErr, err)
+	}
+
+	// 模拟转入钱包不存在的情况
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+		if userID == 2 {
+			return nil, nil
+		}
+		return fromWallet, nil
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟获取转入钱包出错")) {
-		t.Errorf("获取转入钱包出错时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if err == nil {
+		t.Errorf("转入钱包不存在时，预期 should 返回错误，实际无错误")
 	}
 
 	// 模拟转出钱包余额不足的情况
-	fromWallet.Balance = 30.00
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	fromWallet.Balance = decimal.MustParse("30.00")
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		if userID == 2 {
 			return toWallet, nil
 		}
 		return fromWallet, nil
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, fmt.Errorf("Insufficient balance")) {
+	updates = nil
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if err == nil {
 		t.Errorf("转出钱包余额不足时，预期 should 返回错误，实际无错误")
 	}
+	if len(updates) != 0 {
+		t.Errorf("余额不足时不应更新任何余额，实际：%v", updates)
+	}
 
 	// 模拟更新转出钱包余额失败的情况
-	fromWallet.Balance = 200.00
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
-		return errors.New("模拟更新转出钱包余额失败")
+	fromWallet.Balance = decimal.MustParse("200.00")
+	updateFromErr := errors.New("模拟更新转出钱包余额失败")
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+		return updateFromErr
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟更新转出钱包余额失败")) {
-		t.Errorf("更新转出钱包余额失败时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, updateFromErr) {
+		t.Errorf("更新转出钱包余额失败时，预期返回%v，实际：%v", updateFromErr, err)
 	}
 
 	// 模拟更新转入钱包余额失败的情况
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
-		return errors.New("模拟更新转入钱包余额失败")
+	updateToErr := errors.New("模拟更新转入钱包余额失败")
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+		if userID == 2 {
+			return updateToErr
+		}
+		return nil
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟更新转入钱包余额失败")) {
-		t.Errorf("更新转入钱包余额失败时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, updateToErr) {
+		t.Errorf("更新转入钱包余额失败时，预期返回%v，实际：%v", updateToErr, err)
 	}
 
 	// 模拟插入转出交易记录失败的情况
-	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, amount float64) error {
+	mockRepo.updateWalletBalanceFunc = func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
 		return nil
 	}
+	insertOutErr := errors.New("模拟插入转出交易记录失败")
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
-		return errors.New("模拟插入转出交易记录失败")
+		if transaction.TransactionType == "transfer_out" {
+			return insertOutErr
+		}
+		return nil
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟插入转出交易记录失败")) {
-		t.Errorf("插入转出交易记录失败时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, insertOutErr) {
+		t.Errorf("插入转出交易记录失败时，预期返回%v，实际：%v", insertOutErr, err)
 	}
 
 	// 模拟插入转入交易记录失败的情况
+	insertInErr := errors.New("模拟插入转入交易记录失败")
 	mockRepo.insertTransactionFunc = func(ctx context.Context, transaction model.Transaction) error {
-		return errors.New("模拟插入转入交易记录失败")
+		if transaction.TransactionType == "transfer_in" {
+			return insertInErr
+		}
+		return nil
 	}
-	err = walletService.Transfer(context.Background(), 1, 2, 50.00)
-	if err == nil || !errors.Is(err, errors.New("模拟插入转入交易记录失败")) {
-		t.Errorf("插入转入交易记录失败时，预期 should 返回错误，实际无错误")
+	err = walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("50.00"))
+	if !errors.Is(err, insertInErr) {
+		t.Errorf("插入转入交易记录失败时，预期返回%v，实际：%v", insertInErr, err)
 	}
 }
 
 // 测试获取余额功能
 func TestWalletService_GetBalance(t *testing.T) {
 	// 模拟获取钱包成功的情况
-	wallet := createWallet(1, 200.00)
+	wallet := createWallet(1, "200.00")
 	mockRepo := &MockWalletRepository{
-		getWalletFunc: func(ctx context.Context, userID int) (*model.Wallet, error) {
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 			if userID == 1 {
 				return wallet, nil
 			}
@@ -324,19 +765,24 @@ func TestWalletService_GetBalance(t *testing.T) {
 
 	walletService := service.NewWalletService(mockRepo)
 
-	balance, err := walletService.GetBalance(context.Background(), 1)
+	balance, err := walletService.GetBalance(context.Background(), 1, "CNY")
 	if err != nil {
-		t.Errorf("获取余额时预期无错误，实际错误：%v", err)
+		t.Fatalf("获取余额时预期无错误，实际错误：%v", err)
 	}
-	if balance != 200.00 {
-		t.Errorf("获取的余额值不正确，预期为200.00，实际为：%v", balance)
+	if !balance.Ledger.Equal(decimal.MustParse("200.00")) || !balance.Available.Equal(decimal.MustParse("200.00")) {
+		t.Errorf("获取的余额值不正确，预期账面与可用余额均为200.00，实际为：%+v", balance)
+	}
+
+	// 钱包不存在时与其它操作一致返回ErrWalletNotFound，而不是余额0
+	if _, err := walletService.GetBalance(context.Background(), 2, "CNY"); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("钱包不存在时预期返回ErrWalletNotFound，实际：%v", err)
 	}
 
 	// 模拟获取钱包失败的情况
-	mockRepo.getWalletFunc = func(ctx context.Context, userID int) (*model.Wallet, error) {
+	mockRepo.getWalletFunc = func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
 		return nil, errors.New("模拟获取钱包出错")
 	}
-	balance, err = walletService.GetBalance(context.Background(), 1)
+	_, err = walletService.GetBalance(context.Background(), 1, "CNY")
 	if err == nil {
 		t.Errorf("获取钱包出错时，预期 should 返回错误，实际无错误")
 	}
@@ -349,28 +795,1471 @@ func TestWalletService_GetTransactionHistory(t *testing.T) {
 		// 这里可以添加一些模拟的交易记录示例
 	}
 	mockRepo := &MockWalletRepository{
-		getTransactionHistoryFunc: func(ctx context.Context, userID int) ([]model.Transaction, error) {
+		getTransactionHistoryFunc: func(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
 			return transactions, nil
 		},
 	}
 
 	walletService := service.NewWalletService(mockRepo)
 
-	history, err := walletService.GetTransactionHistory(context.Background(), 1)
+	history, err := walletService.GetTransactionHistory(context.Background(), 1, "CNY", model.HistoryFilter{})
 	if err != nil {
 		t.Errorf("获取交易历史时预期无错误，实际错误：%v", err)
 	}
 	// 这里添加对history变量的使用逻辑，例如检查返回的交易历史记录数量是否符合预期
-	if len(history) != len(transactions) {
-		t.Errorf("获取的交易历史记录数量不正确，预期为 %d，实际为 %d", len(transactions), len(history))
+	if len(history.Transactions) != len(transactions) || history.NextCursor != "" {
+		t.Errorf("获取的交易历史记录数量不正确，预期为 %d，实际为 %d", len(transactions), len(history.Transactions))
 	}
 
 	// 模拟获取交易历史失败的情况
-	mockRepo.getTransactionHistoryFunc = func(ctx context.Context, userID int) ([]model.Transaction, error) {
+	mockRepo.getTransactionHistoryFunc = func(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
 		return nil, errors.New("模拟获取交易历史出错")
 	}
-	history, err = walletService.GetTransactionHistory(context.Background(), 1)
+	_, err = walletService.GetTransactionHistory(context.Background(), 1, "CNY", model.HistoryFilter{})
 	if err == nil {
 		t.Errorf("获取交易历史出错时，预期 should 返回错误，实际无错误")
 	}
 }
+
+// 测试服务层返回可用errors.Is/As识别的领域错误
+func TestWalletService_DomainErrors(t *testing.T) {
+	wallet := createWallet(1, "30.00")
+	mockRepo := &MockWalletRepository{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			if userID == 1 {
+				return wallet, nil
+			}
+			return nil, _interface.ErrWalletNotFound
+		},
+	}
+	walletService := service.NewWalletService(mockRepo)
+
+	cases := []struct {
+		name string
+		err  error
+		want *service.Error
+	}{
+		{"余额不足", walletErr(walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("50"))), service.ErrInsufficientFunds},
+		{"钱包不存在", walletErr(walletService.Withdraw(context.Background(), 2, "CNY", decimal.MustParse("1"))), service.ErrWalletNotFound},
+		{"转入钱包不存在", walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("1")), service.ErrWalletNotFound},
+		{"金额非法", walletErr(walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("0.001"))), service.ErrInvalidAmount},
+		{"向自己转账", walletService.Transfer(context.Background(), 1, 1, "CNY", decimal.MustParse("1")), service.ErrSameWallet},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%s：预期错误%v，实际：%v", c.name, c.want, c.err)
+			continue
+		}
+		var domainErr *service.Error
+		if !errors.As(c.err, &domainErr) || domainErr.Code != c.want.Code {
+			t.Errorf("%s：预期错误码%s，实际：%v", c.name, c.want.Code, domainErr)
+		}
+	}
+
+	_, err := walletService.GetWallet(context.Background(), 2, "CNY")
+	if !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("查询不存在的钱包预期返回ErrWalletNotFound，实际：%v", err)
+	}
+
+	limitErr := error(&service.LimitExceededError{Limit: "max_single_withdrawal", Max: decimal.MustParse("100"), Remaining: decimal.Zero})
+	if !errors.Is(fmt.Errorf("withdraw: %w", limitErr), service.ErrLimitExceeded) {
+		t.Errorf("LimitExceededError应可被识别为ErrLimitExceeded")
+	}
+}
+
+// 测试资金变动写入借贷平衡的记账凭证
+func TestWalletService_LedgerPostings(t *testing.T) {
+	wallets := map[int]*model.Wallet{1: createWallet(1, "100.00"), 2: createWallet(2, "0")}
+	var transactions []model.Transaction
+	mockRepo := &MockWalletRepository{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			return wallets[userID], nil
+		},
+		insertTransactionFunc: func(ctx context.Context, transaction model.Transaction) error {
+			transactions = append(transactions, transaction)
+			return nil
+		},
+	}
+	walletService := service.NewWalletService(mockRepo, service.WithCashAccount("system:bank"))
+
+	if _, err := walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("10")); err != nil {
+		t.Fatalf("存款时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("5")); err != nil {
+		t.Fatalf("取款时预期无错误，实际错误：%v", err)
+	}
+	if err := walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("20")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+
+	expected := []struct {
+		entryType     string
+		debitAccount  string
+		creditAccount string
+	}{
+		{"deposit", "system:bank", "wallet:1:CNY"},
+		{"withdrawal", "wallet:1:CNY", "system:bank"},
+		{"transfer", "wallet:1:CNY", "wallet:2:CNY"},
+	}
+	if len(mockRepo.journalEntries) != len(expected) {
+		t.Fatalf("预期写入%d张凭证，实际：%d", len(expected), len(mockRepo.journalEntries))
+	}
+	for i, want := range expected {
+		entry := mockRepo.journalEntries[i]
+		if err := entry.Validate(); err != nil {
+			t.Errorf("凭证%d应借贷平衡，实际：%v", i, err)
+		}
+		if entry.EntryType != want.entryType || len(entry.Postings) != 2 ||
+			entry.Postings[0].Account != want.debitAccount || entry.Postings[0].Direction != model.Debit ||
+			entry.Postings[1].Account != want.creditAccount || entry.Postings[1].Direction != model.Credit {
+			t.Errorf("凭证%d内容不正确：%+v", i, entry)
+		}
+	}
+
+	// 转账的两条交易记录应关联同一张凭证
+	if len(transactions) != 4 || transactions[2].EntryID != 3 || transactions[3].EntryID != 3 {
+		t.Errorf("转账交易记录应关联凭证3，实际：%+v", transactions)
+	}
+}
+
+// 测试账本核对结果
+func TestWalletService_VerifyLedger(t *testing.T) {
+	mockRepo := &MockWalletRepository{
+		getTrialBalanceFunc: func(ctx context.Context) ([]model.TrialBalance, error) {
+			return []model.TrialBalance{
+				{Currency: "CNY", TotalDebits: decimal.MustParse("150.00"), TotalCredits: decimal.MustParse("150.00")},
+				{Currency: "USD", TotalDebits: decimal.MustParse("20.00"), TotalCredits: decimal.MustParse("20.00")},
+			}, nil
+		},
+	}
+	walletService := service.NewWalletService(mockRepo)
+
+	report, err := walletService.VerifyLedger(context.Background())
+	if err != nil || !report.Balanced() {
+		t.Errorf("借贷平衡且无差异时预期核对通过，实际：%+v，%v", report, err)
+	}
+
+	mockRepo.listLedgerMismatchesFunc = func(ctx context.Context) ([]model.LedgerMismatch, error) {
+		return []model.LedgerMismatch{{UserID: 1, WalletBalance: decimal.MustParse("10"), LedgerBalance: decimal.MustParse("5")}}, nil
+	}
+	report, err = walletService.VerifyLedger(context.Background())
+	if err != nil || report.Balanced() || len(report.Mismatches) != 1 {
+		t.Errorf("钱包余额与分录不一致时预期核对失败，实际：%+v，%v", report, err)
+	}
+}
+
+// 测试钱包按币种区分，金额精度随币种变化
+func TestWalletService_MultiCurrency(t *testing.T) {
+	cnyWallet := createWallet(1, "100.00")
+	var inserted []model.Wallet
+	var transactions []model.Transaction
+	mockRepo := &MockWalletRepository{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			if userID == 1 && currency == "CNY" {
+				return cnyWallet, nil
+			}
+			return nil, ErrWalletNotFound
+		},
+		insertWallet: func(ctx context.Context, wallet model.Wallet) error {
+			inserted = append(inserted, wallet)
+			return nil
+		},
+		insertTransactionFunc: func(ctx context.Context, transaction model.Transaction) error {
+			transactions = append(transactions, transaction)
+			return nil
+		},
+	}
+	walletService := service.NewWalletService(mockRepo, service.WithAutoCreateWallets(true))
+
+	// 开启自动创建时，已有CNY钱包的用户存入USD应新建独立的USD钱包，金额按币种补齐小数位
+	if _, err := walletService.Deposit(context.Background(), 1, "USD", decimal.MustParse("12.5")); err != nil {
+		t.Fatalf("存入USD预期无错误，实际错误：%v", err)
+	}
+	if len(inserted) != 1 || inserted[0].Currency != "USD" || inserted[0].Balance.String() != "12.50" {
+		t.Errorf("预期新建余额为12.50的USD钱包，实际：%+v", inserted)
+	}
+	if len(transactions) != 1 || transactions[0].Currency != "USD" {
+		t.Errorf("交易记录应标记币种USD，实际：%+v", transactions)
+	}
+	entry := mockRepo.journalEntries[0]
+	if entry.Postings[1].Account != "wallet:1:USD" || entry.Postings[0].Currency != "USD" || entry.Postings[1].Currency != "USD" {
+		t.Errorf("分录应记入USD钱包账户，实际：%+v", entry.Postings)
+	}
+
+	cases := []struct {
+		name     string
+		currency string
+		amount   string
+		want     *service.Error
+	}{
+		{"JPY不允许小数", "JPY", "1.5", service.ErrInvalidAmount},
+		{"KWD允许三位小数", "KWD", "1.005", nil},
+		{"KWD不允许四位小数", "KWD", "1.0005", service.ErrInvalidAmount},
+		{"未知币种", "XXX", "1", service.ErrUnsupportedCurrency},
+		{"币种代码须大写", "usd", "1", service.ErrUnsupportedCurrency},
+	}
+	for _, c := range cases {
+		_, err := walletService.Deposit(context.Background(), 1, c.currency, decimal.MustParse(c.amount))
+		if c.want == nil && err != nil {
+			t.Errorf("%s：预期无错误，实际：%v", c.name, err)
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s：预期错误%v，实际：%v", c.name, c.want, err)
+		}
+	}
+
+	// 对方没有同币种钱包时不能转账
+	err := walletService.Transfer(context.Background(), 1, 2, "CNY", decimal.MustParse("1"))
+	if !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("转入方没有CNY钱包时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+}
+
+// newFXTestService 创建持有1号用户1000 CNY、2号用户0 USD钱包的服务，汇率为USD/CNY 7.2，点差1%
+func newFXTestService(opts ...service.Option) (service.WalletService, *MockWalletRepository, *[]model.Transaction) {
+	wallets := map[string]*model.Wallet{
+		"1:CNY": {UserID: 1, Currency: "CNY", Balance: decimal.MustParse("1000.00")},
+		"1:USD": {UserID: 1, Currency: "USD", Balance: decimal.MustParse("0.00")},
+		"2:USD": {UserID: 2, Currency: "USD", Balance: decimal.MustParse("0.00")},
+	}
+	var transactions []model.Transaction
+	mockRepo := &MockWalletRepository{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			if wallet, ok := wallets[fmt.Sprintf("%d:%s", userID, currency)]; ok {
+				return wallet, nil
+			}
+			return nil, ErrWalletNotFound
+		},
+		insertTransactionFunc: func(ctx context.Context, transaction model.Transaction) error {
+			transactions = append(transactions, transaction)
+			return nil
+		},
+	}
+	provider := fx.NewStaticProvider(map[string]decimal.Decimal{"USD/CNY": decimal.MustParse("7.2")})
+	opts = append([]service.Option{service.WithFXRateProvider(provider, decimal.MustParse("0.01"), time.Minute)}, opts...)
+	walletService := service.NewWalletService(mockRepo, opts...)
+	return walletService, mockRepo, &transactions
+}
+
+// 测试金额乘以汇率超出可表示的范围时返回ErrInvalidAmount而不是panic
+func TestWalletService_ConvertOverflow(t *testing.T) {
+	walletService, mockRepo, _ := newFXTestService(service.WithFXRateProvider(
+		fx.NewStaticProvider(map[string]decimal.Decimal{"USD/CNY": decimal.MustParse("10000000000")}), decimal.Zero, time.Minute))
+
+	_, err := walletService.Convert(context.Background(), 1, "USD", "CNY", decimal.MustParse("100000000000000"), "")
+	if !errors.Is(err, service.ErrInvalidAmount) {
+		t.Errorf("换汇结果超出范围预期返回ErrInvalidAmount，实际：%v", err)
+	}
+	if len(mockRepo.journalEntries) != 0 {
+		t.Errorf("被拒绝的换汇不应写入凭证，实际：%d", len(mockRepo.journalEntries))
+	}
+}
+
+// 测试按实时汇率换汇：两条腿各一张凭证并记录汇率与点差
+func TestWalletService_Convert(t *testing.T) {
+	walletService, mockRepo, transactions := newFXTestService()
+
+	// CNY/USD = 1/7.2 = 0.13888889，扣除1%点差后为0.13750000，100 CNY兑换13.75 USD
+	conversion, err := walletService.Convert(context.Background(), 1, "CNY", "USD", decimal.MustParse("100"), "")
+	if err != nil {
+		t.Fatalf("换汇时预期无错误，实际错误：%v", err)
+	}
+	if conversion.EffectiveRate.String() != "0.13750000" || conversion.TargetAmount.String() != "13.75" || conversion.SourceAmount.String() != "100.00" {
+		t.Errorf("换汇结果不正确：%+v", conversion)
+	}
+
+	if len(mockRepo.journalEntries) != 2 {
+		t.Fatalf("换汇预期写入2张凭证，实际：%d", len(mockRepo.journalEntries))
+	}
+	sell, buy := mockRepo.journalEntries[0], mockRepo.journalEntries[1]
+	if sell.EntryType != "fx_sell" || sell.Postings[0].Account != "wallet:1:CNY" || sell.Postings[1].Account != model.AccountSystemFX || sell.Postings[1].Currency != "CNY" {
+		t.Errorf("卖出凭证不正确：%+v", sell)
+	}
+	if buy.EntryType != "fx_buy" || buy.Postings[0].Account != model.AccountSystemFX || buy.Postings[1].Account != "wallet:1:USD" || !buy.Postings[1].Amount.Equal(decimal.MustParse("13.75")) {
+		t.Errorf("买入凭证不正确：%+v", buy)
+	}
+	for _, entry := range []model.JournalEntry{sell, buy} {
+		if entry.FX == nil || entry.FX.Rate.String() != "0.13888889" || entry.FX.Spread.String() != "0.01" {
+			t.Errorf("%s凭证应记录汇率与点差，实际：%+v", entry.EntryType, entry.FX)
+		}
+	}
+	if len(*transactions) != 2 || (*transactions)[0].TransactionType != "fx_out" || (*transactions)[1].TransactionType != "fx_in" ||
+		(*transactions)[1].Currency != "USD" || (*transactions)[1].EntryID != 2 {
+		t.Errorf("换汇交易记录不正确：%+v", *transactions)
+	}
+
+	cases := []struct {
+		name string
+		from string
+		to   string
+		want *service.Error
+	}{
+		{"同币种", "CNY", "CNY", service.ErrSameWallet},
+		{"无汇率", "CNY", "JPY", service.ErrRateUnavailable},
+		{"无源钱包", "EUR", "USD", service.ErrRateUnavailable},
+	}
+	for _, c := range cases {
+		_, err := walletService.Convert(context.Background(), 1, c.from, c.to, decimal.MustParse("1"), "")
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s：预期错误%v，实际：%v", c.name, c.want, err)
+		}
+	}
+
+	if _, err := walletService.Convert(context.Background(), 1, "CNY", "USD", decimal.MustParse("5000"), ""); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("余额不足时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+
+	// 未配置汇率源时不支持换汇
+	plain := service.NewWalletService(mockRepo)
+	if _, err := plain.QuoteFX(context.Background(), "CNY", "USD"); !errors.Is(err, service.ErrRateUnavailable) {
+		t.Errorf("未配置汇率源时预期返回ErrRateUnavailable，实际：%v", err)
+	}
+}
+
+// 测试报价锁定汇率、只能使用一次且过期失效
+func TestWalletService_FXQuotes(t *testing.T) {
+	walletService, mockRepo, _ := newFXTestService()
+	ctx := context.Background()
+
+	quote, err := walletService.QuoteFX(ctx, "CNY", "USD")
+	if err != nil {
+		t.Fatalf("报价时预期无错误，实际错误：%v", err)
+	}
+	if quote.ID == "" || quote.EffectiveRate.String() != "0.13750000" || !quote.ExpiresAt.After(quote.CreatedAt) {
+		t.Errorf("报价内容不正确：%+v", quote)
+	}
+
+	// 报价锁定的汇率不受后续行情变化影响
+	mockRepo.fxQuotes[quote.ID].EffectiveRate = decimal.MustParse("0.15")
+	conversion, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("100"), quote.ID)
+	if err != nil || conversion.TargetAmount.String() != "15.00" || conversion.QuoteID != quote.ID {
+		t.Errorf("按报价换汇预期得到15.00 USD，实际：%+v，%v", conversion, err)
+	}
+
+	if _, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("1"), quote.ID); !errors.Is(err, service.ErrQuoteExpired) {
+		t.Errorf("报价重复使用预期返回ErrQuoteExpired，实际：%v", err)
+	}
+
+	expired, _ := walletService.QuoteFX(ctx, "CNY", "USD")
+	mockRepo.fxQuotes[expired.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("1"), expired.ID); !errors.Is(err, service.ErrQuoteExpired) {
+		t.Errorf("报价过期预期返回ErrQuoteExpired，实际：%v", err)
+	}
+
+	other, _ := walletService.QuoteFX(ctx, "USD", "CNY")
+	if _, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("1"), other.ID); !errors.Is(err, service.ErrCurrencyMismatch) {
+		t.Errorf("报价币种对不一致预期返回ErrCurrencyMismatch，实际：%v", err)
+	}
+	if _, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("1"), "missing"); !errors.Is(err, service.ErrQuoteNotFound) {
+		t.Errorf("报价不存在预期返回ErrQuoteNotFound，实际：%v", err)
+	}
+}
+
+// 测试跨币种转账直接记入转入方的目标币种钱包
+func TestWalletService_TransferWithConversion(t *testing.T) {
+	walletService, mockRepo, transactions := newFXTestService()
+
+	conversion, err := walletService.TransferWithConversion(context.Background(), 1, 2, "CNY", "USD", decimal.MustParse("100"), "")
+	if err != nil {
+		t.Fatalf("跨币种转账预期无错误，实际错误：%v", err)
+	}
+	if conversion.ToUserID != 2 || conversion.TargetAmount.String() != "13.75" {
+		t.Errorf("跨币种转账结果不正确：%+v", conversion)
+	}
+	if mockRepo.journalEntries[1].Postings[1].Account != "wallet:2:USD" {
+		t.Errorf("买入腿应贷记转入方USD钱包，实际：%+v", mockRepo.journalEntries[1])
+	}
+	if (*transactions)[0].TransactionType != "transfer_out" || (*transactions)[1].TransactionType != "transfer_in" || (*transactions)[1].UserID != 2 {
+		t.Errorf("跨币种转账交易记录不正确：%+v", *transactions)
+	}
+
+	// 转入方没有目标币种钱包时拒绝，不自动创建
+	if _, err := walletService.TransferWithConversion(context.Background(), 1, 3, "CNY", "USD", decimal.MustParse("1"), ""); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("转入方没有USD钱包时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+}
+
+// 测试换汇与跨币种转账的卖出腿计入出账限额，买入腿检查余额上限，被拒绝时不写入交易
+func TestWalletService_ConversionLimits(t *testing.T) {
+	single, daily := decimal.MustParse("120"), decimal.MustParse("150")
+	walletService, _, transactions := newFXTestService(service.WithDefaultLimits(map[string]model.WalletLimits{
+		"CNY": {MaxSingleWithdrawal: &single, DailyOutgoing: &daily},
+	}))
+	ctx := context.Background()
+
+	_, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("130"), "")
+	assertLimitExceeded(t, err, "max_single_withdrawal", "120")
+	if _, err := walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("100"), ""); err != nil {
+		t.Fatalf("未超出限额的换汇预期无错误，实际错误：%v", err)
+	}
+	_, err = walletService.Convert(ctx, 1, "CNY", "USD", decimal.MustParse("60"), "")
+	assertLimitExceeded(t, err, "daily_outgoing", "50.00")
+	_, err = walletService.TransferWithConversion(ctx, 1, 2, "CNY", "USD", decimal.MustParse("60"), "")
+	assertLimitExceeded(t, err, "daily_outgoing", "50.00")
+
+	maxBalance := decimal.MustParse("5")
+	if _, err := walletService.SetWalletLimits(ctx, 2, "USD", model.WalletLimits{MaxBalance: &maxBalance}); err != nil {
+		t.Fatalf("设置余额上限时预期无错误，实际错误：%v", err)
+	}
+	_, err = walletService.TransferWithConversion(ctx, 1, 2, "CNY", "USD", decimal.MustParse("50"), "")
+	assertLimitExceeded(t, err, "max_balance", "5.00")
+
+	usage, err := walletService.GetLimitUsage(ctx, 1, "CNY")
+	if err != nil || usage.Usage.DailyOutgoing.String() != "100.00" {
+		t.Errorf("换汇应计入当日出账，预期100.00，实际：%+v，%v", usage, err)
+	}
+	if len(*transactions) != 2 {
+		t.Errorf("被拒绝的换汇不应写入交易，预期2条，实际：%+v", *transactions)
+	}
+}
+
+// newHoldTestService 创建持有1号用户100 CNY、2号用户0 CNY钱包的服务，余额随更新而变化
+func newHoldTestService(opts ...service.Option) (service.WalletService, *MockWalletRepository) {
+	wallets := map[int]*model.Wallet{
+		1: createWallet(1, "100.00"),
+		2: createWallet(2, "0.00"),
+	}
+	mockRepo := &MockWalletRepository{
+		getWalletFunc: func(ctx context.Context, userID int, currency string) (*model.Wallet, error) {
+			if wallet, ok := wallets[userID]; ok && currency == "CNY" {
+				copied := *wallet
+				return &copied, nil
+			}
+			return nil, ErrWalletNotFound
+		},
+		updateWalletBalanceFunc: func(ctx context.Context, userID int, currency string, amount decimal.Decimal) error {
+			wallets[userID].Balance = wallets[userID].Balance.Add(amount)
+			return nil
+		},
+		updateWalletStatusFunc: func(ctx context.Context, userID int, currency string, status model.WalletStatus) error {
+			wallets[userID].Status = status
+			return nil
+		},
+	}
+	return service.NewWalletService(mockRepo, opts...), mockRepo
+}
+
+// 测试预授权只减少可用余额，部分请款转为取款并释放剩余冻结金额
+func TestWalletService_AuthorizeAndCapture(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	hold, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("60"), 0, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if hold.Status != model.HoldAuthorized || hold.Amount.String() != "60.00" || len(mockRepo.journalEntries) != 0 {
+		t.Errorf("预授权不应记账，实际：%+v，凭证数：%d", hold, len(mockRepo.journalEntries))
+	}
+	balance, _ := walletService.GetBalance(ctx, 1, "CNY")
+	if balance.Ledger.String() != "100.00" || balance.Held.String() != "60.00" || balance.Available.String() != "40.00" {
+		t.Errorf("预授权后预期账面100.00、冻结60.00、可用40.00，实际：%+v", balance)
+	}
+
+	// 冻结金额不能再被取款或再次授权
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("50")); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("取款超出可用余额时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("50"), 0, 0); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("预授权超出可用余额时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+
+	if _, err := walletService.Capture(ctx, hold.ID, decimal.MustParse("61")); !errors.Is(err, service.ErrInvalidAmount) {
+		t.Errorf("请款超出授权金额时预期返回ErrInvalidAmount，实际：%v", err)
+	}
+	captured, err := walletService.Capture(ctx, hold.ID, decimal.MustParse("30"))
+	if err != nil {
+		t.Fatalf("部分请款时预期无错误，实际错误：%v", err)
+	}
+	if captured.Status != model.HoldCaptured || captured.CapturedAmount.String() != "30.00" || captured.EntryID != 1 {
+		t.Errorf("部分请款结果不正确：%+v", captured)
+	}
+	if len(mockRepo.journalEntries) != 1 || mockRepo.journalEntries[0].EntryType != "withdrawal" {
+		t.Errorf("无收款方的请款预期记为取款，实际：%+v", mockRepo.journalEntries)
+	}
+	balance, _ = walletService.GetBalance(ctx, 1, "CNY")
+	if balance.Ledger.String() != "70.00" || balance.Available.String() != "70.00" {
+		t.Errorf("请款后剩余冻结应释放，预期账面与可用均为70.00，实际：%+v", balance)
+	}
+
+	// 已请款的预授权不能再次请款或撤销
+	if _, err := walletService.Capture(ctx, hold.ID, decimal.Zero); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("重复请款时预期返回ErrHoldNotActive，实际：%v", err)
+	}
+	if _, err := walletService.Void(ctx, hold.ID); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("撤销已请款的预授权时预期返回ErrHoldNotActive，实际：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, 99, decimal.Zero); !errors.Is(err, service.ErrHoldNotFound) {
+		t.Errorf("预授权不存在时预期返回ErrHoldNotFound，实际：%v", err)
+	}
+}
+
+// 测试带收款方的预授权全额请款转为转账，以及撤销与到期释放
+func TestWalletService_HoldLifecycle(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("10"), 3, 0); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("收款方钱包不存在时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("10"), 1, 0); !errors.Is(err, service.ErrSameWallet) {
+		t.Errorf("收款方为自己时预期返回ErrSameWallet，实际：%v", err)
+	}
+
+	hold, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("25"), 2, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, hold.ID, decimal.Zero); err != nil {
+		t.Fatalf("全额请款时预期无错误，实际错误：%v", err)
+	}
+	if entry := mockRepo.journalEntries[0]; entry.EntryType != "transfer" || entry.Postings[1].Account != "wallet:2:CNY" {
+		t.Errorf("有收款方的请款预期记为转账，实际：%+v", entry)
+	}
+	payee, _ := walletService.GetBalance(ctx, 2, "CNY")
+	if payee.Ledger.String() != "25.00" {
+		t.Errorf("收款方预期入账25.00，实际：%+v", payee)
+	}
+
+	voided, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("75"), 0, 0)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if hold, err := walletService.Void(ctx, voided.ID); err != nil || hold.Status != model.HoldVoided {
+		t.Errorf("撤销预授权时预期状态为voided，实际：%+v，%v", hold, err)
+	}
+
+	// 到期的预授权不再占用可用余额，也不能再请款
+	expiring, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("75"), 0, time.Hour)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if !expiring.ExpiresAt.After(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("预期有效期为1小时，实际到期时间：%v", expiring.ExpiresAt)
+	}
+	mockRepo.holds[expiring.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if balance, _ := walletService.GetBalance(ctx, 1, "CNY"); balance.Available.String() != "75.00" {
+		t.Errorf("预授权到期后预期可用余额恢复为75.00，实际：%+v", balance)
+	}
+	if _, err := walletService.Capture(ctx, expiring.ID, decimal.Zero); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("对到期预授权请款时预期返回ErrHoldNotActive，实际：%v", err)
+	}
+	if hold, _ := walletService.GetHold(ctx, expiring.ID); hold.Status != model.HoldExpired {
+		t.Errorf("到期预授权预期按expired返回，实际：%s", hold.Status)
+	}
+	if expired, err := walletService.ExpireHolds(ctx); err != nil || expired != 1 {
+		t.Errorf("预期将1个预授权标记为过期，实际：%d，%v", expired, err)
+	}
+}
+
+// 测试全额冲正存款与取款，重复冲正被拒绝
+func TestWalletService_Reverse(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	if _, err := walletService.Deposit(ctx, 1, "CNY", decimal.MustParse("30")); err != nil {
+		t.Fatalf("存款时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Reverse(ctx, 1, " "); !errors.Is(err, service.ErrReasonRequired) {
+		t.Errorf("未填写原因时预期返回ErrReasonRequired，实际：%v", err)
+	}
+	reversal, err := walletService.Reverse(ctx, 1, "重复入账")
+	if err != nil {
+		t.Fatalf("冲正存款时预期无错误，实际错误：%v", err)
+	}
+	if reversal.Amount.String() != "30.00" || !reversal.Remaining.IsZero() || len(reversal.Transactions) != 1 {
+		t.Errorf("冲正结果不正确：%+v", reversal)
+	}
+	reversalTx := mockRepo.transactions[1]
+	if reversalTx.TransactionType != "deposit_reversal" || reversalTx.ReversalOf != 1 || reversalTx.Reason != "重复入账" {
+		t.Errorf("冲正交易应指向原交易并记录原因，实际：%+v", reversalTx)
+	}
+	entry := mockRepo.journalEntries[1]
+	if entry.EntryType != "reversal" || entry.Postings[0].Account != "wallet:1:CNY" || entry.Postings[1].Account != model.AccountSystemCash {
+		t.Errorf("存款冲正预期借记钱包、贷记现金账户，实际：%+v", entry)
+	}
+	if balance, _ := walletService.GetBalance(ctx, 1, "CNY"); balance.Ledger.String() != "100.00" {
+		t.Errorf("冲正后预期余额恢复为100.00，实际：%+v", balance)
+	}
+
+	cases := []struct {
+		name string
+		txID int
+		want error
+	}{
+		{"重复冲正", 1, service.ErrAlreadyReversed},
+		{"冲正冲正交易", 2, service.ErrNotReversible},
+		{"交易不存在", 99, service.ErrTransactionNotFound},
+	}
+	for _, c := range cases {
+		if _, err := walletService.Reverse(ctx, c.txID, "测试"); !errors.Is(err, c.want) {
+			t.Errorf("%s：预期错误%v，实际：%v", c.name, c.want, err)
+		}
+	}
+
+	// 冲正取款把资金从现金账户退回钱包；存款不支持部分冲正
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("40")); err != nil {
+		t.Fatalf("取款时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Refund(ctx, 3, decimal.MustParse("10"), "部分退款"); !errors.Is(err, service.ErrNotReversible) {
+		t.Errorf("取款部分冲正预期返回ErrNotReversible，实际：%v", err)
+	}
+	if _, err := walletService.Reverse(ctx, 3, "取款失败"); err != nil {
+		t.Fatalf("冲正取款时预期无错误，实际错误：%v", err)
+	}
+	if balance, _ := walletService.GetBalance(ctx, 1, "CNY"); balance.Ledger.String() != "100.00" {
+		t.Errorf("冲正取款后预期余额为100.00，实际：%+v", balance)
+	}
+}
+
+// 测试转账部分退款：由收款方退回付款方，累计不超过原金额
+func TestWalletService_RefundTransfer(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("50")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+	// 以转入记录ID发起退款同样指向这笔转账
+	refund, err := walletService.Refund(ctx, 2, decimal.MustParse("20"), "商品缺货")
+	if err != nil {
+		t.Fatalf("部分退款时预期无错误，实际错误：%v", err)
+	}
+	if refund.TransactionID != 1 || refund.Amount.String() != "20.00" || refund.Remaining.String() != "30.00" {
+		t.Errorf("部分退款结果不正确：%+v", refund)
+	}
+	refundOut, refundIn := mockRepo.transactions[2], mockRepo.transactions[3]
+	if refundOut.UserID != 2 || refundOut.TransactionType != "refund_out" || refundOut.ReversalOf != 2 ||
+		refundIn.UserID != 1 || refundIn.TransactionType != "refund_in" || refundIn.ReversalOf != 1 {
+		t.Errorf("退款交易应分别指向转入、转出记录，实际：%+v，%+v", refundOut, refundIn)
+	}
+	if entry := mockRepo.journalEntries[1]; entry.Postings[0].Account != "wallet:2:CNY" || entry.Postings[1].Account != "wallet:1:CNY" {
+		t.Errorf("退款预期借记收款方、贷记付款方，实际：%+v", entry)
+	}
+
+	if _, err := walletService.Refund(ctx, 1, decimal.MustParse("30.01"), "超额退款"); !errors.Is(err, service.ErrInvalidAmount) {
+		t.Errorf("退款超出剩余金额时预期返回ErrInvalidAmount，实际：%v", err)
+	}
+	// 全额冲正只退回剩余部分
+	rest, err := walletService.Reverse(ctx, 1, "取消订单")
+	if err != nil || rest.Amount.String() != "30.00" {
+		t.Errorf("冲正剩余部分预期为30.00，实际：%+v，%v", rest, err)
+	}
+	if _, err := walletService.Refund(ctx, 1, decimal.MustParse("1"), "再次退款"); !errors.Is(err, service.ErrAlreadyReversed) {
+		t.Errorf("全额退款后再退款预期返回ErrAlreadyReversed，实际：%v", err)
+	}
+	payer, _ := walletService.GetBalance(ctx, 1, "CNY")
+	payee, _ := walletService.GetBalance(ctx, 2, "CNY")
+	if payer.Ledger.String() != "100.00" || payee.Ledger.String() != "0.00" {
+		t.Errorf("全额退款后预期双方余额恢复，实际：%+v，%+v", payer, payee)
+	}
+}
+
+// 测试交易历史分页：多查一条判断是否有下一页，游标指向本页最后一条记录
+func TestWalletService_HistoryPagination(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var all []model.Transaction
+	for id := 5; id >= 1; id-- {
+		all = append(all, model.Transaction{ID: id, UserID: 1, Currency: "CNY", TransactionType: "deposit", Amount: decimal.MustParse("1.00"), TransactionTime: base.Add(time.Duration(id) * time.Minute)})
+	}
+	var filters []model.HistoryFilter
+	mockRepo := &MockWalletRepository{
+		getTransactionHistoryFunc: func(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
+			filters = append(filters, filter)
+			var page []model.Transaction
+			for _, transaction := range all {
+				if filter.Cursor != nil && transaction.ID >= filter.Cursor.ID {
+					continue
+				}
+				if len(page) < filter.Limit {
+					page = append(page, transaction)
+				}
+			}
+			return page, nil
+		},
+	}
+	walletService := service.NewWalletService(mockRepo)
+	ctx := context.Background()
+
+	first, err := walletService.GetTransactionHistory(ctx, 1, "CNY", model.HistoryFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("获取交易历史时预期无错误，实际错误：%v", err)
+	}
+	if len(first.Transactions) != 2 || first.Transactions[1].ID != 4 || first.NextCursor == "" || filters[0].Limit != 3 {
+		t.Fatalf("第一页预期2条记录并返回游标，实际：%+v，查询条件：%+v", first, filters[0])
+	}
+	cursor, err := model.DecodeHistoryCursor(first.NextCursor)
+	if err != nil || cursor.ID != 4 || !cursor.Time.Equal(all[1].TransactionTime) {
+		t.Errorf("游标应指向第一页最后一条记录，实际：%+v，%v", cursor, err)
+	}
+
+	last, err := walletService.GetTransactionHistory(ctx, 1, "CNY", model.HistoryFilter{Limit: 3, Cursor: cursor})
+	if err != nil || len(last.Transactions) != 3 || last.Transactions[0].ID != 3 || last.NextCursor != "" {
+		t.Errorf("最后一页预期3条记录且没有游标，实际：%+v，%v", last, err)
+	}
+
+	if page, _ := walletService.GetTransactionHistory(ctx, 1, "CNY", model.HistoryFilter{}); filters[len(filters)-1].Limit != service.DefaultHistoryLimit+1 || len(page.Transactions) != 5 {
+		t.Errorf("未指定页大小时预期使用默认值，实际查询条件：%+v", filters[len(filters)-1])
+	}
+
+	from, to := base, base.Add(-time.Hour)
+	min, max := decimal.MustParse("10"), decimal.MustParse("1")
+	cases := []struct {
+		name   string
+		filter model.HistoryFilter
+	}{
+		{"页大小超限", model.HistoryFilter{Limit: service.MaxHistoryLimit + 1}},
+		{"未知交易类型", model.HistoryFilter{Types: []string{"bonus"}}},
+		{"金额范围颠倒", model.HistoryFilter{MinAmount: &min, MaxAmount: &max}},
+		{"时间范围颠倒", model.HistoryFilter{From: &from, To: &to}},
+	}
+	for _, c := range cases {
+		if _, err := walletService.GetTransactionHistory(ctx, 1, "CNY", c.filter); !errors.Is(err, service.ErrInvalidFilter) {
+			t.Errorf("%s：预期返回ErrInvalidFilter，实际：%v", c.name, err)
+		}
+	}
+}
+
+// recordingStatementWriter 记录写出的对账单内容
+type recordingStatementWriter struct {
+	header  model.StatementHeader
+	lines   []model.StatementLine
+	summary *model.StatementSummary
+}
+
+func (r *recordingStatementWriter) WriteHeader(header model.StatementHeader) error {
+	r.header = header
+	return nil
+}
+
+func (r *recordingStatementWriter) WriteLine(line model.StatementLine) error {
+	r.lines = append(r.lines, line)
+	return nil
+}
+
+func (r *recordingStatementWriter) WriteFooter(summary model.StatementSummary) error {
+	r.summary = &summary
+	return nil
+}
+
+// 测试对账单：期初余额取期间之前的交易，逐笔累计余额，跨页读取时游标连续
+func TestWalletService_GenerateStatement(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	walletService, mockRepo := newHoldTestService()
+	mockRepo.transactions = []model.Transaction{
+		{ID: 1, UserID: 1, Currency: "CNY", TransactionType: "deposit", Amount: decimal.MustParse("50.00"), TransactionTime: from.Add(-time.Hour)},
+		{ID: 2, UserID: 1, Currency: "CNY", TransactionType: "withdrawal", Amount: decimal.MustParse("5.00"), TransactionTime: from.Add(-time.Minute)},
+	}
+	// 期间内的交易超过一页，最后一笔为取款
+	for i := 0; i < 500; i++ {
+		mockRepo.transactions = append(mockRepo.transactions, model.Transaction{ID: i + 3, UserID: 1, Currency: "CNY", TransactionType: "deposit", Amount: decimal.MustParse("1.00"), TransactionTime: from.Add(time.Duration(i) * time.Minute)})
+	}
+	mockRepo.transactions = append(mockRepo.transactions,
+		model.Transaction{ID: 503, UserID: 1, Currency: "CNY", TransactionType: "withdrawal", Amount: decimal.MustParse("20.00"), TransactionTime: to.Add(-time.Second)},
+		model.Transaction{ID: 504, UserID: 1, Currency: "CNY", TransactionType: "deposit", Amount: decimal.MustParse("9.00"), TransactionTime: to},
+	)
+	var filters []model.HistoryFilter
+	mockRepo.getTransactionHistoryFunc = func(ctx context.Context, userID int, currency string, filter model.HistoryFilter) ([]model.Transaction, error) {
+		filters = append(filters, filter)
+		var page []model.Transaction
+		for _, transaction := range mockRepo.transactions {
+			if transaction.TransactionTime.Before(*filter.From) || !transaction.TransactionTime.Before(*filter.To) {
+				continue
+			}
+			if filter.Cursor != nil && transaction.ID <= filter.Cursor.ID {
+				continue
+			}
+			if len(page) < filter.Limit {
+				page = append(page, transaction)
+			}
+		}
+		return page, nil
+	}
+
+	writer := &recordingStatementWriter{}
+	summary, err := walletService.GenerateStatement(context.Background(), 1, "CNY", from, to, writer)
+	if err != nil {
+		t.Fatalf("生成对账单时预期无错误，实际错误：%v", err)
+	}
+	if writer.header.OpeningBalance.String() != "45.00" || summary.OpeningBalance.String() != "45.00" {
+		t.Errorf("期初余额预期为45.00，实际：%s", writer.header.OpeningBalance)
+	}
+	if len(filters) != 2 || !filters[0].Ascending || filters[1].Cursor == nil || filters[1].Cursor.ID != 502 {
+		t.Errorf("预期按时间正序分两页读取，实际查询条件：%+v", filters)
+	}
+	if len(writer.lines) != 501 || writer.lines[0].Balance.String() != "46.00" {
+		t.Fatalf("预期写出501笔交易并逐笔累计余额，实际：%d笔", len(writer.lines))
+	}
+	last := writer.lines[len(writer.lines)-1]
+	if last.TransactionID != 503 || last.Amount.String() != "-20.00" || last.Balance.String() != "525.00" {
+		t.Errorf("取款应以负数写出并扣减余额，实际：%+v", last)
+	}
+	if writer.summary == nil || summary.ClosingBalance.String() != "525.00" || summary.TotalCredits.String() != "500.00" ||
+		summary.TotalDebits.String() != "20.00" || summary.TransactionCount != 501 {
+		t.Errorf("对账单汇总不正确：%+v", summary)
+	}
+
+	cases := []struct {
+		name     string
+		userID   int
+		from, to time.Time
+		want     error
+	}{
+		{"期间颠倒", 1, to, from, service.ErrInvalidFilter},
+		{"钱包不存在", 3, from, to, service.ErrWalletNotFound},
+	}
+	for _, c := range cases {
+		if _, err := walletService.GenerateStatement(context.Background(), c.userID, "CNY", c.from, c.to, &recordingStatementWriter{}); !errors.Is(err, c.want) {
+			t.Errorf("%s：预期返回%v，实际：%v", c.name, c.want, err)
+		}
+	}
+}
+
+// 测试显式开户记录持有人信息与初始限额，重复开户、非法限额与持有人信息被拒绝
+func TestWalletService_CreateWallet(t *testing.T) {
+	var inserted []model.Wallet
+	mockRepo := &MockWalletRepository{
+		insertWallet: func(ctx context.Context, wallet model.Wallet) error {
+			for _, existing := range inserted {
+				if existing.UserID == wallet.UserID && existing.Currency == wallet.Currency {
+					return _interface.ErrWalletExists
+				}
+			}
+			inserted = append(inserted, wallet)
+			return nil
+		},
+	}
+	walletService := service.NewWalletService(mockRepo)
+	ctx := context.Background()
+
+	maxBalance := decimal.MustParse("5000")
+	wallet, err := walletService.CreateWallet(ctx, model.WalletSpec{UserID: 7, Currency: "JPY", OwnerMetadata: map[string]string{"name": "张三"},
+		Limits: model.WalletLimits{MaxBalance: &maxBalance}})
+	if err != nil || wallet.Status != model.WalletActive || wallet.Balance.String() != "0" || wallet.OwnerMetadata["name"] != "张三" {
+		t.Fatalf("开户预期返回余额为0的active钱包，实际：%+v，%v", wallet, err)
+	}
+	if limits := mockRepo.limits["7|JPY"]; limits.MaxBalance == nil || limits.MaxBalance.String() != "5000" {
+		t.Errorf("开户预期写入初始限额，实际：%+v", mockRepo.limits)
+	}
+	// 开户与写入初始限额各记录一条审计记录
+	if len(mockRepo.auditEntries) != 2 || mockRepo.auditEntries[0].Action != "wallet.create" || mockRepo.auditEntries[1].Details["max_balance"] != "5000" {
+		t.Errorf("开户预期记录wallet.create审计记录，实际：%+v", mockRepo.auditEntries)
+	}
+	if _, err := walletService.CreateWallet(ctx, model.WalletSpec{UserID: 7, Currency: "JPY"}); !errors.Is(err, service.ErrWalletExists) {
+		t.Errorf("重复开户预期返回ErrWalletExists，实际：%v", err)
+	}
+	if _, err := walletService.CreateWallet(ctx, model.WalletSpec{UserID: 7, Currency: "CNY"}); err != nil || len(mockRepo.limits) != 1 {
+		t.Errorf("不带限额开户预期成功且不写入限额，实际：%+v，%v", mockRepo.limits, err)
+	}
+
+	// 终端用户可以开户，但不能在开户时设置限额
+	user := auth.NewContext(ctx, auth.NewPrincipal("user:9", 9, auth.UserScopes, nil))
+	if _, err := walletService.CreateWallet(user, model.WalletSpec{UserID: 9, Currency: "CNY", Limits: model.WalletLimits{MaxBalance: &maxBalance}}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("终端用户开户时设置限额预期返回ErrForbidden，实际：%v", err)
+	}
+	if _, err := walletService.CreateWallet(user, model.WalletSpec{UserID: 9, Currency: "CNY"}); err != nil {
+		t.Errorf("终端用户不带限额开户预期成功，实际错误：%v", err)
+	}
+
+	negative := decimal.MustParse("-1")
+	tooPrecise := decimal.MustParse("0.5")
+	transfers := -1
+	invalid := map[string]struct {
+		spec model.WalletSpec
+		want error
+	}{
+		"币种不受支持":   {model.WalletSpec{UserID: 8, Currency: "XXX"}, service.ErrUnsupportedCurrency},
+		"限额为负数":    {model.WalletSpec{UserID: 8, Currency: "CNY", Limits: model.WalletLimits{DailyOutgoing: &negative}}, service.ErrInvalidLimits},
+		"限额精度超出":   {model.WalletSpec{UserID: 8, Currency: "JPY", Limits: model.WalletLimits{MaxSingleWithdrawal: &tooPrecise}}, service.ErrInvalidLimits},
+		"转账笔数为负":   {model.WalletSpec{UserID: 8, Currency: "CNY", Limits: model.WalletLimits{MaxTransfersPerHour: &transfers}}, service.ErrInvalidLimits},
+		"持有人信息键为空": {model.WalletSpec{UserID: 8, Currency: "CNY", OwnerMetadata: map[string]string{" ": "x"}}, service.ErrInvalidMetadata},
+	}
+	for name, c := range invalid {
+		if _, err := walletService.CreateWallet(ctx, c.spec); !errors.Is(err, c.want) {
+			t.Errorf("%s：预期返回%v，实际：%v", name, c.want, err)
+		}
+	}
+}
+
+// 测试冻结钱包后拒绝资金变动，解冻后恢复；变更状态必须填写原因
+func TestWalletService_FreezeWallet(t *testing.T) {
+	walletService, _ := newHoldTestService()
+	ctx := context.Background()
+
+	if _, err := walletService.FreezeWallet(ctx, 1, "CNY", " "); !errors.Is(err, service.ErrReasonRequired) {
+		t.Errorf("未填写原因时预期返回ErrReasonRequired，实际：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(ctx, 3, "CNY", "风控"); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("钱包不存在时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+	wallet, err := walletService.FreezeWallet(ctx, 1, "CNY", "风控")
+	if err != nil || wallet.Status != model.WalletFrozen {
+		t.Fatalf("冻结钱包预期成功，实际：%+v，%v", wallet, err)
+	}
+
+	amount := decimal.MustParse("10")
+	operations := map[string]func() error{
+		"存款": func() error { return walletErr(walletService.Deposit(ctx, 1, "CNY", amount)) },
+		"取款": func() error { return walletErr(walletService.Withdraw(ctx, 1, "CNY", amount)) },
+		"转出": func() error { return walletService.Transfer(ctx, 1, 2, "CNY", amount) },
+		"转入": func() error { return walletService.Transfer(ctx, 2, 1, "CNY", amount) },
+		"预授权": func() error {
+			_, err := walletService.Authorize(ctx, 1, "CNY", amount, 0, 0)
+			return err
+		},
+	}
+	for name, operation := range operations {
+		if err := operation(); !errors.Is(err, service.ErrWalletFrozen) {
+			t.Errorf("%s：钱包冻结时预期返回ErrWalletFrozen，实际：%v", name, err)
+		}
+	}
+
+	if wallet, err := walletService.UnfreezeWallet(ctx, 1, "CNY", "核查完毕"); err != nil || wallet.Status != model.WalletActive {
+		t.Fatalf("解冻钱包预期成功，实际：%+v，%v", wallet, err)
+	}
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", amount); err != nil {
+		t.Errorf("解冻后取款预期成功，实际错误：%v", err)
+	}
+}
+
+// 测试销户只允许余额为0的active钱包，销户后拒绝资金变动与其它状态变更
+func TestWalletService_CloseWallet(t *testing.T) {
+	walletService, _ := newHoldTestService()
+	ctx := context.Background()
+
+	if _, err := walletService.CloseWallet(ctx, 2, "CNY", ""); !errors.Is(err, service.ErrReasonRequired) {
+		t.Errorf("未填写原因时预期返回ErrReasonRequired，实际：%v", err)
+	}
+	if _, err := walletService.CloseWallet(ctx, 1, "CNY", "客户注销"); !errors.Is(err, service.ErrWalletNotEmpty) {
+		t.Errorf("余额不为0时预期返回ErrWalletNotEmpty，实际：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(ctx, 2, "CNY", "风控"); err != nil {
+		t.Fatalf("冻结钱包预期成功，实际错误：%v", err)
+	}
+	if _, err := walletService.CloseWallet(ctx, 2, "CNY", "客户注销"); !errors.Is(err, service.ErrInvalidStatusTransition) {
+		t.Errorf("冻结的钱包预期不能直接销户，实际：%v", err)
+	}
+	if _, err := walletService.UnfreezeWallet(ctx, 2, "CNY", "核查完毕"); err != nil {
+		t.Fatalf("解冻钱包预期成功，实际错误：%v", err)
+	}
+	wallet, err := walletService.CloseWallet(ctx, 2, "CNY", "客户注销")
+	if err != nil || wallet.Status != model.WalletClosed {
+		t.Fatalf("销户预期成功，实际：%+v，%v", wallet, err)
+	}
+	if wallet, err := walletService.CloseWallet(ctx, 2, "CNY", "重复提交"); err != nil || wallet.Status != model.WalletClosed {
+		t.Errorf("重复销户预期直接返回，实际：%+v，%v", wallet, err)
+	}
+
+	amount := decimal.MustParse("10")
+	operations := map[string]func() error{
+		"存款": func() error { return walletErr(walletService.Deposit(ctx, 2, "CNY", amount)) },
+		"取款": func() error { return walletErr(walletService.Withdraw(ctx, 2, "CNY", amount)) },
+		"转入": func() error { return walletService.Transfer(ctx, 1, 2, "CNY", amount) },
+		"调账": func() error {
+			_, err := walletService.Adjust(ctx, 2, "CNY", amount, "补差")
+			return err
+		},
+	}
+	for name, operation := range operations {
+		if err := operation(); !errors.Is(err, service.ErrWalletClosed) {
+			t.Errorf("%s：钱包已销户时预期返回ErrWalletClosed，实际：%v", name, err)
+		}
+	}
+	if _, err := walletService.FreezeWallet(ctx, 2, "CNY", "风控"); !errors.Is(err, service.ErrInvalidStatusTransition) {
+		t.Errorf("已销户的钱包预期不能冻结，实际：%v", err)
+	}
+	if _, err := walletService.UnfreezeWallet(ctx, 2, "CNY", "恢复"); !errors.Is(err, service.ErrInvalidStatusTransition) {
+		t.Errorf("已销户的钱包预期不能恢复，实际：%v", err)
+	}
+}
+
+// 测试人工调账通过挂账账户记账，冻结的钱包同样允许调账，扣减以可用余额为限
+func TestWalletService_Adjust(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	if _, err := walletService.Adjust(ctx, 1, "CNY", decimal.MustParse("10"), ""); !errors.Is(err, service.ErrReasonRequired) {
+		t.Errorf("未填写原因时预期返回ErrReasonRequired，实际：%v", err)
+	}
+	if _, err := walletService.Adjust(ctx, 1, "CNY", decimal.Zero, "补差"); !errors.Is(err, service.ErrInvalidAmount) {
+		t.Errorf("调账金额为0时预期返回ErrInvalidAmount，实际：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(ctx, 1, "CNY", "风控"); err != nil {
+		t.Fatalf("冻结钱包时预期无错误，实际错误：%v", err)
+	}
+
+	transaction, err := walletService.Adjust(ctx, 1, "CNY", decimal.MustParse("25.5"), "补发利息")
+	if err != nil {
+		t.Fatalf("调增时预期无错误，实际错误：%v", err)
+	}
+	if transaction.TransactionType != "adjustment_credit" || transaction.Amount.String() != "25.50" || transaction.Reason != "补发利息" {
+		t.Errorf("调增交易不正确：%+v", transaction)
+	}
+	entry := mockRepo.journalEntries[0]
+	if entry.EntryType != "adjustment" || entry.Postings[0].Account != model.AccountSystemSuspense || entry.Postings[1].Account != "wallet:1:CNY" {
+		t.Errorf("调增预期借记挂账账户、贷记钱包，实际：%+v", entry)
+	}
+
+	if _, err := walletService.Adjust(ctx, 1, "CNY", decimal.MustParse("-200"), "误入账"); !errors.Is(err, service.ErrInsufficientFunds) {
+		t.Errorf("调减超出可用余额时预期返回ErrInsufficientFunds，实际：%v", err)
+	}
+	transaction, err = walletService.Adjust(ctx, 1, "CNY", decimal.MustParse("-5.5"), "误入账")
+	if err != nil || transaction.TransactionType != "adjustment_debit" || transaction.Amount.String() != "5.50" {
+		t.Fatalf("调减预期成功，实际：%+v，%v", transaction, err)
+	}
+	if entry := mockRepo.journalEntries[1]; entry.Postings[0].Account != "wallet:1:CNY" || entry.Postings[1].Account != model.AccountSystemSuspense {
+		t.Errorf("调减预期借记钱包、贷记挂账账户，实际：%+v", entry)
+	}
+	if balance, _ := walletService.GetBalance(ctx, 1, "CNY"); balance.Ledger.String() != "120.00" {
+		t.Errorf("调账后预期余额为120.00，实际：%+v", balance)
+	}
+}
+
+// 测试服务层按调用方的角色授权，并在交易上记录发起方
+func TestWalletService_RoleAuthorization(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	support := auth.NewContext(context.Background(), auth.NewPrincipal("service:backoffice", 0, nil, []string{auth.RoleSupport}))
+	finance := auth.NewContext(context.Background(), auth.NewPrincipal("service:backoffice", 0, nil, []string{auth.RoleFinance}))
+	user1 := auth.NewContext(context.Background(), auth.NewPrincipal("", 1, nil, nil))
+
+	if _, err := walletService.FreezeWallet(support, 2, "CNY", "风控"); err != nil {
+		t.Errorf("客服冻结钱包时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Adjust(support, 1, "CNY", decimal.MustParse("10"), "补差"); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("客服调账时预期返回ErrForbidden，实际：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(finance, 2, "CNY", "风控"); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("财务冻结钱包时预期返回ErrForbidden，实际：%v", err)
+	}
+	if _, err := walletService.VerifyLedger(user1); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("终端用户核对账本时预期返回ErrForbidden，实际：%v", err)
+	}
+	if _, err := walletService.Withdraw(user1, 2, "CNY", decimal.MustParse("1")); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("终端用户从他人的钱包取款时预期返回ErrForbidden，实际：%v", err)
+	}
+
+	transaction, err := walletService.Adjust(finance, 1, "CNY", decimal.MustParse("10"), "补差")
+	if err != nil {
+		t.Fatalf("财务调账时预期无错误，实际错误：%v", err)
+	}
+	if transaction.Actor != "service:backoffice" {
+		t.Errorf("调账交易预期记录发起方service:backoffice，实际：%q", transaction.Actor)
+	}
+	if _, err := walletService.Withdraw(user1, 1, "CNY", decimal.MustParse("1")); err != nil {
+		t.Fatalf("终端用户从自己的钱包取款时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Deposit(context.Background(), 1, "CNY", decimal.MustParse("1")); err != nil {
+		t.Fatalf("进程内存款时预期无错误，实际错误：%v", err)
+	}
+	actors := make([]string, 0, len(mockRepo.transactions))
+	for _, tx := range mockRepo.transactions {
+		actors = append(actors, tx.Actor)
+	}
+	if len(actors) != 3 || actors[1] != "user:1" || actors[2] != "system" {
+		t.Errorf("交易的发起方预期依次为service:backoffice、user:1、system，实际：%v", actors)
+	}
+}
+
+// walletErr 丢弃存取款返回的钱包，只保留错误
+func walletErr(_ *model.Wallet, err error) error {
+	return err
+}
+
+// holdErr 丢弃预授权的返回值，只保留错误
+func holdErr(_ *model.Hold, err error) error {
+	return err
+}
+
+// assertLimitExceeded 检查err为指定限额的LimitExceededError且剩余额度为remaining
+func assertLimitExceeded(t *testing.T, err error, limit, remaining string) {
+	t.Helper()
+	var exceeded *service.LimitExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, service.ErrLimitExceeded) {
+		t.Errorf("预期触发%s限额，实际：%v", limit, err)
+		return
+	}
+	if exceeded.Limit != limit || exceeded.Remaining.String() != remaining {
+		t.Errorf("预期触发%s限额且剩余%s，实际：%s，剩余%s", limit, remaining, exceeded.Limit, exceeded.Remaining)
+	}
+}
+
+// 测试未配置max_balance时余额仍不能超过可以读出的范围，存款、转账与调账都会被拒绝
+func TestWalletService_BalanceCeiling(t *testing.T) {
+	walletService, _ := newHoldTestService()
+	ctx := context.Background()
+
+	for _, amount := range []string{"900000000000000", "99999999999950"} {
+		if _, err := walletService.Deposit(ctx, 2, "CNY", decimal.MustParse(amount)); err != nil {
+			t.Fatalf("余额未超出上限的存款预期无错误，实际错误：%v", err)
+		}
+	}
+	amount := decimal.MustParse("100")
+	assertLimitExceeded(t, walletErr(walletService.Deposit(ctx, 2, "CNY", amount)), "max_balance", "49.999")
+	assertLimitExceeded(t, walletService.Transfer(ctx, 1, 2, "CNY", amount), "max_balance", "49.999")
+	if _, err := walletService.Adjust(ctx, 2, "CNY", amount, "补记"); !errors.As(err, new(*service.LimitExceededError)) {
+		t.Errorf("调账使余额超出上限预期返回LimitExceededError，实际：%v", err)
+	}
+	if balance, _ := walletService.GetBalance(ctx, 2, "CNY"); balance.Ledger.String() != "999999999999950.00" {
+		t.Errorf("被拒绝的入账不应改变余额，实际：%s", balance.Ledger)
+	}
+}
+
+// 测试取款、转账与存款在变更前检查默认限额与钱包单独设置的限额，被拒绝的操作不改变余额
+func TestWalletService_Limits(t *testing.T) {
+	single, daily := decimal.MustParse("50"), decimal.MustParse("80")
+	walletService, mockRepo := newHoldTestService(service.WithDefaultLimits(map[string]model.WalletLimits{
+		service.DefaultLimitsFallback: {MaxSingleWithdrawal: &single, DailyOutgoing: &single},
+		"CNY":                         {DailyOutgoing: &daily},
+	}))
+	ctx := context.Background()
+
+	assertLimitExceeded(t, walletErr(walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("60"))), "max_single_withdrawal", "50")
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("50")); err != nil {
+		t.Fatalf("未超出限额的取款预期无错误，实际错误：%v", err)
+	}
+	// 币种的默认限额优先于通用默认限额，当日已出账50.00
+	assertLimitExceeded(t, walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("40")), "daily_outgoing", "30.00")
+
+	transfers, raised := 1, decimal.MustParse("200")
+	if _, err := walletService.SetWalletLimits(ctx, 1, "CNY", model.WalletLimits{DailyOutgoing: &raised, MaxTransfersPerHour: &transfers}); err != nil {
+		t.Fatalf("设置钱包限额时预期无错误，实际错误：%v", err)
+	}
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("20")); err != nil {
+		t.Fatalf("提高限额后转账预期无错误，实际错误：%v", err)
+	}
+	assertLimitExceeded(t, walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("5")), "max_transfers_per_hour", "0")
+
+	maxBalance := decimal.MustParse("30")
+	if _, err := walletService.SetWalletLimits(ctx, 2, "CNY", model.WalletLimits{MaxBalance: &maxBalance}); err != nil {
+		t.Fatalf("设置余额上限时预期无错误，实际错误：%v", err)
+	}
+	assertLimitExceeded(t, walletErr(walletService.Deposit(ctx, 2, "CNY", decimal.MustParse("20"))), "max_balance", "10.00")
+	if balance, _ := walletService.GetBalance(ctx, 2, "CNY"); balance.Ledger.String() != "20.00" {
+		t.Errorf("被拒绝的存款不应改变余额，预期20.00，实际：%s", balance.Ledger)
+	}
+
+	usage, err := walletService.GetLimitUsage(ctx, 1, "CNY")
+	if err != nil {
+		t.Fatalf("查询限额使用情况时预期无错误，实际错误：%v", err)
+	}
+	if usage.Limits.MaxSingleWithdrawal.String() != "50" || usage.Limits.DailyOutgoing.String() != "200.00" ||
+		usage.Usage.DailyOutgoing.String() != "70.00" || usage.Usage.TransfersLastHour != 1 ||
+		usage.Remaining.DailyOutgoing.String() != "130.00" || *usage.Remaining.MaxTransfersPerHour != 0 || usage.Remaining.MaxBalance != nil {
+		t.Errorf("限额使用情况不正确：%+v", usage)
+	}
+	if len(mockRepo.transactions) != 3 {
+		t.Errorf("被拒绝的操作不应写入交易，预期3条，实际：%d", len(mockRepo.transactions))
+	}
+
+	negative := decimal.MustParse("-1")
+	if _, err := walletService.SetWalletLimits(ctx, 1, "CNY", model.WalletLimits{MaxBalance: &negative}); !errors.Is(err, service.ErrInvalidLimits) {
+		t.Errorf("设置负数限额时预期返回ErrInvalidLimits，实际：%v", err)
+	}
+	if _, err := walletService.SetWalletLimits(ctx, 3, "CNY", model.WalletLimits{}); !errors.Is(err, service.ErrWalletNotFound) {
+		t.Errorf("为不存在的钱包设置限额时预期返回ErrWalletNotFound，实际：%v", err)
+	}
+	support := auth.NewContext(ctx, auth.NewPrincipal("service:backoffice", 0, nil, []string{auth.RoleSupport}))
+	if _, err := walletService.SetWalletLimits(support, 1, "CNY", model.WalletLimits{}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("客服设置限额时预期返回ErrForbidden，实际：%v", err)
+	}
+}
+
+// 测试手续费与本金一起计入出账限额
+func TestWalletService_FeesCountTowardLimits(t *testing.T) {
+	daily := decimal.MustParse("50")
+	walletService, _ := newHoldTestService(
+		service.WithDefaultLimits(map[string]model.WalletLimits{"CNY": {DailyOutgoing: &daily}}),
+		service.WithFeeSchedule(model.FeeSchedule{
+			model.FeeOperationWithdrawal: {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}},
+			model.FeeOperationTransfer:   {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}},
+		}),
+	)
+	ctx := context.Background()
+
+	// 本金50加手续费1超出当日额度
+	assertLimitExceeded(t, walletErr(walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("50"))), "daily_outgoing", "50")
+	if _, err := walletService.Withdraw(ctx, 1, "CNY", decimal.MustParse("48")); err != nil {
+		t.Fatalf("本金与手续费合计未超出限额时预期无错误，实际错误：%v", err)
+	}
+	// 已用额度为取款48加手续费1，剩余1.00不足以支付转账本金1与手续费1
+	assertLimitExceeded(t, walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("1")), "daily_outgoing", "1.00")
+
+	usage, err := walletService.GetLimitUsage(ctx, 1, "CNY")
+	if err != nil || usage.Usage.DailyOutgoing.String() != "49.00" {
+		t.Errorf("已用额度应包含手续费，预期49.00，实际：%+v，%v", usage, err)
+	}
+}
+
+// 测试预授权在授权时按全额请款与手续费检查出账限额，指定收款方时还检查每小时转账笔数，超出时不冻结资金
+func TestWalletService_AuthorizeLimits(t *testing.T) {
+	single, transfers := decimal.MustParse("50"), 1
+	walletService, _ := newHoldTestService(
+		service.WithDefaultLimits(map[string]model.WalletLimits{"CNY": {MaxSingleWithdrawal: &single, MaxTransfersPerHour: &transfers}}),
+		service.WithFeeSchedule(model.FeeSchedule{model.FeeOperationTransfer: {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}}}),
+	)
+	ctx := context.Background()
+
+	assertLimitExceeded(t, holdErr(walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("60"), 0, 0)), "max_single_withdrawal", "50")
+	// 本金50加转账手续费1超出单笔限额
+	assertLimitExceeded(t, holdErr(walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("50"), 2, 0)), "max_single_withdrawal", "50")
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("49"), 2, 0); err != nil {
+		t.Fatalf("未超出限额的预授权预期无错误，实际错误：%v", err)
+	}
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("1")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+	assertLimitExceeded(t, holdErr(walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("10"), 2, 0)), "max_transfers_per_hour", "0")
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("10"), 0, 0); err != nil {
+		t.Fatalf("未指定收款方的预授权不检查转账笔数，实际错误：%v", err)
+	}
+
+	balance, _ := walletService.GetBalance(ctx, 1, "CNY")
+	if balance.Ledger.String() != "98.00" || balance.Held.String() != "59.00" {
+		t.Errorf("被拒绝的预授权不应冻结资金，预期余额98.00、冻结59.00，实际：%+v", balance)
+	}
+}
+
+// 测试每次状态变更都在同一事务中追加审计记录，记录发起方、请求元数据与变更前后的余额
+func TestWalletService_AuditLog(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := auth.NewContext(context.Background(), auth.NewPrincipal("service:backoffice", 0, nil, []string{auth.RoleFinance, auth.RoleSupport}))
+	ctx = audit.NewContext(ctx, audit.Request{ID: "req-1", ClientIP: "203.0.113.7"})
+
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("30")); !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("没有转账权限时预期返回ErrForbidden，实际：%v", err)
+	}
+	if len(mockRepo.auditEntries) != 0 {
+		t.Fatalf("被拒绝的操作不应产生审计记录，实际：%+v", mockRepo.auditEntries)
+	}
+	if _, err := walletService.Adjust(ctx, 1, "CNY", decimal.MustParse("-20"), "误入账"); err != nil {
+		t.Fatalf("调账时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(ctx, 2, "CNY", "风控"); err != nil {
+		t.Fatalf("冻结钱包时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Withdraw(context.Background(), 1, "CNY", decimal.MustParse("5")); err != nil {
+		t.Fatalf("进程内取款时预期无错误，实际错误：%v", err)
+	}
+
+	entries := mockRepo.auditEntries
+	if len(entries) != 3 {
+		t.Fatalf("预期3条审计记录（调账、冻结、取款），实际：%+v", entries)
+	}
+	adjust := entries[0]
+	if adjust.Action != "wallet.adjust" || adjust.Actor != "service:backoffice" || adjust.RequestID != "req-1" || adjust.ClientIP != "203.0.113.7" ||
+		adjust.UserID != 1 || adjust.BalanceBefore.String() != "100.00" || adjust.BalanceAfter.String() != "80.00" ||
+		adjust.Details["reason"] != "误入账" || adjust.Details["amount"] != "-20.00" {
+		t.Errorf("调账的审计记录不正确：%+v", adjust)
+	}
+	freeze := entries[1]
+	if freeze.Action != "wallet.freeze" || freeze.Details["status_from"] != "active" || freeze.Details["status_to"] != "frozen" ||
+		freeze.BalanceBefore.String() != "0.00" || freeze.BalanceAfter.String() != "0.00" {
+		t.Errorf("冻结的审计记录不正确：%+v", freeze)
+	}
+	if withdraw := entries[2]; withdraw.Action != "withdraw" || withdraw.Actor != "system" || withdraw.RequestID != "" ||
+		withdraw.BalanceBefore.String() != "80.00" || withdraw.BalanceAfter.String() != "75.00" {
+		t.Errorf("进程内取款的审计记录不正确：%+v", withdraw)
+	}
+
+	page, err := walletService.ListAuditLog(ctx, model.AuditFilter{Limit: 2})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("没有审计权限时预期返回ErrForbidden，实际：%+v，%v", page, err)
+	}
+	auditor := auth.NewContext(context.Background(), auth.NewPrincipal("service:audit", 0, nil, []string{auth.RoleAuditor}))
+	page, err = walletService.ListAuditLog(auditor, model.AuditFilter{Limit: 2})
+	if err != nil || len(page.Entries) != 2 || page.Entries[0].ID != 3 || page.NextCursor == "" {
+		t.Fatalf("审计日志第一页预期返回2条并带游标，实际：%+v，%v", page, err)
+	}
+	beforeID, err := model.DecodeAuditCursor(page.NextCursor)
+	if err != nil {
+		t.Fatalf("解析游标时预期无错误，实际错误：%v", err)
+	}
+	page, err = walletService.ListAuditLog(auditor, model.AuditFilter{Limit: 2, BeforeID: beforeID})
+	if err != nil || len(page.Entries) != 1 || page.Entries[0].ID != 1 || page.NextCursor != "" {
+		t.Errorf("审计日志最后一页预期返回1条且不带游标，实际：%+v，%v", page, err)
+	}
+}
+
+// 测试预授权状态变化与过期清理的审计记录，请款回写凭证ID不重复记录
+func TestWalletService_HoldAuditLog(t *testing.T) {
+	walletService, mockRepo := newHoldTestService()
+	ctx := context.Background()
+
+	hold, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("40"), 0, time.Hour)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Capture(ctx, hold.ID, decimal.MustParse("10")); err != nil {
+		t.Fatalf("请款时预期无错误，实际错误：%v", err)
+	}
+	expiring, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("5"), 0, time.Hour)
+	if err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+	mockRepo.holds[expiring.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := walletService.ExpireHolds(ctx); err != nil {
+		t.Fatalf("清理过期预授权时预期无错误，实际错误：%v", err)
+	}
+
+	var actions []string
+	for _, entry := range mockRepo.auditEntries {
+		actions = append(actions, entry.Action+":"+entry.Details["status_to"]+entry.Details["expired"])
+	}
+	expected := []string{"hold.authorize:authorized", "hold.capture:captured", "hold.capture:", "hold.authorize:authorized", "hold.expire:1"}
+	if fmt.Sprint(actions) != fmt.Sprint(expected) {
+		t.Errorf("预授权的审计记录预期为%v，实际：%v", expected, actions)
+	}
+}
+
+// rechainTransactions 把记录的交易时间提前d并按新内容重新计算哈希链，模拟已写入一段时间的交易
+func rechainTransactions(mockRepo *MockWalletRepository, d time.Duration) {
+	heads := make(map[string]string)
+	for i := range mockRepo.transactions {
+		transaction := &mockRepo.transactions[i]
+		key := fmt.Sprintf("%d|%s", transaction.UserID, transaction.Currency)
+		transaction.TransactionTime = transaction.TransactionTime.Add(-d)
+		transaction.PrevHash = heads[key]
+		transaction.Hash = model.TransactionHash(transaction.PrevHash, *transaction)
+		heads[key] = transaction.Hash
+	}
+}
+
+// 测试交易按钱包串成哈希链，检查点签名后篡改、删除中间或末尾的交易都能被发现
+func TestWalletService_HashChain(t *testing.T) {
+	_, mockRepo := newHoldTestService()
+	_, key, _ := ed25519.GenerateKey(nil)
+	walletService := service.NewWalletService(mockRepo, service.WithCheckpointKey(key))
+	ctx := context.Background()
+
+	for _, deposit := range []struct {
+		userID int
+		amount string
+	}{{1, "10"}, {2, "20"}, {1, "30"}, {1, "40"}} {
+		if _, err := walletService.Deposit(ctx, deposit.userID, "CNY", decimal.MustParse(deposit.amount)); err != nil {
+			t.Fatalf("存款时预期无错误，实际错误：%v", err)
+		}
+	}
+	transactions := mockRepo.transactions
+	if transactions[0].PrevHash != "" || transactions[1].PrevHash != "" || transactions[2].PrevHash != transactions[0].Hash || transactions[3].PrevHash != transactions[2].Hash {
+		t.Fatalf("交易应链接到同一钱包的上一笔交易，实际：%+v", transactions)
+	}
+
+	if _, err := walletService.CreateCheckpoint(ctx); err != nil {
+		t.Fatalf("生成检查点时预期无错误，实际错误：%v", err)
+	}
+	if len(mockRepo.checkpoints) != 0 {
+		t.Fatalf("刚写入的交易不应进入检查点，实际：%+v", mockRepo.checkpoints)
+	}
+	rechainTransactions(mockRepo, time.Hour)
+	checkpoint, err := walletService.CreateCheckpoint(ctx)
+	if err != nil || checkpoint == nil || checkpoint.LastTransactionID != 4 || !checkpoint.VerifySignature(key.Public().(ed25519.PublicKey)) {
+		t.Fatalf("预期生成覆盖到交易4的签名检查点，实际：%+v，%v", checkpoint, err)
+	}
+	if again, err := walletService.CreateCheckpoint(ctx); again != nil || err != nil {
+		t.Errorf("没有新交易时预期不生成检查点，实际：%+v，%v", again, err)
+	}
+
+	report, err := walletService.VerifyHashChain(ctx)
+	if err != nil || !report.Intact() || report.TransactionsChecked != 4 || report.CheckpointsVerified != 1 {
+		t.Fatalf("未篡改的哈希链预期校验通过，实际：%+v，%v", report, err)
+	}
+
+	original := append([]model.Transaction(nil), mockRepo.transactions...)
+	cases := []struct {
+		name          string
+		tamper        func() []model.Transaction
+		transactionID int
+		checkpointID  int
+	}{
+		{"修改金额", func() []model.Transaction {
+			tampered := append([]model.Transaction(nil), original...)
+			tampered[2].Amount = decimal.MustParse("3000")
+			return tampered
+		}, 3, 0},
+		{"删除中间的交易", func() []model.Transaction {
+			return append(append([]model.Transaction(nil), original[:2]...), original[3])
+		}, 4, 0},
+		{"删除末尾的交易", func() []model.Transaction {
+			return append([]model.Transaction(nil), original[:3]...)
+		}, 4, 1},
+	}
+	for _, c := range cases {
+		mockRepo.transactions = c.tamper()
+		report, err := walletService.VerifyHashChain(ctx)
+		if err != nil || report.Intact() || report.Break.TransactionID != c.transactionID || report.Break.CheckpointID != c.checkpointID {
+			t.Errorf("%s：预期在交易%d处断链，实际：%+v，%v", c.name, c.transactionID, report.Break, err)
+		}
+	}
+	mockRepo.transactions = original
+
+	// 用另一把密钥校验时签名不通过；未配置密钥时只核对摘要且不能生成检查点
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	report, err = service.NewWalletService(mockRepo, service.WithCheckpointKey(otherKey)).VerifyHashChain(ctx)
+	if err != nil || report.Intact() || report.Break.CheckpointID != 1 {
+		t.Errorf("密钥不符时预期检查点校验失败，实际：%+v，%v", report, err)
+	}
+	unsigned := service.NewWalletService(mockRepo)
+	if report, err := unsigned.VerifyHashChain(ctx); err != nil || !report.Intact() {
+		t.Errorf("未配置密钥时预期只核对摘要并通过，实际：%+v，%v", report, err)
+	}
+	if _, err := unsigned.CreateCheckpoint(ctx); !errors.Is(err, service.ErrCheckpointKeyMissing) {
+		t.Errorf("未配置密钥时预期返回ErrCheckpointKeyMissing，实际：%v", err)
+	}
+}
+
+// 测试资金变动与状态变更在同一事务中写入领域事件，事件写入失败时操作失败
+func TestWalletService_DomainEvents(t *testing.T) {
+	walletService, mockRepo := newHoldTestService(service.WithFeeSchedule(model.FeeSchedule{
+		model.FeeOperationTransfer: {model.FeeAnyCurrency: {Flat: decimal.MustParse("1")}},
+	}))
+	ctx := auth.NewContext(context.Background(), auth.NewPrincipal("service:backoffice", 0, []string{auth.ScopeDeposit, auth.ScopeWithdraw, auth.ScopeTransfer, auth.ScopeHolds}, []string{auth.RoleSupport}))
+
+	if _, err := walletService.Deposit(ctx, 1, "CNY", decimal.MustParse("50")); err != nil {
+		t.Fatalf("存款时预期无错误，实际错误：%v", err)
+	}
+	if err := walletService.Transfer(ctx, 1, 2, "CNY", decimal.MustParse("30")); err != nil {
+		t.Fatalf("转账时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Withdraw(ctx, 2, "CNY", decimal.MustParse("10")); err != nil {
+		t.Fatalf("取款时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.FreezeWallet(ctx, 2, "CNY", "风控"); err != nil {
+		t.Fatalf("冻结钱包时预期无错误，实际错误：%v", err)
+	}
+	if _, err := walletService.Authorize(ctx, 1, "CNY", decimal.MustParse("5"), 0, 0); err != nil {
+		t.Fatalf("预授权时预期无错误，实际错误：%v", err)
+	}
+
+	events := mockRepo.events
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	want := []string{model.EventFundsDeposited, model.EventTransferCompleted, model.EventFundsWithdrawn, model.EventWalletStatusChanged}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("预期事件依次为%v，预授权不产生事件，实际：%v", want, types)
+	}
+	for _, event := range events {
+		if event.Actor != "service:backoffice" || event.Currency != "CNY" || event.OccurredAt.IsZero() {
+			t.Errorf("事件应记录调用方、币种与发生时间，实际：%+v", event)
+		}
+	}
+	if string(events[0].Payload) != `{"entry_id":1,"amount":"50.00","balance_after":"150.00"}` || events[0].UserID != 1 {
+		t.Errorf("存款事件内容不正确，实际：%s", events[0].Payload)
+	}
+	if string(events[1].Payload) != `{"entry_id":2,"from_user_id":1,"to_user_id":2,"amount":"30.00","fee":"1.00"}` || events[1].UserID != 1 || events[1].CounterpartyUserID != 2 {
+		t.Errorf("转账事件应记录双方与手续费，实际：%s", events[1].Payload)
+	}
+	if string(events[2].Payload) != `{"entry_id":4,"amount":"10.00","balance_after":"20.00"}` || events[2].UserID != 2 {
+		t.Errorf("取款事件内容不正确，实际：%s", events[2].Payload)
+	}
+	if string(events[3].Payload) != `{"from":"active","to":"frozen","reason":"风控"}` {
+		t.Errorf("状态变更事件内容不正确，实际：%s", events[3].Payload)
+	}
+
+	mockRepo.outboxErr = errors.New("outbox unavailable")
+	if _, err := walletService.Deposit(ctx, 1, "CNY", decimal.MustParse("1")); err == nil || !errors.Is(err, mockRepo.outboxErr) {
+		t.Errorf("事件写入失败时存款预期失败，实际：%v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet-service/internal/model"
+	"wallet-service/internal/service"
+	"wallet-service/internal/statement"
+	"wallet-service/pkg/decimal"
+)
+
+// writeSampleStatement 以w写出一份包含n笔交易的对账单
+func writeSampleStatement(t *testing.T, w service.StatementWriter, n int) {
+	t.Helper()
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	header := model.StatementHeader{UserID: 1, Currency: "CNY", From: from, To: from.AddDate(0, 1, 0), OpeningBalance: decimal.MustParse("100.00"), GeneratedAt: from}
+	if err := w.WriteHeader(header); err != nil {
+		t.Fatalf("写出抬头时预期无错误，实际错误：%v", err)
+	}
+	balance := header.OpeningBalance
+	for i := 1; i <= n; i++ {
+		line := model.StatementLine{TransactionID: i, TransactionTime: from.Add(time.Duration(i) * time.Hour), TransactionType: "deposit", Amount: decimal.MustParse("1.00")}
+		if i == n {
+			line.TransactionType, line.Amount, line.ReversalOf, line.Reason = "deposit_reversal", decimal.MustParse("-1.00"), 1, "误存 (重复)"
+		}
+		balance = balance.Add(line.Amount)
+		line.Balance = balance
+		if err := w.WriteLine(line); err != nil {
+			t.Fatalf("写出交易时预期无错误，实际错误：%v", err)
+		}
+	}
+	summary := model.StatementSummary{OpeningBalance: header.OpeningBalance, ClosingBalance: balance, TotalCredits: decimal.MustParse(strconv.Itoa(n - 1)), TotalDebits: decimal.MustParse("1"), TransactionCount: n}
+	if err := w.WriteFooter(summary); err != nil {
+		t.Fatalf("写出汇总时预期无错误，实际错误：%v", err)
+	}
+}
+
+// 测试CSV对账单：表头、期初行、交易行与期末行
+func TestStatement_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := statement.NewWriter(statement.FormatCSV, &buf)
+	if err != nil {
+		t.Fatalf("创建CSV写出器时预期无错误，实际错误：%v", err)
+	}
+	writeSampleStatement(t, writer, 2)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 5 {
+		t.Fatalf("预期5行CSV记录，实际：%d行，%v", len(records), err)
+	}
+	if strings.Join(records[0], ",") != "time,transaction_id,type,amount,currency,balance,reversal_of,reason" {
+		t.Errorf("表头不正确：%v", records[0])
+	}
+	if records[1][2] != "opening_balance" || records[1][5] != "100.00" || records[4][2] != "closing_balance" || records[4][5] != "100.00" {
+		t.Errorf("期初、期末行不正确：%v，%v", records[1], records[4])
+	}
+	if strings.Join(records[3], ",") != "2024-05-01T02:00:00Z,2,deposit_reversal,-1.00,CNY,100.00,1,误存 (重复)" {
+		t.Errorf("冲正交易行不正确：%v", records[3])
+	}
+}
+
+// 测试JSON Lines对账单：每行一个带record类型的对象
+func TestStatement_JSONL(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := statement.NewWriter(statement.FormatJSONL, &buf)
+	if err != nil {
+		t.Fatalf("创建JSONL写出器时预期无错误，实际错误：%v", err)
+	}
+	writeSampleStatement(t, writer, 2)
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("每行预期为合法JSON，实际：%s，%v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 4 || records[0]["record"] != "header" || records[3]["record"] != "summary" {
+		t.Fatalf("预期抬头、2笔交易、汇总共4行，实际：%v", records)
+	}
+	if records[0]["opening_balance"] != "100.00" || records[2]["record"] != "transaction" || records[2]["amount"] != "-1.00" || records[2]["reversal_of"] != float64(1) {
+		t.Errorf("JSONL内容不正确：%v", records)
+	}
+}
+
+// 测试PDF对账单：文件结构完整、交叉引用偏移正确、超过一页时分页，内存中只保留一页
+func TestStatement_PDF(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := statement.NewWriter(statement.FormatPDF, &buf)
+	if err != nil {
+		t.Fatalf("创建PDF写出器时预期无错误，实际错误：%v", err)
+	}
+	writeSampleStatement(t, writer, 150)
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("PDF文件头或文件尾不正确")
+	}
+	if !strings.Contains(pdf, "/Count 3 >>") {
+		t.Errorf("150笔交易预期分为3页")
+	}
+	if !strings.Contains(pdf, `reversal of 1: ?? \(??\)`) {
+		t.Errorf("非ASCII字符应替换为?，括号应转义")
+	}
+
+	// 交叉引用表中每个对象的偏移都应指向"n 0 obj"
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if startxref == nil {
+		t.Fatalf("缺少startxref")
+	}
+	offset, _ := strconv.Atoi(startxref[1])
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[offset:], -1)
+	if len(entries) == 0 {
+		t.Fatalf("交叉引用表为空")
+	}
+	for i, entry := range entries {
+		position, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[position:], want) {
+			t.Errorf("对象%d的偏移%d不正确", i+1, position)
+		}
+	}
+}
+
+// 测试不支持的格式
+func TestStatement_UnsupportedFormat(t *testing.T) {
+	if _, err := statement.NewWriter("xlsx", &bytes.Buffer{}); !errors.Is(err, statement.ErrUnsupportedFormat) {
+		t.Errorf("预期返回ErrUnsupportedFormat，实际：%v", err)
+	}
+	if statement.ContentType(statement.FormatPDF) != "application/pdf" || statement.ContentType("xlsx") != "" {
+		t.Errorf("Content-Type映射不正确")
+	}
+}